@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"auth_service/services"
+	"crypto/subtle"
 	"encoding/json"
 	"net/http"
+	"strings"
 )
 
 // AuthHandler представляет хендлер для аутентификации
@@ -63,13 +65,13 @@ func (handler *AuthHandler) RegisterAdminHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
-	claims, err := handler.JWTService.VerifyToken(token)
+	_, _, email, err := handler.JWTService.VerifyToken(token)
 	if err != nil {
 		http.Error(w, "Invalid token", http.StatusUnauthorized)
 		return
 	}
 
-	superAdmin, err := handler.AuthService.UserRepository.FindByEmail(claims.Email)
+	superAdmin, err := handler.AuthService.UserRepository.FindByEmail(email)
 	if err != nil || superAdmin == nil {
 		http.Error(w, "Super administrator not found", http.StatusUnauthorized)
 		return
@@ -140,13 +142,13 @@ func (handler *AuthHandler) UpdateUserAccessAndRatingHandler(w http.ResponseWrit
 		return
 	}
 
-	claims, err := handler.JWTService.VerifyToken(token)
+	_, _, email, err := handler.JWTService.VerifyToken(token)
 	if err != nil {
 		http.Error(w, "Invalid token", http.StatusUnauthorized)
 		return
 	}
 
-	admin, err := handler.AuthService.UserRepository.FindByEmail(claims.Email)
+	admin, err := handler.AuthService.UserRepository.FindByEmail(email)
 	if err != nil || admin == nil {
 		http.Error(w, "Administrator not found", http.StatusUnauthorized)
 		return
@@ -197,3 +199,46 @@ func (handler *AuthHandler) VerifyTokenHandler(w http.ResponseWriter, r *http.Re
 
 	json.NewEncoder(w).Encode(response)
 }
+
+// LookupUserHandler находит получателя платежа по email или username.
+// Возвращает только идентификатор и username пользователя
+func (handler *AuthHandler) LookupUserHandler(w http.ResponseWriter, r *http.Request) {
+	identifier := strings.TrimSpace(r.URL.Query().Get("identifier"))
+	if identifier == "" {
+		http.Error(w, "identifier parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := handler.AuthService.FindUser(identifier)
+	if err != nil {
+		http.Error(w, "Failed to find user", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	response := struct {
+		UserID   string `json:"user_id"`
+		Username string `json:"username"`
+	}{
+		UserID:   user.ID,
+		Username: user.Username,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ServiceOnly пропускает только запросы других сервисов с токеном из Authorization
+func ServiceOnly(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
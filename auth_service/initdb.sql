@@ -0,0 +1,23 @@
+CREATE TABLE users (
+                       id VARCHAR(25) PRIMARY KEY,
+                       email VARCHAR(255) NOT NULL UNIQUE,
+                       username VARCHAR(255) NOT NULL UNIQUE,
+                       password VARCHAR(255) NOT NULL,
+                       access_level INT NOT NULL DEFAULT 1,
+                       rating_level INT NOT NULL DEFAULT 1
+);
+
+-- Outbox событий для других сервисов; событие записывается в одной
+-- транзакции с изменением и доставляется, пока получатель его не примет
+CREATE TABLE user_events (
+                             id BIGSERIAL PRIMARY KEY,
+                             type VARCHAR(50) NOT NULL,
+                             payload JSONB NOT NULL,
+                             attempts INT NOT NULL DEFAULT 0,
+                             last_error TEXT NOT NULL DEFAULT '',
+                             next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                             created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                             published_at TIMESTAMP
+);
+
+CREATE INDEX user_events_pending_idx ON user_events (next_attempt_at) WHERE published_at IS NULL;
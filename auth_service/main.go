@@ -1 +1,57 @@
-package auth_service
+package main
+
+import (
+	"auth_service/handlers"
+	"auth_service/repositories"
+	"auth_service/services"
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	// Подключение к базе данных
+	connStr := "user=username dbname=authdb sslmode=disable"
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	// Инициализация репозиториев
+	userRepo := repositories.NewUserRepository(db)
+	jwtRepo := repositories.NewJWTRepository(db, []byte(os.Getenv("JWT_SECRET")))
+	eventRepo := repositories.NewEventRepository(db)
+
+	// Инициализация сервисов
+	authService := services.NewAuthService(userRepo)
+	jwtService := services.NewJWTService(jwtRepo)
+
+	// События о новых пользователях доставляются в сервис кошельков
+	walletURL := os.Getenv("WALLET_SERVICE_URL")
+	if walletURL == "" {
+		walletURL = "http://localhost:8080"
+	}
+	publisher := services.NewEventPublisher(eventRepo, walletURL, os.Getenv("WALLET_EVENTS_TOKEN"))
+	go publisher.Run(context.Background(), 5*time.Second)
+
+	// Инициализация хендлеров
+	authHandler := handlers.NewAuthHandler(authService, jwtService)
+
+	http.HandleFunc("POST /register", authHandler.RegisterHandler)
+	http.HandleFunc("POST /register_admin", authHandler.RegisterAdminHandler)
+	http.HandleFunc("POST /login", authHandler.AuthenticateHandler)
+	http.HandleFunc("POST /update_access", authHandler.UpdateUserAccessAndRatingHandler)
+	http.HandleFunc("POST /verify_token", authHandler.VerifyTokenHandler)
+
+	// Поиск пользователей доступен только другим сервисам
+	http.HandleFunc("GET /users/lookup", handlers.ServiceOnly(os.Getenv("SERVICE_TOKEN"), authHandler.LookupUserHandler))
+
+	log.Println("Starting server on :8081")
+	log.Fatal(http.ListenAndServe(":8081", nil))
+}
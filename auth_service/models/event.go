@@ -0,0 +1,29 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Типы событий, которые сервис публикует для других сервисов
+const (
+	EventUserRegistered = "user_registered"
+)
+
+// Event представляет событие из outbox, ожидающее доставки
+type Event struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// UserRegistered представляет событие о регистрации пользователя
+type UserRegistered struct {
+	EventID      int64     `json:"event_id"`
+	UserID       string    `json:"user_id"`
+	Username     string    `json:"username"`
+	Email        string    `json:"email"`
+	RegisteredAt time.Time `json:"registered_at"`
+}
@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"auth_service/models"
+	"database/sql"
+	"encoding/json"
+)
+
+// Максимальная пауза между попытками доставки события, в секундах
+const maxEventRetryDelay = 3600
+
+// EventRepository представляет outbox событий. События записываются в той же
+// транзакции, что и изменение, о котором они сообщают, и доставляются позже
+type EventRepository struct {
+	DB *sql.DB
+}
+
+// NewEventRepository создает новый экземпляр репозитория событий
+func NewEventRepository(db *sql.DB) *EventRepository {
+	return &EventRepository{DB: db}
+}
+
+// Pending возвращает недоставленные события, время повторной попытки которых наступило
+func (repo *EventRepository) Pending(limit int) ([]*models.Event, error) {
+	rows, err := repo.DB.Query(`
+		SELECT id, type, payload, attempts, created_at FROM user_events
+		WHERE published_at IS NULL AND next_attempt_at <= NOW()
+		ORDER BY id
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.Event
+	for rows.Next() {
+		var event models.Event
+		if err := rows.Scan(&event.ID, &event.Type, &event.Payload, &event.Attempts, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+// MarkPublished отмечает событие доставленным
+func (repo *EventRepository) MarkPublished(id int64) error {
+	_, err := repo.DB.Exec("UPDATE user_events SET published_at = NOW(), last_error = '' WHERE id = $1", id)
+	return err
+}
+
+// MarkFailed откладывает следующую попытку доставки, пауза растет с числом попыток
+func (repo *EventRepository) MarkFailed(id int64, reason string) error {
+	_, err := repo.DB.Exec(`
+		UPDATE user_events
+		SET attempts = attempts + 1, last_error = $2,
+			next_attempt_at = NOW() + LEAST(POWER(attempts + 1, 2), $3) * INTERVAL '1 second'
+		WHERE id = $1`, id, reason, maxEventRetryDelay)
+	return err
+}
+
+// insertEvent записывает событие в outbox в рамках транзакции
+func insertEvent(tx *sql.Tx, eventType string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO user_events (type, payload) VALUES ($1, $2)", eventType, body)
+	return err
+}
@@ -55,12 +55,30 @@ func (repo *UserRepository) Save(user *models.User) error {
 
 	user.ID = generateUniqueID(userType, user.AccessLevel, user.RatingLevel)
 
-	_, err = repo.DB.Exec("INSERT INTO users (id, email, username, password, access_level, rating_level) VALUES ($1, $2, $3, $4, $5, $6)",
+	// Пользователь и событие о его регистрации сохраняются вместе, чтобы
+	// сервис кошельков узнал о каждом новом пользователе
+	tx, err := repo.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO users (id, email, username, password, access_level, rating_level) VALUES ($1, $2, $3, $4, $5, $6)",
 		user.ID, user.Email, user.Username, user.Password, user.AccessLevel, user.RatingLevel)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	event := models.UserRegistered{
+		UserID:       user.ID,
+		Username:     user.Username,
+		Email:        user.Email,
+		RegisteredAt: time.Now().UTC(),
+	}
+	if err := insertEvent(tx, models.EventUserRegistered, event); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // SaveAdmin сохраняет администратора в базе данных
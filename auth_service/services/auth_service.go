@@ -5,6 +5,7 @@ import (
 	"auth_service/repositories"
 	"errors"
 	"regexp"
+	"strings"
 )
 
 // AuthService представляет сервис авторизации
@@ -72,6 +73,14 @@ func (service *AuthService) AuthenticateUser(identifier, password string) (*mode
 	return user, nil
 }
 
+// FindUser ищет пользователя по email или username, nil если его нет
+func (service *AuthService) FindUser(identifier string) (*models.User, error) {
+	if strings.Contains(identifier, "@") {
+		return service.UserRepository.FindByEmail(identifier)
+	}
+	return service.UserRepository.FindByUserName(identifier)
+}
+
 // UpdateUserAccessAndRating обновляет уровень доступа и рейтинг пользователя (только для администраторов)
 func (service *AuthService) UpdateUserAccessAndRating(userID string, accessLevel, ratingLevel int, admin *models.User) error {
 	if admin.AccessLevel > 2 {
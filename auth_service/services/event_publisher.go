@@ -0,0 +1,105 @@
+package services
+
+import (
+	"auth_service/models"
+	"auth_service/repositories"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// EventPublisher доставляет события из outbox в сервис кошельков
+type EventPublisher struct {
+	EventRepository *repositories.EventRepository
+	URL             string
+	Token           string
+	Client          *http.Client
+}
+
+// NewEventPublisher создает новый экземпляр публикатора событий
+func NewEventPublisher(eventRepo *repositories.EventRepository, url, token string) *EventPublisher {
+	return &EventPublisher{
+		EventRepository: eventRepo,
+		URL:             url,
+		Token:           token,
+		Client:          &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Run публикует накопившиеся события с заданным интервалом до отмены ctx
+func (publisher *EventPublisher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := publisher.PublishPending(ctx); err != nil {
+			log.Printf("event publisher: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PublishPending отправляет события, ожидающие доставки. Неудачная доставка
+// откладывается и повторяется позже
+func (publisher *EventPublisher) PublishPending(ctx context.Context) error {
+	events, err := publisher.EventRepository.Pending(100)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		if err := publisher.send(ctx, event); err != nil {
+			log.Printf("event publisher: event %d: %v", event.ID, err)
+			if err := publisher.EventRepository.MarkFailed(event.ID, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := publisher.EventRepository.MarkPublished(event.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (publisher *EventPublisher) send(ctx context.Context, event *models.Event) error {
+	// Идентификатор события передается получателю вместе с данными
+	var body map[string]interface{}
+	if err := json.Unmarshal(event.Payload, &body); err != nil {
+		return err
+	}
+	body["event_id"] = event.ID
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, publisher.URL+"/events/"+event.Type, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if publisher.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+publisher.Token)
+	}
+
+	resp, err := publisher.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
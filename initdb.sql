@@ -1,7 +1,11 @@
 
 CREATE TABLE users (
                        id SERIAL PRIMARY KEY,
-                       username VARCHAR(50) UNIQUE NOT NULL
+                       username VARCHAR(50) UNIQUE NOT NULL,
+                       -- Id of the user in the auth service, set when the wallet is provisioned
+                       auth_user_id VARCHAR(25) UNIQUE,
+                       access_level SMALLINT NOT NULL DEFAULT 1 CHECK (access_level BETWEEN 1 AND 3),
+                       rating_level SMALLINT NOT NULL DEFAULT 1 CHECK (rating_level BETWEEN 0 AND 9)
 );
 
 
@@ -11,13 +15,48 @@ CREATE TABLE wallets (
                          FOREIGN KEY (user_id) REFERENCES users(id)
 );
 
+CREATE TABLE currencies (
+                        code VARCHAR(10) PRIMARY KEY,
+                        kind VARCHAR(10) NOT NULL CHECK (kind IN ('fiat', 'crypto')),
+                        decimals SMALLINT NOT NULL CHECK (decimals BETWEEN 0 AND 18),
+                        min_amount NUMERIC(38, 18) NOT NULL DEFAULT 0 CHECK (min_amount >= 0),
+                        enabled BOOLEAN NOT NULL DEFAULT TRUE,
+                        confirmations SMALLINT NOT NULL DEFAULT 0 CHECK (confirmations BETWEEN 0 AND 1000)
+);
+
+INSERT INTO currencies (code, kind, decimals, min_amount, confirmations) VALUES
+                        ('USD', 'fiat', 2, 0.01, 0),
+                        ('EUR', 'fiat', 2, 0.01, 0),
+                        ('BTC', 'crypto', 8, 0.00001, 3),
+                        ('ETH', 'crypto', 8, 0.0001, 12);
+
 CREATE TABLE accounts (
                         id SERIAL PRIMARY KEY,
                         account_number VARCHAR(32) UNIQUE NOT NULL,
-                        balance NUMERIC(20, 2) NOT NULL DEFAULT 0.00,
-                        active BOOLEAN NOT NULL DEFAULT TRUE
+                        currency VARCHAR(10) NOT NULL REFERENCES currencies(code),
+                        balance NUMERIC(38, 18) NOT NULL DEFAULT 0,
+                        held_balance NUMERIC(38, 18) NOT NULL DEFAULT 0,
+                        active BOOLEAN NOT NULL DEFAULT TRUE,
+                        status VARCHAR(10) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'frozen', 'closed')),
+                        status_reason TEXT NOT NULL DEFAULT '',
+                        status_changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                        CONSTRAINT accounts_balance_non_negative CHECK (balance >= 0),
+                        CONSTRAINT accounts_held_balance_covered CHECK (held_balance >= 0 AND held_balance <= balance)
 );
 
+CREATE TABLE account_holds (
+                        id BIGSERIAL PRIMARY KEY,
+                        account_number VARCHAR(32) NOT NULL REFERENCES accounts(account_number),
+                        amount NUMERIC(38, 18) NOT NULL CHECK (amount > 0),
+                        kind VARCHAR(20) NOT NULL,
+                        reference VARCHAR(64) NOT NULL DEFAULT '',
+                        status VARCHAR(10) NOT NULL DEFAULT 'active',
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                        updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX account_holds_account_idx ON account_holds (account_number) WHERE status = 'active';
+
 
 CREATE TABLE orders (
                         id SERIAL PRIMARY KEY,
@@ -27,11 +66,474 @@ CREATE TABLE orders (
                         amount NUMERIC(20, 8) NOT NULL,
                         desired_currency VARCHAR(50) NOT NULL,
                         status VARCHAR(20) NOT NULL DEFAULT 'PENDING',
+                        hold_id BIGINT REFERENCES account_holds(id),
+                        limit_charge_id BIGINT,
                         created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
                         FOREIGN KEY (seller_id) REFERENCES users(id),
                         FOREIGN KEY (buyer_id) REFERENCES users(id)
 );
 
+CREATE TABLE account_movements (
+                        id BIGSERIAL PRIMARY KEY,
+                        account_number VARCHAR(32) NOT NULL REFERENCES accounts(account_number),
+                        type VARCHAR(20) NOT NULL,
+                        amount NUMERIC(38, 18) NOT NULL,
+                        balance_after NUMERIC(38, 18) NOT NULL,
+                        counterparty VARCHAR(100),
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX account_movements_account_idx ON account_movements (account_number, created_at, id);
+
+-- Balances from before movements were recorded open the history, so the
+-- movements of older accounts add up to their balance.
+INSERT INTO account_movements (account_number, type, amount, balance_after, created_at)
+SELECT a.account_number, 'opening', a.balance - COALESCE(m.total, 0), a.balance - COALESCE(m.total, 0),
+       COALESCE(m.first_at - INTERVAL '1 microsecond', NOW())
+FROM accounts a
+LEFT JOIN (SELECT account_number, SUM(amount) AS total, MIN(created_at) AS first_at
+           FROM account_movements GROUP BY account_number) m ON m.account_number = a.account_number
+WHERE a.balance <> COALESCE(m.total, 0);
+
+-- End of day (UTC) balances, taken from the last movement before midnight.
+CREATE TABLE balance_snapshots (
+                        account_number VARCHAR(32) NOT NULL REFERENCES accounts(account_number),
+                        day DATE NOT NULL,
+                        balance NUMERIC(38, 18) NOT NULL,
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                        PRIMARY KEY (account_number, day)
+);
+
+CREATE INDEX balance_snapshots_day_idx ON balance_snapshots (day);
+
+CREATE TABLE idempotency_keys (
+                        key VARCHAR(128) PRIMARY KEY,
+                        request_hash CHAR(64) NOT NULL,
+                        status_code INT,
+                        response_body BYTEA,
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE scheduled_transfers (
+                        id BIGSERIAL PRIMARY KEY,
+                        sender_account_number VARCHAR(32) NOT NULL REFERENCES accounts(account_number),
+                        receiver_account_number VARCHAR(32) NOT NULL REFERENCES accounts(account_number),
+                        amount NUMERIC(38, 18) NOT NULL CHECK (amount > 0),
+                        kind VARCHAR(10) NOT NULL CHECK (kind IN ('once', 'interval', 'cron')),
+                        interval_seconds INT NOT NULL DEFAULT 0,
+                        cron VARCHAR(100) NOT NULL DEFAULT '',
+                        end_at TIMESTAMPTZ,
+                        next_run_at TIMESTAMPTZ NOT NULL,
+                        retry_at TIMESTAMPTZ,
+                        status VARCHAR(10) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'paused', 'cancelled', 'completed', 'failed')),
+                        attempts INT NOT NULL DEFAULT 0,
+                        runs INT NOT NULL DEFAULT 0,
+                        last_run_at TIMESTAMPTZ,
+                        last_error TEXT NOT NULL DEFAULT '',
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                        updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX scheduled_transfers_due_idx ON scheduled_transfers (COALESCE(retry_at, next_run_at)) WHERE status = 'active';
+CREATE INDEX scheduled_transfers_sender_idx ON scheduled_transfers (sender_account_number);
+
+-- Every attempt to execute an occurrence of a scheduled transfer. The unique
+-- index makes sure an occurrence is transferred at most once.
+CREATE TABLE scheduled_transfer_runs (
+                        id BIGSERIAL PRIMARY KEY,
+                        scheduled_transfer_id BIGINT NOT NULL REFERENCES scheduled_transfers(id),
+                        scheduled_for TIMESTAMPTZ NOT NULL,
+                        status VARCHAR(10) NOT NULL CHECK (status IN ('succeeded', 'failed')),
+                        error TEXT NOT NULL DEFAULT '',
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX scheduled_transfer_runs_once_idx ON scheduled_transfer_runs (scheduled_transfer_id, scheduled_for) WHERE status = 'succeeded';
+
+-- Deposit addresses are derived from the extended public key of the currency
+-- at m/44'/coin'/account'/0/derivation_index. Indexes are handed out in order
+-- per currency, so an address belongs to exactly one account.
+CREATE TABLE deposit_addresses (
+                        id BIGSERIAL PRIMARY KEY,
+                        account_number VARCHAR(32) NOT NULL REFERENCES accounts(account_number),
+                        currency VARCHAR(10) NOT NULL REFERENCES currencies(code),
+                        address VARCHAR(100) UNIQUE NOT NULL,
+                        derivation_index BIGINT NOT NULL CHECK (derivation_index >= 0 AND derivation_index < 2147483648),
+                        derivation_path VARCHAR(64) NOT NULL,
+                        created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                        UNIQUE (currency, derivation_index)
+);
+
+CREATE INDEX deposit_addresses_account_idx ON deposit_addresses (account_number, id);
+CREATE INDEX deposit_addresses_lower_address_idx ON deposit_addresses (currency, lower(address));
+
+-- The most recent blocks the deposit watcher has scanned per currency, kept
+-- to notice when a reorg replaces them.
+CREATE TABLE chain_blocks (
+                        currency VARCHAR(10) NOT NULL REFERENCES currencies(code),
+                        height BIGINT NOT NULL,
+                        hash VARCHAR(100) NOT NULL,
+                        PRIMARY KEY (currency, height)
+);
+
+-- A deposit is an output paying a deposit address. It is credited to the
+-- account once, when it has the confirmations the currency requires.
+CREATE TABLE chain_deposits (
+                        id BIGSERIAL PRIMARY KEY,
+                        currency VARCHAR(10) NOT NULL REFERENCES currencies(code),
+                        txid VARCHAR(100) NOT NULL,
+                        vout BIGINT NOT NULL CHECK (vout >= 0),
+                        address VARCHAR(100) NOT NULL REFERENCES deposit_addresses(address),
+                        account_number VARCHAR(32) NOT NULL REFERENCES accounts(account_number),
+                        amount NUMERIC(38, 18) NOT NULL CHECK (amount > 0),
+                        block_height BIGINT NOT NULL,
+                        block_hash VARCHAR(100) NOT NULL,
+                        confirmations BIGINT NOT NULL DEFAULT 0,
+                        status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'credited', 'orphaned', 'reorged', 'reversed')),
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                        credited_at TIMESTAMPTZ,
+                        UNIQUE (currency, txid, vout)
+);
+
+CREATE INDEX chain_deposits_status_idx ON chain_deposits (currency, status, block_height);
+CREATE INDEX chain_deposits_account_idx ON chain_deposits (account_number, id);
+
+-- A withdrawal to an external address. The hold reserves amount plus fee
+-- until the payment is confirmed or the withdrawal fails.
+CREATE TABLE withdrawals (
+                        id BIGSERIAL PRIMARY KEY,
+                        account_number VARCHAR(32) NOT NULL REFERENCES accounts(account_number),
+                        currency VARCHAR(10) NOT NULL REFERENCES currencies(code),
+                        address VARCHAR(100) NOT NULL,
+                        amount NUMERIC(38, 18) NOT NULL CHECK (amount > 0),
+                        fee NUMERIC(38, 18) NOT NULL DEFAULT 0 CHECK (fee >= 0),
+                        hold_id BIGINT NOT NULL REFERENCES account_holds(id),
+                        limit_charge_id BIGINT,
+                        requested_by VARCHAR(100) NOT NULL DEFAULT '',
+                        requires_approval BOOLEAN NOT NULL DEFAULT FALSE,
+                        approved_by VARCHAR(100) NOT NULL DEFAULT '',
+                        txid VARCHAR(100) NOT NULL DEFAULT '',
+                        raw_tx BYTEA,
+                        confirmations BIGINT NOT NULL DEFAULT 0,
+                        attempts INT NOT NULL DEFAULT 0,
+                        last_error TEXT NOT NULL DEFAULT '',
+                        status VARCHAR(10) NOT NULL CHECK (status IN ('requested', 'approved', 'signed', 'broadcast', 'confirmed', 'failed')),
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                        updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX withdrawals_status_idx ON withdrawals (status, id);
+CREATE INDEX withdrawals_account_idx ON withdrawals (account_number, id);
+
+-- External addresses users withdraw to, stored in canonical form. A new
+-- address can be used from usable_at on.
+CREATE TABLE address_book (
+                        id BIGSERIAL PRIMARY KEY,
+                        user_id INT NOT NULL REFERENCES users(id),
+                        currency VARCHAR(10) NOT NULL REFERENCES currencies(code),
+                        address VARCHAR(100) NOT NULL,
+                        label VARCHAR(100) NOT NULL DEFAULT '',
+                        usable_at TIMESTAMPTZ NOT NULL,
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                        UNIQUE (user_id, currency, address)
+);
+
+-- Whitelist only mode stays in force until whitelist_until after it is
+-- turned off. Address book changes are mailed to email.
+CREATE TABLE address_book_settings (
+                        user_id INT PRIMARY KEY REFERENCES users(id),
+                        whitelist_only BOOLEAN NOT NULL DEFAULT FALSE,
+                        whitelist_until TIMESTAMPTZ,
+                        email VARCHAR(254) NOT NULL DEFAULT '',
+                        updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Reports of the reconciliation job, the drift per account and chain is
+-- kept in report as it was returned.
+CREATE TABLE reconciliation_reports (
+                        id BIGSERIAL PRIMARY KEY,
+                        started_at TIMESTAMPTZ NOT NULL,
+                        finished_at TIMESTAMPTZ NOT NULL,
+                        discrepancies INT NOT NULL,
+                        report JSONB NOT NULL
+);
+
+-- Transfers to other users addressed by username or email. The recipient's
+-- username is kept as it was when the payment was made.
+CREATE TABLE payments (
+                        id BIGSERIAL PRIMARY KEY,
+                        sender_account_number VARCHAR(32) NOT NULL REFERENCES accounts(account_number),
+                        recipient_account_number VARCHAR(32) NOT NULL REFERENCES accounts(account_number),
+                        recipient_username VARCHAR(50) NOT NULL,
+                        currency VARCHAR(10) NOT NULL REFERENCES currencies(code),
+                        amount NUMERIC(38, 18) NOT NULL CHECK (amount > 0),
+                        fee NUMERIC(38, 18) NOT NULL DEFAULT 0 CHECK (fee >= 0),
+                        memo VARCHAR(140) NOT NULL DEFAULT '',
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX payments_sender_idx ON payments (sender_account_number, id);
+CREATE INDEX payments_recipient_idx ON payments (recipient_account_number, id);
+
+-- Batch payouts from one account. Totals and counts cover the paid lines.
+CREATE TABLE payouts (
+                        id BIGSERIAL PRIMARY KEY,
+                        account_number VARCHAR(32) NOT NULL REFERENCES accounts(account_number),
+                        currency VARCHAR(10) NOT NULL REFERENCES currencies(code),
+                        mode VARCHAR(20) NOT NULL CHECK (mode IN ('all_or_nothing', 'best_effort')),
+                        status VARCHAR(20) NOT NULL CHECK (status IN ('processing', 'completed', 'partially_completed', 'failed')),
+                        line_count INT NOT NULL,
+                        paid_count INT NOT NULL DEFAULT 0,
+                        failed_count INT NOT NULL DEFAULT 0,
+                        total_amount NUMERIC(38, 18) NOT NULL DEFAULT 0,
+                        total_fees NUMERIC(38, 18) NOT NULL DEFAULT 0,
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX payouts_account_idx ON payouts (account_number, id);
+
+-- The result of every line of a payout. A reference is paid at most once
+-- per account, resubmitted lines are recorded as duplicates.
+CREATE TABLE payout_lines (
+                        payout_id BIGINT NOT NULL REFERENCES payouts(id),
+                        account_number VARCHAR(32) NOT NULL,
+                        line INT NOT NULL,
+                        recipient TEXT NOT NULL,
+                        recipient_account_number VARCHAR(32) NOT NULL DEFAULT '',
+                        amount NUMERIC(38, 18) NOT NULL,
+                        currency TEXT NOT NULL,
+                        reference TEXT NOT NULL,
+                        fee NUMERIC(38, 18) NOT NULL DEFAULT 0,
+                        status VARCHAR(10) NOT NULL CHECK (status IN ('invalid', 'paid', 'failed', 'duplicate')),
+                        error TEXT NOT NULL DEFAULT '',
+                        PRIMARY KEY (payout_id, line)
+);
+
+CREATE UNIQUE INDEX payout_lines_reference_idx ON payout_lines (account_number, reference) WHERE status = 'paid';
+
+-- Requests for a payment into payee_account_number, shared by reference.
+-- Open requests past expires_at are read as expired.
+CREATE TABLE payment_requests (
+                        id BIGSERIAL PRIMARY KEY,
+                        reference VARCHAR(20) NOT NULL UNIQUE,
+                        payee_account_number VARCHAR(32) NOT NULL REFERENCES accounts(account_number),
+                        currency VARCHAR(10) NOT NULL REFERENCES currencies(code),
+                        amount NUMERIC(38, 18) NOT NULL CHECK (amount > 0),
+                        memo VARCHAR(140) NOT NULL DEFAULT '',
+                        status VARCHAR(10) NOT NULL CHECK (status IN ('open', 'paid', 'expired', 'cancelled')),
+                        expires_at TIMESTAMPTZ NOT NULL,
+                        payer_account_number VARCHAR(32) NOT NULL DEFAULT '',
+                        paid_at TIMESTAMPTZ,
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                        updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX payment_requests_payee_idx ON payment_requests (payee_account_number, id);
+CREATE INDEX payment_requests_payer_idx ON payment_requests (payer_account_number, id) WHERE payer_account_number <> '';
+
+-- Organizations own accounts shared by their members. Transfers above the
+-- threshold of their currency wait for an approver other than the initiator.
+CREATE TABLE organizations (
+                        id BIGSERIAL PRIMARY KEY,
+                        name VARCHAR(100) NOT NULL,
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE organization_members (
+                        organization_id BIGINT NOT NULL REFERENCES organizations(id),
+                        user_id INT NOT NULL REFERENCES users(id),
+                        role VARCHAR(10) NOT NULL CHECK (role IN ('viewer', 'initiator', 'approver')),
+                        added_by INT NOT NULL,
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                        PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE TABLE organization_accounts (
+                        account_number VARCHAR(32) PRIMARY KEY REFERENCES accounts(account_number),
+                        organization_id BIGINT NOT NULL REFERENCES organizations(id)
+);
+
+CREATE INDEX organization_accounts_organization_idx ON organization_accounts (organization_id);
+
+CREATE TABLE organization_thresholds (
+                        organization_id BIGINT NOT NULL REFERENCES organizations(id),
+                        currency VARCHAR(10) NOT NULL REFERENCES currencies(code),
+                        amount NUMERIC(38, 18) NOT NULL CHECK (amount >= 0),
+                        PRIMARY KEY (organization_id, currency)
+);
+
+CREATE TABLE organization_transfers (
+                        id BIGSERIAL PRIMARY KEY,
+                        organization_id BIGINT NOT NULL REFERENCES organizations(id),
+                        sender_account_number VARCHAR(32) NOT NULL REFERENCES organization_accounts(account_number),
+                        receiver_account_number VARCHAR(32) NOT NULL,
+                        currency VARCHAR(10) NOT NULL REFERENCES currencies(code),
+                        amount NUMERIC(38, 18) NOT NULL CHECK (amount > 0),
+                        memo VARCHAR(140) NOT NULL DEFAULT '',
+                        requires_approval BOOLEAN NOT NULL,
+                        initiated_by INT NOT NULL,
+                        approved_by INT,
+                        rejected_by INT,
+                        reason TEXT NOT NULL DEFAULT '',
+                        status VARCHAR(10) NOT NULL CHECK (status IN ('pending', 'executed', 'rejected', 'cancelled', 'failed')),
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                        updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                        CHECK (approved_by IS NULL OR approved_by <> initiated_by)
+);
+
+CREATE INDEX organization_transfers_organization_idx ON organization_transfers (organization_id, id);
+
+-- Every action taken in an organization, attributed to the member.
+CREATE TABLE organization_activity (
+                        id BIGSERIAL PRIMARY KEY,
+                        organization_id BIGINT NOT NULL REFERENCES organizations(id),
+                        user_id INT NOT NULL,
+                        action VARCHAR(40) NOT NULL,
+                        details JSONB NOT NULL DEFAULT '{}',
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX organization_activity_organization_idx ON organization_activity (organization_id, id);
+
+-- Scoped access to the wallet of owner_id, for another user or for the
+-- holder of an API key, of which only the SHA-256 is kept.
+CREATE TABLE access_grants (
+                        id BIGSERIAL PRIMARY KEY,
+                        owner_id INT NOT NULL REFERENCES users(id),
+                        grantee_user_id INT REFERENCES users(id),
+                        api_key_hash VARCHAR(64) UNIQUE,
+                        scopes TEXT[] NOT NULL,
+                        expires_at TIMESTAMPTZ NOT NULL,
+                        revoked_at TIMESTAMPTZ,
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                        CHECK ((grantee_user_id IS NULL) <> (api_key_hash IS NULL)),
+                        CHECK (scopes <@ ARRAY['view', 'trade'])
+);
+
+CREATE INDEX access_grants_owner_idx ON access_grants (owner_id, id);
+CREATE INDEX access_grants_grantee_idx ON access_grants (grantee_user_id, owner_id) WHERE revoked_at IS NULL;
+
+-- Gift codes paid out of the house account of the currency. Only the
+-- SHA-256 of a code is kept, code_suffix is its last four characters.
+CREATE TABLE vouchers (
+                        id BIGSERIAL PRIMARY KEY,
+                        code_hash VARCHAR(64) NOT NULL UNIQUE,
+                        code_suffix VARCHAR(4) NOT NULL,
+                        currency VARCHAR(10) NOT NULL REFERENCES currencies(code),
+                        amount NUMERIC(38, 18) NOT NULL CHECK (amount > 0),
+                        max_redemptions INT NOT NULL CHECK (max_redemptions > 0),
+                        redemptions INT NOT NULL DEFAULT 0 CHECK (redemptions <= max_redemptions),
+                        status VARCHAR(10) NOT NULL CHECK (status IN ('active', 'disabled')),
+                        expires_at TIMESTAMPTZ NOT NULL,
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- A user redeems a voucher at most once, however many uses it has.
+CREATE TABLE voucher_redemptions (
+                        id BIGSERIAL PRIMARY KEY,
+                        voucher_id BIGINT NOT NULL REFERENCES vouchers(id),
+                        user_id INT NOT NULL REFERENCES users(id),
+                        account_number VARCHAR(32) NOT NULL REFERENCES accounts(account_number),
+                        currency VARCHAR(10) NOT NULL,
+                        amount NUMERIC(38, 18) NOT NULL,
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                        UNIQUE (voucher_id, user_id)
+);
+
+-- The rule of an operation with the highest min_amount not above the amount
+-- applies, rules of the currency win over the ones for any currency ('*').
+-- Flat fees and bounds are in the rule currency, so rules for any currency
+-- can only charge a percentage.
+CREATE TABLE fee_rules (
+                        id BIGSERIAL PRIMARY KEY,
+                        operation VARCHAR(20) NOT NULL CHECK (operation IN ('transfer', 'withdrawal', 'trade')),
+                        currency VARCHAR(10) NOT NULL DEFAULT '*',
+                        min_amount NUMERIC(38, 18) NOT NULL DEFAULT 0 CHECK (min_amount >= 0),
+                        flat NUMERIC(38, 18) NOT NULL DEFAULT 0 CHECK (flat >= 0),
+                        percent NUMERIC(7, 4) NOT NULL DEFAULT 0 CHECK (percent >= 0 AND percent <= 100),
+                        min_fee NUMERIC(38, 18) NOT NULL DEFAULT 0 CHECK (min_fee >= 0),
+                        max_fee NUMERIC(38, 18) NOT NULL DEFAULT 0 CHECK (max_fee >= 0),
+                        UNIQUE (operation, currency, min_amount),
+                        CHECK (currency <> '*' OR (min_amount = 0 AND flat = 0 AND min_fee = 0 AND max_fee = 0))
+);
+
+INSERT INTO fee_rules (operation, currency, min_amount, flat, percent, min_fee, max_fee) VALUES
+                        ('transfer', '*', 0, 0, 0.1, 0, 0),
+                        ('transfer', 'USD', 0, 0.25, 0.1, 0, 5),
+                        ('transfer', 'USD', 10000, 0, 0.05, 0, 25),
+                        ('withdrawal', '*', 0, 0, 0.5, 0, 0),
+                        ('withdrawal', 'USD', 0, 1, 0.2, 0, 20),
+                        ('withdrawal', 'BTC', 0, 0.0002, 0, 0, 0),
+                        ('withdrawal', 'ETH', 0, 0.002, 0, 0, 0),
+                        ('trade', '*', 0, 0, 0.2, 0, 0);
+
+-- Users with at least min_rating get the discount on every fee, the best
+-- discount they qualify for wins.
+CREATE TABLE fee_discounts (
+                        min_rating SMALLINT PRIMARY KEY CHECK (min_rating BETWEEN 0 AND 9),
+                        percent NUMERIC(5, 2) NOT NULL CHECK (percent >= 0 AND percent <= 100)
+);
+
+INSERT INTO fee_discounts (min_rating, percent) VALUES (5, 10), (7, 25), (9, 50);
+
+-- Fees are credited to the house account of the fee currency, which is
+-- opened the first time a fee is charged in that currency.
+CREATE TABLE house_accounts (
+                        currency VARCHAR(10) PRIMARY KEY REFERENCES currencies(code),
+                        account_number VARCHAR(32) UNIQUE NOT NULL REFERENCES accounts(account_number)
+);
+
+-- Limits are in USD. A tier applies to the users of its access level whose
+-- rating is at least min_rating, the tier with the highest min_rating wins.
+-- A kind and period without a tier is not limited.
+CREATE TABLE limit_tiers (
+                        access_level SMALLINT NOT NULL,
+                        min_rating SMALLINT NOT NULL,
+                        kind VARCHAR(20) NOT NULL CHECK (kind IN ('withdrawal', 'transfer', 'order')),
+                        period VARCHAR(10) NOT NULL CHECK (period IN ('day', 'month')),
+                        amount NUMERIC(38, 18) NOT NULL CHECK (amount >= 0),
+                        PRIMARY KEY (access_level, min_rating, kind, period)
+);
+
+INSERT INTO limit_tiers (access_level, min_rating, kind, period, amount) VALUES
+                        (1, 0, 'withdrawal', 'day', 500), (1, 0, 'withdrawal', 'month', 2000),
+                        (1, 0, 'transfer', 'day', 1000), (1, 0, 'transfer', 'month', 5000),
+                        (1, 0, 'order', 'day', 1000), (1, 0, 'order', 'month', 5000),
+                        (1, 3, 'withdrawal', 'day', 5000), (1, 3, 'withdrawal', 'month', 50000),
+                        (1, 3, 'transfer', 'day', 10000), (1, 3, 'transfer', 'month', 100000),
+                        (1, 3, 'order', 'day', 20000), (1, 3, 'order', 'month', 200000),
+                        (1, 7, 'withdrawal', 'day', 50000), (1, 7, 'withdrawal', 'month', 500000),
+                        (1, 7, 'transfer', 'day', 100000), (1, 7, 'transfer', 'month', 1000000),
+                        (1, 7, 'order', 'day', 250000), (1, 7, 'order', 'month', 2500000),
+                        (2, 0, 'withdrawal', 'day', 200), (2, 0, 'withdrawal', 'month', 1000),
+                        (2, 0, 'transfer', 'day', 500), (2, 0, 'transfer', 'month', 2000),
+                        (2, 0, 'order', 'day', 500), (2, 0, 'order', 'month', 2000),
+                        (3, 0, 'withdrawal', 'day', 0), (3, 0, 'withdrawal', 'month', 0),
+                        (3, 0, 'transfer', 'day', 100), (3, 0, 'transfer', 'month', 500),
+                        (3, 0, 'order', 'day', 0), (3, 0, 'order', 'month', 0);
+
+CREATE TABLE limit_overrides (
+                        user_id INT NOT NULL REFERENCES users(id),
+                        kind VARCHAR(20) NOT NULL,
+                        period VARCHAR(10) NOT NULL,
+                        amount NUMERIC(38, 18) NOT NULL CHECK (amount >= 0),
+                        reason TEXT NOT NULL DEFAULT '',
+                        updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                        PRIMARY KEY (user_id, kind, period)
+);
+
+-- Every operation counted against a limit, valued in USD.
+CREATE TABLE limit_charges (
+                        id BIGSERIAL PRIMARY KEY,
+                        user_id INT NOT NULL REFERENCES users(id),
+                        kind VARCHAR(20) NOT NULL,
+                        amount NUMERIC(38, 18) NOT NULL,
+                        reference VARCHAR(64) NOT NULL DEFAULT '',
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX limit_charges_user_idx ON limit_charges (user_id, kind, created_at);
+
 
 -- Insert users
 INSERT INTO users (username) VALUES ('seller1'), ('buyer1');
@@ -2,4 +2,13 @@ module transaction
 
 go 1.22
 
-require github.com/lib/pq v1.10.9
+require wallet v0.0.0
+
+require (
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.3.0 // indirect
+	github.com/lib/pq v1.10.9 // indirect
+	golang.org/x/crypto v0.24.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+)
+
+replace wallet => ../wallet
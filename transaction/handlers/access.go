@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+	wallethandlers "wallet/handlers"
+	walletservices "wallet/services"
+)
+
+// authorize проверяет, что действующее лицо запроса (заголовки X-Acting-User
+// или X-API-Key) может действовать с кошельком владельца в рамках scope.
+// Запросы без этих заголовков выполняются от имени владельца, как и раньше
+func authorize(w http.ResponseWriter, r *http.Request, grantService *walletservices.GrantService, ownerID int, scope string) bool {
+	principal, err := wallethandlers.RequestPrincipal(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if err := grantService.Authorize(r.Context(), principal, ownerID, scope); err != nil {
+		writeError(w, err)
+		return false
+	}
+	return true
+}
+
+// authorizeAccount - то же для владельца счета
+func authorizeAccount(w http.ResponseWriter, r *http.Request, grantService *walletservices.GrantService, accountNumber, scope string) bool {
+	principal, err := wallethandlers.RequestPrincipal(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if err := grantService.AuthorizeAccount(r.Context(), principal, accountNumber, scope); err != nil {
+		writeError(w, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"transaction/services"
+	"wallet/accountnumber"
+	"wallet/chainaddress"
+	walletrepositories "wallet/repositories"
+	walletservices "wallet/services"
+)
+
+// writeError переводит ошибки предметной области в HTTP статусы
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, walletrepositories.ErrAccountNotFound),
+		errors.Is(err, walletrepositories.ErrHoldNotFound),
+		errors.Is(err, walletrepositories.ErrCurrencyNotFound),
+		errors.Is(err, walletrepositories.ErrWithdrawalNotFound),
+		errors.Is(err, walletservices.ErrRecipientNotFound),
+		errors.Is(err, services.ErrOrderNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, walletrepositories.ErrAccountFrozen),
+		errors.Is(err, walletrepositories.ErrAccountClosed),
+		errors.Is(err, walletrepositories.ErrHoldNotActive),
+		errors.Is(err, walletrepositories.ErrUsernameTaken),
+		errors.Is(err, services.ErrOrderNotPending):
+		status = http.StatusConflict
+	case errors.Is(err, walletservices.ErrAccessDenied),
+		errors.Is(err, walletservices.ErrOrganizationAccount),
+		errors.Is(err, walletservices.ErrNotTradeParty),
+		errors.Is(err, services.ErrNotOrderSeller):
+		status = http.StatusForbidden
+	case errors.Is(err, walletrepositories.ErrInsufficientFunds),
+		errors.Is(err, walletrepositories.ErrLimitExceeded),
+		errors.Is(err, walletservices.ErrCurrencyDisabled),
+		errors.Is(err, walletservices.ErrCurrencyMismatch),
+		errors.Is(err, walletservices.ErrAmountBelowMinimum),
+		errors.Is(err, walletservices.ErrWithdrawalUnsupported),
+		errors.Is(err, walletservices.ErrAddressCoolingOff),
+		errors.Is(err, walletservices.ErrAddressNotWhitelisted):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, accountnumber.ErrInvalid),
+		errors.Is(err, walletservices.ErrInvalidAmount),
+		errors.Is(err, walletservices.ErrSameAccount),
+		errors.Is(err, walletservices.ErrCryptocurrencyExpected),
+		errors.Is(err, walletservices.ErrWithdrawalAddressRequired),
+		errors.Is(err, chainaddress.ErrInvalidAddress),
+		errors.Is(err, walletservices.ErrRecipientRequired),
+		errors.Is(err, walletservices.ErrSelfPayment),
+		errors.Is(err, walletservices.ErrMemoTooLong):
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
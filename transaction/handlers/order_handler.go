@@ -4,14 +4,17 @@ import (
 	"encoding/json"
 	"net/http"
 	"transaction/services"
+	walletmodels "wallet/models"
+	walletservices "wallet/services"
 )
 
 type OrderHandler struct {
 	OrderService *services.OrderService
+	GrantService *walletservices.GrantService
 }
 
-func NewOrderHandler(orderService *services.OrderService) *OrderHandler {
-	return &OrderHandler{OrderService: orderService}
+func NewOrderHandler(orderService *services.OrderService, grantService *walletservices.GrantService) *OrderHandler {
+	return &OrderHandler{OrderService: orderService, GrantService: grantService}
 }
 
 func (handler *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
@@ -29,9 +32,13 @@ func (handler *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !authorize(w, r, handler.GrantService, orderData.SellerID, walletmodels.GrantScopeTrade) {
+		return
+	}
+
 	err = handler.OrderService.CreateOrder(r.Context(), orderData.SellerID, orderData.Cryptocurrency, orderData.Amount, orderData.Price, orderData.ExchangeTo)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
@@ -92,11 +99,43 @@ func (handler *OrderHandler) PurchaseOrder(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = handler.OrderService.PurchaseOrder(r.Context(), purchaseData.BuyerID, purchaseData.OrderID)
+	if !authorize(w, r, handler.GrantService, purchaseData.BuyerID, walletmodels.GrantScopeTrade) {
+		return
+	}
+
+	receipt, err := handler.OrderService.PurchaseOrder(r.Context(), purchaseData.BuyerID, purchaseData.OrderID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(receipt)
+}
+
+// CancelOrder снимает заказ продавца с торговой площадки
+func (handler *OrderHandler) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	var cancelData struct {
+		SellerID int
+		OrderID  int
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&cancelData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !authorize(w, r, handler.GrantService, cancelData.SellerID, walletmodels.GrantScopeTrade) {
+		return
+	}
+
+	order, err := handler.OrderService.CancelOrder(r.Context(), cancelData.SellerID, cancelData.OrderID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
 }
@@ -4,15 +4,21 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
-	"transaction/services"
+	"wallet/accountnumber"
+	wallethandlers "wallet/handlers"
+	walletmodels "wallet/models"
+	walletservices "wallet/services"
 )
 
 type WalletHandler struct {
-	WalletService *services.WalletService
+	WalletService     *walletservices.WalletService
+	WithdrawalService *walletservices.WithdrawalService
+	PaymentService    *walletservices.PaymentService
+	GrantService      *walletservices.GrantService
 }
 
-func NewWalletHandler(walletService *services.WalletService) *WalletHandler {
-	return &WalletHandler{WalletService: walletService}
+func NewWalletHandler(walletService *walletservices.WalletService, withdrawalService *walletservices.WithdrawalService, paymentService *walletservices.PaymentService, grantService *walletservices.GrantService) *WalletHandler {
+	return &WalletHandler{WalletService: walletService, WithdrawalService: withdrawalService, PaymentService: paymentService, GrantService: grantService}
 }
 
 func (handler *WalletHandler) GetUserWallet(w http.ResponseWriter, r *http.Request) {
@@ -27,8 +33,11 @@ func (handler *WalletHandler) GetUserWallet(w http.ResponseWriter, r *http.Reque
 		http.Error(w, "Invalid User ID parameter", http.StatusBadRequest)
 		return
 	}
+	if !authorize(w, r, handler.GrantService, userID, walletmodels.GrantScopeView) {
+		return
+	}
 
-	wallet, err := handler.WalletService.GetUserWallet(r.Context(), userID)
+	wallet, err := handler.WalletService.GetWalletByUserId(r.Context(), userID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -49,9 +58,16 @@ func (handler *WalletHandler) Deposit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = handler.WalletService.Deposit(r.Context(), depositData.Amount, depositData.AccountNumber)
+	// Номер со сбитой контрольной суммой отклоняем сразу, не обращаясь к базе
+	accountNumber, err := accountnumber.Parse(depositData.AccountNumber)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+
+	err = handler.WalletService.Deposit(r.Context(), accountNumber, depositData.Amount)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
@@ -62,6 +78,7 @@ func (handler *WalletHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 	var withdrawalData struct {
 		Amount        float64
 		AccountNumber string
+		Address       string
 	}
 
 	err := json.NewDecoder(r.Body).Decode(&withdrawalData)
@@ -70,13 +87,41 @@ func (handler *WalletHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = handler.WalletService.Withdraw(r.Context(), withdrawalData.Amount, withdrawalData.AccountNumber)
+	accountNumber, err := accountnumber.Parse(withdrawalData.AccountNumber)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+	// Вывод нельзя делегировать, выводит только владелец счета
+	if !authorizeAccount(w, r, handler.GrantService, accountNumber, walletmodels.GrantScopeOwner) {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	// С адресом создаем заявку на вывод во внешний кошелек, она исполняется
+	// позже, поэтому отвечаем 202
+	if withdrawalData.Address != "" {
+		// Заявителем записывается действующее лицо, уже проверенное выше
+		principal, _ := wallethandlers.RequestPrincipal(r)
+		withdrawal, err := handler.WithdrawalService.RequestWithdrawal(r.Context(), accountNumber, withdrawalData.Address, withdrawalData.Amount, principal)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(withdrawal)
+		return
+	}
+
+	receipt, err := handler.WalletService.Withdraw(r.Context(), accountNumber, withdrawalData.Amount)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(receipt)
 }
 
 func (handler *WalletHandler) Transfer(w http.ResponseWriter, r *http.Request) {
@@ -92,11 +137,62 @@ func (handler *WalletHandler) Transfer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = handler.WalletService.Transfer(r.Context(), transferData.Amount, transferData.SenderAccountNumber, transferData.ReceiverAccountNumber)
+	senderAccountNumber, err := accountnumber.Parse(transferData.SenderAccountNumber)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+	receiverAccountNumber, err := accountnumber.Parse(transferData.ReceiverAccountNumber)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !authorizeAccount(w, r, handler.GrantService, senderAccountNumber, walletmodels.GrantScopeOwner) {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	receipt, err := handler.WalletService.Transfer(r.Context(), senderAccountNumber, receiverAccountNumber, transferData.Amount)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(receipt)
+}
+
+// Pay переводит средства пользователю по его username или email, номер счета
+// получателя знать не нужно. В ответе квитанции обеих сторон
+func (handler *WalletHandler) Pay(w http.ResponseWriter, r *http.Request) {
+	var paymentData struct {
+		Amount              float64
+		SenderAccountNumber string
+		Recipient           string
+		Memo                string
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&paymentData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	senderAccountNumber, err := accountnumber.Parse(paymentData.SenderAccountNumber)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !authorizeAccount(w, r, handler.GrantService, senderAccountNumber, walletmodels.GrantScopeOwner) {
+		return
+	}
+
+	receipt, err := handler.PaymentService.Pay(r.Context(), senderAccountNumber, paymentData.Recipient, paymentData.Amount, paymentData.Memo)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(receipt)
 }
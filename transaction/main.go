@@ -4,10 +4,18 @@ import (
 	"database/sql"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"time"
 	"transaction/handlers"
 	"transaction/repositories"
 	"transaction/services"
+	"wallet/authclient"
+	"wallet/fx"
+	"wallet/mail"
+	walletrepositories "wallet/repositories"
+	walletservices "wallet/services"
+	"wallet/walletclient"
 )
 
 func main() {
@@ -18,28 +26,112 @@ func main() {
 		log.Fatal(err)
 	}
 	// Инициализация репозиториев
-	walletRepo := repositories.NewWalletRepository(db)
+	walletRepo := walletrepositories.NewWalletRepository(db)
+	accountRepo := walletrepositories.NewAccountRepository(db)
+	holdRepo := walletrepositories.NewHoldRepository(db)
+	currencyRepo := walletrepositories.NewCurrencyRepository(db)
+	limitRepo := walletrepositories.NewLimitRepository(db)
+	feeRepo := walletrepositories.NewFeeRepository(db)
+	depositAddressRepo := walletrepositories.NewDepositAddressRepository(db)
+	withdrawalRepo := walletrepositories.NewWithdrawalRepository(db)
+	addressBookRepo := walletrepositories.NewAddressBookRepository(db)
+	paymentRepo := walletrepositories.NewPaymentRepository(db)
+	grantRepo := walletrepositories.NewGrantRepository(db)
+	organizationRepo := walletrepositories.NewOrganizationRepository(db)
 	orderRepo := repositories.NewOrderRepository(db)
 
+	// Курсы валют для пересчета лимитов. Лимиты задаются в USD, без курсов
+	// их нельзя проверить для других валют
+	ratesFile := os.Getenv("FX_RATES_FILE")
+	if ratesFile == "" {
+		log.Fatal("FX_RATES_FILE is required")
+	}
+	staticRates, err := fx.LoadStaticRates(ratesFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rates := fx.NewCachedRates(staticRates, time.Minute)
+
 	// Инициализация сервисов
-	walletService := services.NewWalletService(walletRepo)
-	orderService := services.NewOrderService(orderRepo, walletService)
+	currencyService := walletservices.NewCurrencyService(currencyRepo)
+	limitService := walletservices.NewLimitService(limitRepo, walletRepo, currencyService, rates)
+	feeService := walletservices.NewFeeService(feeRepo, limitRepo, walletRepo, currencyService)
+	walletService := walletservices.NewWalletService(walletRepo, accountRepo, currencyService, limitService, feeService)
+	holdService := walletservices.NewHoldService(holdRepo, accountRepo, walletRepo, currencyService, feeService, walletService)
+
+	// Права, выданные владельцами кошельков другим пользователям и API ключам
+	grantService := walletservices.NewGrantService(grantRepo, walletRepo, organizationRepo)
+
+	// Адреса для вывода проверяются по тем же сетям, что и адреса пополнения
+	depositKeys := make(map[string]string)
+	for _, currency := range []string{"BTC", "ETH"} {
+		if key := os.Getenv("DEPOSIT_XPUB_" + currency); key != "" {
+			depositKeys[currency] = key
+		}
+	}
+	depositAddressService, err := walletservices.NewDepositAddressService(depositAddressRepo, accountRepo, currencyService, depositKeys)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Уведомления об изменениях адресной книги отправляет сервис кошельков,
+	// здесь адресная книга только проверяется при выводе
+	coolingOff := walletservices.MinAddressCoolingOff
+	if value := os.Getenv("ADDRESS_COOLING_OFF"); value != "" {
+		coolingOff, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	addressBookService, err := walletservices.NewAddressBookService(addressBookRepo, walletRepo, currencyService, depositAddressService, mail.Log{}, coolingOff)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Выводы дороже порога (в валюте лимитов) ждут одобрения администратора
+	approvalThreshold := 1000.0
+	if threshold := os.Getenv("WITHDRAWAL_APPROVAL_THRESHOLD"); threshold != "" {
+		approvalThreshold, err = strconv.ParseFloat(threshold, 64)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	withdrawalService := walletservices.NewWithdrawalService(withdrawalRepo, accountRepo, walletRepo, currencyService, feeService, limitService, depositAddressService, addressBookService, approvalThreshold)
+
+	// Получателей платежей ищем в сервисе авторизации
+	authServiceURL := os.Getenv("AUTH_SERVICE_URL")
+	if authServiceURL == "" {
+		authServiceURL = "http://localhost:8081"
+	}
+	directory := authclient.New(authServiceURL, os.Getenv("AUTH_SERVICE_TOKEN"))
+	paymentService := walletservices.NewPaymentService(paymentRepo, walletRepo, accountRepo, walletService, limitService, feeService, directory)
+
+	// Если задан адрес сервиса кошельков, заказы работают с ним по HTTP,
+	// иначе - с сервисами кошельков в этом же процессе. Списания лимитов
+	// авторизуются токеном WALLET_EVENTS_TOKEN
+	var wallets services.Wallets = walletclient.NewLocal(walletService, holdService, currencyService, limitService)
+	if walletServiceURL := os.Getenv("WALLET_SERVICE_URL"); walletServiceURL != "" {
+		wallets = walletclient.New(walletServiceURL, walletclient.WithToken(os.Getenv("WALLET_EVENTS_TOKEN")))
+	}
+	orderService := services.NewOrderService(orderRepo, wallets)
 
 	// Инициализация хендлеров
-	walletHandler := handlers.NewWalletHandler(walletService)
-	orderHandler := handlers.NewOrderHandler(orderService)
+	walletHandler := handlers.NewWalletHandler(walletService, withdrawalService, paymentService, grantService)
+	orderHandler := handlers.NewOrderHandler(orderService, grantService)
 
 	// Настройка маршрутов
 	http.HandleFunc("/wallet", walletHandler.GetUserWallet)
 	http.HandleFunc("/wallet/deposit", walletHandler.Deposit)
 	http.HandleFunc("/wallet/withdraw", walletHandler.Withdraw)
 	http.HandleFunc("/wallet/transfer", walletHandler.Transfer)
+	http.HandleFunc("/wallet/pay", walletHandler.Pay)
 
 	http.HandleFunc("/orders", orderHandler.FindOrders)
 	http.HandleFunc("/orders/create", orderHandler.CreateOrder)
 	http.HandleFunc("/orders/by-currency", orderHandler.FindOrdersByCurrency)
 	http.HandleFunc("/orders/by-seller", orderHandler.FindOrdersBySellerUsername)
 	http.HandleFunc("/orders/purchase", orderHandler.PurchaseOrder)
+	http.HandleFunc("/orders/cancel", orderHandler.CancelOrder)
 
 	// Запуск HTTP-сервера
 	server := &http.Server{
@@ -1,6 +0,0 @@
-package models
-
-type Wallet struct {
-	UserID   int      `json:"user_id"`
-	Accounts []string `json:"accounts"`
-}
@@ -9,4 +9,6 @@ type Order struct {
 	Price          float64 `json:"price"`
 	Status         string  `json:"status"`
 	ExchangeTo     string  `json:"exchange_to"`
+	HoldID         int64   `json:"hold_id,omitempty"`
+	LimitChargeID  int64   `json:"-"`
 }
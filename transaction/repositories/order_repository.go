@@ -16,8 +16,8 @@ func NewOrderRepository(db *sql.DB) *OrderRepository {
 
 func (repo *OrderRepository) CreateOrder(ctx context.Context, order *models.Order) (int, error) {
 	var orderID int
-	query := "INSERT INTO orders (seller_id, cryptocurrency, amount, price, status, exchange_to) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id"
-	err := repo.DB.QueryRowContext(ctx, query, order.SellerID, order.Cryptocurrency, order.Amount, order.Price, order.Status, order.ExchangeTo).Scan(&orderID)
+	query := "INSERT INTO orders (seller_id, cryptocurrency, amount, price, status, exchange_to, hold_id, limit_charge_id) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, 0), NULLIF($8, 0)) RETURNING id"
+	err := repo.DB.QueryRowContext(ctx, query, order.SellerID, order.Cryptocurrency, order.Amount, order.Price, order.Status, order.ExchangeTo, order.HoldID, order.LimitChargeID).Scan(&orderID)
 	if err != nil {
 		return 0, err
 	}
@@ -25,7 +25,7 @@ func (repo *OrderRepository) CreateOrder(ctx context.Context, order *models.Orde
 }
 
 func (repo *OrderRepository) GetOrders(ctx context.Context) ([]*models.Order, error) {
-	query := "SELECT id, seller_id, cryptocurrency, amount, price, status, exchange_to FROM orders"
+	query := "SELECT id, seller_id, cryptocurrency, amount, price, status, exchange_to, COALESCE(hold_id, 0), COALESCE(limit_charge_id, 0) FROM orders"
 	rows, err := repo.DB.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -35,7 +35,7 @@ func (repo *OrderRepository) GetOrders(ctx context.Context) ([]*models.Order, er
 	var orders []*models.Order
 	for rows.Next() {
 		var order models.Order
-		err := rows.Scan(&order.ID, &order.SellerID, &order.Cryptocurrency, &order.Amount, &order.Price, &order.Status, &order.ExchangeTo)
+		err := rows.Scan(&order.ID, &order.SellerID, &order.Cryptocurrency, &order.Amount, &order.Price, &order.Status, &order.ExchangeTo, &order.HoldID, &order.LimitChargeID)
 		if err != nil {
 			return nil, err
 		}
@@ -46,7 +46,7 @@ func (repo *OrderRepository) GetOrders(ctx context.Context) ([]*models.Order, er
 }
 
 func (repo *OrderRepository) GetOrdersByCurrency(ctx context.Context, currency string) ([]*models.Order, error) {
-	query := "SELECT id, seller_id, cryptocurrency, amount, price, status, exchange_to FROM orders WHERE cryptocurrency = $1"
+	query := "SELECT id, seller_id, cryptocurrency, amount, price, status, exchange_to, COALESCE(hold_id, 0), COALESCE(limit_charge_id, 0) FROM orders WHERE cryptocurrency = $1"
 	rows, err := repo.DB.QueryContext(ctx, query, currency)
 	if err != nil {
 		return nil, err
@@ -56,7 +56,7 @@ func (repo *OrderRepository) GetOrdersByCurrency(ctx context.Context, currency s
 	var orders []*models.Order
 	for rows.Next() {
 		var order models.Order
-		err := rows.Scan(&order.ID, &order.SellerID, &order.Cryptocurrency, &order.Amount, &order.Price, &order.Status, &order.ExchangeTo)
+		err := rows.Scan(&order.ID, &order.SellerID, &order.Cryptocurrency, &order.Amount, &order.Price, &order.Status, &order.ExchangeTo, &order.HoldID, &order.LimitChargeID)
 		if err != nil {
 			return nil, err
 		}
@@ -67,7 +67,7 @@ func (repo *OrderRepository) GetOrdersByCurrency(ctx context.Context, currency s
 }
 
 func (repo *OrderRepository) GetOrdersBySellerUsername(ctx context.Context, username string) ([]*models.Order, error) {
-	query := "SELECT id, seller_id, cryptocurrency, amount, price, status, exchange_to FROM orders WHERE seller_id IN (SELECT id FROM users WHERE username = $1)"
+	query := "SELECT id, seller_id, cryptocurrency, amount, price, status, exchange_to, COALESCE(hold_id, 0), COALESCE(limit_charge_id, 0) FROM orders WHERE seller_id IN (SELECT id FROM users WHERE username = $1)"
 	rows, err := repo.DB.QueryContext(ctx, query, username)
 	if err != nil {
 		return nil, err
@@ -77,7 +77,7 @@ func (repo *OrderRepository) GetOrdersBySellerUsername(ctx context.Context, user
 	var orders []*models.Order
 	for rows.Next() {
 		var order models.Order
-		err := rows.Scan(&order.ID, &order.SellerID, &order.Cryptocurrency, &order.Amount, &order.Price, &order.Status, &order.ExchangeTo)
+		err := rows.Scan(&order.ID, &order.SellerID, &order.Cryptocurrency, &order.Amount, &order.Price, &order.Status, &order.ExchangeTo, &order.HoldID, &order.LimitChargeID)
 		if err != nil {
 			return nil, err
 		}
@@ -88,11 +88,11 @@ func (repo *OrderRepository) GetOrdersBySellerUsername(ctx context.Context, user
 }
 
 func (repo *OrderRepository) GetOrderByID(ctx context.Context, orderID int) (*models.Order, error) {
-	query := "SELECT id, seller_id, cryptocurrency, amount, price, status, exchange_to FROM orders WHERE id = $1"
+	query := "SELECT id, seller_id, cryptocurrency, amount, price, status, exchange_to, COALESCE(hold_id, 0), COALESCE(limit_charge_id, 0) FROM orders WHERE id = $1"
 	row := repo.DB.QueryRowContext(ctx, query, orderID)
 
 	var order models.Order
-	err := row.Scan(&order.ID, &order.SellerID, &order.Cryptocurrency, &order.Amount, &order.Price, &order.Status, &order.ExchangeTo)
+	err := row.Scan(&order.ID, &order.SellerID, &order.Cryptocurrency, &order.Amount, &order.Price, &order.Status, &order.ExchangeTo, &order.HoldID, &order.LimitChargeID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
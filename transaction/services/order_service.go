@@ -5,21 +5,88 @@ import (
 	"errors"
 	"transaction/models"
 	"transaction/repositories"
+	"wallet/accountnumber"
+	walletmodels "wallet/models"
+	walletservices "wallet/services"
 )
 
+var (
+	ErrOrderNotFound   = errors.New("order not found")
+	ErrNotOrderSeller  = errors.New("order belongs to another seller")
+	ErrOrderNotPending = errors.New("order is not pending")
+)
+
+// Wallets - операции кошелька, нужные сервису заказов. Интерфейс реализуют
+// walletclient.Client (HTTP клиент сервиса кошельков) и walletclient.Local
+// (сервисы кошельков в этом же процессе).
+type Wallets interface {
+	GetWalletByUserId(ctx context.Context, userID int) (*walletmodels.Wallet, error)
+	GetCurrency(ctx context.Context, code string) (*walletmodels.Currency, error)
+	Transfer(ctx context.Context, senderAccountNumber, receiverAccountNumber string, amount float64) (*walletmodels.Receipt, error)
+	PlaceHold(ctx context.Context, accountNumber string, amount float64, kind, reference string) (*walletmodels.Hold, error)
+	ReleaseHold(ctx context.Context, holdID int64) (*walletmodels.Hold, error)
+	CaptureHold(ctx context.Context, holdID int64, destinationAccountNumber string) (*walletmodels.Hold, error)
+	SettleTrade(ctx context.Context, holdID int64, destinationAccountNumber, payerAccountNumber, payeeAccountNumber string, paymentAmount float64) (*walletmodels.Receipt, error)
+	ChargeLimit(ctx context.Context, userID int, kind, currency string, amount float64, reference string) (*walletmodels.LimitCharge, error)
+	ReleaseLimit(ctx context.Context, chargeID int64) error
+}
+
 type OrderService struct {
-	orderRepo     *repositories.OrderRepository
-	walletService *WalletService
+	orderRepo *repositories.OrderRepository
+	wallets   Wallets
 }
 
-func NewOrderService(repo *repositories.OrderRepository, walletService *WalletService) *OrderService {
+func NewOrderService(repo *repositories.OrderRepository, wallets Wallets) *OrderService {
 	return &OrderService{
-		orderRepo:     repo,
-		walletService: walletService,
+		orderRepo: repo,
+		wallets:   wallets,
 	}
 }
 
 func (service *OrderService) CreateOrder(ctx context.Context, sellerID int, cryptocurrency string, amount, price float64, exchangeTo string) error {
+	// Продавать можно только включенную криптовалюту, получать оплату - в любой включенной валюте
+	crypto, err := service.requireCurrency(ctx, cryptocurrency)
+	if err != nil {
+		return err
+	}
+	if crypto.Kind != walletmodels.CurrencyCrypto {
+		return walletservices.ErrCryptocurrencyExpected
+	}
+	quote, err := service.requireCurrency(ctx, exchangeTo)
+	if err != nil {
+		return err
+	}
+	cryptocurrency, exchangeTo = crypto.Code, quote.Code
+
+	amount = crypto.Round(amount)
+	if amount <= 0 || price <= 0 {
+		return walletservices.ErrInvalidAmount
+	}
+
+	// Находим счет продавца в продаваемой криптовалюте
+	sellerWallet, err := service.wallets.GetWalletByUserId(ctx, sellerID)
+	if err != nil {
+		return err
+	}
+
+	sellerAccountNumber := findAccount(sellerWallet, cryptocurrency)
+	if sellerAccountNumber == "" {
+		return errors.New("seller has no account in the order currency")
+	}
+
+	// Объем заказа в валюте оплаты учитывается в лимитах продавца
+	charge, err := service.wallets.ChargeLimit(ctx, sellerID, walletmodels.LimitOrder, exchangeTo, quote.Round(price*amount), sellerAccountNumber)
+	if err != nil {
+		return err
+	}
+
+	// Резервируем продаваемую сумму, пока заказ открыт
+	hold, err := service.wallets.PlaceHold(ctx, sellerAccountNumber, amount, walletmodels.HoldOrder, "")
+	if err != nil {
+		_ = service.wallets.ReleaseLimit(ctx, charge.ID)
+		return err
+	}
+
 	// Создаем новый заказ
 	order := &models.Order{
 		SellerID:       sellerID,
@@ -28,11 +95,18 @@ func (service *OrderService) CreateOrder(ctx context.Context, sellerID int, cryp
 		Price:          price,
 		ExchangeTo:     exchangeTo,
 		Status:         "PENDING",
+		HoldID:         hold.ID,
+		LimitChargeID:  charge.ID,
 	}
 
 	// Добавляем заказ в базу данных
-	_, err := service.orderRepo.CreateOrder(ctx, order)
-	return err
+	_, err = service.orderRepo.CreateOrder(ctx, order)
+	if err != nil {
+		_, _ = service.wallets.ReleaseHold(ctx, hold.ID)
+		_ = service.wallets.ReleaseLimit(ctx, charge.ID)
+		return err
+	}
+	return nil
 }
 
 func (service *OrderService) FindOrders(ctx context.Context) ([]*models.Order, error) {
@@ -62,74 +136,153 @@ func (service *OrderService) FindOrdersBySellerUsername(ctx context.Context, use
 	return orders, nil
 }
 
-func (service *OrderService) PurchaseOrder(ctx context.Context, buyerID, orderID int) error {
+// PurchaseOrder покупает заказ и возвращает квитанцию об оплате с
+// удержанными комиссиями
+func (service *OrderService) PurchaseOrder(ctx context.Context, buyerID, orderID int) (*walletmodels.Receipt, error) {
 	// Получаем информацию о заказе
 	order, err := service.orderRepo.GetOrderByID(ctx, orderID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Проверяем, что заказ существует и его статус "PENDING"
 	if order == nil || order.Status != "PENDING" {
-		return errors.New("order is not available for purchase")
+		return nil, errors.New("order is not available for purchase")
 	}
 
 	// Получаем номера счетов продавца и покупателя
-	sellerWallet, err := service.walletService.GetUserWallet(ctx, order.SellerID)
+	sellerWallet, err := service.wallets.GetWalletByUserId(ctx, order.SellerID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	buyerWallet, err := service.walletService.GetUserWallet(ctx, buyerID)
+	buyerWallet, err := service.wallets.GetWalletByUserId(ctx, buyerID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	sellerAccountNumber := ""
-	buyerAccountNumber := ""
+	// Оплата идет со счета покупателя в валюте оплаты на такой же счет
+	// продавца, криптовалюта - между их счетами в криптовалюте
+	buyerPaymentAccount := findAccount(buyerWallet, order.ExchangeTo)
+	sellerPaymentAccount := findAccount(sellerWallet, order.ExchangeTo)
+	sellerCryptoAccount := findAccount(sellerWallet, order.Cryptocurrency)
+	buyerCryptoAccount := findAccount(buyerWallet, order.Cryptocurrency)
 
-	// Ищем счета продавца и покупателя
-	for _, account := range sellerWallet.Accounts {
-		if account[:3] == order.Cryptocurrency {
-			sellerAccountNumber = account
-			break
-		}
+	if buyerPaymentAccount == "" || sellerPaymentAccount == "" || sellerCryptoAccount == "" || buyerCryptoAccount == "" {
+		return nil, errors.New("appropriate accounts not found for transaction")
+	}
+
+	// Определяем сумму оплаты с точностью валюты оплаты
+	quote, err := service.wallets.GetCurrency(ctx, order.ExchangeTo)
+	if err != nil {
+		return nil, err
+	}
+	exchangeAmount := quote.Round(order.Price * order.Amount)
+
+	// Покупка учитывается в лимитах покупателя на заказы
+	charge, err := service.wallets.ChargeLimit(ctx, buyerID, walletmodels.LimitOrder, order.ExchangeTo, exchangeAmount, buyerPaymentAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	var receipt *walletmodels.Receipt
+	if order.HoldID != 0 {
+		// Оплата, списание резерва заказа и комиссия продавца за сделку
+		// проводятся кошельком одной транзакцией
+		receipt, err = service.wallets.SettleTrade(ctx, order.HoldID, buyerCryptoAccount, buyerPaymentAccount, sellerPaymentAccount, exchangeAmount)
+	} else {
+		receipt, err = service.purchaseWithoutHold(ctx, order, buyerPaymentAccount, sellerPaymentAccount, sellerCryptoAccount, buyerCryptoAccount, exchangeAmount)
+	}
+	if err != nil {
+		_ = service.wallets.ReleaseLimit(ctx, charge.ID)
+		return nil, err
+	}
+
+	// Обновляем статус заказа
+	order.Status = "COMPLETED"
+	err = service.orderRepo.UpdateOrder(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+
+	return receipt, nil
+}
+
+// CancelOrder снимает с торговой площадки открытый заказ продавца,
+// освобождает его резерв и возвращает объем заказа в лимиты продавца
+func (service *OrderService) CancelOrder(ctx context.Context, sellerID, orderID int) (*models.Order, error) {
+	order, err := service.orderRepo.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, ErrOrderNotFound
+	}
+	if order.SellerID != sellerID {
+		return nil, ErrNotOrderSeller
+	}
+	if order.Status != "PENDING" {
+		return nil, ErrOrderNotPending
 	}
 
-	for _, account := range buyerWallet.Accounts {
-		if account[:3] == order.ExchangeTo {
-			buyerAccountNumber = account
-			break
+	// Освобожденный резерв уже нельзя списать, поэтому одновременная
+	// покупка заказа не пройдет
+	if order.HoldID != 0 {
+		if _, err := service.wallets.ReleaseHold(ctx, order.HoldID); err != nil {
+			return nil, err
 		}
 	}
 
-	if sellerAccountNumber == "" || buyerAccountNumber == "" {
-		return errors.New("appropriate accounts not found for transaction")
+	order.Status = "CANCELLED"
+	if err := service.orderRepo.UpdateOrder(ctx, order); err != nil {
+		return nil, err
 	}
 
-	// Определяем количество валюты для обмена
-	exchangeAmount := order.Price * order.Amount
+	// Заказы, созданные до учета списаний, лимиты не занимали
+	if order.LimitChargeID != 0 {
+		_ = service.wallets.ReleaseLimit(ctx, order.LimitChargeID)
+	}
+	return order, nil
+}
 
-	// Выполняем перевод средств
-	err = service.walletService.Transfer(ctx, exchangeAmount, buyerAccountNumber, sellerAccountNumber)
+// purchaseWithoutHold проводит покупку заказа, созданного до появления
+// резервов, двумя обычными переводами с комиссией за перевод
+func (service *OrderService) purchaseWithoutHold(ctx context.Context, order *models.Order, buyerPaymentAccount, sellerPaymentAccount, sellerCryptoAccount, buyerCryptoAccount string, exchangeAmount float64) (*walletmodels.Receipt, error) {
+	receipt, err := service.wallets.Transfer(ctx, buyerPaymentAccount, sellerPaymentAccount, exchangeAmount)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Теперь переводим криптовалюту от продавца к покупателю
-	err = service.walletService.Transfer(ctx, order.Amount, sellerAccountNumber, buyerAccountNumber)
+	_, err = service.wallets.Transfer(ctx, sellerCryptoAccount, buyerCryptoAccount, order.Amount)
 	if err != nil {
-		// В случае ошибки возвращаем первый перевод
-		_ = service.walletService.Transfer(ctx, exchangeAmount, sellerAccountNumber, buyerAccountNumber)
-		return err
+		// В случае ошибки возвращаем оплату покупателю
+		_, _ = service.wallets.Transfer(ctx, sellerPaymentAccount, buyerPaymentAccount, exchangeAmount)
+		return nil, err
 	}
+	return receipt, nil
+}
 
-	// Обновляем статус заказа
-	order.Status = "COMPLETED"
-	err = service.orderRepo.UpdateOrder(ctx, order)
+// requireCurrency возвращает валюту, если она зарегистрирована и включена
+func (service *OrderService) requireCurrency(ctx context.Context, code string) (*walletmodels.Currency, error) {
+	currency, err := service.wallets.GetCurrency(ctx, code)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if !currency.Enabled {
+		return nil, walletservices.ErrCurrencyDisabled
 	}
+	return currency, nil
+}
 
-	return nil
+// findAccount возвращает номер счета кошелька в заданной валюте
+func findAccount(wallet *walletmodels.Wallet, currency string) string {
+	if wallet == nil {
+		return ""
+	}
+	for _, account := range wallet.Accounts {
+		if accountnumber.Currency(account) == currency {
+			return account
+		}
+	}
+	return ""
 }
@@ -0,0 +1,65 @@
+// Package alert hands problems found by background jobs to whoever is on
+// call.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+type Alert struct {
+	Source  string      `json:"source"`
+	Summary string      `json:"summary"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+type Hook interface {
+	Alert(ctx context.Context, alert Alert) error
+}
+
+// Webhook posts alerts as JSON to a URL, e.g. of a chat or paging service.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhook(url string) *Webhook {
+	return &Webhook{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (hook *Webhook) Alert(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := hook.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+// Log writes alerts to the log, for setups without a webhook.
+type Log struct{}
+
+func (Log) Alert(ctx context.Context, alert Alert) error {
+	log.Printf("ALERT %s: %s", alert.Source, alert.Summary)
+	return nil
+}
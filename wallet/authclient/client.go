@@ -0,0 +1,60 @@
+// Package authclient looks users up in the auth service.
+package authclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"wallet/models"
+	"wallet/services"
+)
+
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// New returns a client of the auth service at baseURL. The token
+// authenticates the wallet service to it.
+func New(baseURL, token string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// LookupUser finds the user by username or email. Unknown users are
+// services.ErrRecipientNotFound.
+func (c *Client) LookupUser(ctx context.Context, identifier string) (*models.DirectoryUser, error) {
+	endpoint := c.baseURL + "/users/lookup?identifier=" + url.QueryEscape(identifier)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("auth service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, services.ErrRecipientNotFound
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("auth service: %s", resp.Status)
+	}
+
+	var user models.DirectoryUser
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("auth service: %w", err)
+	}
+	return &user, nil
+}
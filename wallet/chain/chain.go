@@ -0,0 +1,36 @@
+// Package chain is the wallet's view of a blockchain: blocks with the
+// outputs they pay. Implementations talk to a node or an indexer; Simulated
+// keeps a chain in memory for tests and local setups.
+package chain
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrBlockNotFound = errors.New("block not found")
+
+// Output is a payment to an address, identified by its transaction and
+// output index. Amount is in whole coins.
+type Output struct {
+	TxID    string  `json:"txid"`
+	Vout    uint32  `json:"vout"`
+	Address string  `json:"address"`
+	Amount  float64 `json:"amount"`
+}
+
+type Block struct {
+	Height     int64    `json:"height"`
+	Hash       string   `json:"hash"`
+	ParentHash string   `json:"parent_hash"`
+	Outputs    []Output `json:"outputs"`
+}
+
+// Client reads the chain of one currency.
+type Client interface {
+	// TipHeight returns the height of the best block.
+	TipHeight(ctx context.Context) (int64, error)
+	// BlockByHeight returns the block at height on the best chain, or
+	// ErrBlockNotFound above the tip.
+	BlockByHeight(ctx context.Context, height int64) (*Block, error)
+}
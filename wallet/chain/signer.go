@@ -0,0 +1,51 @@
+package chain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// Payment is an outgoing payment to an external address.
+type Payment struct {
+	Currency string
+	// Reference identifies the payment, the same reference always yields
+	// the same transaction.
+	Reference string
+	Address   string
+	Amount    float64
+}
+
+type SignedTransaction struct {
+	TxID string
+	Raw  []byte
+}
+
+// Signer signs payments out of the hot wallet. Implementations hold the
+// private keys, usually in an HSM or a separate signing service.
+type Signer interface {
+	Sign(ctx context.Context, payment Payment) (*SignedTransaction, error)
+}
+
+// Broadcaster sends signed transactions to the network of one currency and
+// follows them.
+type Broadcaster interface {
+	Broadcast(ctx context.Context, tx *SignedTransaction) error
+	// Confirmations returns how many blocks include the transaction, 0 while
+	// it waits in the mempool.
+	Confirmations(ctx context.Context, txid string) (int64, error)
+}
+
+// FakeSigner "signs" a payment by serializing it. The transaction id is the
+// hash of that, so signing is deterministic.
+type FakeSigner struct{}
+
+func (FakeSigner) Sign(ctx context.Context, payment Payment) (*SignedTransaction, error) {
+	raw := []byte(fmt.Sprintf("%s|%s|%s|%v", payment.Currency, payment.Reference, payment.Address, payment.Amount))
+	sum := sha256.Sum256(raw)
+	return &SignedTransaction{TxID: hex.EncodeToString(sum[:]), Raw: raw}, nil
+}
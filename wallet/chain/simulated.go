@@ -0,0 +1,150 @@
+package chain
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+)
+
+var ErrReorgTooDeep = errors.New("reorg is deeper than the chain")
+
+// Simulated is an in-memory chain that only moves when told to. It starts
+// with a genesis block at height 0. It is also a Broadcaster: broadcast
+// transactions wait in the mempool and go into the next mined block.
+type Simulated struct {
+	mu      sync.Mutex
+	blocks  []*Block
+	mempool []*SignedTransaction
+}
+
+func NewSimulated() *Simulated {
+	return &Simulated{blocks: []*Block{{Height: 0, Hash: randomHash()}}}
+}
+
+func (s *Simulated) TipHeight(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(len(s.blocks) - 1), nil
+}
+
+func (s *Simulated) BlockByHeight(ctx context.Context, height int64) (*Block, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if height < 0 || height >= int64(len(s.blocks)) {
+		return nil, ErrBlockNotFound
+	}
+	block := *s.blocks[height]
+	block.Outputs = append([]Output(nil), block.Outputs...)
+	return &block, nil
+}
+
+// Mine appends a block paying the outputs and including the mempool.
+// Outputs without a TxID get a random one.
+func (s *Simulated) Mine(outputs ...Output) *Block {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mine(outputs)
+}
+
+// Reorg replaces the last depth blocks with depth new empty blocks, as if a
+// competing chain had won. Outputs of the dropped blocks that should survive
+// have to be mined again; broadcast transactions go back to the mempool.
+func (s *Simulated) Reorg(depth int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if depth < 1 || depth >= len(s.blocks) {
+		return ErrReorgTooDeep
+	}
+
+	var dropped []*SignedTransaction
+	for _, block := range s.blocks[len(s.blocks)-depth:] {
+		for _, output := range block.Outputs {
+			if output.Address == "" {
+				dropped = append(dropped, &SignedTransaction{TxID: output.TxID})
+			}
+		}
+	}
+
+	s.blocks = s.blocks[:len(s.blocks)-depth]
+	for i := 0; i < depth; i++ {
+		s.mine(nil)
+	}
+	s.mempool = append(dropped, s.mempool...)
+	return nil
+}
+
+func (s *Simulated) Broadcast(ctx context.Context, tx *SignedTransaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.find(tx.TxID); ok {
+		return nil
+	}
+	for _, pending := range s.mempool {
+		if pending.TxID == tx.TxID {
+			return nil
+		}
+	}
+	s.mempool = append(s.mempool, tx)
+	return nil
+}
+
+func (s *Simulated) Confirmations(ctx context.Context, txid string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if height, ok := s.find(txid); ok {
+		return int64(len(s.blocks)) - height, nil
+	}
+	for _, pending := range s.mempool {
+		if pending.TxID == txid {
+			return 0, nil
+		}
+	}
+	return 0, ErrTransactionNotFound
+}
+
+// find returns the height of the block including the transaction.
+func (s *Simulated) find(txid string) (int64, bool) {
+	for _, block := range s.blocks {
+		for _, output := range block.Outputs {
+			if output.TxID == txid {
+				return block.Height, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (s *Simulated) mine(outputs []Output) *Block {
+	tip := s.blocks[len(s.blocks)-1]
+	block := &Block{
+		Height:     tip.Height + 1,
+		Hash:       randomHash(),
+		ParentHash: tip.Hash,
+		Outputs:    make([]Output, 0, len(outputs)+len(s.mempool)),
+	}
+	for _, output := range outputs {
+		if output.TxID == "" {
+			output.TxID = randomHash()
+		}
+		block.Outputs = append(block.Outputs, output)
+	}
+	// Outgoing payments pay an address outside the wallet, only the
+	// transaction id matters here.
+	for _, tx := range s.mempool {
+		block.Outputs = append(block.Outputs, Output{TxID: tx.TxID})
+	}
+	s.mempool = nil
+	s.blocks = append(s.blocks, block)
+
+	mined := *block
+	mined.Outputs = append([]Output(nil), block.Outputs...)
+	return &mined
+}
+
+func randomHash() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
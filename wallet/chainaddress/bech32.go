@@ -0,0 +1,99 @@
+package chainaddress
+
+import "strings"
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var bech32Generator = [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= bech32Generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+// bech32Encode encodes 5 bit groups with the bech32 checksum.
+func bech32Encode(hrp string, data []byte) string {
+	values := append(bech32HRPExpand(hrp), data...)
+	polymod := bech32Polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ 1
+
+	out := make([]byte, 0, len(hrp)+1+len(data)+6)
+	out = append(out, hrp...)
+	out = append(out, '1')
+	for _, d := range data {
+		out = append(out, bech32Charset[d])
+	}
+	for i := 0; i < 6; i++ {
+		out = append(out, bech32Charset[(polymod>>uint(5*(5-i)))&31])
+	}
+	return string(out)
+}
+
+// convertBits regroups bytes of fromBits bits into groups of toBits bits,
+// padding the last group with zeros.
+func convertBits(data []byte, fromBits, toBits uint) []byte {
+	var acc, bits uint
+	maxv := uint(1)<<toBits - 1
+	out := make([]byte, 0, len(data)*int(fromBits)/int(toBits)+1)
+	for _, b := range data {
+		acc = acc<<fromBits | uint(b)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+	if bits > 0 {
+		out = append(out, byte(acc<<(toBits-bits)&maxv))
+	}
+	return out
+}
+
+// bech32Decode splits a bech32 string into its human readable part and 5 bit
+// data groups, without the checksum.
+func bech32Decode(s string) (string, []byte, error) {
+	if len(s) > 90 || (strings.ToLower(s) != s && strings.ToUpper(s) != s) {
+		return "", nil, ErrInvalidAddress
+	}
+	s = strings.ToLower(s)
+
+	separator := strings.LastIndexByte(s, '1')
+	if separator < 1 || separator+7 > len(s) {
+		return "", nil, ErrInvalidAddress
+	}
+
+	hrp := s[:separator]
+	data := make([]byte, 0, len(s)-separator-1)
+	for i := separator + 1; i < len(s); i++ {
+		d := strings.IndexByte(bech32Charset, s[i])
+		if d < 0 {
+			return "", nil, ErrInvalidAddress
+		}
+		data = append(data, byte(d))
+	}
+
+	if bech32Polymod(append(bech32HRPExpand(hrp), data...)) != 1 {
+		return "", nil, ErrInvalidAddress
+	}
+	return hrp, data[:len(data)-6], nil
+}
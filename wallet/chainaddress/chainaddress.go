@@ -0,0 +1,120 @@
+// Package chainaddress turns public keys into on-chain receive addresses:
+// native segwit (bech32, BIP173) addresses for bitcoin and checksummed
+// (EIP-55) addresses for ethereum. It also checks addresses of others,
+// including legacy base58check bitcoin addresses.
+package chainaddress
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"wallet/hdkey"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"golang.org/x/crypto/sha3"
+)
+
+var ErrInvalidAddress = errors.New("invalid address")
+
+// Human readable parts of bitcoin bech32 addresses.
+const (
+	BitcoinMainnet = "bc"
+	BitcoinTestnet = "tb"
+)
+
+// Bitcoin returns the pay-to-witness-public-key-hash address of the key.
+func Bitcoin(key *secp256k1.PublicKey, hrp string) string {
+	program := hdkey.Hash160(key.SerializeCompressed())
+	// Witness version 0 followed by the program in 5 bit groups.
+	data := append([]byte{0}, convertBits(program, 8, 5)...)
+	return bech32Encode(hrp, data)
+}
+
+// Ethereum returns the EIP-55 checksummed address of the key.
+func Ethereum(key *secp256k1.PublicKey) string {
+	// The address is the last 20 bytes of the Keccak-256 hash of X and Y.
+	hash := keccak256(key.SerializeUncompressed()[1:])
+	return checksummed(hash[12:])
+}
+
+func checksummed(raw []byte) string {
+	address := hex.EncodeToString(raw)
+
+	// A letter is upper case if the matching nibble of the hash of the
+	// lower case address is 8 or more.
+	checksum := keccak256([]byte(address))
+	var b strings.Builder
+	b.WriteString("0x")
+	for i, c := range address {
+		nibble := checksum[i/2]
+		if i%2 == 0 {
+			nibble >>= 4
+		}
+		if c >= 'a' && nibble&0x0f >= 8 {
+			c -= 'a' - 'A'
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// Version bytes of legacy pay-to-public-key-hash and pay-to-script-hash
+// addresses per bech32 human readable part of the same network.
+var base58Versions = map[string][2]byte{
+	BitcoinMainnet: {0x00, 0x05},
+	BitcoinTestnet: {0x6f, 0xc4},
+}
+
+// NormalizeBitcoin checks a bitcoin address of the network given by hrp and
+// returns it in canonical form, native segwit addresses in lower case.
+// Witness version 0 programs are 20 or 32 bytes long, legacy base58check
+// addresses hash a key or a script.
+func NormalizeBitcoin(address, hrp string) (string, error) {
+	if versions, ok := base58Versions[hrp]; ok {
+		payload, err := hdkey.DecodeBase58Check(address)
+		if err == nil && len(payload) == 21 && (payload[0] == versions[0] || payload[0] == versions[1]) {
+			return address, nil
+		}
+	}
+
+	addressHRP, data, err := bech32Decode(address)
+	if err != nil || addressHRP != hrp || len(data) < 1 {
+		return "", ErrInvalidAddress
+	}
+
+	// Version 1 and above use bech32m, which is not supported yet.
+	version, program := data[0], convertBits(data[1:], 5, 8)
+	if version != 0 || len(data[1:])*5%8 >= 5 {
+		return "", ErrInvalidAddress
+	}
+	program = program[:len(data[1:])*5/8]
+	if len(program) != 20 && len(program) != 32 {
+		return "", ErrInvalidAddress
+	}
+	return strings.ToLower(address), nil
+}
+
+// NormalizeEthereum checks a 0x prefixed address and returns it with its
+// EIP-55 checksum. Mixed case addresses must already carry a valid one.
+func NormalizeEthereum(address string) (string, error) {
+	if len(address) != 42 || !strings.HasPrefix(address, "0x") {
+		return "", ErrInvalidAddress
+	}
+	raw, err := hex.DecodeString(address[2:])
+	if err != nil {
+		return "", ErrInvalidAddress
+	}
+
+	digits := address[2:]
+	mixed := digits != strings.ToLower(digits) && digits != strings.ToUpper(digits)
+	if mixed && checksummed(raw) != address {
+		return "", ErrInvalidAddress
+	}
+	return checksummed(raw), nil
+}
+
+func keccak256(data []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(data)
+	return h.Sum(nil)
+}
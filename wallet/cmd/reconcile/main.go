@@ -0,0 +1,53 @@
+// Command reconcile runs a single reconciliation of the wallet ledger, prints
+// the report as JSON and exits with status 2 if it found discrepancies.
+//
+// Chain holdings are checked by the reconciliation job of the wallet
+// service, which owns the chain clients; this command checks the balances
+// against their movements and holds.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"os"
+	"wallet/alert"
+	"wallet/chain"
+	"wallet/repositories"
+	"wallet/services"
+
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	connStr := "user=username dbname=walletdb sslmode=disable"
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	var alerts alert.Hook = alert.Log{}
+	if alertURL := os.Getenv("RECONCILIATION_ALERT_URL"); alertURL != "" {
+		alerts = alert.NewWebhook(alertURL)
+	}
+
+	currencyService := services.NewCurrencyService(repositories.NewCurrencyRepository(db))
+	reconciliationService := services.NewReconciliationService(repositories.NewReconciliationRepository(db), currencyService,
+		map[string]chain.Client{}, map[string]chain.Broadcaster{}, alerts)
+
+	report, err := reconciliationService.Reconcile(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatal(err)
+	}
+	if report.Discrepancies > 0 {
+		os.Exit(2)
+	}
+}
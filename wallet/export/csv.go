@@ -0,0 +1,59 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+	"wallet/models"
+)
+
+// WriteStatementCSV writes the statement as a single table. Every period
+// starts with an "opening" row and ends with a "closing" row, the movements
+// of the period are listed in between.
+func WriteStatementCSV(w io.Writer, statement *models.Statement) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{"period_start", "period_end", "record", "date", "type", "amount", "balance", "counterparty"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, period := range statement.Periods {
+		start := period.Start.Format(time.RFC3339)
+		end := period.End.Format(time.RFC3339)
+
+		err := writer.Write([]string{start, end, "opening", start, "", "", formatAmount(period.OpeningBalance, statement.Decimals), ""})
+		if err != nil {
+			return err
+		}
+
+		for _, movement := range period.Movements {
+			err := writer.Write([]string{
+				start,
+				end,
+				"movement",
+				movement.CreatedAt.Format(time.RFC3339),
+				movement.Type,
+				formatAmount(movement.Amount, statement.Decimals),
+				formatAmount(movement.BalanceAfter, statement.Decimals),
+				movement.Counterparty,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		err = writer.Write([]string{start, end, "closing", end, "", "", formatAmount(period.ClosingBalance, statement.Decimals), ""})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatAmount(amount float64, decimals int) string {
+	return strconv.FormatFloat(amount, 'f', decimals, 64)
+}
@@ -0,0 +1,125 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"wallet/models"
+)
+
+const (
+	pdfLinesPerPage = 52
+	pdfFontSize     = 10
+	pdfLeading      = 14
+	pdfMarginLeft   = 50
+	pdfMarginTop    = 800
+)
+
+// WriteStatementPDF renders the statement as a plain text A4 document. The
+// document only uses the standard Helvetica font, so no font files need to
+// be embedded.
+func WriteStatementPDF(w io.Writer, statement *models.Statement) error {
+	lines := []string{
+		"Account statement",
+		"Account: " + statement.AccountNumber,
+		"Currency: " + statement.Currency,
+		fmt.Sprintf("Period: %s - %s", statement.From.Format("2006-01-02"), statement.To.Format("2006-01-02")),
+		"Opening balance: " + formatAmount(statement.OpeningBalance, statement.Decimals),
+		"Closing balance: " + formatAmount(statement.ClosingBalance, statement.Decimals),
+	}
+
+	for _, period := range statement.Periods {
+		lines = append(lines,
+			"",
+			fmt.Sprintf("%s - %s", period.Start.Format("2006-01-02"), period.End.Format("2006-01-02")),
+			fmt.Sprintf("  Opening balance: %s", formatAmount(period.OpeningBalance, statement.Decimals)),
+		)
+		for _, movement := range period.Movements {
+			line := fmt.Sprintf("  %s  %-12s  %14s  %14s",
+				movement.CreatedAt.Format("2006-01-02 15:04"),
+				movement.Type,
+				formatAmount(movement.Amount, statement.Decimals),
+				formatAmount(movement.BalanceAfter, statement.Decimals),
+			)
+			if movement.Counterparty != "" {
+				line += "  " + movement.Counterparty
+			}
+			lines = append(lines, line)
+		}
+		lines = append(lines,
+			fmt.Sprintf("  Credits: %s  Debits: %s", formatAmount(period.Credits, statement.Decimals), formatAmount(period.Debits, statement.Decimals)),
+			fmt.Sprintf("  Closing balance: %s", formatAmount(period.ClosingBalance, statement.Decimals)),
+		)
+	}
+
+	var pages [][]string
+	for len(lines) > pdfLinesPerPage {
+		pages = append(pages, lines[:pdfLinesPerPage])
+		lines = lines[pdfLinesPerPage:]
+	}
+	pages = append(pages, lines)
+
+	_, err := w.Write(renderPDF(pages))
+	return err
+}
+
+// renderPDF lays out objects as: 1 catalog, 2 page tree, 3 font, then a
+// page object and its content stream for every page.
+func renderPDF(pages [][]string) []byte {
+	var buf bytes.Buffer
+	var offsets []int
+
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+
+	for i, lines := range pages {
+		var content bytes.Buffer
+		fmt.Fprintf(&content, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", pdfFontSize, pdfLeading, pdfMarginLeft, pdfMarginTop)
+		for _, line := range lines {
+			fmt.Fprintf(&content, "(%s) Tj T*\n", escapePDFText(line))
+		}
+		content.WriteString("ET")
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", 5+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.Bytes()
+}
+
+func escapePDFText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteRune('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
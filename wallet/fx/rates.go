@@ -0,0 +1,124 @@
+// Package fx provides exchange rates used to value balances held in
+// different currencies.
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"wallet/models"
+)
+
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+// RateProvider returns the rate to convert one unit of base into quote.
+type RateProvider interface {
+	Rate(ctx context.Context, base, quote string) (models.Rate, error)
+}
+
+// StaticRates serves a fixed set of rates. A rate for BASE/QUOTE also
+// answers QUOTE/BASE with the inverse value.
+type StaticRates struct {
+	rates map[string]float64
+	asOf  time.Time
+}
+
+// NewStaticRates takes rates keyed by "BASE/QUOTE", e.g. "BTC/USD".
+func NewStaticRates(rates map[string]float64) *StaticRates {
+	normalized := make(map[string]float64, len(rates))
+	for pair, value := range rates {
+		normalized[strings.ToUpper(pair)] = value
+	}
+	return &StaticRates{rates: normalized, asOf: time.Now().UTC()}
+}
+
+// LoadStaticRates reads rates from a JSON file of the form
+// {"BTC/USD": 65000, "EUR/USD": 1.08}.
+func LoadStaticRates(path string) (*StaticRates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates map[string]float64
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("parse rates file %s: %w", path, err)
+	}
+	return NewStaticRates(rates), nil
+}
+
+func (s *StaticRates) Rate(ctx context.Context, base, quote string) (models.Rate, error) {
+	base, quote = strings.ToUpper(base), strings.ToUpper(quote)
+	rate := models.Rate{Base: base, Quote: quote, AsOf: s.asOf}
+
+	switch {
+	case base == quote:
+		rate.Value = 1
+	case s.rates[base+"/"+quote] > 0:
+		rate.Value = s.rates[base+"/"+quote]
+	case s.rates[quote+"/"+base] > 0:
+		rate.Value = 1 / s.rates[quote+"/"+base]
+	default:
+		return models.Rate{}, fmt.Errorf("%w: %s/%s", ErrRateNotFound, base, quote)
+	}
+	return rate, nil
+}
+
+// CachedRates keeps the rates fetched from another provider for ttl. The
+// rate returned keeps the AsOf time of the original fetch, so callers can
+// report exactly which quote they used.
+type CachedRates struct {
+	provider RateProvider
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	rates map[string]cachedRate
+}
+
+type cachedRate struct {
+	rate      models.Rate
+	fetchedAt time.Time
+}
+
+func NewCachedRates(provider RateProvider, ttl time.Duration) *CachedRates {
+	return &CachedRates{provider: provider, ttl: ttl, rates: make(map[string]cachedRate)}
+}
+
+func (c *CachedRates) Rate(ctx context.Context, base, quote string) (models.Rate, error) {
+	pair := strings.ToUpper(base + "/" + quote)
+
+	c.mu.Lock()
+	cached, ok := c.rates[pair]
+	c.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < c.ttl {
+		return cached.rate, nil
+	}
+
+	rate, err := c.provider.Rate(ctx, base, quote)
+	if err != nil {
+		return models.Rate{}, err
+	}
+
+	c.mu.Lock()
+	c.rates[pair] = cachedRate{rate: rate, fetchedAt: time.Now()}
+	c.mu.Unlock()
+
+	return rate, nil
+}
+
+// Recorded returns every rate currently held by the cache.
+func (c *CachedRates) Recorded() []models.Rate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rates := make([]models.Rate, 0, len(c.rates))
+	for _, cached := range c.rates {
+		rates = append(rates, cached.rate)
+	}
+	return rates
+}
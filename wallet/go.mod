@@ -2,4 +2,11 @@ module wallet
 
 go 1.22
 
-require github.com/lib/pq v1.10.9
+require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.3.0
+	github.com/lib/pq v1.10.9
+	golang.org/x/crypto v0.24.0
+)
+
+require golang.org/x/sys v0.21.0 // indirect
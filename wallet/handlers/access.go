@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"wallet/models"
+	"wallet/services"
+)
+
+// Who acts on a wallet, set by the gateway that authenticated the request.
+// Requests without either header act for the owner, as they did before
+// grants existed.
+const (
+	ActingUserHeader = "X-Acting-User"
+	APIKeyHeader     = "X-API-Key"
+)
+
+var (
+	errInvalidActingUser = errors.New("invalid " + ActingUserHeader + " header")
+	errInvalidResourceID = errors.New("invalid id")
+)
+
+// AccountScope lets the request through if its principal may use the scope
+// on the wallet owning the {number} account.
+func AccountScope(grantService *services.GrantService, scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authorizeAccount(w, r, grantService, r.PathValue("number"), scope, next)
+	}
+}
+
+// BodyAccountScope is AccountScope for the account named by field in the
+// JSON body. The body is left for next to read.
+func BodyAccountScope(grantService *services.GrantService, scope, field string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var fields map[string]json.RawMessage
+		var accountNumber string
+		if json.Unmarshal(body, &fields) == nil {
+			json.Unmarshal(fields[field], &accountNumber)
+		}
+		authorizeAccount(w, r, grantService, accountNumber, scope, next)
+	}
+}
+
+// UserScope lets the request through if its principal may use the scope on
+// the wallet of the {user_id} in the path, or the user_id query parameter.
+func UserScope(grantService *services.GrantService, scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		value := r.PathValue("user_id")
+		if value == "" {
+			value = r.URL.Query().Get("user_id")
+		}
+		ownerID, err := strconv.Atoi(value)
+		authorizeUser(w, r, grantService, ownerID, err == nil, scope, next)
+	}
+}
+
+// BodyUserScope is UserScope for the user_id in the JSON body. The body is
+// left for next to read.
+func BodyUserScope(grantService *services.GrantService, scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var fields struct {
+			UserID *int `json:"user_id"`
+		}
+		json.Unmarshal(body, &fields)
+		ownerID := 0
+		if fields.UserID != nil {
+			ownerID = *fields.UserID
+		}
+		authorizeUser(w, r, grantService, ownerID, fields.UserID != nil, scope, next)
+	}
+}
+
+// ResourceAccounts returns the accounts the resource in the path belongs to.
+type ResourceAccounts func(r *http.Request) ([]string, error)
+
+// ResourceScope is AccountScope for a resource addressed by its id, like a
+// hold or a withdrawal. The principal needs the scope on one of the accounts
+// the resource belongs to.
+func ResourceScope(grantService *services.GrantService, scope string, accounts ResourceAccounts, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := RequestPrincipal(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if principal == nil {
+			next(w, r)
+			return
+		}
+
+		accountNumbers, err := accounts(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		for _, accountNumber := range accountNumbers {
+			err := grantService.AuthorizeAccount(r.Context(), principal, accountNumber, scope)
+			if err == nil {
+				next(w, r)
+				return
+			}
+			if !errors.Is(err, services.ErrAccessDenied) {
+				writeError(w, err)
+				return
+			}
+		}
+		writeError(w, services.ErrAccessDenied)
+	}
+}
+
+func authorizeUser(w http.ResponseWriter, r *http.Request, grantService *services.GrantService, ownerID int, valid bool, scope string, next http.HandlerFunc) {
+	principal, err := RequestPrincipal(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if principal == nil {
+		next(w, r)
+		return
+	}
+	if !valid {
+		http.Error(w, "invalid user_id", http.StatusBadRequest)
+		return
+	}
+
+	if err := grantService.Authorize(r.Context(), principal, ownerID, scope); err != nil {
+		writeError(w, err)
+		return
+	}
+	next(w, r)
+}
+
+func authorizeAccount(w http.ResponseWriter, r *http.Request, grantService *services.GrantService, accountNumber, scope string, next http.HandlerFunc) {
+	principal, err := RequestPrincipal(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := grantService.AuthorizeAccount(r.Context(), principal, accountNumber, scope); err != nil {
+		writeError(w, err)
+		return
+	}
+	next(w, r)
+}
+
+// RequestPrincipal returns who acts, or nil if the request does not say.
+func RequestPrincipal(r *http.Request) (*models.Principal, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return &models.Principal{APIKey: key}, nil
+	}
+	value := r.Header.Get(ActingUserHeader)
+	if value == "" {
+		return nil, nil
+	}
+	userID, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, errInvalidActingUser
+	}
+	return &models.Principal{UserID: userID}, nil
+}
+
+// pathID parses the {id} of a resource in the path.
+func pathID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return 0, errInvalidResourceID
+	}
+	return id, nil
+}
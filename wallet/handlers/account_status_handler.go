@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"wallet/accountnumber"
+	"wallet/models"
+	"wallet/services"
+)
+
+type AccountStatusRequest struct {
+	Reason string `json:"reason"`
+}
+
+// FreezeAccountHandler blocks all money movements on an account.
+func FreezeAccountHandler(walletService *services.WalletService) http.HandlerFunc {
+	return accountStatusHandler(walletService.FreezeAccount)
+}
+
+// UnfreezeAccountHandler makes a frozen account active again.
+func UnfreezeAccountHandler(walletService *services.WalletService) http.HandlerFunc {
+	return accountStatusHandler(walletService.UnfreezeAccount)
+}
+
+// CloseAccountHandler closes an account with a zero balance for good.
+func CloseAccountHandler(walletService *services.WalletService) http.HandlerFunc {
+	return accountStatusHandler(walletService.CloseAccount)
+}
+
+func accountStatusHandler(change func(ctx context.Context, accountNumber, reason string) (*models.Account, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountNumber, err := accountnumber.Parse(r.PathValue("number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		var req AccountStatusRequest
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		account, err := change(r.Context(), accountNumber, req.Reason)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(account)
+	}
+}
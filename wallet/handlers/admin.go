@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var errInvalidAdmins = errors.New("admins must be a comma separated list of name:token")
+
+type adminKey struct{}
+
+// AdminOnly protects administrative endpoints with a static bearer token.
+// An empty token disables the endpoints altogether.
+func AdminOnly(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			http.Error(w, "admin authorization required", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// NamedAdminOnly is AdminOnly for endpoints that record which admin acted.
+// Every admin has their own token, admins maps the tokens to the names.
+func NamedAdminOnly(admins map[string]string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		name := ""
+		for token, admin := range admins {
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 {
+				name = admin
+			}
+		}
+		if name == "" {
+			http.Error(w, "admin authorization required", http.StatusUnauthorized)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), adminKey{}, name)))
+	}
+}
+
+// RequestAdmin returns the name of the admin NamedAdminOnly let through.
+func RequestAdmin(r *http.Request) string {
+	name, _ := r.Context().Value(adminKey{}).(string)
+	return name
+}
+
+// ParseAdmins reads admins in the form name:token,name:token and returns
+// the names by token.
+func ParseAdmins(value string) (map[string]string, error) {
+	admins := make(map[string]string)
+	if value == "" {
+		return admins, nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		name, token, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || name == "" || token == "" {
+			return nil, errInvalidAdmins
+		}
+		if _, exists := admins[token]; exists {
+			return nil, errInvalidAdmins
+		}
+		admins[token] = name
+	}
+	return admins, nil
+}
@@ -1,10 +1,10 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"wallet/accountnumber"
 	"wallet/services"
 )
 
@@ -14,7 +14,9 @@ type CreateAccountRequest struct {
 }
 
 // BalanceHandler handles the request for getting the wallet balance of a user.
-func BalanceHandler(service *services.WalletService) http.HandlerFunc {
+// Every account is returned with its balance, the total is valued in the
+// currency given by the quote query parameter (USD by default).
+func BalanceHandler(service *services.ValuationService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID := r.URL.Query().Get("user_id")
 		if userID == "" {
@@ -28,11 +30,37 @@ func BalanceHandler(service *services.WalletService) http.HandlerFunc {
 			return
 		}
 
-		wallet, err := service.GetWalletByUserId(context.Background(), id)
+		valuation, err := service.ValueWallet(r.Context(), id, r.URL.Query().Get("quote"))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(valuation)
+	}
+}
+
+// WalletHandler returns the account numbers of a user's wallet.
+func WalletHandler(service *services.WalletService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("user_id"))
+		if err != nil {
+			http.Error(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+
+		wallet, err := service.GetWalletByUserId(r.Context(), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if wallet == nil {
+			writeError(w, services.ErrWalletNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(wallet)
 	}
 }
@@ -51,9 +79,15 @@ func UpdateBalanceHandler(walletService *services.WalletService) http.HandlerFun
 			return
 		}
 
-		err = walletService.Deposit(context.Background(), req.AccountNumber, req.Amount)
+		accountNumber, err := accountnumber.Parse(req.AccountNumber)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		err = walletService.Deposit(r.Context(), accountNumber, req.Amount)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -70,9 +104,9 @@ func CreateAccountHandler(walletService *services.WalletService) http.HandlerFun
 			return
 		}
 
-		account, err := walletService.CreateAccount(context.Background(), req.UserID, req.Currency)
+		account, err := walletService.CreateAccount(r.Context(), req.UserID, req.Currency)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+	"wallet/accountnumber"
+	"wallet/services"
+)
+
+// ListBalanceSnapshotsHandler returns the end of day balances of an account
+// for the dates ?from= through ?to=.
+func ListBalanceSnapshotsHandler(service *services.BalanceSnapshotService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountNumber, err := accountnumber.Parse(r.PathValue("number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		from, err := time.Parse("2006-01-02", r.URL.Query().Get("from"))
+		if err != nil {
+			http.Error(w, "from must be a date", http.StatusBadRequest)
+			return
+		}
+		to, err := time.Parse("2006-01-02", r.URL.Query().Get("to"))
+		if err != nil {
+			http.Error(w, "to must be a date", http.StatusBadRequest)
+			return
+		}
+
+		series, err := service.ListSnapshots(r.Context(), accountNumber, from, to)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(series)
+	}
+}
+
+// SnapshotBalancesHandler takes the snapshots of ?date= again, e.g. after
+// the job missed it. Accounts that have one already keep it.
+func SnapshotBalancesHandler(service *services.BalanceSnapshotService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		day, err := time.Parse("2006-01-02", r.URL.Query().Get("date"))
+		if err != nil {
+			http.Error(w, "date must be a date", http.StatusBadRequest)
+			return
+		}
+
+		count, err := service.SnapshotDay(r.Context(), day)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"date": day.Format("2006-01-02"), "accounts": count})
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"wallet/accountnumber"
+	"wallet/chain"
+	"wallet/services"
+)
+
+type MineBlockRequest struct {
+	Outputs []chain.Output `json:"outputs"`
+}
+
+type ReorgRequest struct {
+	Depth int `json:"depth"`
+}
+
+// ListChainDepositsHandler returns the on-chain deposits to the account with
+// their confirmations.
+func ListChainDepositsHandler(watcher *services.DepositWatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountNumber, err := accountnumber.Parse(r.PathValue("number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		deposits, err := watcher.ListDeposits(r.Context(), accountNumber)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(deposits)
+	}
+}
+
+// MineBlockHandler appends a block with the given outputs to a simulated
+// chain.
+func MineBlockHandler(chains map[string]*chain.Simulated) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		simulated, ok := chains[strings.ToUpper(r.PathValue("currency"))]
+		if !ok {
+			http.Error(w, "no simulated chain for this currency", http.StatusNotFound)
+			return
+		}
+
+		var req MineBlockRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+
+		block := simulated.Mine(req.Outputs...)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(block)
+	}
+}
+
+// ReorgHandler replaces the last blocks of a simulated chain.
+func ReorgHandler(chains map[string]*chain.Simulated) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		simulated, ok := chains[strings.ToUpper(r.PathValue("currency"))]
+		if !ok {
+			http.Error(w, "no simulated chain for this currency", http.StatusNotFound)
+			return
+		}
+
+		var req ReorgRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := simulated.Reorg(req.Depth); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"wallet/models"
+	"wallet/services"
+)
+
+// ListCurrenciesHandler returns the enabled currencies, all=true includes the
+// disabled ones as well.
+func ListCurrenciesHandler(currencyService *services.CurrencyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currencies, err := currencyService.ListCurrencies(r.Context(), r.URL.Query().Get("all") == "true")
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(currencies)
+	}
+}
+
+func GetCurrencyHandler(currencyService *services.CurrencyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		currency, err := currencyService.GetCurrency(r.Context(), r.PathValue("code"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(currency)
+	}
+}
+
+func CreateCurrencyHandler(currencyService *services.CurrencyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var currency models.Currency
+		if err := json.NewDecoder(r.Body).Decode(&currency); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := currencyService.CreateCurrency(r.Context(), &currency); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(currency)
+	}
+}
+
+// UpdateCurrencyHandler replaces the settings of a currency, e.g. to disable
+// it. The code in the path wins over the one in the body.
+func UpdateCurrencyHandler(currencyService *services.CurrencyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var currency models.Currency
+		if err := json.NewDecoder(r.Body).Decode(&currency); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		currency.Code = r.PathValue("code")
+
+		if err := currencyService.UpdateCurrency(r.Context(), &currency); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(currency)
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"wallet/accountnumber"
+	"wallet/models"
+	"wallet/services"
+)
+
+// DepositAddressHandler returns the on-chain address to deposit to the
+// account.
+func DepositAddressHandler(service *services.DepositAddressService) http.HandlerFunc {
+	return depositAddressHandler(service.GetDepositAddress, http.StatusOK)
+}
+
+// NewDepositAddressHandler hands out a new address for the account.
+func NewDepositAddressHandler(service *services.DepositAddressService) http.HandlerFunc {
+	return depositAddressHandler(service.NewDepositAddress, http.StatusCreated)
+}
+
+func ListDepositAddressesHandler(service *services.DepositAddressService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountNumber, err := accountnumber.Parse(r.PathValue("number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		addresses, err := service.ListDepositAddresses(r.Context(), accountNumber)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(addresses)
+	}
+}
+
+func depositAddressHandler(action func(ctx context.Context, accountNumber string) (*models.DepositAddress, error), status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountNumber, err := accountnumber.Parse(r.PathValue("number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		address, err := action(r.Context(), accountNumber)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(address)
+	}
+}
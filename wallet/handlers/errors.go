@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"wallet/accountnumber"
+	"wallet/chainaddress"
+	"wallet/fx"
+	"wallet/repositories"
+	"wallet/schedule"
+	"wallet/services"
+)
+
+// writeError maps domain errors to HTTP status codes. Anything unknown is
+// reported as an internal error.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, repositories.ErrAccountNotFound),
+		errors.Is(err, repositories.ErrHoldNotFound),
+		errors.Is(err, repositories.ErrCurrencyNotFound),
+		errors.Is(err, repositories.ErrUserNotFound),
+		errors.Is(err, repositories.ErrLimitOverrideNotFound),
+		errors.Is(err, repositories.ErrLimitChargeNotFound),
+		errors.Is(err, repositories.ErrScheduledTransferNotFound),
+		errors.Is(err, repositories.ErrFeeRuleNotFound),
+		errors.Is(err, repositories.ErrWithdrawalNotFound),
+		errors.Is(err, repositories.ErrAddressBookEntryNotFound),
+		errors.Is(err, repositories.ErrReconciliationNotFound),
+		errors.Is(err, repositories.ErrPaymentNotFound),
+		errors.Is(err, repositories.ErrPaymentRequestNotFound),
+		errors.Is(err, repositories.ErrPayoutNotFound),
+		errors.Is(err, repositories.ErrOrganizationNotFound),
+		errors.Is(err, repositories.ErrOrganizationTransferNotFound),
+		errors.Is(err, repositories.ErrGrantNotFound),
+		errors.Is(err, repositories.ErrVoucherNotFound),
+		errors.Is(err, services.ErrRecipientNotFound),
+		errors.Is(err, services.ErrWalletNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, repositories.ErrAccountFrozen),
+		errors.Is(err, repositories.ErrAccountClosed),
+		errors.Is(err, repositories.ErrAccountNotEmpty),
+		errors.Is(err, repositories.ErrInvalidStatusTransition),
+		errors.Is(err, repositories.ErrHoldNotActive),
+		errors.Is(err, repositories.ErrCurrencyExists),
+		errors.Is(err, repositories.ErrCurrencyInUse),
+		errors.Is(err, repositories.ErrInvalidScheduleTransition),
+		errors.Is(err, repositories.ErrFeeRuleExists),
+		errors.Is(err, repositories.ErrInvalidWithdrawalTransition),
+		errors.Is(err, services.ErrRequesterUnknown),
+		errors.Is(err, repositories.ErrAddressBookEntryExists),
+		errors.Is(err, repositories.ErrUsernameTaken),
+		errors.Is(err, repositories.ErrPaymentRequestNotOpen),
+		errors.Is(err, repositories.ErrDuplicateReference),
+		errors.Is(err, repositories.ErrLastApprover),
+		errors.Is(err, repositories.ErrInvalidOrganizationTransferTransition),
+		errors.Is(err, repositories.ErrGrantRevoked),
+		errors.Is(err, repositories.ErrVoucherNotRedeemable),
+		errors.Is(err, repositories.ErrVoucherAlreadyRedeemed):
+		status = http.StatusConflict
+	case errors.Is(err, services.ErrSelfApproval),
+		errors.Is(err, services.ErrSelfTransferApproval),
+		errors.Is(err, repositories.ErrNotOrganizationMember),
+		errors.Is(err, services.ErrInsufficientRole),
+		errors.Is(err, services.ErrNotTradeParty),
+		errors.Is(err, services.ErrAccessDenied),
+		errors.Is(err, services.ErrOrganizationAccount):
+		status = http.StatusForbidden
+	case errors.Is(err, repositories.ErrInsufficientFunds),
+		errors.Is(err, fx.ErrRateNotFound),
+		errors.Is(err, services.ErrCurrencyDisabled),
+		errors.Is(err, services.ErrCurrencyMismatch),
+		errors.Is(err, services.ErrAmountBelowMinimum),
+		errors.Is(err, repositories.ErrLimitExceeded),
+		errors.Is(err, services.ErrDepositAddressUnsupported),
+		errors.Is(err, services.ErrWithdrawalUnsupported),
+		errors.Is(err, services.ErrAddressCoolingOff),
+		errors.Is(err, services.ErrAddressNotWhitelisted),
+		errors.Is(err, services.ErrNoVoucherAccount):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, accountnumber.ErrInvalid),
+		errors.Is(err, errInvalidResourceID),
+		errors.Is(err, services.ErrInvalidCursor),
+		errors.Is(err, services.ErrInvalidPeriod),
+		errors.Is(err, services.ErrInvalidRange),
+		errors.Is(err, services.ErrStatementRangeTooLong),
+		errors.Is(err, services.ErrStatementTooLarge),
+		errors.Is(err, services.ErrReasonRequired),
+		errors.Is(err, services.ErrInvalidAmount),
+		errors.Is(err, services.ErrSameAccount),
+		errors.Is(err, services.ErrInvalidHoldKind),
+		errors.Is(err, services.ErrDestinationRequired),
+		errors.Is(err, services.ErrNotTradeHold),
+		errors.Is(err, services.ErrHoldKindNotAllowed),
+		errors.Is(err, services.ErrInvalidCurrency),
+		errors.Is(err, services.ErrInvalidCurrencyKind),
+		errors.Is(err, services.ErrInvalidDecimals),
+		errors.Is(err, services.ErrInvalidMinAmount),
+		errors.Is(err, services.ErrInvalidConfirmations),
+		errors.Is(err, services.ErrInvalidLimitKind),
+		errors.Is(err, services.ErrInvalidLimitPeriod),
+		errors.Is(err, services.ErrInvalidLimitAmount),
+		errors.Is(err, services.ErrInvalidLevel),
+		errors.Is(err, schedule.ErrInvalidCron),
+		errors.Is(err, services.ErrInvalidSchedule),
+		errors.Is(err, services.ErrIntervalTooShort),
+		errors.Is(err, services.ErrEndBeforeStart),
+		errors.Is(err, services.ErrScheduleInPast),
+		errors.Is(err, services.ErrInvalidFeeOperation),
+		errors.Is(err, services.ErrInvalidFeeRule),
+		errors.Is(err, services.ErrAnyCurrencyFeeRule),
+		errors.Is(err, services.ErrInvalidFeeDiscount),
+		errors.Is(err, chainaddress.ErrInvalidAddress),
+		errors.Is(err, services.ErrWithdrawalAddressRequired),
+		errors.Is(err, services.ErrApproverRequired),
+		errors.Is(err, services.ErrInvalidEmail),
+		errors.Is(err, services.ErrInvalidUserEvent),
+		errors.Is(err, services.ErrRecipientRequired),
+		errors.Is(err, services.ErrSelfPayment),
+		errors.Is(err, services.ErrMemoTooLong),
+		errors.Is(err, services.ErrInvalidExpiry),
+		errors.Is(err, services.ErrSnapshotRangeTooLong),
+		errors.Is(err, services.ErrDayNotOver),
+		errors.Is(err, services.ErrInvalidPayoutFile),
+		errors.Is(err, services.ErrTooManyPayoutLines),
+		errors.Is(err, services.ErrInvalidPayoutMode),
+		errors.Is(err, services.ErrEmptyPayout),
+		errors.Is(err, services.ErrInvalidOrganizationName),
+		errors.Is(err, services.ErrInvalidOrganizationRole),
+		errors.Is(err, services.ErrInvalidThreshold),
+		errors.Is(err, services.ErrNotOrganizationAccount),
+		errors.Is(err, services.ErrInvalidGrantScope),
+		errors.Is(err, services.ErrInvalidGrantee),
+		errors.Is(err, services.ErrInvalidGrantExpiry),
+		errors.Is(err, services.ErrInvalidVoucherCount),
+		errors.Is(err, services.ErrInvalidMaxRedemptions),
+		errors.Is(err, services.ErrInvalidVoucherExpiry),
+		errors.Is(err, services.ErrVoucherCodeRequired):
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
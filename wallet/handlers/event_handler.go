@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"wallet/models"
+	"wallet/services"
+)
+
+// UserRegisteredHandler consumes the user registered events of the auth
+// service and returns the provisioned wallet. Any non 2xx answer makes the
+// auth service deliver the event again later.
+func UserRegisteredHandler(provisioningService *services.ProvisioningService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var event models.UserRegistered
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		wallet, err := provisioningService.ProvisionWallet(r.Context(), &event)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(wallet)
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"wallet/accountnumber"
+	"wallet/models"
+	"wallet/services"
+)
+
+// FeeScheduleHandler returns every fee rule and the rating discounts.
+func FeeScheduleHandler(feeService *services.FeeService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		schedule, err := feeService.GetSchedule(r.Context())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(schedule)
+	}
+}
+
+// FeeQuoteHandler returns the fee the account would pay for an operation
+// over the amount given in the query.
+func FeeQuoteHandler(feeService *services.FeeService, walletService *services.WalletService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		accountNumber, err := accountnumber.Parse(query.Get("account_number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		amount, err := strconv.ParseFloat(query.Get("amount"), 64)
+		if err != nil || amount <= 0 {
+			writeError(w, services.ErrInvalidAmount)
+			return
+		}
+
+		account, err := walletService.GetAccount(r.Context(), accountNumber)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		fee, err := feeService.Quote(r.Context(), query.Get("operation"), account, amount)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if fee == nil {
+			fee = &models.FeeItem{Type: query.Get("operation"), AccountNumber: accountNumber, Currency: account.Currency}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(fee)
+	}
+}
+
+func CreateFeeRuleHandler(feeService *services.FeeService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rule models.FeeRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := feeService.CreateRule(r.Context(), &rule); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(rule)
+	}
+}
+
+func DeleteFeeRuleHandler(feeService *services.FeeService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid fee rule id", http.StatusBadRequest)
+			return
+		}
+
+		if err := feeService.DeleteRule(r.Context(), id); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// SetFeeDiscountHandler sets the discount for users with at least the
+// rating in the path.
+func SetFeeDiscountHandler(feeService *services.FeeService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		minRating, err := strconv.Atoi(r.PathValue("min_rating"))
+		if err != nil {
+			http.Error(w, "invalid rating", http.StatusBadRequest)
+			return
+		}
+
+		var discount models.FeeDiscount
+		if err := json.NewDecoder(r.Body).Decode(&discount); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		discount.MinRating = minRating
+
+		if err := feeService.SetDiscount(r.Context(), &discount); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(discount)
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+	"wallet/services"
+)
+
+// GrantRequest names either another user or asks for a new API key.
+type GrantRequest struct {
+	GranteeUserID int        `json:"grantee_user_id"`
+	APIKey        bool       `json:"api_key"`
+	Scopes        []string   `json:"scopes"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+}
+
+// CreateGrantHandler gives scoped access to the wallet of {user_id}. A new
+// API key is only ever returned here.
+func CreateGrantHandler(grantService *services.GrantService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, err := strconv.Atoi(r.PathValue("user_id"))
+		if err != nil {
+			http.Error(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+
+		var req GrantRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		grant, err := grantService.CreateGrant(r.Context(), ownerID, req.GranteeUserID, req.APIKey, req.Scopes, req.ExpiresAt)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(grant)
+	}
+}
+
+func ListGrantsHandler(grantService *services.GrantService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, err := strconv.Atoi(r.PathValue("user_id"))
+		if err != nil {
+			http.Error(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+
+		grants, err := grantService.ListGrants(r.Context(), ownerID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(grants)
+	}
+}
+
+func RevokeGrantHandler(grantService *services.GrantService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ownerID, err := strconv.Atoi(r.PathValue("user_id"))
+		if err != nil {
+			http.Error(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid grant id", http.StatusBadRequest)
+			return
+		}
+
+		grant, err := grantService.RevokeGrant(r.Context(), ownerID, id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(grant)
+	}
+}
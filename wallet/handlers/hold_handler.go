@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"wallet/accountnumber"
+	"wallet/services"
+)
+
+type PlaceHoldRequest struct {
+	Amount    float64 `json:"amount"`
+	Kind      string  `json:"kind"`
+	Reference string  `json:"reference"`
+}
+
+type CaptureHoldRequest struct {
+	DestinationAccountNumber string `json:"destination_account_number"`
+}
+
+type SettleTradeRequest struct {
+	DestinationAccountNumber string  `json:"destination_account_number"`
+	PayerAccountNumber       string  `json:"payer_account_number"`
+	PayeeAccountNumber       string  `json:"payee_account_number"`
+	PaymentAmount            float64 `json:"payment_amount"`
+}
+
+// AccountHandler returns an account with its held and available balances.
+func AccountHandler(walletService *services.WalletService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountNumber, err := accountnumber.Parse(r.PathValue("number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		account, err := walletService.GetAccount(r.Context(), accountNumber)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(account)
+	}
+}
+
+func PlaceHoldHandler(holdService *services.HoldService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountNumber, err := accountnumber.Parse(r.PathValue("number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		var req PlaceHoldRequest
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		hold, err := holdService.PlaceHold(r.Context(), accountNumber, req.Amount, req.Kind, req.Reference)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(hold)
+	}
+}
+
+func ListHoldsHandler(holdService *services.HoldService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountNumber, err := accountnumber.Parse(r.PathValue("number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		holds, err := holdService.ListHolds(r.Context(), accountNumber)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(holds)
+	}
+}
+
+func GetHoldHandler(holdService *services.HoldService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		holdID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid hold id", http.StatusBadRequest)
+			return
+		}
+
+		hold, err := holdService.GetHold(r.Context(), holdID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(hold)
+	}
+}
+
+func ReleaseHoldHandler(holdService *services.HoldService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		holdID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid hold id", http.StatusBadRequest)
+			return
+		}
+
+		hold, err := holdService.ReleaseHold(r.Context(), holdID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(hold)
+	}
+}
+
+func CaptureHoldHandler(holdService *services.HoldService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		holdID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid hold id", http.StatusBadRequest)
+			return
+		}
+
+		var req CaptureHoldRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if req.DestinationAccountNumber == "" {
+			writeError(w, services.ErrDestinationRequired)
+			return
+		}
+		req.DestinationAccountNumber, err = accountnumber.Parse(req.DestinationAccountNumber)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		hold, err := holdService.CaptureHold(r.Context(), holdID, req.DestinationAccountNumber)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(hold)
+	}
+}
+
+// SettleTradeHandler pays for a held order and captures the hold to the
+// buyer, returning the receipt of the payment.
+func SettleTradeHandler(holdService *services.HoldService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		holdID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid hold id", http.StatusBadRequest)
+			return
+		}
+
+		var req SettleTradeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		accounts := []*string{&req.DestinationAccountNumber, &req.PayerAccountNumber, &req.PayeeAccountNumber}
+		for _, number := range accounts {
+			if *number, err = accountnumber.Parse(*number); err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+
+		receipt, err := holdService.SettleTrade(r.Context(), holdID, req.DestinationAccountNumber, req.PayerAccountNumber, req.PayeeAccountNumber, req.PaymentAmount)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(receipt)
+	}
+}
+
+// HoldAccounts returns the account of the hold in the path.
+func HoldAccounts(holdService *services.HoldService) ResourceAccounts {
+	return func(r *http.Request) ([]string, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		hold, err := holdService.GetHold(r.Context(), id)
+		if err != nil {
+			return nil, err
+		}
+		return []string{hold.AccountNumber}, nil
+	}
+}
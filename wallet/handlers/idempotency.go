@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"wallet/repositories"
+)
+
+// IdempotencyKeyHeader lets clients retry a request without applying it twice.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Idempotent replays the stored response when a request arrives again with
+// the same Idempotency-Key. Requests without the header pass through.
+// Server errors are not stored, so the client can retry them.
+func Idempotent(repo *repositories.IdempotencyRepository, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > 128 {
+			http.Error(w, "idempotency key is too long", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// The query is part of the request, a payout dry run must not be
+		// replayed for the real one.
+		hash := sha256.New()
+		io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		record, created, err := repo.Begin(r.Context(), key, requestHash)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if !created {
+			switch {
+			case record.RequestHash != requestHash:
+				http.Error(w, "idempotency key was used for a different request", http.StatusUnprocessableEntity)
+			case record.StatusCode == 0:
+				http.Error(w, "request with this idempotency key is in progress", http.StatusConflict)
+			default:
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(record.StatusCode)
+				w.Write(record.ResponseBody)
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r)
+
+		// The outcome is stored even if the client went away in the meantime.
+		ctx := context.WithoutCancel(r.Context())
+		if recorder.status >= http.StatusInternalServerError {
+			repo.Abandon(ctx, key)
+			return
+		}
+		repo.Complete(ctx, key, recorder.status, recorder.body.Bytes())
+	}
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (recorder *responseRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func (recorder *responseRecorder) Write(b []byte) (int, error) {
+	recorder.body.Write(b)
+	return recorder.ResponseWriter.Write(b)
+}
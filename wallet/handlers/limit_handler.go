@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"wallet/models"
+	"wallet/services"
+)
+
+type ChargeLimitRequest struct {
+	Kind      string  `json:"kind"`
+	Currency  string  `json:"currency"`
+	Amount    float64 `json:"amount"`
+	Reference string  `json:"reference"`
+}
+
+type SetLevelsRequest struct {
+	AccessLevel int `json:"access_level"`
+	RatingLevel int `json:"rating_level"`
+}
+
+// LimitsHandler returns the limits of the user given by the user_id query
+// parameter together with what is left of them.
+func LimitsHandler(limitService *services.LimitService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+		if err != nil {
+			http.Error(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+
+		limits, err := limitService.Limits(r.Context(), userID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(limits)
+	}
+}
+
+// ChargeLimitHandler counts an operation of another service, e.g. an order,
+// against the limits of a user.
+func ChargeLimitHandler(limitService *services.LimitService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.Atoi(r.PathValue("user_id"))
+		if err != nil {
+			http.Error(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+
+		var req ChargeLimitRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		charge, err := limitService.ChargeLimit(r.Context(), userID, req.Kind, req.Currency, req.Amount, req.Reference)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(charge)
+	}
+}
+
+func ReleaseLimitHandler(limitService *services.LimitService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chargeID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid charge id", http.StatusBadRequest)
+			return
+		}
+
+		if err := limitService.ReleaseLimit(r.Context(), chargeID); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// SetLimitOverrideHandler replaces one limit of a user regardless of the
+// user's levels.
+func SetLimitOverrideHandler(limitService *services.LimitService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.Atoi(r.PathValue("user_id"))
+		if err != nil {
+			http.Error(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+
+		var override models.LimitOverride
+		if err := json.NewDecoder(r.Body).Decode(&override); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		override.UserID = userID
+
+		if err := limitService.SetOverride(r.Context(), &override); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(override)
+	}
+}
+
+// DeleteLimitOverrideHandler puts a limit back to the one of the user's tier.
+func DeleteLimitOverrideHandler(limitService *services.LimitService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.Atoi(r.PathValue("user_id"))
+		if err != nil {
+			http.Error(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+
+		err = limitService.DeleteOverride(r.Context(), userID, r.PathValue("kind"), r.PathValue("period"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func SetLevelsHandler(limitService *services.LimitService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.Atoi(r.PathValue("user_id"))
+		if err != nil {
+			http.Error(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+
+		var req SetLevelsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := limitService.SetLevels(r.Context(), userID, req.AccessLevel, req.RatingLevel); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		limits, err := limitService.Limits(r.Context(), userID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(limits)
+	}
+}
@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"wallet/accountnumber"
+	"wallet/services"
+)
+
+// Organization requests act as the user in the X-Acting-User header, set by
+// the gateway that authenticated the request. API keys cannot act for
+// members.
+
+var errActingMemberRequired = errors.New(ActingUserHeader + " header is required")
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+type OrganizationMemberRequest struct {
+	MemberID int    `json:"member_id"`
+	Role     string `json:"role"`
+}
+
+type OrganizationAccountRequest struct {
+	Currency string `json:"currency"`
+}
+
+type OrganizationThresholdRequest struct {
+	Amount float64 `json:"amount"`
+}
+
+type OrganizationTransferRequest struct {
+	SenderAccountNumber   string  `json:"sender_account_number"`
+	ReceiverAccountNumber string  `json:"receiver_account_number"`
+	Amount                float64 `json:"amount"`
+	Memo                  string  `json:"memo"`
+}
+
+type OrganizationDecisionRequest struct {
+	Reason string `json:"reason"`
+}
+
+func CreateOrganizationHandler(organizationService *services.OrganizationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := actingMember(w, r)
+		if !ok {
+			return
+		}
+
+		var req CreateOrganizationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		org, err := organizationService.CreateOrganization(r.Context(), userID, req.Name)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(org)
+	}
+}
+
+func GetOrganizationHandler(organizationService *services.OrganizationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgID, userID, ok := organizationQuery(w, r)
+		if !ok {
+			return
+		}
+
+		org, err := organizationService.GetOrganization(r.Context(), orgID, userID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(org)
+	}
+}
+
+// SetOrganizationMemberHandler adds a member or changes their role.
+func SetOrganizationMemberHandler(organizationService *services.OrganizationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid organization id", http.StatusBadRequest)
+			return
+		}
+
+		userID, ok := actingMember(w, r)
+		if !ok {
+			return
+		}
+
+		var req OrganizationMemberRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		member, err := organizationService.SetMember(r.Context(), orgID, userID, req.MemberID, req.Role)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(member)
+	}
+}
+
+func RemoveOrganizationMemberHandler(organizationService *services.OrganizationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgID, userID, ok := organizationQuery(w, r)
+		if !ok {
+			return
+		}
+
+		memberID, err := strconv.Atoi(r.PathValue("member_id"))
+		if err != nil {
+			http.Error(w, "invalid member_id", http.StatusBadRequest)
+			return
+		}
+
+		if err := organizationService.RemoveMember(r.Context(), orgID, userID, memberID); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func OpenOrganizationAccountHandler(organizationService *services.OrganizationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid organization id", http.StatusBadRequest)
+			return
+		}
+
+		userID, ok := actingMember(w, r)
+		if !ok {
+			return
+		}
+
+		var req OrganizationAccountRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		account, err := organizationService.OpenAccount(r.Context(), orgID, userID, req.Currency)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(account)
+	}
+}
+
+func ListOrganizationAccountsHandler(organizationService *services.OrganizationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgID, userID, ok := organizationQuery(w, r)
+		if !ok {
+			return
+		}
+
+		accounts, err := organizationService.ListAccounts(r.Context(), orgID, userID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(accounts)
+	}
+}
+
+// SetOrganizationThresholdHandler sets the approval threshold of the
+// currency in the path.
+func SetOrganizationThresholdHandler(organizationService *services.OrganizationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid organization id", http.StatusBadRequest)
+			return
+		}
+
+		userID, ok := actingMember(w, r)
+		if !ok {
+			return
+		}
+
+		var req OrganizationThresholdRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := organizationService.SetThreshold(r.Context(), orgID, userID, r.PathValue("currency"), req.Amount); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// InitiateOrganizationTransferHandler answers 201 for a transfer executed
+// right away and 202 for one waiting for approval.
+func InitiateOrganizationTransferHandler(organizationService *services.OrganizationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid organization id", http.StatusBadRequest)
+			return
+		}
+
+		userID, ok := actingMember(w, r)
+		if !ok {
+			return
+		}
+
+		var req OrganizationTransferRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		sender, err := accountnumber.Parse(req.SenderAccountNumber)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		receiver, err := accountnumber.Parse(req.ReceiverAccountNumber)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		t, err := organizationService.InitiateTransfer(r.Context(), orgID, userID, sender, receiver, req.Amount, req.Memo)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		status := http.StatusCreated
+		if t.RequiresApproval {
+			status = http.StatusAccepted
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(t)
+	}
+}
+
+// ListOrganizationTransfersHandler returns the organization's transfers,
+// filtered by ?status= (comma separated) if given.
+func ListOrganizationTransfersHandler(organizationService *services.OrganizationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgID, userID, ok := organizationQuery(w, r)
+		if !ok {
+			return
+		}
+
+		var statuses []string
+		if status := r.URL.Query().Get("status"); status != "" {
+			statuses = strings.Split(status, ",")
+		}
+
+		transfers, err := organizationService.ListTransfers(r.Context(), orgID, userID, statuses)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(transfers)
+	}
+}
+
+func GetOrganizationTransferHandler(organizationService *services.OrganizationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgID, userID, ok := organizationQuery(w, r)
+		if !ok {
+			return
+		}
+
+		transferID, err := strconv.ParseInt(r.PathValue("transfer_id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid transfer id", http.StatusBadRequest)
+			return
+		}
+
+		t, err := organizationService.GetTransfer(r.Context(), orgID, transferID, userID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(t)
+	}
+}
+
+func ApproveOrganizationTransferHandler(organizationService *services.OrganizationService) http.HandlerFunc {
+	return organizationDecisionHandler(func(r *http.Request, orgID, transferID int64, userID int, req OrganizationDecisionRequest) (interface{}, error) {
+		return organizationService.ApproveTransfer(r.Context(), orgID, transferID, userID)
+	})
+}
+
+func RejectOrganizationTransferHandler(organizationService *services.OrganizationService) http.HandlerFunc {
+	return organizationDecisionHandler(func(r *http.Request, orgID, transferID int64, userID int, req OrganizationDecisionRequest) (interface{}, error) {
+		return organizationService.RejectTransfer(r.Context(), orgID, transferID, userID, req.Reason)
+	})
+}
+
+func CancelOrganizationTransferHandler(organizationService *services.OrganizationService) http.HandlerFunc {
+	return organizationDecisionHandler(func(r *http.Request, orgID, transferID int64, userID int, req OrganizationDecisionRequest) (interface{}, error) {
+		return organizationService.CancelTransfer(r.Context(), orgID, transferID, userID)
+	})
+}
+
+func ListOrganizationActivityHandler(organizationService *services.OrganizationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgID, userID, ok := organizationQuery(w, r)
+		if !ok {
+			return
+		}
+
+		activity, err := organizationService.ListActivity(r.Context(), orgID, userID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(activity)
+	}
+}
+
+// organizationDecisionHandler parses the transfer and the decision body
+// shared by approve, reject and cancel.
+func organizationDecisionHandler(decide func(r *http.Request, orgID, transferID int64, userID int, req OrganizationDecisionRequest) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orgID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid organization id", http.StatusBadRequest)
+			return
+		}
+		transferID, err := strconv.ParseInt(r.PathValue("transfer_id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid transfer id", http.StatusBadRequest)
+			return
+		}
+
+		userID, ok := actingMember(w, r)
+		if !ok {
+			return
+		}
+
+		var req OrganizationDecisionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		t, err := decide(r, orgID, transferID, userID, req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(t)
+	}
+}
+
+// organizationQuery parses the organization id in the path and returns it
+// with the acting member.
+func organizationQuery(w http.ResponseWriter, r *http.Request) (int64, int, bool) {
+	orgID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid organization id", http.StatusBadRequest)
+		return 0, 0, false
+	}
+
+	userID, ok := actingMember(w, r)
+	return orgID, userID, ok
+}
+
+// actingMember returns the user the request acts as.
+func actingMember(w http.ResponseWriter, r *http.Request) (int, bool) {
+	principal, err := RequestPrincipal(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return 0, false
+	}
+	if principal == nil || principal.APIKey != "" {
+		http.Error(w, errActingMemberRequired.Error(), http.StatusUnauthorized)
+		return 0, false
+	}
+	return principal.UserID, true
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"wallet/accountnumber"
+	"wallet/services"
+)
+
+type PaymentRequest struct {
+	SenderAccountNumber string  `json:"sender_account_number"`
+	Recipient           string  `json:"recipient"`
+	Amount              float64 `json:"amount"`
+	Memo                string  `json:"memo"`
+}
+
+// PayHandler pays the user with the username or email in recipient and
+// returns the receipts of both parties.
+func PayHandler(paymentService *services.PaymentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req PaymentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		sender, err := accountnumber.Parse(req.SenderAccountNumber)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		receipt, err := paymentService.Pay(r.Context(), sender, req.Recipient, req.Amount, req.Memo)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(receipt)
+	}
+}
+
+func GetPaymentHandler(paymentService *services.PaymentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid payment id", http.StatusBadRequest)
+			return
+		}
+
+		payment, err := paymentService.GetPayment(r.Context(), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(payment)
+	}
+}
+
+// ListPaymentsHandler returns the payments sent and received by the account,
+// ?limit= of them.
+func ListPaymentsHandler(paymentService *services.PaymentService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountNumber, err := accountnumber.Parse(r.PathValue("number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		limit := 0
+		if value := r.URL.Query().Get("limit"); value != "" {
+			if limit, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		payments, err := paymentService.ListPayments(r.Context(), accountNumber, limit)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(payments)
+	}
+}
+
+// PaymentAccounts returns the sender and the recipient of the payment in
+// the path.
+func PaymentAccounts(paymentService *services.PaymentService) ResourceAccounts {
+	return func(r *http.Request) ([]string, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		payment, err := paymentService.GetPayment(r.Context(), id)
+		if err != nil {
+			return nil, err
+		}
+		return []string{payment.SenderAccountNumber, payment.RecipientAccountNumber}, nil
+	}
+}
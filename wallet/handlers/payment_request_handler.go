@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+	"wallet/accountnumber"
+	"wallet/services"
+)
+
+type PaymentRequestRequest struct {
+	Amount    float64    `json:"amount"`
+	Currency  string     `json:"currency"`
+	Memo      string     `json:"memo"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type PayPaymentRequestRequest struct {
+	PayerAccountNumber string `json:"payer_account_number"`
+}
+
+// CreatePaymentRequestHandler asks for a payment into the account. The
+// returned reference is what the payer needs.
+func CreatePaymentRequestHandler(paymentRequestService *services.PaymentRequestService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountNumber, err := accountnumber.Parse(r.PathValue("number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		var req PaymentRequestRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		pr, err := paymentRequestService.CreatePaymentRequest(r.Context(), accountNumber, req.Currency, req.Amount, req.Memo, req.ExpiresAt)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(pr)
+	}
+}
+
+func ListPaymentRequestsHandler(paymentRequestService *services.PaymentRequestService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountNumber, err := accountnumber.Parse(r.PathValue("number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		requests, err := paymentRequestService.ListPaymentRequests(r.Context(), accountNumber)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(requests)
+	}
+}
+
+func GetPaymentRequestHandler(paymentRequestService *services.PaymentRequestService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pr, err := paymentRequestService.GetPaymentRequest(r.Context(), r.PathValue("reference"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pr)
+	}
+}
+
+// PayPaymentRequestHandler pays the request from the payer's account and
+// returns it together with the transfer receipt.
+func PayPaymentRequestHandler(paymentRequestService *services.PaymentRequestService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req PayPaymentRequestRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		payer, err := accountnumber.Parse(req.PayerAccountNumber)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		paid, err := paymentRequestService.Pay(r.Context(), r.PathValue("reference"), payer)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(paid)
+	}
+}
+
+func CancelPaymentRequestHandler(paymentRequestService *services.PaymentRequestService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pr, err := paymentRequestService.Cancel(r.Context(), r.PathValue("reference"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pr)
+	}
+}
+
+// PaymentRequestAccounts returns the payee of the payment request in the
+// path and its payer once it is paid.
+func PaymentRequestAccounts(paymentRequestService *services.PaymentRequestService) ResourceAccounts {
+	return func(r *http.Request) ([]string, error) {
+		pr, err := paymentRequestService.GetPaymentRequest(r.Context(), r.PathValue("reference"))
+		if err != nil {
+			return nil, err
+		}
+		accounts := []string{pr.PayeeAccountNumber}
+		if pr.PayerAccountNumber != "" {
+			accounts = append(accounts, pr.PayerAccountNumber)
+		}
+		return accounts, nil
+	}
+}
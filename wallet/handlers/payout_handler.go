@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"wallet/accountnumber"
+	"wallet/models"
+	"wallet/services"
+)
+
+const maxPayoutFileSize = 5 << 20
+
+// SubmitPayoutHandler pays the lines of the uploaded file, CSV (text/csv)
+// or JSON lines (application/x-ndjson). Query parameters: mode,
+// all_or_nothing by default or best_effort, and dry_run to only preview.
+func SubmitPayoutHandler(payoutService *services.PayoutService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountNumber, err := accountnumber.Parse(r.PathValue("number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		query := r.URL.Query()
+		mode := query.Get("mode")
+		if mode == "" {
+			mode = models.PayoutAllOrNothing
+		}
+		dryRun := false
+		if value := query.Get("dry_run"); value != "" {
+			if dryRun, err = strconv.ParseBool(value); err != nil {
+				http.Error(w, "invalid dry_run", http.StatusBadRequest)
+				return
+			}
+		}
+
+		format := query.Get("format")
+		if format == "" {
+			mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			switch mediaType {
+			case "text/csv":
+				format = services.PayoutFormatCSV
+			case "application/x-ndjson", "application/jsonl", "application/json":
+				format = services.PayoutFormatJSONL
+			}
+		}
+
+		lines, err := services.ParsePayoutFile(http.MaxBytesReader(w, r.Body, maxPayoutFileSize), format)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		payout, err := payoutService.Submit(r.Context(), accountNumber, lines, mode, dryRun)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		status := http.StatusCreated
+		switch payout.Status {
+		case models.PayoutPreview:
+			status = http.StatusOK
+		case models.PayoutRejected:
+			status = http.StatusUnprocessableEntity
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(payout)
+	}
+}
+
+func GetPayoutHandler(payoutService *services.PayoutService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid payout id", http.StatusBadRequest)
+			return
+		}
+
+		payout, err := payoutService.GetPayout(r.Context(), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(payout)
+	}
+}
+
+// PayoutAccounts returns the account of the payout in the path.
+func PayoutAccounts(payoutService *services.PayoutService) ResourceAccounts {
+	return func(r *http.Request) ([]string, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		payout, err := payoutService.GetPayout(r.Context(), id)
+		if err != nil {
+			return nil, err
+		}
+		return []string{payout.AccountNumber}, nil
+	}
+}
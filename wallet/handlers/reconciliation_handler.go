@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"wallet/services"
+)
+
+// ReconcileHandler runs a reconciliation right away and returns its report.
+func ReconcileHandler(reconciliationService *services.ReconciliationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report, err := reconciliationService.Reconcile(r.Context())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// ListReconciliationsHandler returns the latest reports, ?limit= of them.
+func ListReconciliationsHandler(reconciliationService *services.ReconciliationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 0
+		if value := r.URL.Query().Get("limit"); value != "" {
+			var err error
+			if limit, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		reports, err := reconciliationService.ListReports(r.Context(), limit)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(reports)
+	}
+}
+
+func GetReconciliationHandler(reconciliationService *services.ReconciliationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid report id", http.StatusBadRequest)
+			return
+		}
+
+		report, err := reconciliationService.GetReport(r.Context(), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}
+}
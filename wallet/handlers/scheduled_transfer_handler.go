@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+	"wallet/accountnumber"
+	"wallet/models"
+	"wallet/services"
+)
+
+type ScheduleTransferRequest struct {
+	ReceiverAccountNumber string     `json:"receiver_account_number"`
+	Amount                float64    `json:"amount"`
+	RunAt                 time.Time  `json:"run_at"`
+	IntervalSeconds       int        `json:"interval_seconds"`
+	Cron                  string     `json:"cron"`
+	EndAt                 *time.Time `json:"end_at"`
+}
+
+// ScheduleTransferHandler creates a one-off (run_at), recurring
+// (interval_seconds) or cron scheduled transfer from the account.
+func ScheduleTransferHandler(service *services.ScheduledTransferService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sender, err := accountnumber.Parse(r.PathValue("number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		var req ScheduleTransferRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		receiver, err := accountnumber.Parse(req.ReceiverAccountNumber)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		st := &models.ScheduledTransfer{
+			SenderAccountNumber:   sender,
+			ReceiverAccountNumber: receiver,
+			Amount:                req.Amount,
+			IntervalSeconds:       req.IntervalSeconds,
+			Cron:                  req.Cron,
+			EndAt:                 req.EndAt,
+		}
+		st, err = service.CreateScheduledTransfer(r.Context(), st, req.RunAt)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(st)
+	}
+}
+
+func ListScheduledTransfersHandler(service *services.ScheduledTransferService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountNumber, err := accountnumber.Parse(r.PathValue("number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		transfers, err := service.ListScheduledTransfers(r.Context(), accountNumber)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(transfers)
+	}
+}
+
+func GetScheduledTransferHandler(service *services.ScheduledTransferService) http.HandlerFunc {
+	return scheduledTransferHandler(service.GetScheduledTransfer)
+}
+
+// ListScheduledTransferRunsHandler returns every attempt to execute the
+// transfer, newest first.
+func ListScheduledTransferRunsHandler(service *services.ScheduledTransferService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid scheduled transfer id", http.StatusBadRequest)
+			return
+		}
+
+		runs, err := service.ListRuns(r.Context(), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(runs)
+	}
+}
+
+func PauseScheduledTransferHandler(service *services.ScheduledTransferService) http.HandlerFunc {
+	return scheduledTransferHandler(service.Pause)
+}
+
+func ResumeScheduledTransferHandler(service *services.ScheduledTransferService) http.HandlerFunc {
+	return scheduledTransferHandler(service.Resume)
+}
+
+func CancelScheduledTransferHandler(service *services.ScheduledTransferService) http.HandlerFunc {
+	return scheduledTransferHandler(service.Cancel)
+}
+
+func scheduledTransferHandler(action func(ctx context.Context, id int64) (*models.ScheduledTransfer, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid scheduled transfer id", http.StatusBadRequest)
+			return
+		}
+
+		st, err := action(r.Context(), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(st)
+	}
+}
+
+// ScheduledTransferAccounts returns the sender of the scheduled transfer in
+// the path.
+func ScheduledTransferAccounts(scheduledTransferService *services.ScheduledTransferService) ResourceAccounts {
+	return func(r *http.Request) ([]string, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		t, err := scheduledTransferService.GetScheduledTransfer(r.Context(), id)
+		if err != nil {
+			return nil, err
+		}
+		return []string{t.SenderAccountNumber}, nil
+	}
+}
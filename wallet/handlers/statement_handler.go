@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"wallet/accountnumber"
+	"wallet/export"
+	"wallet/models"
+	"wallet/services"
+)
+
+// TransactionsHandler lists the movements of an account. Supported query
+// parameters: from, to, type (comma separated), min_amount, max_amount,
+// cursor and limit.
+func TransactionsHandler(service *services.StatementService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountNumber, err := accountnumber.Parse(r.PathValue("number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		query := r.URL.Query()
+
+		var filter models.MovementFilter
+
+		if filter.From, err = parseTimeParam(query.Get("from"), false); err != nil {
+			http.Error(w, "invalid from", http.StatusBadRequest)
+			return
+		}
+		if filter.To, err = parseTimeParam(query.Get("to"), true); err != nil {
+			http.Error(w, "invalid to", http.StatusBadRequest)
+			return
+		}
+		if types := query.Get("type"); types != "" {
+			filter.Types = strings.Split(types, ",")
+		}
+		if filter.MinAmount, err = parseFloatParam(query.Get("min_amount")); err != nil {
+			http.Error(w, "invalid min_amount", http.StatusBadRequest)
+			return
+		}
+		if filter.MaxAmount, err = parseFloatParam(query.Get("max_amount")); err != nil {
+			http.Error(w, "invalid max_amount", http.StatusBadRequest)
+			return
+		}
+		if cursor := query.Get("cursor"); cursor != "" {
+			if filter.BeforeID, err = services.DecodeCursor(cursor); err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+		if limit := query.Get("limit"); limit != "" {
+			if filter.Limit, err = strconv.Atoi(limit); err != nil {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		page, err := service.ListTransactions(r.Context(), accountNumber, filter)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(page)
+	}
+}
+
+// StatementHandler exports the account statement between from and to, at
+// most a year apart. The format query parameter selects json (default), csv
+// or pdf, period selects day or month (default) granularity.
+func StatementHandler(service *services.StatementService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountNumber, err := accountnumber.Parse(r.PathValue("number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		query := r.URL.Query()
+
+		from, err := parseTimeParam(query.Get("from"), false)
+		if err != nil || from.IsZero() {
+			http.Error(w, "from is required", http.StatusBadRequest)
+			return
+		}
+		to, err := parseTimeParam(query.Get("to"), true)
+		if err != nil {
+			http.Error(w, "invalid to", http.StatusBadRequest)
+			return
+		}
+		if to.IsZero() {
+			to = time.Now().UTC()
+		}
+
+		period := query.Get("period")
+		if period == "" {
+			period = models.PeriodMonth
+		}
+
+		statement, err := service.Statement(r.Context(), accountNumber, from, to, period)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		filename := fmt.Sprintf("statement-%s-%s", accountNumber, from.Format("20060102"))
+		switch query.Get("format") {
+		case "", "json":
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(statement)
+		case "csv":
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", "attachment; filename="+filename+".csv")
+			export.WriteStatementCSV(w, statement)
+		case "pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Header().Set("Content-Disposition", "attachment; filename="+filename+".pdf")
+			export.WriteStatementPDF(w, statement)
+		default:
+			http.Error(w, "format must be json, csv or pdf", http.StatusBadRequest)
+		}
+	}
+}
+
+// parseTimeParam accepts RFC 3339 timestamps and plain dates. A plain date
+// used as an upper bound covers the whole day.
+func parseTimeParam(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func parseFloatParam(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// BalanceAtHandler returns the balance of an account at ?at=, a timestamp
+// or a date meaning the end of that day. Without at it is the balance now.
+func BalanceAtHandler(service *services.StatementService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountNumber, err := accountnumber.Parse(r.PathValue("number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		at, err := parseTimeParam(r.URL.Query().Get("at"), true)
+		if err != nil {
+			http.Error(w, "invalid at", http.StatusBadRequest)
+			return
+		}
+		if at.IsZero() {
+			at = time.Now()
+		}
+
+		balance, err := service.BalanceAt(r.Context(), accountNumber, at)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(balance)
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"wallet/accountnumber"
+	"wallet/services"
+)
+
+type TransferRequest struct {
+	SenderAccountNumber   string  `json:"sender_account_number"`
+	ReceiverAccountNumber string  `json:"receiver_account_number"`
+	Amount                float64 `json:"amount"`
+}
+
+// TransferHandler moves funds between two accounts and returns the receipt.
+func TransferHandler(walletService *services.WalletService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req TransferRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		sender, err := accountnumber.Parse(req.SenderAccountNumber)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		receiver, err := accountnumber.Parse(req.ReceiverAccountNumber)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		receipt, err := walletService.Transfer(r.Context(), sender, receiver, req.Amount)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(receipt)
+	}
+}
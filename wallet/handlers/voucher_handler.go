@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+	"wallet/services"
+)
+
+type MintVouchersRequest struct {
+	Currency       string     `json:"currency"`
+	Amount         float64    `json:"amount"`
+	MaxRedemptions int        `json:"max_redemptions"`
+	Count          int        `json:"count"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+}
+
+type RedeemVoucherRequest struct {
+	UserID int    `json:"user_id"`
+	Code   string `json:"code"`
+}
+
+// MintVouchersHandler mints single use vouchers unless max_redemptions says
+// otherwise, one unless count does. The response is the only place the
+// codes are ever shown.
+func MintVouchersHandler(voucherService *services.VoucherService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := MintVouchersRequest{MaxRedemptions: 1, Count: 1}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		vouchers, err := voucherService.Mint(r.Context(), req.Currency, req.Amount, req.MaxRedemptions, req.Count, req.ExpiresAt)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(vouchers)
+	}
+}
+
+func GetVoucherHandler(voucherService *services.VoucherService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid voucher id", http.StatusBadRequest)
+			return
+		}
+
+		voucher, err := voucherService.GetVoucher(r.Context(), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(voucher)
+	}
+}
+
+func ListVoucherRedemptionsHandler(voucherService *services.VoucherService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid voucher id", http.StatusBadRequest)
+			return
+		}
+
+		redemptions, err := voucherService.ListRedemptions(r.Context(), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(redemptions)
+	}
+}
+
+func DisableVoucherHandler(voucherService *services.VoucherService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid voucher id", http.StatusBadRequest)
+			return
+		}
+
+		voucher, err := voucherService.DisableVoucher(r.Context(), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(voucher)
+	}
+}
+
+// RedeemVoucherHandler pays the voucher into the user's account in its
+// currency.
+func RedeemVoucherHandler(voucherService *services.VoucherService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req RedeemVoucherRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		redemption, err := voucherService.Redeem(r.Context(), req.UserID, req.Code)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(redemption)
+	}
+}
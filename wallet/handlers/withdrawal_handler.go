@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"wallet/accountnumber"
+	"wallet/services"
+)
+
+type WithdrawalRequest struct {
+	Address string  `json:"address"`
+	Amount  float64 `json:"amount"`
+}
+
+type RejectWithdrawalRequest struct {
+	Reason string `json:"reason"`
+}
+
+// RequestWithdrawalHandler reserves the funds of a withdrawal to an external
+// address. It is accepted rather than done, the payment goes out later.
+// The requester is the acting user of the request.
+func RequestWithdrawalHandler(withdrawalService *services.WithdrawalService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountNumber, err := accountnumber.Parse(r.PathValue("number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		principal, err := RequestPrincipal(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req WithdrawalRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		withdrawal, err := withdrawalService.RequestWithdrawal(r.Context(), accountNumber, req.Address, req.Amount, principal)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(withdrawal)
+	}
+}
+
+func ListAccountWithdrawalsHandler(withdrawalService *services.WithdrawalService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accountNumber, err := accountnumber.Parse(r.PathValue("number"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		withdrawals, err := withdrawalService.ListAccountWithdrawals(r.Context(), accountNumber)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(withdrawals)
+	}
+}
+
+func GetWithdrawalHandler(withdrawalService *services.WithdrawalService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid withdrawal id", http.StatusBadRequest)
+			return
+		}
+
+		withdrawal, err := withdrawalService.GetWithdrawal(r.Context(), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(withdrawal)
+	}
+}
+
+func CancelWithdrawalHandler(withdrawalService *services.WithdrawalService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid withdrawal id", http.StatusBadRequest)
+			return
+		}
+
+		withdrawal, err := withdrawalService.Cancel(r.Context(), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(withdrawal)
+	}
+}
+
+// ListWithdrawalsHandler returns the withdrawals with ?status=, by default
+// the ones waiting for approval.
+func ListWithdrawalsHandler(withdrawalService *services.WithdrawalService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		withdrawals, err := withdrawalService.ListWithdrawals(r.Context(), r.URL.Query().Get("status"))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(withdrawals)
+	}
+}
+
+// ApproveWithdrawalHandler approves in the name of the admin behind
+// NamedAdminOnly, as does RejectWithdrawalHandler.
+func ApproveWithdrawalHandler(withdrawalService *services.WithdrawalService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid withdrawal id", http.StatusBadRequest)
+			return
+		}
+
+		withdrawal, err := withdrawalService.Approve(r.Context(), id, RequestAdmin(r))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(withdrawal)
+	}
+}
+
+func RejectWithdrawalHandler(withdrawalService *services.WithdrawalService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid withdrawal id", http.StatusBadRequest)
+			return
+		}
+
+		var req RejectWithdrawalRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		withdrawal, err := withdrawalService.Reject(r.Context(), id, RequestAdmin(r), req.Reason)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(withdrawal)
+	}
+}
+
+// WithdrawalAccounts returns the account of the withdrawal in the path.
+func WithdrawalAccounts(withdrawalService *services.WithdrawalService) ResourceAccounts {
+	return func(r *http.Request) ([]string, error) {
+		id, err := pathID(r)
+		if err != nil {
+			return nil, err
+		}
+		withdrawal, err := withdrawalService.GetWithdrawal(r.Context(), id)
+		if err != nil {
+			return nil, err
+		}
+		return []string{withdrawal.AccountNumber}, nil
+	}
+}
@@ -0,0 +1,64 @@
+package hdkey
+
+import (
+	"bytes"
+	"math/big"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var base58Radix = big.NewInt(58)
+
+func encodeBase58Check(payload []byte) string {
+	data := append(append([]byte{}, payload...), doubleSHA256(payload)[:4]...)
+
+	n := new(big.Int).SetBytes(data)
+	mod := new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, base58Radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	// Leading zero bytes are kept as leading ones.
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+// DecodeBase58Check decodes base58 data ending in the first 4 bytes of its
+// double SHA-256, the encoding of extended keys and legacy bitcoin
+// addresses. It returns the data without the checksum.
+func DecodeBase58Check(s string) ([]byte, error) {
+	n := new(big.Int)
+	zeros := 0
+	for i := 0; i < len(s); i++ {
+		digit := bytes.IndexByte([]byte(base58Alphabet), s[i])
+		if digit < 0 {
+			return nil, ErrInvalidKey
+		}
+		if digit == 0 && n.Sign() == 0 {
+			zeros++
+		}
+		n.Mul(n, base58Radix)
+		n.Add(n, big.NewInt(int64(digit)))
+	}
+
+	data := append(make([]byte, zeros), n.Bytes()...)
+	if len(data) < 4 {
+		return nil, ErrInvalidKey
+	}
+
+	payload, checksum := data[:len(data)-4], data[len(data)-4:]
+	if !bytes.Equal(doubleSHA256(payload)[:4], checksum) {
+		return nil, errInvalidChecksum
+	}
+	return payload, nil
+}
@@ -0,0 +1,218 @@
+// Package hdkey derives public keys from a BIP32 extended public key.
+//
+// The service only ever holds extended public keys, usually of a BIP44
+// account (m/44'/coin'/account'). Below that only normal, non hardened
+// children can be derived, which is all a receive address needs:
+// m/44'/coin'/account'/0/index. The private keys stay offline.
+package hdkey
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// HardenedOffset is added to the index of hardened children.
+const HardenedOffset uint32 = 0x80000000
+
+var (
+	ErrInvalidKey      = errors.New("invalid extended public key")
+	ErrPrivateKey      = errors.New("extended private keys are not accepted, use the public key")
+	ErrHardenedChild   = errors.New("hardened children cannot be derived from a public key")
+	ErrInvalidChild    = errors.New("child key is invalid, use the next index")
+	ErrInvalidPath     = errors.New("invalid derivation path")
+	ErrUnknownVersion  = errors.New("unknown extended key version")
+	errInvalidChecksum = errors.New("invalid extended key checksum")
+)
+
+// Network is the chain an extended key was serialized for.
+type Network int
+
+const (
+	Mainnet Network = iota
+	Testnet
+)
+
+// Public key versions of xpub, tpub and the BIP84 zpub and vpub.
+var versions = map[uint32]Network{
+	0x0488b21e: Mainnet,
+	0x04b24746: Mainnet,
+	0x043587cf: Testnet,
+	0x045f1cf6: Testnet,
+}
+
+// Private key versions, only recognised to give a helpful error.
+var privateVersions = map[uint32]bool{
+	0x0488ade4: true,
+	0x04b2430c: true,
+	0x04358394: true,
+	0x045f18bc: true,
+}
+
+const serializedLength = 78
+
+type ExtendedKey struct {
+	version     uint32
+	depth       uint8
+	parent      [4]byte
+	childNumber uint32
+	chainCode   [32]byte
+	key         *secp256k1.PublicKey
+}
+
+// Parse decodes a base58 serialized extended public key.
+func Parse(s string) (*ExtendedKey, error) {
+	payload, err := DecodeBase58Check(strings.TrimSpace(s))
+	if err != nil || len(payload) != serializedLength {
+		return nil, ErrInvalidKey
+	}
+
+	version := binary.BigEndian.Uint32(payload[0:4])
+	if privateVersions[version] {
+		return nil, ErrPrivateKey
+	}
+	if _, ok := versions[version]; !ok {
+		return nil, ErrUnknownVersion
+	}
+
+	key, err := secp256k1.ParsePubKey(payload[45:78])
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	extended := &ExtendedKey{
+		version:     version,
+		depth:       payload[4],
+		childNumber: binary.BigEndian.Uint32(payload[9:13]),
+		key:         key,
+	}
+	copy(extended.parent[:], payload[5:9])
+	copy(extended.chainCode[:], payload[13:45])
+	return extended, nil
+}
+
+// String returns the base58 serialization of the key.
+func (k *ExtendedKey) String() string {
+	payload := make([]byte, 0, serializedLength)
+	payload = binary.BigEndian.AppendUint32(payload, k.version)
+	payload = append(payload, k.depth)
+	payload = append(payload, k.parent[:]...)
+	payload = binary.BigEndian.AppendUint32(payload, k.childNumber)
+	payload = append(payload, k.chainCode[:]...)
+	payload = append(payload, k.key.SerializeCompressed()...)
+	return encodeBase58Check(payload)
+}
+
+func (k *ExtendedKey) Network() Network {
+	return versions[k.version]
+}
+
+func (k *ExtendedKey) Depth() uint8 {
+	return k.depth
+}
+
+// ChildNumber is the index the key was derived with from its parent,
+// including HardenedOffset for hardened keys.
+func (k *ExtendedKey) ChildNumber() uint32 {
+	return k.childNumber
+}
+
+func (k *ExtendedKey) PublicKey() *secp256k1.PublicKey {
+	return k.key
+}
+
+// Child derives the normal child with the given index (BIP32 CKDpub).
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	if index >= HardenedOffset {
+		return nil, ErrHardenedChild
+	}
+	if k.depth == 255 {
+		return nil, ErrInvalidPath
+	}
+
+	data := make([]byte, 0, 37)
+	data = append(data, k.key.SerializeCompressed()...)
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	mac := hmac.New(sha512.New, k.chainCode[:])
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	var tweak secp256k1.ModNScalar
+	if overflow := tweak.SetByteSlice(sum[:32]); overflow {
+		return nil, ErrInvalidChild
+	}
+
+	// child = tweak*G + parent
+	var point, parent, child secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(&tweak, &point)
+	k.key.AsJacobian(&parent)
+	secp256k1.AddNonConst(&point, &parent, &child)
+	if (child.X.IsZero() && child.Y.IsZero()) || child.Z.IsZero() {
+		return nil, ErrInvalidChild
+	}
+	child.ToAffine()
+
+	derived := &ExtendedKey{
+		version:     k.version,
+		depth:       k.depth + 1,
+		childNumber: index,
+		key:         secp256k1.NewPublicKey(&child.X, &child.Y),
+	}
+	copy(derived.parent[:], Hash160(k.key.SerializeCompressed())[:4])
+	copy(derived.chainCode[:], sum[32:])
+	return derived, nil
+}
+
+// Derive follows a relative path of normal children, e.g. "0/5".
+func (k *ExtendedKey) Derive(path string) (*ExtendedKey, error) {
+	key := k
+	for _, part := range strings.Split(strings.Trim(path, "/"), "/") {
+		if strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h") {
+			return nil, ErrHardenedChild
+		}
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, ErrInvalidPath
+		}
+		if key, err = key.Child(uint32(index)); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// Path formats the absolute path of a key derived from a BIP44 account key
+// by the relative path, e.g. m/44'/0'/0'/0/5. The purpose and coin of the
+// account key are not part of its serialization and have to be given.
+func (k *ExtendedKey) Path(purpose, coin uint32, relative ...uint32) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "m/%d'/%d'/%d'", purpose, coin, k.childNumber&^HardenedOffset)
+	for _, index := range relative {
+		fmt.Fprintf(&b, "/%d", index)
+	}
+	return b.String()
+}
+
+// Hash160 is RIPEMD160(SHA256(data)), the hash in key fingerprints and
+// bitcoin addresses.
+func Hash160(data []byte) []byte {
+	sha := sha256.Sum256(data)
+	h := ripemd160.New()
+	h.Write(sha[:])
+	return h.Sum(nil)
+}
+
+func doubleSHA256(data []byte) []byte {
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
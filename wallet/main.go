@@ -1,11 +1,22 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	_ "github.com/lib/pq"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"wallet/alert"
+	"wallet/authclient"
+	"wallet/chain"
+	"wallet/fx"
 	"wallet/handlers"
+	"wallet/mail"
+	"wallet/models"
 	"wallet/repositories"
 	"wallet/services"
 )
@@ -21,12 +32,266 @@ func main() {
 	// Repository and Service setup
 	walletRepo := repositories.NewWalletRepository(db)
 	accountRepo := repositories.NewAccountRepository(db)
-	walletService := services.NewWalletService(walletRepo, accountRepo)
+	movementRepo := repositories.NewMovementRepository(db)
+	holdRepo := repositories.NewHoldRepository(db)
+	idempotencyRepo := repositories.NewIdempotencyRepository(db)
+	currencyRepo := repositories.NewCurrencyRepository(db)
+	limitRepo := repositories.NewLimitRepository(db)
+	scheduledTransferRepo := repositories.NewScheduledTransferRepository(db)
+	feeRepo := repositories.NewFeeRepository(db)
+	depositAddressRepo := repositories.NewDepositAddressRepository(db)
+	chainDepositRepo := repositories.NewChainDepositRepository(db)
+	withdrawalRepo := repositories.NewWithdrawalRepository(db)
+	addressBookRepo := repositories.NewAddressBookRepository(db)
+	reconciliationRepo := repositories.NewReconciliationRepository(db)
+	paymentRepo := repositories.NewPaymentRepository(db)
+	paymentRequestRepo := repositories.NewPaymentRequestRepository(db)
+	balanceSnapshotRepo := repositories.NewBalanceSnapshotRepository(db)
+	payoutRepo := repositories.NewPayoutRepository(db)
+	organizationRepo := repositories.NewOrganizationRepository(db)
+	grantRepo := repositories.NewGrantRepository(db)
+	voucherRepo := repositories.NewVoucherRepository(db)
 
-	// Handlers setup
-	http.HandleFunc("/get_balance", handlers.BalanceHandler(walletService))
-	http.HandleFunc("/update_balance", handlers.UpdateBalanceHandler(walletService))
-	http.HandleFunc("/create_purse", handlers.CreateAccountHandler(walletService))
+	// Exchange rates for wallet valuation and limits. Limits are kept in USD
+	// and cannot be checked for other currencies without rates.
+	ratesFile := os.Getenv("FX_RATES_FILE")
+	if ratesFile == "" {
+		log.Fatal("FX_RATES_FILE is required")
+	}
+	staticRates, err := fx.LoadStaticRates(ratesFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rates := fx.NewCachedRates(staticRates, time.Minute)
+
+	currencyService := services.NewCurrencyService(currencyRepo)
+	limitService := services.NewLimitService(limitRepo, walletRepo, currencyService, rates)
+	feeService := services.NewFeeService(feeRepo, limitRepo, walletRepo, currencyService)
+	walletService := services.NewWalletService(walletRepo, accountRepo, currencyService, limitService, feeService)
+	grantService := services.NewGrantService(grantRepo, walletRepo, organizationRepo)
+	statementService := services.NewStatementService(accountRepo, movementRepo, currencyService)
+	balanceSnapshotService := services.NewBalanceSnapshotService(balanceSnapshotRepo, accountRepo, currencyService)
+	holdService := services.NewHoldService(holdRepo, accountRepo, walletRepo, currencyService, feeService, walletService)
+	valuationService := services.NewValuationService(walletRepo, accountRepo, currencyService, rates)
+	// Registered users get a wallet with an account in each of
+	// WALLET_DEFAULT_CURRENCIES, e.g. "USD,BTC".
+	provisioningService := services.NewProvisioningService(walletRepo, currencyService, strings.Split(os.Getenv("WALLET_DEFAULT_CURRENCIES"), ","))
+	// Payments find their recipients in the auth service at AUTH_SERVICE_URL,
+	// authenticated with AUTH_SERVICE_TOKEN.
+	authServiceURL := os.Getenv("AUTH_SERVICE_URL")
+	if authServiceURL == "" {
+		authServiceURL = "http://localhost:8081"
+	}
+	directory := authclient.New(authServiceURL, os.Getenv("AUTH_SERVICE_TOKEN"))
+	paymentService := services.NewPaymentService(paymentRepo, walletRepo, accountRepo, walletService, limitService, feeService, directory)
+	payoutService := services.NewPayoutService(payoutRepo, accountRepo, currencyService, paymentService, limitService, feeService)
+	paymentRequestService := services.NewPaymentRequestService(paymentRequestRepo, accountRepo, walletService)
+	organizationService := services.NewOrganizationService(organizationRepo, currencyService, walletService)
+	voucherService := services.NewVoucherService(voucherRepo, walletRepo, accountRepo, currencyService, feeService)
+	scheduledTransferService := services.NewScheduledTransferService(scheduledTransferRepo, accountRepo, currencyService, limitService, feeService)
+
+	// Extended public keys of the BIP44 accounts deposit addresses are
+	// derived from, e.g. DEPOSIT_XPUB_BTC=xpub6C...
+	depositKeys := make(map[string]string)
+	for _, currency := range []string{"BTC", "ETH"} {
+		if key := os.Getenv("DEPOSIT_XPUB_" + currency); key != "" {
+			depositKeys[currency] = key
+		}
+	}
+	depositAddressService, err := services.NewDepositAddressService(depositAddressRepo, accountRepo, currencyService, depositKeys)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Chain clients of the deposit watcher. Only the simulated chain ships
+	// with the service, DEPOSIT_CHAIN=simulated runs one per deposit key.
+	chainClients := make(map[string]chain.Client)
+	simulatedChains := make(map[string]*chain.Simulated)
+	if os.Getenv("DEPOSIT_CHAIN") == "simulated" {
+		for currency := range depositKeys {
+			simulatedChains[currency] = chain.NewSimulated()
+			chainClients[currency] = simulatedChains[currency]
+		}
+	}
+	depositWatcher := services.NewDepositWatcher(chainDepositRepo, depositAddressRepo, currencyService, chainClients)
+	go depositWatcher.Run(context.Background(), 30*time.Second)
+
+	// Address book changes are mailed through SMTP_ADDR, or logged if it is
+	// not set. New addresses wait ADDRESS_COOLING_OFF, 24h to 48h.
+	var mailer mail.Sender = mail.Log{}
+	if smtpAddr := os.Getenv("SMTP_ADDR"); smtpAddr != "" {
+		mailer = mail.NewSMTP(smtpAddr, os.Getenv("SMTP_FROM"), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"))
+	}
+	coolingOff := services.MinAddressCoolingOff
+	if value := os.Getenv("ADDRESS_COOLING_OFF"); value != "" {
+		coolingOff, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	addressBookService, err := services.NewAddressBookService(addressBookRepo, walletRepo, currencyService, depositAddressService, mailer, coolingOff)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Withdrawals worth more than WITHDRAWAL_APPROVAL_THRESHOLD, in the
+	// limit currency, need an admin's approval.
+	approvalThreshold := 1000.0
+	if threshold := os.Getenv("WITHDRAWAL_APPROVAL_THRESHOLD"); threshold != "" {
+		approvalThreshold, err = strconv.ParseFloat(threshold, 64)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	withdrawalService := services.NewWithdrawalService(withdrawalRepo, accountRepo, walletRepo, currencyService, feeService, limitService, depositAddressService, addressBookService, approvalThreshold)
+
+	// Withdrawals are paid from the simulated chains with the fake signer
+	// until a real signing service is connected.
+	broadcasters := make(map[string]chain.Broadcaster)
+	for currency, simulated := range simulatedChains {
+		broadcasters[currency] = simulated
+	}
+	withdrawalProcessor := services.NewWithdrawalProcessor(withdrawalRepo, withdrawalService, currencyService, feeService, chain.FakeSigner{}, broadcasters)
+	go withdrawalProcessor.Run(context.Background(), 15*time.Second)
+
+	// Reconciliation runs every RECONCILIATION_INTERVAL, daily by default.
+	// Discrepancies are posted to RECONCILIATION_ALERT_URL or logged.
+	var alerts alert.Hook = alert.Log{}
+	if alertURL := os.Getenv("RECONCILIATION_ALERT_URL"); alertURL != "" {
+		alerts = alert.NewWebhook(alertURL)
+	}
+	reconciliationInterval := 24 * time.Hour
+	if value := os.Getenv("RECONCILIATION_INTERVAL"); value != "" {
+		reconciliationInterval, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	reconciliationService := services.NewReconciliationService(reconciliationRepo, currencyService, chainClients, broadcasters, alerts)
+	go reconciliationService.Run(context.Background(), reconciliationInterval)
+
+	// End of day balances are snapshotted once the day is over, checked hourly
+	go balanceSnapshotService.Run(context.Background(), time.Hour)
+
+	// Scheduler worker for scheduled and recurring transfers
+	go scheduledTransferService.Run(context.Background(), 15*time.Second)
+
+	// Handlers setup. Principals acting on someone else's wallet need a
+	// grant with the scope of the route, see handlers.ActingUserHeader.
+	// Organization routes check the acting user's membership instead.
+	holdAccounts := handlers.HoldAccounts(holdService)
+	withdrawalAccounts := handlers.WithdrawalAccounts(withdrawalService)
+	paymentRequestAccounts := handlers.PaymentRequestAccounts(paymentRequestService)
+	scheduledTransferAccounts := handlers.ScheduledTransferAccounts(scheduledTransferService)
+	http.HandleFunc("/get_balance", handlers.UserScope(grantService, models.GrantScopeView, handlers.BalanceHandler(valuationService)))
+	http.HandleFunc("/update_balance", handlers.BodyAccountScope(grantService, models.GrantScopeOwner, "account_number", handlers.Idempotent(idempotencyRepo, handlers.UpdateBalanceHandler(walletService))))
+	http.HandleFunc("/create_purse", handlers.BodyUserScope(grantService, models.GrantScopeOwner, handlers.CreateAccountHandler(walletService)))
+	http.HandleFunc("GET /wallets/{user_id}", handlers.UserScope(grantService, models.GrantScopeView, handlers.WalletHandler(walletService)))
+	http.HandleFunc("GET /accounts/{number}", handlers.AccountScope(grantService, models.GrantScopeView, handlers.AccountHandler(walletService)))
+	http.HandleFunc("GET /accounts/{number}/transactions", handlers.AccountScope(grantService, models.GrantScopeView, handlers.TransactionsHandler(statementService)))
+	http.HandleFunc("GET /accounts/{number}/statement", handlers.AccountScope(grantService, models.GrantScopeView, handlers.StatementHandler(statementService)))
+	http.HandleFunc("GET /accounts/{number}/balance", handlers.AccountScope(grantService, models.GrantScopeView, handlers.BalanceAtHandler(statementService)))
+	http.HandleFunc("GET /accounts/{number}/balance_snapshots", handlers.AccountScope(grantService, models.GrantScopeView, handlers.ListBalanceSnapshotsHandler(balanceSnapshotService)))
+	http.HandleFunc("GET /accounts/{number}/deposit_address", handlers.AccountScope(grantService, models.GrantScopeView, handlers.DepositAddressHandler(depositAddressService)))
+	http.HandleFunc("POST /accounts/{number}/deposit_address", handlers.AccountScope(grantService, models.GrantScopeOwner, handlers.Idempotent(idempotencyRepo, handlers.NewDepositAddressHandler(depositAddressService))))
+	http.HandleFunc("GET /accounts/{number}/deposit_addresses", handlers.AccountScope(grantService, models.GrantScopeView, handlers.ListDepositAddressesHandler(depositAddressService)))
+	http.HandleFunc("GET /accounts/{number}/deposits", handlers.AccountScope(grantService, models.GrantScopeView, handlers.ListChainDepositsHandler(depositWatcher)))
+	http.HandleFunc("GET /accounts/{number}/withdrawals", handlers.AccountScope(grantService, models.GrantScopeView, handlers.ListAccountWithdrawalsHandler(withdrawalService)))
+	http.HandleFunc("POST /accounts/{number}/withdrawals", handlers.AccountScope(grantService, models.GrantScopeOwner, handlers.Idempotent(idempotencyRepo, handlers.RequestWithdrawalHandler(withdrawalService))))
+	http.HandleFunc("GET /withdrawals/{id}", handlers.ResourceScope(grantService, models.GrantScopeView, withdrawalAccounts, handlers.GetWithdrawalHandler(withdrawalService)))
+	http.HandleFunc("POST /withdrawals/{id}/cancel", handlers.ResourceScope(grantService, models.GrantScopeOwner, withdrawalAccounts, handlers.CancelWithdrawalHandler(withdrawalService)))
+	http.HandleFunc("GET /accounts/{number}/holds", handlers.AccountScope(grantService, models.GrantScopeView, handlers.ListHoldsHandler(holdService)))
+	http.HandleFunc("POST /accounts/{number}/holds", handlers.AccountScope(grantService, models.GrantScopeTrade, handlers.Idempotent(idempotencyRepo, handlers.PlaceHoldHandler(holdService))))
+	http.HandleFunc("GET /holds/{id}", handlers.ResourceScope(grantService, models.GrantScopeView, holdAccounts, handlers.GetHoldHandler(holdService)))
+	http.HandleFunc("POST /holds/{id}/release", handlers.ResourceScope(grantService, models.GrantScopeTrade, holdAccounts, handlers.Idempotent(idempotencyRepo, handlers.ReleaseHoldHandler(holdService))))
+	http.HandleFunc("POST /holds/{id}/capture", handlers.ResourceScope(grantService, models.GrantScopeOwner, holdAccounts, handlers.Idempotent(idempotencyRepo, handlers.CaptureHoldHandler(holdService))))
+	http.HandleFunc("POST /holds/{id}/settle", handlers.ResourceScope(grantService, models.GrantScopeOwner, holdAccounts, handlers.BodyAccountScope(grantService, models.GrantScopeOwner, "payer_account_number", handlers.Idempotent(idempotencyRepo, handlers.SettleTradeHandler(holdService)))))
+	http.HandleFunc("POST /transfers", handlers.BodyAccountScope(grantService, models.GrantScopeOwner, "sender_account_number", handlers.Idempotent(idempotencyRepo, handlers.TransferHandler(walletService))))
+	http.HandleFunc("POST /payments", handlers.BodyAccountScope(grantService, models.GrantScopeOwner, "sender_account_number", handlers.Idempotent(idempotencyRepo, handlers.PayHandler(paymentService))))
+	http.HandleFunc("GET /payments/{id}", handlers.ResourceScope(grantService, models.GrantScopeView, handlers.PaymentAccounts(paymentService), handlers.GetPaymentHandler(paymentService)))
+	http.HandleFunc("GET /accounts/{number}/payments", handlers.AccountScope(grantService, models.GrantScopeView, handlers.ListPaymentsHandler(paymentService)))
+	http.HandleFunc("POST /accounts/{number}/payouts", handlers.AccountScope(grantService, models.GrantScopeOwner, handlers.Idempotent(idempotencyRepo, handlers.SubmitPayoutHandler(payoutService))))
+	http.HandleFunc("GET /payouts/{id}", handlers.ResourceScope(grantService, models.GrantScopeView, handlers.PayoutAccounts(payoutService), handlers.GetPayoutHandler(payoutService)))
+	http.HandleFunc("GET /accounts/{number}/payment_requests", handlers.AccountScope(grantService, models.GrantScopeView, handlers.ListPaymentRequestsHandler(paymentRequestService)))
+	http.HandleFunc("POST /accounts/{number}/payment_requests", handlers.AccountScope(grantService, models.GrantScopeOwner, handlers.Idempotent(idempotencyRepo, handlers.CreatePaymentRequestHandler(paymentRequestService))))
+	http.HandleFunc("GET /payment_requests/{reference}", handlers.ResourceScope(grantService, models.GrantScopeView, paymentRequestAccounts, handlers.GetPaymentRequestHandler(paymentRequestService)))
+	http.HandleFunc("POST /payment_requests/{reference}/pay", handlers.BodyAccountScope(grantService, models.GrantScopeOwner, "payer_account_number", handlers.Idempotent(idempotencyRepo, handlers.PayPaymentRequestHandler(paymentRequestService))))
+	http.HandleFunc("POST /payment_requests/{reference}/cancel", handlers.ResourceScope(grantService, models.GrantScopeOwner, paymentRequestAccounts, handlers.CancelPaymentRequestHandler(paymentRequestService)))
+	http.HandleFunc("POST /organizations", handlers.Idempotent(idempotencyRepo, handlers.CreateOrganizationHandler(organizationService)))
+	http.HandleFunc("GET /organizations/{id}", handlers.GetOrganizationHandler(organizationService))
+	http.HandleFunc("POST /organizations/{id}/members", handlers.SetOrganizationMemberHandler(organizationService))
+	http.HandleFunc("DELETE /organizations/{id}/members/{member_id}", handlers.RemoveOrganizationMemberHandler(organizationService))
+	http.HandleFunc("GET /organizations/{id}/accounts", handlers.ListOrganizationAccountsHandler(organizationService))
+	http.HandleFunc("POST /organizations/{id}/accounts", handlers.Idempotent(idempotencyRepo, handlers.OpenOrganizationAccountHandler(organizationService)))
+	http.HandleFunc("PUT /organizations/{id}/thresholds/{currency}", handlers.SetOrganizationThresholdHandler(organizationService))
+	http.HandleFunc("GET /organizations/{id}/transfers", handlers.ListOrganizationTransfersHandler(organizationService))
+	http.HandleFunc("POST /organizations/{id}/transfers", handlers.Idempotent(idempotencyRepo, handlers.InitiateOrganizationTransferHandler(organizationService)))
+	http.HandleFunc("GET /organizations/{id}/transfers/{transfer_id}", handlers.GetOrganizationTransferHandler(organizationService))
+	http.HandleFunc("POST /organizations/{id}/transfers/{transfer_id}/approve", handlers.Idempotent(idempotencyRepo, handlers.ApproveOrganizationTransferHandler(organizationService)))
+	http.HandleFunc("POST /organizations/{id}/transfers/{transfer_id}/reject", handlers.RejectOrganizationTransferHandler(organizationService))
+	http.HandleFunc("POST /organizations/{id}/transfers/{transfer_id}/cancel", handlers.CancelOrganizationTransferHandler(organizationService))
+	http.HandleFunc("GET /organizations/{id}/activity", handlers.ListOrganizationActivityHandler(organizationService))
+	http.HandleFunc("GET /accounts/{number}/scheduled_transfers", handlers.AccountScope(grantService, models.GrantScopeView, handlers.ListScheduledTransfersHandler(scheduledTransferService)))
+	http.HandleFunc("POST /accounts/{number}/scheduled_transfers", handlers.AccountScope(grantService, models.GrantScopeOwner, handlers.Idempotent(idempotencyRepo, handlers.ScheduleTransferHandler(scheduledTransferService))))
+	http.HandleFunc("GET /scheduled_transfers/{id}", handlers.ResourceScope(grantService, models.GrantScopeView, scheduledTransferAccounts, handlers.GetScheduledTransferHandler(scheduledTransferService)))
+	http.HandleFunc("GET /scheduled_transfers/{id}/runs", handlers.ResourceScope(grantService, models.GrantScopeView, scheduledTransferAccounts, handlers.ListScheduledTransferRunsHandler(scheduledTransferService)))
+	http.HandleFunc("POST /scheduled_transfers/{id}/pause", handlers.ResourceScope(grantService, models.GrantScopeOwner, scheduledTransferAccounts, handlers.PauseScheduledTransferHandler(scheduledTransferService)))
+	http.HandleFunc("POST /scheduled_transfers/{id}/resume", handlers.ResourceScope(grantService, models.GrantScopeOwner, scheduledTransferAccounts, handlers.ResumeScheduledTransferHandler(scheduledTransferService)))
+	http.HandleFunc("POST /scheduled_transfers/{id}/cancel", handlers.ResourceScope(grantService, models.GrantScopeOwner, scheduledTransferAccounts, handlers.CancelScheduledTransferHandler(scheduledTransferService)))
+	http.HandleFunc("GET /users/{user_id}/grants", handlers.UserScope(grantService, models.GrantScopeOwner, handlers.ListGrantsHandler(grantService)))
+	http.HandleFunc("POST /users/{user_id}/grants", handlers.UserScope(grantService, models.GrantScopeOwner, handlers.CreateGrantHandler(grantService)))
+	http.HandleFunc("DELETE /users/{user_id}/grants/{id}", handlers.UserScope(grantService, models.GrantScopeOwner, handlers.RevokeGrantHandler(grantService)))
+	http.HandleFunc("POST /vouchers/redeem", handlers.BodyUserScope(grantService, models.GrantScopeOwner, handlers.Idempotent(idempotencyRepo, handlers.RedeemVoucherHandler(voucherService))))
+	http.HandleFunc("GET /currencies", handlers.ListCurrenciesHandler(currencyService))
+	http.HandleFunc("GET /currencies/{code}", handlers.GetCurrencyHandler(currencyService))
+	http.HandleFunc("GET /users/{user_id}/address_book", handlers.UserScope(grantService, models.GrantScopeView, handlers.ListAddressesHandler(addressBookService)))
+	http.HandleFunc("POST /users/{user_id}/address_book", handlers.UserScope(grantService, models.GrantScopeOwner, handlers.Idempotent(idempotencyRepo, handlers.AddAddressHandler(addressBookService))))
+	http.HandleFunc("PATCH /users/{user_id}/address_book/{id}", handlers.UserScope(grantService, models.GrantScopeOwner, handlers.UpdateAddressHandler(addressBookService)))
+	http.HandleFunc("DELETE /users/{user_id}/address_book/{id}", handlers.UserScope(grantService, models.GrantScopeOwner, handlers.DeleteAddressHandler(addressBookService)))
+	http.HandleFunc("GET /users/{user_id}/address_book/settings", handlers.UserScope(grantService, models.GrantScopeView, handlers.AddressBookSettingsHandler(addressBookService)))
+	http.HandleFunc("PUT /users/{user_id}/address_book/settings", handlers.UserScope(grantService, models.GrantScopeOwner, handlers.UpdateAddressBookSettingsHandler(addressBookService)))
+	http.HandleFunc("GET /limits", handlers.UserScope(grantService, models.GrantScopeView, handlers.LimitsHandler(limitService)))
+	http.HandleFunc("GET /fees", handlers.FeeScheduleHandler(feeService))
+	http.HandleFunc("GET /fees/quote", handlers.FeeQuoteHandler(feeService, walletService))
+
+	// Events and limit charges of the other services, authorized by
+	// WALLET_EVENTS_TOKEN
+	eventsToken := os.Getenv("WALLET_EVENTS_TOKEN")
+	http.HandleFunc("POST /events/user_registered", handlers.AdminOnly(eventsToken, handlers.UserRegisteredHandler(provisioningService)))
+	http.HandleFunc("POST /users/{user_id}/limit_charges", handlers.AdminOnly(eventsToken, handlers.Idempotent(idempotencyRepo, handlers.ChargeLimitHandler(limitService))))
+	http.HandleFunc("DELETE /limit_charges/{id}", handlers.AdminOnly(eventsToken, handlers.ReleaseLimitHandler(limitService)))
+
+	// Admin endpoints. Withdrawal approvals record the admin, so they take
+	// the personal tokens of WALLET_ADMINS (name:token,...) instead.
+	adminToken := os.Getenv("WALLET_ADMIN_TOKEN")
+	admins, err := handlers.ParseAdmins(os.Getenv("WALLET_ADMINS"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	http.HandleFunc("POST /admin/accounts/{number}/freeze", handlers.AdminOnly(adminToken, handlers.FreezeAccountHandler(walletService)))
+	http.HandleFunc("POST /admin/accounts/{number}/unfreeze", handlers.AdminOnly(adminToken, handlers.UnfreezeAccountHandler(walletService)))
+	http.HandleFunc("POST /admin/accounts/{number}/close", handlers.AdminOnly(adminToken, handlers.CloseAccountHandler(walletService)))
+	http.HandleFunc("POST /admin/currencies", handlers.AdminOnly(adminToken, handlers.CreateCurrencyHandler(currencyService)))
+	http.HandleFunc("PUT /admin/currencies/{code}", handlers.AdminOnly(adminToken, handlers.UpdateCurrencyHandler(currencyService)))
+	http.HandleFunc("PUT /admin/users/{user_id}/levels", handlers.AdminOnly(adminToken, handlers.SetLevelsHandler(limitService)))
+	http.HandleFunc("PUT /admin/users/{user_id}/limits", handlers.AdminOnly(adminToken, handlers.SetLimitOverrideHandler(limitService)))
+	http.HandleFunc("DELETE /admin/users/{user_id}/limits/{kind}/{period}", handlers.AdminOnly(adminToken, handlers.DeleteLimitOverrideHandler(limitService)))
+	http.HandleFunc("POST /admin/fees", handlers.AdminOnly(adminToken, handlers.CreateFeeRuleHandler(feeService)))
+	http.HandleFunc("DELETE /admin/fees/{id}", handlers.AdminOnly(adminToken, handlers.DeleteFeeRuleHandler(feeService)))
+	http.HandleFunc("POST /admin/chains/{currency}/blocks", handlers.AdminOnly(adminToken, handlers.MineBlockHandler(simulatedChains)))
+	http.HandleFunc("POST /admin/chains/{currency}/reorg", handlers.AdminOnly(adminToken, handlers.ReorgHandler(simulatedChains)))
+	http.HandleFunc("PUT /admin/fee_discounts/{min_rating}", handlers.AdminOnly(adminToken, handlers.SetFeeDiscountHandler(feeService)))
+	http.HandleFunc("POST /admin/vouchers", handlers.AdminOnly(adminToken, handlers.MintVouchersHandler(voucherService)))
+	http.HandleFunc("GET /admin/vouchers/{id}", handlers.AdminOnly(adminToken, handlers.GetVoucherHandler(voucherService)))
+	http.HandleFunc("GET /admin/vouchers/{id}/redemptions", handlers.AdminOnly(adminToken, handlers.ListVoucherRedemptionsHandler(voucherService)))
+	http.HandleFunc("POST /admin/vouchers/{id}/disable", handlers.AdminOnly(adminToken, handlers.DisableVoucherHandler(voucherService)))
+	http.HandleFunc("POST /admin/balance_snapshots", handlers.AdminOnly(adminToken, handlers.SnapshotBalancesHandler(balanceSnapshotService)))
+	http.HandleFunc("POST /admin/reconciliations", handlers.AdminOnly(adminToken, handlers.ReconcileHandler(reconciliationService)))
+	http.HandleFunc("GET /admin/reconciliations", handlers.AdminOnly(adminToken, handlers.ListReconciliationsHandler(reconciliationService)))
+	http.HandleFunc("GET /admin/reconciliations/{id}", handlers.AdminOnly(adminToken, handlers.GetReconciliationHandler(reconciliationService)))
+	http.HandleFunc("GET /admin/withdrawals", handlers.AdminOnly(adminToken, handlers.ListWithdrawalsHandler(withdrawalService)))
+	http.HandleFunc("POST /admin/withdrawals/{id}/approve", handlers.NamedAdminOnly(admins, handlers.ApproveWithdrawalHandler(withdrawalService)))
+	http.HandleFunc("POST /admin/withdrawals/{id}/reject", handlers.NamedAdminOnly(admins, handlers.RejectWithdrawalHandler(withdrawalService)))
 
 	// Start HTTP server
 	log.Fatal(http.ListenAndServe(":8080", nil))
@@ -1,8 +1,24 @@
 package models
 
+import "time"
+
+// Account states. Only active accounts can send or receive money; closed is
+// final.
+const (
+	AccountActive = "active"
+	AccountFrozen = "frozen"
+	AccountClosed = "closed"
+)
+
 type Account struct {
-	ID            int     `json:"id"`
-	AccountNumber string  `json:"account_number"`
-	Balance       float64 `json:"balance"`
-	Active        bool    `json:"active"`
+	ID              int       `json:"id"`
+	AccountNumber   string    `json:"account_number"`
+	Currency        string    `json:"currency"`
+	Balance         float64   `json:"balance"`
+	HeldBalance     float64   `json:"held_balance"`
+	Available       float64   `json:"available_balance"`
+	Active          bool      `json:"active"`
+	Status          string    `json:"status"`
+	StatusReason    string    `json:"status_reason,omitempty"`
+	StatusChangedAt time.Time `json:"status_changed_at"`
 }
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// AddressBookEntry is an external address a user withdraws to. A new entry
+// cannot be used before UsableAt, so a hijacked session has to wait while
+// the owner is notified.
+type AddressBookEntry struct {
+	ID        int64     `json:"id"`
+	UserID    int       `json:"user_id"`
+	Currency  string    `json:"currency"`
+	Address   string    `json:"address"`
+	Label     string    `json:"label"`
+	Usable    bool      `json:"usable"`
+	UsableAt  time.Time `json:"usable_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddressBookSettings are kept per user. In whitelist only mode withdrawals
+// go to usable address book entries only. Turning the mode off takes effect
+// at WhitelistUntil, after the same cooling-off as a new address.
+type AddressBookSettings struct {
+	UserID         int        `json:"user_id"`
+	WhitelistOnly  bool       `json:"whitelist_only"`
+	WhitelistUntil *time.Time `json:"whitelist_until,omitempty"`
+	Email          string     `json:"email"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Whitelisted reports whether withdrawals are restricted to the address book
+// at the time.
+func (settings *AddressBookSettings) Whitelisted(at time.Time) bool {
+	return settings.WhitelistOnly || (settings.WhitelistUntil != nil && at.Before(*settings.WhitelistUntil))
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// AccountBalance is the balance an account had at a moment in the past.
+type AccountBalance struct {
+	AccountNumber string    `json:"account_number"`
+	Currency      string    `json:"currency"`
+	At            time.Time `json:"at"`
+	Balance       float64   `json:"balance"`
+}
+
+// BalanceSnapshot is the balance of an account at the end of a day (UTC).
+type BalanceSnapshot struct {
+	Date    string  `json:"date"`
+	Balance float64 `json:"balance"`
+}
+
+type BalanceSnapshotSeries struct {
+	AccountNumber string             `json:"account_number"`
+	Currency      string             `json:"currency"`
+	Snapshots     []*BalanceSnapshot `json:"snapshots"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Chain deposit states. A pending deposit is credited once it has enough
+// confirmations. If a reorg drops its block a pending deposit is orphaned
+// and a credited one is reorged until the credit has been reversed. A
+// deposit that shows up again on the new chain becomes pending again, or
+// credited if it had not been reversed yet.
+const (
+	DepositPending  = "pending"
+	DepositCredited = "credited"
+	DepositOrphaned = "orphaned"
+	DepositReorged  = "reorged"
+	DepositReversed = "reversed"
+)
+
+// ChainDeposit is an output paying one of the deposit addresses, identified
+// by TxID and Vout.
+type ChainDeposit struct {
+	ID            int64      `json:"id"`
+	Currency      string     `json:"currency"`
+	TxID          string     `json:"txid"`
+	Vout          uint32     `json:"vout"`
+	Address       string     `json:"address"`
+	AccountNumber string     `json:"account_number"`
+	Amount        float64    `json:"amount"`
+	BlockHeight   int64      `json:"block_height"`
+	BlockHash     string     `json:"block_hash"`
+	Confirmations int64      `json:"confirmations"`
+	Status        string     `json:"status"`
+	CreatedAt     time.Time  `json:"created_at"`
+	CreditedAt    *time.Time `json:"credited_at,omitempty"`
+}
+
+// ChainBlock is a block the deposit watcher has scanned.
+type ChainBlock struct {
+	Currency string
+	Height   int64
+	Hash     string
+}
@@ -0,0 +1,28 @@
+package models
+
+import "math"
+
+// Currency kinds.
+const (
+	CurrencyFiat   = "fiat"
+	CurrencyCrypto = "crypto"
+)
+
+// Currency is an entry of the supported currency registry. Amounts in the
+// currency are kept with Decimals places and may not be below MinAmount.
+// On-chain deposits of a cryptocurrency are credited once they have
+// Confirmations blocks.
+type Currency struct {
+	Code          string  `json:"code"`
+	Kind          string  `json:"kind"`
+	Decimals      int     `json:"decimals"`
+	MinAmount     float64 `json:"min_amount"`
+	Enabled       bool    `json:"enabled"`
+	Confirmations int     `json:"confirmations"`
+}
+
+// Round rounds amount half away from zero to the precision of the currency.
+func (currency *Currency) Round(amount float64) float64 {
+	scale := math.Pow10(currency.Decimals)
+	return math.Round(amount*scale) / scale
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// DepositAddress is an on-chain address funds for an account are received
+// at. It is derived from the service's extended public key for the chain at
+// DerivationPath.
+type DepositAddress struct {
+	ID              int64     `json:"id"`
+	AccountNumber   string    `json:"account_number"`
+	Currency        string    `json:"currency"`
+	Address         string    `json:"address"`
+	DerivationIndex uint32    `json:"derivation_index"`
+	DerivationPath  string    `json:"derivation_path"`
+	CreatedAt       time.Time `json:"created_at"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Operations that are charged a fee.
+const (
+	FeeTransfer   = "transfer"
+	FeeWithdrawal = "withdrawal"
+	FeeTrade      = "trade"
+)
+
+// FeeAnyCurrency marks a rule that applies to every currency without a rule
+// of its own.
+const FeeAnyCurrency = "*"
+
+// FeeRule is one entry of the fee schedule. The fee is Flat plus Percent of
+// the amount, kept between MinFee and MaxFee (0 means no maximum).
+type FeeRule struct {
+	ID        int64   `json:"id"`
+	Operation string  `json:"operation"`
+	Currency  string  `json:"currency"`
+	MinAmount float64 `json:"min_amount"`
+	Flat      float64 `json:"flat"`
+	Percent   float64 `json:"percent"`
+	MinFee    float64 `json:"min_fee"`
+	MaxFee    float64 `json:"max_fee"`
+}
+
+type FeeDiscount struct {
+	MinRating int     `json:"min_rating"`
+	Percent   float64 `json:"percent"`
+}
+
+type FeeSchedule struct {
+	Rules     []*FeeRule     `json:"rules"`
+	Discounts []*FeeDiscount `json:"discounts"`
+}
+
+// FeeItem is a fee charged for an operation, after the rating discount.
+type FeeItem struct {
+	Type          string  `json:"type"`
+	AccountNumber string  `json:"account_number"`
+	Currency      string  `json:"currency"`
+	Amount        float64 `json:"amount"`
+	RuleID        int64   `json:"rule_id"`
+	Discount      float64 `json:"discount_percent,omitempty"`
+}
+
+// Receipt itemises a money movement. Total is what was taken from
+// AccountNumber, Net what reached the counterparty.
+type Receipt struct {
+	Operation                 string    `json:"operation"`
+	AccountNumber             string    `json:"account_number"`
+	CounterpartyAccountNumber string    `json:"counterparty_account_number,omitempty"`
+	Currency                  string    `json:"currency"`
+	Amount                    float64   `json:"amount"`
+	Fees                      []FeeItem `json:"fees"`
+	Total                     float64   `json:"total"`
+	Net                       float64   `json:"net"`
+	CreatedAt                 time.Time `json:"created_at"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Grant scopes. A grant lets its holder view the owner's balances and
+// history, or place and cancel orders for them as well. GrantScopeOwner is
+// never granted: moving money out of a wallet and managing its grants take
+// the owner themself.
+const (
+	GrantScopeView  = "view"
+	GrantScopeTrade = "trade"
+	GrantScopeOwner = "owner"
+)
+
+// Grant gives another user, or the holder of an API key, scoped access to
+// the owner's wallet until it expires or is revoked. The API key is shown
+// once, when the grant is created, only its hash is stored.
+type Grant struct {
+	ID            int64      `json:"id"`
+	OwnerID       int        `json:"owner_id"`
+	GranteeUserID *int       `json:"grantee_user_id,omitempty"`
+	APIKey        string     `json:"api_key,omitempty"`
+	Scopes        []string   `json:"scopes"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// Principal is who acts on a wallet: a user or the holder of an API key.
+type Principal struct {
+	UserID int
+	APIKey string
+}
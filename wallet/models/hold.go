@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Hold kinds describe why funds are reserved.
+const (
+	HoldOrder      = "order"
+	HoldWithdrawal = "withdrawal"
+	HoldDispute    = "dispute"
+)
+
+// Hold states. Only active holds count towards the held balance.
+const (
+	HoldActive   = "active"
+	HoldReleased = "released"
+	HoldCaptured = "captured"
+)
+
+// Hold reserves part of an account balance without moving it.
+type Hold struct {
+	ID            int64     `json:"id"`
+	AccountNumber string    `json:"account_number"`
+	Amount        float64   `json:"amount"`
+	Kind          string    `json:"kind"`
+	Reference     string    `json:"reference,omitempty"`
+	Status        string    `json:"status"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
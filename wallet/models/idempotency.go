@@ -0,0 +1,10 @@
+package models
+
+// IdempotencyRecord remembers the response of a request made with an
+// Idempotency-Key header. StatusCode is zero while the request is running.
+type IdempotencyRecord struct {
+	Key          string
+	RequestHash  string
+	StatusCode   int
+	ResponseBody []byte
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Operations limited per user.
+const (
+	LimitWithdrawal = "withdrawal"
+	LimitTransfer   = "transfer"
+	LimitOrder      = "order"
+)
+
+// Periods a limit applies to. Days and months start at midnight UTC.
+const (
+	LimitDaily   = "day"
+	LimitMonthly = "month"
+)
+
+// Limit is the allowance of a user for one kind of operation in one period,
+// taken from the tier of the user's levels or from an admin override.
+type Limit struct {
+	Kind       string  `json:"kind"`
+	Period     string  `json:"period"`
+	Amount     float64 `json:"amount"`
+	Overridden bool    `json:"overridden"`
+}
+
+type LimitUsage struct {
+	Limit
+	Used      float64   `json:"used"`
+	Remaining float64   `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+type UserLimits struct {
+	UserID      int           `json:"user_id"`
+	AccessLevel int           `json:"access_level"`
+	RatingLevel int           `json:"rating_level"`
+	Currency    string        `json:"currency"`
+	Limits      []*LimitUsage `json:"limits"`
+}
+
+type LimitOverride struct {
+	UserID    int       `json:"user_id"`
+	Kind      string    `json:"kind"`
+	Period    string    `json:"period"`
+	Amount    float64   `json:"amount"`
+	Reason    string    `json:"reason"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LimitCharge is an operation counted against the limits of a user. Amount
+// is in the limit currency.
+type LimitCharge struct {
+	ID        int64     `json:"id"`
+	UserID    int       `json:"user_id"`
+	Kind      string    `json:"kind"`
+	Amount    float64   `json:"amount"`
+	Reference string    `json:"reference"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Movement types recorded for every change of an account balance.
+const (
+	MovementDeposit     = "deposit"
+	MovementWithdrawal  = "withdrawal"
+	MovementTransferIn  = "transfer_in"
+	MovementTransferOut = "transfer_out"
+	MovementFee         = "fee"
+	// A chain deposit taken back after a reorg dropped its block.
+	MovementDepositReversal = "deposit_reversal"
+	// The balance an account had before its movements were recorded.
+	MovementOpening = "opening"
+)
+
+// Movement is a single entry of an account's history. Amount is signed:
+// credits are positive and debits are negative.
+type Movement struct {
+	ID            int64     `json:"id"`
+	AccountNumber string    `json:"account_number"`
+	Type          string    `json:"type"`
+	Amount        float64   `json:"amount"`
+	BalanceAfter  float64   `json:"balance_after"`
+	Counterparty  string    `json:"counterparty,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// MovementFilter narrows down the history of an account. Zero values mean
+// "no restriction"; amounts are compared against the absolute amount.
+type MovementFilter struct {
+	From      time.Time
+	To        time.Time
+	Types     []string
+	MinAmount float64
+	MaxAmount float64
+	BeforeID  int64
+	Limit     int
+}
+
+type MovementPage struct {
+	Transactions []*Movement `json:"transactions"`
+	NextCursor   string      `json:"next_cursor,omitempty"`
+}
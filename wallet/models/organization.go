@@ -0,0 +1,74 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Organization member roles, each allowed what the ones before it are.
+// Viewers see the accounts, initiators start transfers and approvers approve
+// them and manage the organization.
+const (
+	OrganizationViewer    = "viewer"
+	OrganizationInitiator = "initiator"
+	OrganizationApprover  = "approver"
+)
+
+// Organization transfer states. A pending transfer waits for an approver
+// other than the initiator.
+const (
+	OrganizationTransferPending   = "pending"
+	OrganizationTransferExecuted  = "executed"
+	OrganizationTransferRejected  = "rejected"
+	OrganizationTransferCancelled = "cancelled"
+	OrganizationTransferFailed    = "failed"
+)
+
+// Organization owns accounts shared by its members. Transfers above the
+// threshold of their currency need approval, currencies without a
+// threshold always do.
+type Organization struct {
+	ID         int64                 `json:"id"`
+	Name       string                `json:"name"`
+	Members    []*OrganizationMember `json:"members"`
+	Accounts   []string              `json:"accounts"`
+	Thresholds map[string]float64    `json:"thresholds"`
+	CreatedAt  time.Time             `json:"created_at"`
+}
+
+type OrganizationMember struct {
+	UserID    int       `json:"user_id"`
+	Role      string    `json:"role"`
+	AddedBy   int       `json:"added_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OrganizationTransfer is a transfer from an account of the organization,
+// with the members who initiated and decided on it.
+type OrganizationTransfer struct {
+	ID                    int64     `json:"id"`
+	OrganizationID        int64     `json:"organization_id"`
+	SenderAccountNumber   string    `json:"sender_account_number"`
+	ReceiverAccountNumber string    `json:"receiver_account_number"`
+	Currency              string    `json:"currency"`
+	Amount                float64   `json:"amount"`
+	Memo                  string    `json:"memo,omitempty"`
+	RequiresApproval      bool      `json:"requires_approval"`
+	InitiatedBy           int       `json:"initiated_by"`
+	ApprovedBy            *int      `json:"approved_by,omitempty"`
+	RejectedBy            *int      `json:"rejected_by,omitempty"`
+	Reason                string    `json:"reason,omitempty"`
+	Status                string    `json:"status"`
+	Receipt               *Receipt  `json:"receipt,omitempty"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+// OrganizationActivity records who did what in the organization.
+type OrganizationActivity struct {
+	ID        int64           `json:"id"`
+	UserID    int             `json:"user_id"`
+	Action    string          `json:"action"`
+	Details   json.RawMessage `json:"details"`
+	CreatedAt time.Time       `json:"created_at"`
+}
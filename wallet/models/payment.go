@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Receipt operations of a payment to another user.
+const (
+	PaymentSent     = "payment_sent"
+	PaymentReceived = "payment_received"
+)
+
+// DirectoryUser is a user found in the auth service. UserID is the auth
+// service's id of the user.
+type DirectoryUser struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// Payment is a transfer to another user, addressed by username or email
+// instead of an account number.
+type Payment struct {
+	ID                     int64     `json:"id"`
+	SenderAccountNumber    string    `json:"sender_account_number"`
+	RecipientAccountNumber string    `json:"recipient_account_number"`
+	RecipientUsername      string    `json:"recipient_username"`
+	Currency               string    `json:"currency"`
+	Amount                 float64   `json:"amount"`
+	Fee                    float64   `json:"fee"`
+	Memo                   string    `json:"memo,omitempty"`
+	CreatedAt              time.Time `json:"created_at"`
+}
+
+// PaymentReceipt holds the receipts of both parties of a payment.
+type PaymentReceipt struct {
+	Payment   *Payment `json:"payment"`
+	Sender    *Receipt `json:"sender"`
+	Recipient *Receipt `json:"recipient"`
+}
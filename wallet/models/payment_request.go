@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Payment request states. An open request past its expiry is reported as
+// expired.
+const (
+	PaymentRequestOpen      = "open"
+	PaymentRequestPaid      = "paid"
+	PaymentRequestExpired   = "expired"
+	PaymentRequestCancelled = "cancelled"
+)
+
+// PaymentRequest asks another user to pay Amount into PayeeAccountNumber.
+// Reference is the id that is shared with the payer.
+type PaymentRequest struct {
+	ID                 int64      `json:"id"`
+	Reference          string     `json:"reference"`
+	PayeeAccountNumber string     `json:"payee_account_number"`
+	Currency           string     `json:"currency"`
+	Amount             float64    `json:"amount"`
+	Memo               string     `json:"memo,omitempty"`
+	Status             string     `json:"status"`
+	ExpiresAt          time.Time  `json:"expires_at"`
+	PayerAccountNumber string     `json:"payer_account_number,omitempty"`
+	PaidAt             *time.Time `json:"paid_at,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// PaidPaymentRequest is a settled payment request with the payer's receipt.
+type PaidPaymentRequest struct {
+	PaymentRequest *PaymentRequest `json:"payment_request"`
+	Receipt        *Receipt        `json:"receipt"`
+}
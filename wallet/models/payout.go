@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// Payout modes. All or nothing pays every line in one transaction or none,
+// best effort pays the lines one by one and reports the ones that failed.
+const (
+	PayoutAllOrNothing = "all_or_nothing"
+	PayoutBestEffort   = "best_effort"
+)
+
+// Payout states. A preview is the result of a dry run and is not stored, a
+// rejected all or nothing payout had invalid lines and paid nothing.
+const (
+	PayoutPreview            = "preview"
+	PayoutRejected           = "rejected"
+	PayoutProcessing         = "processing"
+	PayoutCompleted          = "completed"
+	PayoutPartiallyCompleted = "partially_completed"
+	PayoutFailed             = "failed"
+)
+
+// Payout line states. A duplicate line has a reference that was paid
+// before, by this or an earlier payout of the account.
+const (
+	PayoutLineValid     = "valid"
+	PayoutLineInvalid   = "invalid"
+	PayoutLinePaid      = "paid"
+	PayoutLineFailed    = "failed"
+	PayoutLineDuplicate = "duplicate"
+)
+
+// Payout pays many recipients from one account.
+type Payout struct {
+	ID            int64         `json:"id,omitempty"`
+	AccountNumber string        `json:"account_number"`
+	Currency      string        `json:"currency"`
+	Mode          string        `json:"mode"`
+	Status        string        `json:"status"`
+	LineCount     int           `json:"line_count"`
+	PaidCount     int           `json:"paid_count"`
+	FailedCount   int           `json:"failed_count"`
+	TotalAmount   float64       `json:"total_amount"`
+	TotalFees     float64       `json:"total_fees"`
+	Available     float64       `json:"available,omitempty"`
+	Lines         []*PayoutLine `json:"lines"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+// PayoutLine is one recipient of a payout. Line is the line number in the
+// uploaded file. Recipient is an account number, username or email.
+type PayoutLine struct {
+	Line                   int      `json:"line"`
+	Recipient              string   `json:"recipient"`
+	RecipientAccountNumber string   `json:"recipient_account_number,omitempty"`
+	Amount                 float64  `json:"amount"`
+	Currency               string   `json:"currency"`
+	Reference              string   `json:"reference"`
+	Fee                    float64  `json:"fee"`
+	FeeItem                *FeeItem `json:"-"`
+	Status                 string   `json:"status"`
+	Error                  string   `json:"error,omitempty"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Rate is the price of one unit of Base expressed in Quote.
+type Rate struct {
+	Base  string    `json:"base"`
+	Quote string    `json:"quote"`
+	Value float64   `json:"value"`
+	AsOf  time.Time `json:"as_of"`
+}
+
+// Value is nil when no rate to the quote currency is known; such accounts
+// are left out of the total.
+type AccountValuation struct {
+	AccountNumber string   `json:"account_number"`
+	Currency      string   `json:"currency"`
+	Balance       float64  `json:"balance"`
+	Available     float64  `json:"available_balance"`
+	Value         *float64 `json:"value"`
+}
+
+// WalletValuation is a wallet with every account valued in QuoteCurrency.
+// Rates lists the exchange rates the total was computed with and Unpriced
+// the currencies that had no rate.
+type WalletValuation struct {
+	UserID        int                 `json:"user_id"`
+	QuoteCurrency string              `json:"quote_currency"`
+	Accounts      []*AccountValuation `json:"accounts"`
+	Total         float64             `json:"total"`
+	Rates         []Rate              `json:"rates"`
+	Unpriced      []string            `json:"unpriced_currencies"`
+}
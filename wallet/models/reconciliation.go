@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// AccountDrift is an account whose balances do not match its history. The
+// balance should be the sum of the movements and the held balance the sum
+// of the active holds.
+type AccountDrift struct {
+	AccountNumber string  `json:"account_number"`
+	Currency      string  `json:"currency"`
+	Balance       float64 `json:"balance"`
+	MovementTotal float64 `json:"movement_total"`
+	Drift         float64 `json:"drift"`
+	HeldBalance   float64 `json:"held_balance"`
+	ActiveHolds   float64 `json:"active_holds"`
+	HeldDrift     float64 `json:"held_drift"`
+}
+
+// ChainDrift compares what the accounts of a crypto currency hold in total
+// with what the chain shows the service received and paid out.
+type ChainDrift struct {
+	Currency string `json:"currency"`
+	// Liabilities is the sum of all account balances in the currency.
+	Liabilities float64 `json:"liabilities"`
+	// Deposits and Withdrawals are the booked chain deposits and confirmed
+	// withdrawals.
+	Deposits    float64 `json:"deposits"`
+	Withdrawals float64 `json:"withdrawals"`
+	// OnChain is deposits minus withdrawals, counting only those the chain
+	// client still confirms.
+	OnChain float64 `json:"on_chain"`
+	Drift   float64 `json:"drift"`
+	// Missing lists the deposit outputs (txid:vout) and withdrawal txids the
+	// chain does not know.
+	Missing []string `json:"missing,omitempty"`
+}
+
+type ReconciliationReport struct {
+	ID              int64          `json:"id"`
+	StartedAt       time.Time      `json:"started_at"`
+	FinishedAt      time.Time      `json:"finished_at"`
+	AccountsChecked int            `json:"accounts_checked"`
+	Discrepancies   int            `json:"discrepancies"`
+	Accounts        []AccountDrift `json:"accounts"`
+	Chains          []ChainDrift   `json:"chains"`
+}
+
+// LedgerSnapshot is the part of the ledger reconciliation looks at, read in
+// a single transaction.
+type LedgerSnapshot struct {
+	AccountsChecked int
+	Accounts        []AccountDrift
+	// Liabilities per currency code.
+	Liabilities map[string]float64
+	Deposits    []*ChainDeposit
+	Withdrawals []*Withdrawal
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Kinds of scheduled transfers.
+const (
+	ScheduleOnce     = "once"
+	ScheduleInterval = "interval"
+	ScheduleCron     = "cron"
+)
+
+// Scheduled transfer states. Completed, cancelled and failed transfers are
+// not executed anymore, a failed one can be resumed.
+const (
+	ScheduleActive    = "active"
+	SchedulePaused    = "paused"
+	ScheduleCancelled = "cancelled"
+	ScheduleCompleted = "completed"
+	ScheduleFailed    = "failed"
+)
+
+// Outcomes of a scheduled transfer run.
+const (
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+type ScheduledTransfer struct {
+	ID                    int64      `json:"id"`
+	SenderAccountNumber   string     `json:"sender_account_number"`
+	ReceiverAccountNumber string     `json:"receiver_account_number"`
+	Amount                float64    `json:"amount"`
+	Kind                  string     `json:"kind"`
+	IntervalSeconds       int        `json:"interval_seconds,omitempty"`
+	Cron                  string     `json:"cron,omitempty"`
+	EndAt                 *time.Time `json:"end_at,omitempty"`
+	NextRunAt             time.Time  `json:"next_run_at"`
+	RetryAt               *time.Time `json:"retry_at,omitempty"`
+	Status                string     `json:"status"`
+	Attempts              int        `json:"attempts"`
+	Runs                  int        `json:"runs"`
+	LastRunAt             *time.Time `json:"last_run_at,omitempty"`
+	LastError             string     `json:"last_error,omitempty"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+type ScheduledTransferRun struct {
+	ID                  int64     `json:"id"`
+	ScheduledTransferID int64     `json:"scheduled_transfer_id"`
+	ScheduledFor        time.Time `json:"scheduled_for"`
+	Status              string    `json:"status"`
+	Error               string    `json:"error,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+}
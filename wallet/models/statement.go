@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Statement periods supported by the statement export.
+const (
+	PeriodDay   = "day"
+	PeriodMonth = "month"
+)
+
+type StatementPeriod struct {
+	Start          time.Time   `json:"start"`
+	End            time.Time   `json:"end"`
+	OpeningBalance float64     `json:"opening_balance"`
+	ClosingBalance float64     `json:"closing_balance"`
+	Credits        float64     `json:"credits"`
+	Debits         float64     `json:"debits"`
+	Movements      []*Movement `json:"movements"`
+}
+
+type Statement struct {
+	AccountNumber  string             `json:"account_number"`
+	Currency       string             `json:"currency"`
+	Decimals       int                `json:"decimals"`
+	From           time.Time          `json:"from"`
+	To             time.Time          `json:"to"`
+	OpeningBalance float64            `json:"opening_balance"`
+	ClosingBalance float64            `json:"closing_balance"`
+	Periods        []*StatementPeriod `json:"periods"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Voucher states. Only active vouchers are stored as such, an active
+// voucher that is used up or past its expiry reads as exhausted or expired.
+const (
+	VoucherActive    = "active"
+	VoucherExhausted = "exhausted"
+	VoucherExpired   = "expired"
+	VoucherDisabled  = "disabled"
+)
+
+// Voucher pays Amount out of the house account of Currency to every user
+// redeeming its code, up to MaxRedemptions users. The code is shown once,
+// when the voucher is minted, CodeSuffix tells vouchers apart afterwards.
+type Voucher struct {
+	ID             int64     `json:"id"`
+	Code           string    `json:"code,omitempty"`
+	CodeSuffix     string    `json:"code_suffix"`
+	Currency       string    `json:"currency"`
+	Amount         float64   `json:"amount"`
+	MaxRedemptions int       `json:"max_redemptions"`
+	Redemptions    int       `json:"redemptions"`
+	Status         string    `json:"status"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type VoucherRedemption struct {
+	ID            int64     `json:"id"`
+	VoucherID     int64     `json:"voucher_id"`
+	UserID        int       `json:"user_id"`
+	AccountNumber string    `json:"account_number"`
+	Currency      string    `json:"currency"`
+	Amount        float64   `json:"amount"`
+	CreatedAt     time.Time `json:"created_at"`
+}
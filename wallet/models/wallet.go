@@ -1,6 +1,18 @@
 package models
 
+import "time"
+
 type Wallet struct {
 	UserID   int      `json:"user_id"`
 	Accounts []string `json:"accounts"`
 }
+
+// UserRegistered is the event the auth service publishes for every new user.
+// UserID is the auth service's id of the user.
+type UserRegistered struct {
+	EventID      int64     `json:"event_id"`
+	UserID       string    `json:"user_id"`
+	Username     string    `json:"username"`
+	Email        string    `json:"email"`
+	RegisteredAt time.Time `json:"registered_at"`
+}
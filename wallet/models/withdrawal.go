@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Withdrawal states. A withdrawal above the approval threshold starts as
+// requested and waits for an admin, smaller ones start approved. From there
+// it is signed, broadcast and confirmed by the withdrawal processor. Failed
+// is final; the reserved funds go back to the account.
+const (
+	WithdrawalRequested = "requested"
+	WithdrawalApproved  = "approved"
+	WithdrawalSigned    = "signed"
+	WithdrawalBroadcast = "broadcast"
+	WithdrawalConfirmed = "confirmed"
+	WithdrawalFailed    = "failed"
+)
+
+// Withdrawal is a payment from an account to an external address. Amount
+// plus Fee stay reserved by the hold until the payment is confirmed.
+type Withdrawal struct {
+	ID               int64     `json:"id"`
+	AccountNumber    string    `json:"account_number"`
+	Currency         string    `json:"currency"`
+	Address          string    `json:"address"`
+	Amount           float64   `json:"amount"`
+	Fee              float64   `json:"fee"`
+	HoldID           int64     `json:"hold_id"`
+	LimitChargeID    *int64    `json:"-"`
+	RequestedBy      string    `json:"requested_by"`
+	RequiresApproval bool      `json:"requires_approval"`
+	ApprovedBy       string    `json:"approved_by,omitempty"`
+	TxID             string    `json:"txid,omitempty"`
+	RawTx            []byte    `json:"-"`
+	Confirmations    int64     `json:"confirmations"`
+	Attempts         int       `json:"attempts"`
+	LastError        string    `json:"last_error,omitempty"`
+	Status           string    `json:"status"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
@@ -3,11 +3,28 @@ package repositories
 import (
 	"context"
 	"database/sql"
-	"fmt"
-	"math/rand"
+	"errors"
+	"wallet/accountnumber"
 	"wallet/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrAccountNotFound         = errors.New("account not found")
+	ErrAccountFrozen           = errors.New("account is frozen")
+	ErrAccountClosed           = errors.New("account is closed")
+	ErrAccountNotEmpty         = errors.New("account balance must be zero to close the account")
+	ErrInvalidStatusTransition = errors.New("invalid account status transition")
+	ErrInsufficientFunds       = errors.New("insufficient available funds")
 )
 
+// maxAccountNumberAttempts bounds the retries on account number collisions,
+// which are already unlikely with 16 random digits.
+const maxAccountNumberAttempts = 5
+
+const accountColumns = "id, account_number, currency, balance, held_balance, active, status, status_reason, status_changed_at"
+
 type AccountRepository struct {
 	DB *sql.DB
 }
@@ -17,44 +34,170 @@ func NewAccountRepository(db *sql.DB) *AccountRepository {
 }
 
 func (repo *AccountRepository) CreateAccount(ctx context.Context, currency string) (*models.Account, error) {
-	accountNumber := generateAccountNumber(currency)
-	query := "INSERT INTO accounts (account_number, balance, active) VALUES ($1, $2, $3) RETURNING id, account_number, balance, active"
+	return createAccount(ctx, repo.DB, currency)
+}
 
-	var account models.Account
-	err := repo.DB.QueryRowContext(ctx, query, accountNumber, 0.00, true).Scan(&account.ID, &account.AccountNumber, &account.Balance, &account.Active)
+func (repo *AccountRepository) GetAccountByNumber(ctx context.Context, accountNumber string) (*models.Account, error) {
+	query := "SELECT " + accountColumns + " FROM accounts WHERE account_number = $1"
+
+	account, err := scanAccount(repo.DB.QueryRowContext(ctx, query, accountNumber))
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAccountNotFound
+		}
 		return nil, err
 	}
 
-	return &account, nil
+	return account, nil
 }
 
-func (repo *AccountRepository) GetAccountByNumber(ctx context.Context, accountNumber string) (*models.Account, error) {
-	query := "SELECT id, account_number, balance, active FROM accounts WHERE account_number = $1"
+// UpdateStatus moves the account to a new state. The account row is locked
+// while the transition is checked, so a concurrent deposit cannot slip in
+// between the zero balance check and closing the account.
+func (repo *AccountRepository) UpdateStatus(ctx context.Context, accountNumber, status, reason string) (*models.Account, error) {
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
-	var account models.Account
-	err := repo.DB.QueryRowContext(ctx, query, accountNumber).Scan(&account.ID, &account.AccountNumber, &account.Balance, &account.Active)
+	query := "SELECT " + accountColumns + " FROM accounts WHERE account_number = $1 FOR UPDATE"
+	account, err := scanAccount(tx.QueryRowContext(ctx, query, accountNumber))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("account not found")
+			return nil, ErrAccountNotFound
 		}
 		return nil, err
 	}
 
-	return &account, nil
+	if err := checkStatusTransition(account, status); err != nil {
+		return nil, err
+	}
+
+	query = `
+			UPDATE accounts
+			SET status = $1, status_reason = $2, active = $3, status_changed_at = NOW()
+			WHERE account_number = $4
+			RETURNING ` + accountColumns
+	account, err = scanAccount(tx.QueryRowContext(ctx, query, status, reason, status == models.AccountActive, accountNumber))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+func checkStatusTransition(account *models.Account, status string) error {
+	switch {
+	case account.Status == models.AccountClosed:
+		return ErrAccountClosed
+	case status == models.AccountClosed:
+		if account.Balance != 0 || account.HeldBalance != 0 {
+			return ErrAccountNotEmpty
+		}
+		return nil
+	case status == models.AccountFrozen && account.Status == models.AccountActive,
+		status == models.AccountActive && account.Status == models.AccountFrozen:
+		return nil
+	}
+	return ErrInvalidStatusTransition
+}
+
+// createAccount opens an account with a new random number. A number that is
+// already taken is simply generated again. Conflicts are skipped instead of
+// raised, so this also works inside a transaction.
+func createAccount(ctx context.Context, q querier, currency string) (*models.Account, error) {
+	query := `
+			INSERT INTO accounts (account_number, currency, balance, active, status) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (account_number) DO NOTHING
+			RETURNING ` + accountColumns
+
+	for attempt := 1; attempt <= maxAccountNumberAttempts; attempt++ {
+		accountNumber, err := accountnumber.Generate(currency)
+		if err != nil {
+			return nil, err
+		}
+
+		account, err := scanAccount(q.QueryRowContext(ctx, query, accountNumber, currency, 0.00, true, models.AccountActive))
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return account, nil
+	}
+	return nil, errors.New("could not generate a free account number")
+}
+
+// lockActiveAccount locks the account for the rest of the transaction and
+// fails unless money can be moved in or out of it.
+func lockActiveAccount(ctx context.Context, tx *sql.Tx, accountNumber string) (*models.Account, error) {
+	account, err := lockAccount(ctx, tx, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	switch account.Status {
+	case models.AccountFrozen:
+		return nil, ErrAccountFrozen
+	case models.AccountClosed:
+		return nil, ErrAccountClosed
+	}
+	return account, nil
+}
+
+// lockAccount locks the account for the rest of the transaction whatever
+// its status.
+func lockAccount(ctx context.Context, tx *sql.Tx, accountNumber string) (*models.Account, error) {
+	query := "SELECT " + accountColumns + " FROM accounts WHERE account_number = $1 FOR UPDATE"
+	account, err := scanAccount(tx.QueryRowContext(ctx, query, accountNumber))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAccountNotFound
+		}
+		return nil, err
+	}
+	return account, nil
 }
 
-func (repo *AccountRepository) UpdateAccount(ctx context.Context, account *models.Account) error {
-	query := "UPDATE accounts SET balance = $1, active = $2 WHERE account_number  = $3"
-	_, err := repo.DB.ExecContext(ctx, query, account.Balance, account.Active, account.AccountNumber)
-	return err
+// GetAccountsByNumbers loads several accounts at once. Unknown numbers are
+// skipped.
+func (repo *AccountRepository) GetAccountsByNumbers(ctx context.Context, accountNumbers []string) ([]*models.Account, error) {
+	query := "SELECT " + accountColumns + " FROM accounts WHERE account_number = ANY($1) ORDER BY id"
+	rows, err := repo.DB.QueryContext(ctx, query, pq.Array(accountNumbers))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []*models.Account
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+
+	return accounts, rows.Err()
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
 }
 
-func generateAccountNumber(currency string) string {
-	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, 29)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
+func scanAccount(row rowScanner) (*models.Account, error) {
+	var account models.Account
+	err := row.Scan(&account.ID, &account.AccountNumber, &account.Currency, &account.Balance, &account.HeldBalance, &account.Active, &account.Status, &account.StatusReason, &account.StatusChangedAt)
+	if err != nil {
+		return nil, err
 	}
-	return currency + string(b)
+	account.Available = account.Balance - account.HeldBalance
+	return &account, nil
 }
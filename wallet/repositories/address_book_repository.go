@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"wallet/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrAddressBookEntryNotFound = errors.New("address book entry not found")
+	ErrAddressBookEntryExists   = errors.New("address is already in the address book")
+)
+
+const addressBookColumns = "id, user_id, currency, address, label, usable_at <= NOW(), usable_at, created_at"
+
+type AddressBookRepository struct {
+	DB *sql.DB
+}
+
+func NewAddressBookRepository(db *sql.DB) *AddressBookRepository {
+	return &AddressBookRepository{DB: db}
+}
+
+func (repo *AddressBookRepository) ListEntries(ctx context.Context, userID int) ([]*models.AddressBookEntry, error) {
+	query := "SELECT " + addressBookColumns + " FROM address_book WHERE user_id = $1 ORDER BY currency, id"
+	rows, err := repo.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*models.AddressBookEntry{}
+	for rows.Next() {
+		entry, err := scanAddressBookEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// FindEntry returns the user's entry for the address, or nil if the address
+// is not in the address book.
+func (repo *AddressBookRepository) FindEntry(ctx context.Context, userID int, currency, address string) (*models.AddressBookEntry, error) {
+	query := "SELECT " + addressBookColumns + " FROM address_book WHERE user_id = $1 AND currency = $2 AND address = $3"
+	entry, err := scanAddressBookEntry(repo.DB.QueryRowContext(ctx, query, userID, currency, address))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return entry, err
+}
+
+func (repo *AddressBookRepository) CreateEntry(ctx context.Context, entry *models.AddressBookEntry) (*models.AddressBookEntry, error) {
+	query := `
+			INSERT INTO address_book (user_id, currency, address, label, usable_at)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING ` + addressBookColumns
+	created, err := scanAddressBookEntry(repo.DB.QueryRowContext(ctx, query, entry.UserID, entry.Currency, entry.Address, entry.Label, entry.UsableAt))
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return nil, ErrAddressBookEntryExists
+	}
+	return created, err
+}
+
+func (repo *AddressBookRepository) UpdateLabel(ctx context.Context, userID int, id int64, label string) (*models.AddressBookEntry, error) {
+	query := "UPDATE address_book SET label = $1 WHERE id = $2 AND user_id = $3 RETURNING " + addressBookColumns
+	entry, err := scanAddressBookEntry(repo.DB.QueryRowContext(ctx, query, label, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrAddressBookEntryNotFound
+	}
+	return entry, err
+}
+
+// DeleteEntry removes the entry and returns what it was.
+func (repo *AddressBookRepository) DeleteEntry(ctx context.Context, userID int, id int64) (*models.AddressBookEntry, error) {
+	query := "DELETE FROM address_book WHERE id = $1 AND user_id = $2 RETURNING " + addressBookColumns
+	entry, err := scanAddressBookEntry(repo.DB.QueryRowContext(ctx, query, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrAddressBookEntryNotFound
+	}
+	return entry, err
+}
+
+// GetSettings returns the settings of the user, the defaults if they were
+// never changed.
+func (repo *AddressBookRepository) GetSettings(ctx context.Context, userID int) (*models.AddressBookSettings, error) {
+	query := "SELECT user_id, whitelist_only, whitelist_until, email, updated_at FROM address_book_settings WHERE user_id = $1"
+
+	var settings models.AddressBookSettings
+	err := repo.DB.QueryRowContext(ctx, query, userID).Scan(&settings.UserID, &settings.WhitelistOnly, &settings.WhitelistUntil,
+		&settings.Email, &settings.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return &models.AddressBookSettings{UserID: userID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (repo *AddressBookRepository) SaveSettings(ctx context.Context, settings *models.AddressBookSettings) error {
+	query := `
+			INSERT INTO address_book_settings (user_id, whitelist_only, whitelist_until, email)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (user_id) DO UPDATE
+			SET whitelist_only = EXCLUDED.whitelist_only, whitelist_until = EXCLUDED.whitelist_until,
+				email = EXCLUDED.email, updated_at = NOW()
+			RETURNING updated_at`
+	return repo.DB.QueryRowContext(ctx, query, settings.UserID, settings.WhitelistOnly, settings.WhitelistUntil,
+		settings.Email).Scan(&settings.UpdatedAt)
+}
+
+func scanAddressBookEntry(row rowScanner) (*models.AddressBookEntry, error) {
+	var entry models.AddressBookEntry
+	err := row.Scan(&entry.ID, &entry.UserID, &entry.Currency, &entry.Address, &entry.Label, &entry.Usable,
+		&entry.UsableAt, &entry.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"wallet/models"
+)
+
+const snapshotDateLayout = "2006-01-02"
+
+type BalanceSnapshotRepository struct {
+	DB *sql.DB
+}
+
+func NewBalanceSnapshotRepository(db *sql.DB) *BalanceSnapshotRepository {
+	return &BalanceSnapshotRepository{DB: db}
+}
+
+// SnapshotDay stores the end of day balance of every account for the day,
+// taken from the last movement before midnight. Accounts that already have
+// a snapshot of the day are left as they are, so a day can be run again.
+func (repo *BalanceSnapshotRepository) SnapshotDay(ctx context.Context, day time.Time) (int64, error) {
+	query := `
+			INSERT INTO balance_snapshots (account_number, day, balance)
+			SELECT a.account_number, $1::date, COALESCE((
+				SELECT m.balance_after FROM account_movements m
+				WHERE m.account_number = a.account_number AND m.created_at < $2
+				ORDER BY m.created_at DESC, m.id DESC
+				LIMIT 1), 0)
+			FROM accounts a
+			ON CONFLICT (account_number, day) DO NOTHING`
+	end := day.AddDate(0, 0, 1)
+	result, err := repo.DB.ExecContext(ctx, query, day.Format(snapshotDateLayout), end)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// LatestDay returns the last day snapshots were taken for, the zero time if
+// there are none yet.
+func (repo *BalanceSnapshotRepository) LatestDay(ctx context.Context) (time.Time, error) {
+	var day sql.NullTime
+	if err := repo.DB.QueryRowContext(ctx, "SELECT MAX(day) FROM balance_snapshots").Scan(&day); err != nil {
+		return time.Time{}, err
+	}
+	if !day.Valid {
+		return time.Time{}, nil
+	}
+	return day.Time.UTC(), nil
+}
+
+// ListSnapshots returns the snapshots of the account for the days from
+// through to, oldest first.
+func (repo *BalanceSnapshotRepository) ListSnapshots(ctx context.Context, accountNumber string, from, to time.Time) ([]*models.BalanceSnapshot, error) {
+	query := "SELECT day, balance FROM balance_snapshots WHERE account_number = $1 AND day BETWEEN $2::date AND $3::date ORDER BY day"
+	rows, err := repo.DB.QueryContext(ctx, query, accountNumber, from.Format(snapshotDateLayout), to.Format(snapshotDateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []*models.BalanceSnapshot{}
+	for rows.Next() {
+		var day time.Time
+		var snapshot models.BalanceSnapshot
+		if err := rows.Scan(&day, &snapshot.Balance); err != nil {
+			return nil, err
+		}
+		snapshot.Date = day.Format(snapshotDateLayout)
+		snapshots = append(snapshots, &snapshot)
+	}
+	return snapshots, rows.Err()
+}
@@ -0,0 +1,220 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"wallet/models"
+)
+
+var (
+	ErrChainDepositNotFound = errors.New("chain deposit not found")
+	// ErrChainCursorMoved means another watcher recorded blocks in the
+	// meantime or the block does not extend the last one recorded.
+	ErrChainCursorMoved = errors.New("chain cursor moved")
+)
+
+const (
+	chainDepositColumns = "id, currency, txid, vout, address, account_number, amount, block_height, block_hash, confirmations, status, created_at, credited_at"
+	// Blocks are kept for reorg detection only, reorgs deeper than this are
+	// not detected.
+	keptChainBlocks = 1000
+	// Confirmations stop being counted once no currency can require more.
+	maxTrackedConfirmations = 1000
+)
+
+type ChainDepositRepository struct {
+	DB *sql.DB
+}
+
+func NewChainDepositRepository(db *sql.DB) *ChainDepositRepository {
+	return &ChainDepositRepository{DB: db}
+}
+
+// LastBlock returns the most recent block scanned for the currency, or nil
+// if none has been yet.
+func (repo *ChainDepositRepository) LastBlock(ctx context.Context, currency string) (*models.ChainBlock, error) {
+	query := "SELECT currency, height, hash FROM chain_blocks WHERE currency = $1 ORDER BY height DESC LIMIT 1"
+
+	var block models.ChainBlock
+	err := repo.DB.QueryRowContext(ctx, query, currency).Scan(&block.Currency, &block.Height, &block.Hash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+// RecordBlock stores a scanned block together with the deposits found in it.
+// The block must extend the last recorded one. Deposits seen before, on a
+// chain that has since been dropped, are moved to the new block.
+func (repo *ChainDepositRepository) RecordBlock(ctx context.Context, block *models.ChainBlock, parentHash string, deposits []*models.ChainDeposit) error {
+	return inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		if err := lockChain(ctx, tx, block.Currency); err != nil {
+			return err
+		}
+
+		var lastHeight int64
+		var lastHash string
+		query := "SELECT height, hash FROM chain_blocks WHERE currency = $1 ORDER BY height DESC LIMIT 1"
+		err := tx.QueryRowContext(ctx, query, block.Currency).Scan(&lastHeight, &lastHash)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if err == nil && (lastHeight != block.Height-1 || lastHash != parentHash) {
+			return ErrChainCursorMoved
+		}
+
+		query = "INSERT INTO chain_blocks (currency, height, hash) VALUES ($1, $2, $3)"
+		if _, err := tx.ExecContext(ctx, query, block.Currency, block.Height, block.Hash); err != nil {
+			return err
+		}
+
+		query = `
+				INSERT INTO chain_deposits (currency, txid, vout, address, account_number, amount, block_height, block_hash)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+				ON CONFLICT (currency, txid, vout) DO UPDATE
+				SET block_height = EXCLUDED.block_height, block_hash = EXCLUDED.block_hash, confirmations = 0,
+					status = CASE WHEN chain_deposits.status IN ('credited', 'reorged') THEN 'credited' ELSE 'pending' END`
+		for _, deposit := range deposits {
+			_, err := tx.ExecContext(ctx, query, block.Currency, deposit.TxID, deposit.Vout, deposit.Address,
+				deposit.AccountNumber, deposit.Amount, block.Height, block.Hash)
+			if err != nil {
+				return err
+			}
+		}
+
+		query = "DELETE FROM chain_blocks WHERE currency = $1 AND height <= $2"
+		_, err = tx.ExecContext(ctx, query, block.Currency, block.Height-keptChainBlocks)
+		return err
+	})
+}
+
+// Rollback forgets the blocks from height on after a reorg. Their pending
+// deposits are orphaned, credited ones wait for their reversal.
+func (repo *ChainDepositRepository) Rollback(ctx context.Context, currency string, height int64) error {
+	return inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		if err := lockChain(ctx, tx, currency); err != nil {
+			return err
+		}
+
+		query := "DELETE FROM chain_blocks WHERE currency = $1 AND height >= $2"
+		if _, err := tx.ExecContext(ctx, query, currency, height); err != nil {
+			return err
+		}
+
+		query = `
+				UPDATE chain_deposits
+				SET status = CASE status WHEN 'credited' THEN 'reorged' ELSE 'orphaned' END, confirmations = 0
+				WHERE currency = $1 AND block_height >= $2 AND status IN ('pending', 'credited')`
+		_, err := tx.ExecContext(ctx, query, currency, height)
+		return err
+	})
+}
+
+// UpdateConfirmations recounts the confirmations of the deposits on the
+// chain for a tip at the given height.
+func (repo *ChainDepositRepository) UpdateConfirmations(ctx context.Context, currency string, tipHeight int64) error {
+	query := `
+			UPDATE chain_deposits
+			SET confirmations = LEAST($2 - block_height + 1, $3)
+			WHERE currency = $1 AND status IN ('pending', 'credited') AND confirmations < $3`
+	_, err := repo.DB.ExecContext(ctx, query, currency, tipHeight, maxTrackedConfirmations)
+	return err
+}
+
+// ListDeposits returns the deposits of the currency with the status, in
+// blocks up to maxHeight.
+func (repo *ChainDepositRepository) ListDeposits(ctx context.Context, currency, status string, maxHeight int64) ([]*models.ChainDeposit, error) {
+	query := "SELECT " + chainDepositColumns + " FROM chain_deposits WHERE currency = $1 AND status = $2 AND block_height <= $3 ORDER BY id"
+	return repo.list(ctx, query, currency, status, maxHeight)
+}
+
+func (repo *ChainDepositRepository) ListAccountDeposits(ctx context.Context, accountNumber string) ([]*models.ChainDeposit, error) {
+	query := "SELECT " + chainDepositColumns + " FROM chain_deposits WHERE account_number = $1 ORDER BY id DESC"
+	return repo.list(ctx, query, accountNumber)
+}
+
+// Credit books a pending deposit to its account. A deposit that is no
+// longer pending, e.g. credited by another watcher, is left alone.
+func (repo *ChainDepositRepository) Credit(ctx context.Context, id int64) (*models.ChainDeposit, error) {
+	return repo.settle(ctx, id, models.DepositPending, models.DepositCredited, models.MovementDeposit, 1)
+}
+
+// Reverse takes a deposit dropped by a reorg back from its account.
+func (repo *ChainDepositRepository) Reverse(ctx context.Context, id int64) (*models.ChainDeposit, error) {
+	return repo.settle(ctx, id, models.DepositReorged, models.DepositReversed, models.MovementDepositReversal, -1)
+}
+
+func (repo *ChainDepositRepository) settle(ctx context.Context, id int64, from, to, movementType string, sign float64) (*models.ChainDeposit, error) {
+	var deposit *models.ChainDeposit
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		query := "SELECT " + chainDepositColumns + " FROM chain_deposits WHERE id = $1 FOR UPDATE"
+		current, err := scanChainDeposit(tx.QueryRowContext(ctx, query, id))
+		if err == sql.ErrNoRows {
+			return ErrChainDepositNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if current.Status != from {
+			deposit = current
+			return nil
+		}
+
+		counterparty := fmt.Sprintf("%s:%d", current.TxID, current.Vout)
+		if _, err := changeBalance(ctx, tx, current.AccountNumber, sign*current.Amount, movementType, counterparty); err != nil {
+			return err
+		}
+
+		query = `
+				UPDATE chain_deposits
+				SET status = $1, credited_at = CASE WHEN $1 = 'credited' THEN NOW() ELSE credited_at END
+				WHERE id = $2
+				RETURNING ` + chainDepositColumns
+		deposit, err = scanChainDeposit(tx.QueryRowContext(ctx, query, to, id))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deposit, nil
+}
+
+func (repo *ChainDepositRepository) list(ctx context.Context, query string, args ...interface{}) ([]*models.ChainDeposit, error) {
+	rows, err := repo.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deposits := []*models.ChainDeposit{}
+	for rows.Next() {
+		deposit, err := scanChainDeposit(rows)
+		if err != nil {
+			return nil, err
+		}
+		deposits = append(deposits, deposit)
+	}
+	return deposits, rows.Err()
+}
+
+// lockChain serializes the watchers of a currency.
+func lockChain(ctx context.Context, tx *sql.Tx, currency string) error {
+	_, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('chain_blocks:' || $1))", currency)
+	return err
+}
+
+func scanChainDeposit(row rowScanner) (*models.ChainDeposit, error) {
+	var deposit models.ChainDeposit
+	err := row.Scan(&deposit.ID, &deposit.Currency, &deposit.TxID, &deposit.Vout, &deposit.Address, &deposit.AccountNumber,
+		&deposit.Amount, &deposit.BlockHeight, &deposit.BlockHash, &deposit.Confirmations, &deposit.Status,
+		&deposit.CreatedAt, &deposit.CreditedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &deposit, nil
+}
@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"wallet/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func chainDepositRow(status string) *sqlmock.Rows {
+	columns := []string{"id", "currency", "txid", "vout", "address", "account_number", "amount", "block_height", "block_hash",
+		"confirmations", "status", "created_at", "credited_at"}
+	return sqlmock.NewRows(columns).AddRow(3, "BTC", "deposittx", 1, "bc1qdeposit", testAccount, 1.5, 10, "blockhash",
+		6, status, time.Now(), nil)
+}
+
+func expectLockDeposit(mock sqlmock.Sqlmock, status string) {
+	mock.ExpectQuery(query("FROM chain_deposits WHERE id = $1 FOR UPDATE")).WithArgs(3).WillReturnRows(chainDepositRow(status))
+}
+
+func TestChainDepositCreditsOnce(t *testing.T) {
+	db, mock := newMockDB(t)
+	repo := NewChainDepositRepository(db)
+
+	mock.ExpectBegin()
+	expectLockDeposit(mock, models.DepositPending)
+	expectLockAccount(mock, testAccount, models.AccountActive, 0, 0)
+	mock.ExpectQuery(query("UPDATE accounts SET balance = balance + $1")).WithArgs(1.5, testAccount).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(1.5))
+	mock.ExpectExec(query("INSERT INTO account_movements")).WithArgs(testAccount, models.MovementDeposit, 1.5, 1.5, "deposittx:1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(query("UPDATE chain_deposits")).WithArgs(models.DepositCredited, 3).WillReturnRows(chainDepositRow(models.DepositCredited))
+	mock.ExpectCommit()
+
+	deposit, err := repo.Credit(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deposit.Status != models.DepositCredited {
+		t.Fatalf("status = %s, want %s", deposit.Status, models.DepositCredited)
+	}
+
+	// Another watcher got there first, the balance is not touched again.
+	mock.ExpectBegin()
+	expectLockDeposit(mock, models.DepositCredited)
+	mock.ExpectCommit()
+
+	if deposit, err = repo.Credit(context.Background(), 3); err != nil {
+		t.Fatal(err)
+	}
+	if deposit.Status != models.DepositCredited {
+		t.Fatalf("second credit: status = %s, want %s", deposit.Status, models.DepositCredited)
+	}
+}
+
+func TestChainDepositCreditWaitsForFrozenAccount(t *testing.T) {
+	db, mock := newMockDB(t)
+	repo := NewChainDepositRepository(db)
+
+	mock.ExpectBegin()
+	expectLockDeposit(mock, models.DepositPending)
+	expectLockAccount(mock, testAccount, models.AccountFrozen, 0, 0)
+	mock.ExpectRollback()
+
+	if _, err := repo.Credit(context.Background(), 3); !errors.Is(err, ErrAccountFrozen) {
+		t.Fatalf("err = %v, want %v", err, ErrAccountFrozen)
+	}
+}
+
+func TestChainDepositReversesReorgedCredit(t *testing.T) {
+	db, mock := newMockDB(t)
+	repo := NewChainDepositRepository(db)
+
+	mock.ExpectBegin()
+	expectLockDeposit(mock, models.DepositReorged)
+	expectLockAccount(mock, testAccount, models.AccountActive, 2, 0)
+	mock.ExpectQuery(query("UPDATE accounts SET balance = balance + $1")).WithArgs(-1.5, testAccount).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(0.5))
+	mock.ExpectExec(query("INSERT INTO account_movements")).WithArgs(testAccount, models.MovementDepositReversal, -1.5, 0.5, "deposittx:1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(query("UPDATE chain_deposits")).WithArgs(models.DepositReversed, 3).WillReturnRows(chainDepositRow(models.DepositReversed))
+	mock.ExpectCommit()
+
+	deposit, err := repo.Reverse(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deposit.Status != models.DepositReversed {
+		t.Fatalf("status = %s, want %s", deposit.Status, models.DepositReversed)
+	}
+}
+
+func TestChainDepositReverseLeavesPendingDeposit(t *testing.T) {
+	db, mock := newMockDB(t)
+	repo := NewChainDepositRepository(db)
+
+	mock.ExpectBegin()
+	expectLockDeposit(mock, models.DepositPending)
+	mock.ExpectCommit()
+
+	deposit, err := repo.Reverse(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deposit.Status != models.DepositPending {
+		t.Fatalf("status = %s, want %s", deposit.Status, models.DepositPending)
+	}
+}
+
+func TestChainDepositRecordBlockRejectsGap(t *testing.T) {
+	db, mock := newMockDB(t)
+	repo := NewChainDepositRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(query("pg_advisory_xact_lock")).WithArgs("BTC").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(query("SELECT height, hash FROM chain_blocks")).WithArgs("BTC").
+		WillReturnRows(sqlmock.NewRows([]string{"height", "hash"}).AddRow(5, "hash5"))
+	mock.ExpectRollback()
+
+	block := &models.ChainBlock{Currency: "BTC", Height: 7, Hash: "hash7"}
+	if err := repo.RecordBlock(context.Background(), block, "hash6", nil); !errors.Is(err, ErrChainCursorMoved) {
+		t.Fatalf("err = %v, want %v", err, ErrChainCursorMoved)
+	}
+}
+
+func TestChainDepositRollbackMarksDroppedDeposits(t *testing.T) {
+	db, mock := newMockDB(t)
+	repo := NewChainDepositRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(query("pg_advisory_xact_lock")).WithArgs("BTC").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(query("DELETE FROM chain_blocks WHERE currency = $1 AND height >= $2")).WithArgs("BTC", 10).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(query("SET status = CASE status WHEN 'credited' THEN 'reorged' ELSE 'orphaned' END")).WithArgs("BTC", 10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.Rollback(context.Background(), "BTC", 10); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"wallet/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrCurrencyNotFound = errors.New("currency not found")
+	ErrCurrencyExists   = errors.New("currency already exists")
+	ErrCurrencyInUse    = errors.New("decimals cannot change while accounts hold funds in the currency")
+)
+
+const currencyColumns = "code, kind, decimals, min_amount, enabled, confirmations"
+
+type CurrencyRepository struct {
+	DB *sql.DB
+}
+
+func NewCurrencyRepository(db *sql.DB) *CurrencyRepository {
+	return &CurrencyRepository{DB: db}
+}
+
+func (repo *CurrencyRepository) GetCurrency(ctx context.Context, code string) (*models.Currency, error) {
+	query := "SELECT " + currencyColumns + " FROM currencies WHERE code = $1"
+
+	var currency models.Currency
+	err := repo.DB.QueryRowContext(ctx, query, code).Scan(&currency.Code, &currency.Kind, &currency.Decimals, &currency.MinAmount, &currency.Enabled, &currency.Confirmations)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCurrencyNotFound
+		}
+		return nil, err
+	}
+
+	return &currency, nil
+}
+
+func (repo *CurrencyRepository) ListCurrencies(ctx context.Context, includeDisabled bool) ([]*models.Currency, error) {
+	query := "SELECT " + currencyColumns + " FROM currencies WHERE enabled OR $1 ORDER BY code"
+	rows, err := repo.DB.QueryContext(ctx, query, includeDisabled)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	currencies := []*models.Currency{}
+	for rows.Next() {
+		var currency models.Currency
+		err := rows.Scan(&currency.Code, &currency.Kind, &currency.Decimals, &currency.MinAmount, &currency.Enabled, &currency.Confirmations)
+		if err != nil {
+			return nil, err
+		}
+		currencies = append(currencies, &currency)
+	}
+
+	return currencies, rows.Err()
+}
+
+func (repo *CurrencyRepository) CreateCurrency(ctx context.Context, currency *models.Currency) error {
+	query := "INSERT INTO currencies (" + currencyColumns + ") VALUES ($1, $2, $3, $4, $5, $6)"
+	_, err := repo.DB.ExecContext(ctx, query, currency.Code, currency.Kind, currency.Decimals, currency.MinAmount, currency.Enabled, currency.Confirmations)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrCurrencyExists
+	}
+	return err
+}
+
+// UpdateCurrency changes the settings of the currency. The precision is
+// fixed once accounts hold funds in it, their amounts would no longer fit.
+func (repo *CurrencyRepository) UpdateCurrency(ctx context.Context, currency *models.Currency) error {
+	return inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		var decimals int
+		err := tx.QueryRowContext(ctx, "SELECT decimals FROM currencies WHERE code = $1 FOR UPDATE", currency.Code).Scan(&decimals)
+		if err == sql.ErrNoRows {
+			return ErrCurrencyNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if decimals != currency.Decimals {
+			var inUse bool
+			query := "SELECT EXISTS (SELECT 1 FROM accounts WHERE currency = $1 AND (balance <> 0 OR held_balance <> 0))"
+			if err := tx.QueryRowContext(ctx, query, currency.Code).Scan(&inUse); err != nil {
+				return err
+			}
+			if inUse {
+				return ErrCurrencyInUse
+			}
+		}
+
+		query := "UPDATE currencies SET kind = $1, decimals = $2, min_amount = $3, enabled = $4, confirmations = $5 WHERE code = $6"
+		_, err = tx.ExecContext(ctx, query, currency.Kind, currency.Decimals, currency.MinAmount, currency.Enabled, currency.Confirmations, currency.Code)
+		return err
+	})
+}
@@ -0,0 +1,34 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"wallet/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestUpdateCurrencyKeepsDecimalsOfCurrencyInUse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	repo := NewCurrencyRepository(db)
+	btc := &models.Currency{Code: "BTC", Kind: models.CurrencyCrypto, Decimals: 6, Enabled: true, Confirmations: 3}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(query("SELECT decimals FROM currencies WHERE code = $1 FOR UPDATE")).WithArgs("BTC").
+		WillReturnRows(sqlmock.NewRows([]string{"decimals"}).AddRow(8))
+	mock.ExpectQuery(query("FROM accounts WHERE currency = $1")).WithArgs("BTC").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	if err := repo.UpdateCurrency(context.Background(), btc); !errors.Is(err, ErrCurrencyInUse) {
+		t.Fatalf("err = %v, want %v", err, ErrCurrencyInUse)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"wallet/models"
+
+	"github.com/lib/pq"
+)
+
+var ErrDepositAddressNotFound = errors.New("deposit address not found")
+
+const depositAddressColumns = "id, account_number, currency, address, derivation_index, derivation_path, created_at"
+
+type DepositAddressRepository struct {
+	DB *sql.DB
+}
+
+func NewDepositAddressRepository(db *sql.DB) *DepositAddressRepository {
+	return &DepositAddressRepository{DB: db}
+}
+
+// GetLatest returns the address most recently handed out for the account.
+func (repo *DepositAddressRepository) GetLatest(ctx context.Context, accountNumber string) (*models.DepositAddress, error) {
+	query := "SELECT " + depositAddressColumns + " FROM deposit_addresses WHERE account_number = $1 ORDER BY id DESC LIMIT 1"
+	address, err := scanDepositAddress(repo.DB.QueryRowContext(ctx, query, accountNumber))
+	if err == sql.ErrNoRows {
+		return nil, ErrDepositAddressNotFound
+	}
+	return address, err
+}
+
+func (repo *DepositAddressRepository) List(ctx context.Context, accountNumber string) ([]*models.DepositAddress, error) {
+	query := "SELECT " + depositAddressColumns + " FROM deposit_addresses WHERE account_number = $1 ORDER BY id DESC"
+	rows, err := repo.DB.QueryContext(ctx, query, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	addresses := []*models.DepositAddress{}
+	for rows.Next() {
+		address, err := scanDepositAddress(rows)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, rows.Err()
+}
+
+// Create stores a new address for the account. derive is called with the
+// next unused index of the currency and returns the address for it; it may
+// skip indexes that yield no valid key. Indexes are handed out one at a
+// time per currency, so no address is ever given to two accounts.
+func (repo *DepositAddressRepository) Create(ctx context.Context, accountNumber string, derive func(next uint32) (*models.DepositAddress, error)) (*models.DepositAddress, error) {
+	var created *models.DepositAddress
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		account, err := lockActiveAccount(ctx, tx, accountNumber)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('deposit_addresses:' || $1))", account.Currency); err != nil {
+			return err
+		}
+
+		var next uint32
+		query := "SELECT COALESCE(MAX(derivation_index) + 1, 0) FROM deposit_addresses WHERE currency = $1"
+		if err := tx.QueryRowContext(ctx, query, account.Currency).Scan(&next); err != nil {
+			return err
+		}
+
+		address, err := derive(next)
+		if err != nil {
+			return err
+		}
+
+		query = `
+				INSERT INTO deposit_addresses (account_number, currency, address, derivation_index, derivation_path)
+				VALUES ($1, $2, $3, $4, $5)
+				RETURNING ` + depositAddressColumns
+		created, err = scanDepositAddress(tx.QueryRowContext(ctx, query,
+			accountNumber, account.Currency, address.Address, address.DerivationIndex, address.DerivationPath))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func scanDepositAddress(row rowScanner) (*models.DepositAddress, error) {
+	var address models.DepositAddress
+	err := row.Scan(&address.ID, &address.AccountNumber, &address.Currency, &address.Address,
+		&address.DerivationIndex, &address.DerivationPath, &address.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// FindByAddresses returns the deposit addresses of the currency among
+// addresses. The comparison ignores case, ethereum addresses are often
+// reported without the EIP-55 checksum.
+func (repo *DepositAddressRepository) FindByAddresses(ctx context.Context, currency string, addresses []string) ([]*models.DepositAddress, error) {
+	lower := make([]string, len(addresses))
+	for i, address := range addresses {
+		lower[i] = strings.ToLower(address)
+	}
+
+	query := "SELECT " + depositAddressColumns + " FROM deposit_addresses WHERE currency = $1 AND lower(address) = ANY($2)"
+	rows, err := repo.DB.QueryContext(ctx, query, currency, pq.Array(lower))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var found []*models.DepositAddress
+	for rows.Next() {
+		address, err := scanDepositAddress(rows)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, address)
+	}
+	return found, rows.Err()
+}
@@ -0,0 +1,178 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"wallet/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrFeeRuleNotFound = errors.New("fee rule not found")
+	ErrFeeRuleExists   = errors.New("fee rule for this operation, currency and min_amount already exists")
+)
+
+const feeRuleColumns = "id, operation, currency, min_amount, flat, percent, min_fee, max_fee"
+
+type FeeRepository struct {
+	DB *sql.DB
+}
+
+func NewFeeRepository(db *sql.DB) *FeeRepository {
+	return &FeeRepository{DB: db}
+}
+
+// FindRule returns the rule for the amount, or nil if the operation is free.
+func (repo *FeeRepository) FindRule(ctx context.Context, operation, currency string, amount float64) (*models.FeeRule, error) {
+	query := `
+			SELECT ` + feeRuleColumns + `
+			FROM fee_rules
+			WHERE operation = $1 AND currency IN ($2, $3) AND min_amount <= $4
+			ORDER BY currency = $2 DESC, min_amount DESC
+			LIMIT 1`
+
+	var rule models.FeeRule
+	err := repo.DB.QueryRowContext(ctx, query, operation, currency, models.FeeAnyCurrency, amount).Scan(
+		&rule.ID, &rule.Operation, &rule.Currency, &rule.MinAmount, &rule.Flat, &rule.Percent, &rule.MinFee, &rule.MaxFee)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// FindDiscount returns the discount in percent for the rating.
+func (repo *FeeRepository) FindDiscount(ctx context.Context, ratingLevel int) (float64, error) {
+	query := "SELECT COALESCE(MAX(percent), 0) FROM fee_discounts WHERE min_rating <= $1"
+
+	var percent float64
+	err := repo.DB.QueryRowContext(ctx, query, ratingLevel).Scan(&percent)
+	return percent, err
+}
+
+func (repo *FeeRepository) GetSchedule(ctx context.Context) (*models.FeeSchedule, error) {
+	schedule := &models.FeeSchedule{Rules: []*models.FeeRule{}, Discounts: []*models.FeeDiscount{}}
+
+	rows, err := repo.DB.QueryContext(ctx, "SELECT "+feeRuleColumns+" FROM fee_rules ORDER BY operation, currency, min_amount")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rule models.FeeRule
+		if err := rows.Scan(&rule.ID, &rule.Operation, &rule.Currency, &rule.MinAmount, &rule.Flat, &rule.Percent, &rule.MinFee, &rule.MaxFee); err != nil {
+			return nil, err
+		}
+		schedule.Rules = append(schedule.Rules, &rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = repo.DB.QueryContext(ctx, "SELECT min_rating, percent FROM fee_discounts ORDER BY min_rating")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var discount models.FeeDiscount
+		if err := rows.Scan(&discount.MinRating, &discount.Percent); err != nil {
+			return nil, err
+		}
+		schedule.Discounts = append(schedule.Discounts, &discount)
+	}
+
+	return schedule, rows.Err()
+}
+
+func (repo *FeeRepository) CreateRule(ctx context.Context, rule *models.FeeRule) error {
+	query := `
+			INSERT INTO fee_rules (operation, currency, min_amount, flat, percent, min_fee, max_fee)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id`
+	err := repo.DB.QueryRowContext(ctx, query, rule.Operation, rule.Currency, rule.MinAmount, rule.Flat, rule.Percent, rule.MinFee, rule.MaxFee).Scan(&rule.ID)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrFeeRuleExists
+	}
+	return err
+}
+
+func (repo *FeeRepository) DeleteRule(ctx context.Context, id int64) error {
+	result, err := repo.DB.ExecContext(ctx, "DELETE FROM fee_rules WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrFeeRuleNotFound
+	}
+	return nil
+}
+
+// SetDiscount creates or replaces the discount for a rating.
+func (repo *FeeRepository) SetDiscount(ctx context.Context, discount *models.FeeDiscount) error {
+	query := `
+			INSERT INTO fee_discounts (min_rating, percent) VALUES ($1, $2)
+			ON CONFLICT (min_rating) DO UPDATE SET percent = EXCLUDED.percent`
+	_, err := repo.DB.ExecContext(ctx, query, discount.MinRating, discount.Percent)
+	return err
+}
+
+// GetHouseAccount returns the revenue account of the currency and opens it
+// the first time it is needed.
+func (repo *FeeRepository) GetHouseAccount(ctx context.Context, currency string) (string, error) {
+	var accountNumber string
+	err := repo.DB.QueryRowContext(ctx, "SELECT account_number FROM house_accounts WHERE currency = $1", currency).Scan(&accountNumber)
+	if err != sql.ErrNoRows {
+		return accountNumber, err
+	}
+
+	err = inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		// Serializes the creation, otherwise two callers could both open an account.
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('house_accounts'))"); err != nil {
+			return err
+		}
+
+		err := tx.QueryRowContext(ctx, "SELECT account_number FROM house_accounts WHERE currency = $1", currency).Scan(&accountNumber)
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		account, err := createAccount(ctx, tx, currency)
+		if err != nil {
+			return err
+		}
+		accountNumber = account.AccountNumber
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO house_accounts (currency, account_number) VALUES ($1, $2)", currency, accountNumber)
+		return err
+	})
+	return accountNumber, err
+}
+
+// chargeFee moves the fee from the payer to the house account. Both accounts
+// must already be locked by the caller.
+func chargeFee(ctx context.Context, tx *sql.Tx, fee *models.FeeItem, houseAccountNumber string) error {
+	if fee == nil || fee.Amount == 0 {
+		return nil
+	}
+
+	_, err := changeBalance(ctx, tx, fee.AccountNumber, -fee.Amount, models.MovementFee, houseAccountNumber)
+	if err != nil {
+		return err
+	}
+
+	_, err = changeBalance(ctx, tx, houseAccountNumber, fee.Amount, models.MovementFee, fee.AccountNumber)
+	return err
+}
@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+	"wallet/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrGrantNotFound = errors.New("grant not found")
+	ErrGrantRevoked  = errors.New("grant is already revoked")
+)
+
+const grantColumns = "id, owner_id, grantee_user_id, scopes, expires_at, revoked_at, created_at"
+
+type GrantRepository struct {
+	DB *sql.DB
+}
+
+func NewGrantRepository(db *sql.DB) *GrantRepository {
+	return &GrantRepository{DB: db}
+}
+
+// CreateGrant stores a grant to grant.GranteeUserID, or to the API key with
+// the hash if there is no grantee.
+func (repo *GrantRepository) CreateGrant(ctx context.Context, grant *models.Grant, apiKeyHash string) (*models.Grant, error) {
+	query := `
+			INSERT INTO access_grants (owner_id, grantee_user_id, api_key_hash, scopes, expires_at)
+			VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+			RETURNING ` + grantColumns
+	created, err := scanGrant(repo.DB.QueryRowContext(ctx, query, grant.OwnerID, grant.GranteeUserID, apiKeyHash, pq.Array(grant.Scopes), grant.ExpiresAt))
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return nil, ErrUserNotFound
+	}
+	return created, err
+}
+
+// ListGrants returns the grants the owner gave, newest first, including the
+// expired and revoked ones.
+func (repo *GrantRepository) ListGrants(ctx context.Context, ownerID int) ([]*models.Grant, error) {
+	query := "SELECT " + grantColumns + " FROM access_grants WHERE owner_id = $1 ORDER BY id DESC"
+	rows, err := repo.DB.QueryContext(ctx, query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []*models.Grant{}
+	for rows.Next() {
+		grant, err := scanGrant(rows)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, grant)
+	}
+	return grants, rows.Err()
+}
+
+func (repo *GrantRepository) RevokeGrant(ctx context.Context, ownerID int, id int64) (*models.Grant, error) {
+	query := "UPDATE access_grants SET revoked_at = NOW() WHERE id = $1 AND owner_id = $2 AND revoked_at IS NULL RETURNING " + grantColumns
+	grant, err := scanGrant(repo.DB.QueryRowContext(ctx, query, id, ownerID))
+	if err != sql.ErrNoRows {
+		return grant, err
+	}
+
+	var exists bool
+	if err := repo.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM access_grants WHERE id = $1 AND owner_id = $2)", id, ownerID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrGrantRevoked
+	}
+	return nil, ErrGrantNotFound
+}
+
+// HasGrant reports whether the owner gave the principal the scope in a grant
+// that is neither expired nor revoked. An API key identifies its holder,
+// granteeUserID is only looked at without one.
+func (repo *GrantRepository) HasGrant(ctx context.Context, ownerID, granteeUserID int, apiKeyHash, scope string, at time.Time) (bool, error) {
+	query := `
+			SELECT EXISTS (
+				SELECT 1 FROM access_grants
+				WHERE owner_id = $1 AND $2 = ANY(scopes) AND revoked_at IS NULL AND expires_at > $3
+				AND CASE WHEN $4 <> '' THEN api_key_hash = $4 ELSE grantee_user_id = $5 END
+			)`
+	var granted bool
+	err := repo.DB.QueryRowContext(ctx, query, ownerID, scope, at, apiKeyHash, granteeUserID).Scan(&granted)
+	return granted, err
+}
+
+func scanGrant(row rowScanner) (*models.Grant, error) {
+	var grant models.Grant
+	var granteeUserID sql.NullInt64
+	var revokedAt sql.NullTime
+	err := row.Scan(&grant.ID, &grant.OwnerID, &granteeUserID, pq.Array(&grant.Scopes), &grant.ExpiresAt, &revokedAt, &grant.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if granteeUserID.Valid {
+		id := int(granteeUserID.Int64)
+		grant.GranteeUserID = &id
+	}
+	if revokedAt.Valid {
+		grant.RevokedAt = &revokedAt.Time
+	}
+	return &grant, nil
+}
@@ -0,0 +1,225 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"wallet/models"
+)
+
+var (
+	ErrHoldNotFound  = errors.New("hold not found")
+	ErrHoldNotActive = errors.New("hold is no longer active")
+)
+
+const holdColumns = "id, account_number, amount, kind, reference, status, created_at, updated_at"
+
+type HoldRepository struct {
+	DB *sql.DB
+}
+
+func NewHoldRepository(db *sql.DB) *HoldRepository {
+	return &HoldRepository{DB: db}
+}
+
+// PlaceHold reserves amount on the account. The hold only succeeds if the
+// available balance covers it.
+func (repo *HoldRepository) PlaceHold(ctx context.Context, accountNumber string, amount float64, kind, reference string) (*models.Hold, error) {
+	var hold *models.Hold
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		var err error
+		hold, err = placeHold(ctx, tx, accountNumber, amount, kind, reference)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+func (repo *HoldRepository) GetHold(ctx context.Context, holdID int64) (*models.Hold, error) {
+	hold, err := scanHold(repo.DB.QueryRowContext(ctx, "SELECT "+holdColumns+" FROM account_holds WHERE id = $1", holdID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrHoldNotFound
+		}
+		return nil, err
+	}
+	return hold, nil
+}
+
+// ListActiveHolds returns the holds currently reserving funds on the account.
+func (repo *HoldRepository) ListActiveHolds(ctx context.Context, accountNumber string) ([]*models.Hold, error) {
+	query := "SELECT " + holdColumns + " FROM account_holds WHERE account_number = $1 AND status = $2 ORDER BY id"
+	rows, err := repo.DB.QueryContext(ctx, query, accountNumber, models.HoldActive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holds := []*models.Hold{}
+	for rows.Next() {
+		var hold models.Hold
+		err := rows.Scan(&hold.ID, &hold.AccountNumber, &hold.Amount, &hold.Kind, &hold.Reference, &hold.Status, &hold.CreatedAt, &hold.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, &hold)
+	}
+
+	return holds, rows.Err()
+}
+
+// ReleaseHold gives the reserved funds back to the available balance.
+func (repo *HoldRepository) ReleaseHold(ctx context.Context, holdID int64) (*models.Hold, error) {
+	var hold *models.Hold
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		var err error
+		hold, err = releaseHold(ctx, tx, holdID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// CaptureHold moves the reserved funds to the destination account and
+// charges the fee of the transfer in the same transaction. Withdrawals take
+// their funds off through WithdrawalRepository.Confirm.
+func (repo *HoldRepository) CaptureHold(ctx context.Context, holdID int64, destinationAccountNumber string, fee *models.FeeItem, houseAccountNumber string) (*models.Hold, error) {
+	var hold *models.Hold
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		active, err := lockActiveHold(ctx, tx, holdID)
+		if err != nil {
+			return err
+		}
+		if err := lockAccounts(ctx, tx, feeAccounts(fee, houseAccountNumber, active.AccountNumber, destinationAccountNumber)...); err != nil {
+			return err
+		}
+
+		hold, err = captureHold(ctx, tx, holdID, destinationAccountNumber)
+		if err != nil {
+			return err
+		}
+		return chargeFee(ctx, tx, fee, houseAccountNumber)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// SettleTrade completes a trade in one transaction: the payer pays the
+// payee, the held funds of the order go to the destination account and the
+// trade fee is charged. Either all of it happens or nothing does.
+func (repo *HoldRepository) SettleTrade(ctx context.Context, holdID int64, destinationAccountNumber, payerAccountNumber, payeeAccountNumber string, paymentAmount float64, fee *models.FeeItem, houseAccountNumber string) (*models.Hold, error) {
+	var hold *models.Hold
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		active, err := lockActiveHold(ctx, tx, holdID)
+		if err != nil {
+			return err
+		}
+
+		accounts := feeAccounts(fee, houseAccountNumber, active.AccountNumber, destinationAccountNumber, payerAccountNumber, payeeAccountNumber)
+		if err := lockAccounts(ctx, tx, accounts...); err != nil {
+			return err
+		}
+
+		if err := transferWithFee(ctx, tx, payerAccountNumber, payeeAccountNumber, paymentAmount, fee, houseAccountNumber); err != nil {
+			return err
+		}
+
+		hold, err = captureHold(ctx, tx, holdID, destinationAccountNumber)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+func placeHold(ctx context.Context, tx *sql.Tx, accountNumber string, amount float64, kind, reference string) (*models.Hold, error) {
+	account, err := lockActiveAccount(ctx, tx, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	if account.Available < amount {
+		return nil, ErrInsufficientFunds
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE accounts SET held_balance = held_balance + $1 WHERE account_number = $2", amount, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "INSERT INTO account_holds (account_number, amount, kind, reference, status) VALUES ($1, $2, $3, $4, $5) RETURNING " + holdColumns
+	return scanHold(tx.QueryRowContext(ctx, query, accountNumber, amount, kind, reference, models.HoldActive))
+}
+
+func releaseHold(ctx context.Context, tx *sql.Tx, holdID int64) (*models.Hold, error) {
+	hold, err := lockActiveHold(ctx, tx, holdID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE accounts SET held_balance = held_balance - $1 WHERE account_number = $2", hold.Amount, hold.AccountNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return finishHold(ctx, tx, holdID, models.HoldReleased)
+}
+
+// captureHold locks the hold before the accounts, the same order every other
+// hold operation uses.
+func captureHold(ctx context.Context, tx *sql.Tx, holdID int64, destinationAccountNumber string) (*models.Hold, error) {
+	hold, err := lockActiveHold(ctx, tx, holdID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockAccounts(ctx, tx, hold.AccountNumber, destinationAccountNumber); err != nil {
+		return nil, err
+	}
+
+	// Releasing the reservation first makes the funds available to the debit.
+	_, err = tx.ExecContext(ctx, "UPDATE accounts SET held_balance = held_balance - $1 WHERE account_number = $2", hold.Amount, hold.AccountNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := transfer(ctx, tx, hold.AccountNumber, destinationAccountNumber, hold.Amount); err != nil {
+		return nil, err
+	}
+
+	return finishHold(ctx, tx, holdID, models.HoldCaptured)
+}
+
+func lockActiveHold(ctx context.Context, tx *sql.Tx, holdID int64) (*models.Hold, error) {
+	hold, err := scanHold(tx.QueryRowContext(ctx, "SELECT "+holdColumns+" FROM account_holds WHERE id = $1 FOR UPDATE", holdID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrHoldNotFound
+		}
+		return nil, err
+	}
+	if hold.Status != models.HoldActive {
+		return nil, ErrHoldNotActive
+	}
+	return hold, nil
+}
+
+func finishHold(ctx context.Context, tx *sql.Tx, holdID int64, status string) (*models.Hold, error) {
+	query := "UPDATE account_holds SET status = $1, updated_at = NOW() WHERE id = $2 RETURNING " + holdColumns
+	return scanHold(tx.QueryRowContext(ctx, query, status, holdID))
+}
+
+func scanHold(row *sql.Row) (*models.Hold, error) {
+	var hold models.Hold
+	err := row.Scan(&hold.ID, &hold.AccountNumber, &hold.Amount, &hold.Kind, &hold.Reference, &hold.Status, &hold.CreatedAt, &hold.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"wallet/models"
+)
+
+type IdempotencyRepository struct {
+	DB *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{DB: db}
+}
+
+// Begin claims the key for a new request. If the key was already claimed the
+// existing record is returned and created is false.
+func (repo *IdempotencyRepository) Begin(ctx context.Context, key, requestHash string) (record *models.IdempotencyRecord, created bool, err error) {
+	query := "INSERT INTO idempotency_keys (key, request_hash) VALUES ($1, $2) ON CONFLICT (key) DO NOTHING"
+	result, err := repo.DB.ExecContext(ctx, query, key, requestHash)
+	if err != nil {
+		return nil, false, err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return nil, false, err
+	} else if rows == 1 {
+		return &models.IdempotencyRecord{Key: key, RequestHash: requestHash}, true, nil
+	}
+
+	record = &models.IdempotencyRecord{Key: key}
+	query = "SELECT request_hash, COALESCE(status_code, 0), COALESCE(response_body, ''::bytea) FROM idempotency_keys WHERE key = $1"
+	err = repo.DB.QueryRowContext(ctx, query, key).Scan(&record.RequestHash, &record.StatusCode, &record.ResponseBody)
+	if err != nil {
+		return nil, false, err
+	}
+	return record, false, nil
+}
+
+func (repo *IdempotencyRepository) Complete(ctx context.Context, key string, statusCode int, body []byte) error {
+	query := "UPDATE idempotency_keys SET status_code = $1, response_body = $2 WHERE key = $3"
+	_, err := repo.DB.ExecContext(ctx, query, statusCode, body, key)
+	return err
+}
+
+// Abandon frees the key so the request can be retried.
+func (repo *IdempotencyRepository) Abandon(ctx context.Context, key string) error {
+	_, err := repo.DB.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key = $1", key)
+	return err
+}
@@ -0,0 +1,204 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+	"wallet/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrUserNotFound          = errors.New("user not found")
+	ErrLimitExceeded         = errors.New("transaction limit exceeded")
+	ErrLimitOverrideNotFound = errors.New("limit override not found")
+	ErrLimitChargeNotFound   = errors.New("limit charge not found")
+)
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type LimitRepository struct {
+	DB *sql.DB
+}
+
+func NewLimitRepository(db *sql.DB) *LimitRepository {
+	return &LimitRepository{DB: db}
+}
+
+func (repo *LimitRepository) GetLevels(ctx context.Context, userID int) (accessLevel, ratingLevel int, err error) {
+	return getLevels(ctx, repo.DB, userID, false)
+}
+
+func (repo *LimitRepository) SetLevels(ctx context.Context, userID, accessLevel, ratingLevel int) error {
+	query := "UPDATE users SET access_level = $1, rating_level = $2 WHERE id = $3"
+	result, err := repo.DB.ExecContext(ctx, query, accessLevel, ratingLevel, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// GetLimits returns the limits that apply to the user right now.
+func (repo *LimitRepository) GetLimits(ctx context.Context, userID, accessLevel, ratingLevel int) ([]*models.Limit, error) {
+	return getLimits(ctx, repo.DB, userID, accessLevel, ratingLevel)
+}
+
+// GetUsage sums the charges of one kind made since the start of a period.
+func (repo *LimitRepository) GetUsage(ctx context.Context, userID int, kind string, since time.Time) (float64, error) {
+	query := "SELECT COALESCE(SUM(amount), 0) FROM limit_charges WHERE user_id = $1 AND kind = $2 AND created_at >= $3"
+
+	var used float64
+	err := repo.DB.QueryRowContext(ctx, query, userID, kind, since).Scan(&used)
+	return used, err
+}
+
+// Charge records the charge if it fits into every limit of its kind. The
+// user row is locked, so concurrent charges of the same user are checked one
+// after another.
+func (repo *LimitRepository) Charge(ctx context.Context, charge *models.LimitCharge, periodStarts map[string]time.Time) error {
+	return inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		accessLevel, ratingLevel, err := getLevels(ctx, tx, charge.UserID, true)
+		if err != nil {
+			return err
+		}
+
+		limits, err := getLimits(ctx, tx, charge.UserID, accessLevel, ratingLevel)
+		if err != nil {
+			return err
+		}
+
+		for _, limit := range limits {
+			if limit.Kind != charge.Kind {
+				continue
+			}
+
+			// Compared as NUMERIC, a float sum could overshoot the limit by a rounding error.
+			query := `
+				SELECT COALESCE(SUM(amount), 0) + $1::numeric > $2::numeric
+				FROM limit_charges
+				WHERE user_id = $3 AND kind = $4 AND created_at >= $5`
+			var exceeded bool
+			err := tx.QueryRowContext(ctx, query, charge.Amount, limit.Amount, charge.UserID, charge.Kind, periodStarts[limit.Period]).Scan(&exceeded)
+			if err != nil {
+				return err
+			}
+			if exceeded {
+				return ErrLimitExceeded
+			}
+		}
+
+		query := "INSERT INTO limit_charges (user_id, kind, amount, reference) VALUES ($1, $2, $3, $4) RETURNING id, created_at"
+		return tx.QueryRowContext(ctx, query, charge.UserID, charge.Kind, charge.Amount, charge.Reference).Scan(&charge.ID, &charge.CreatedAt)
+	})
+}
+
+// ReleaseCharge gives the allowance back when the charged operation failed.
+func (repo *LimitRepository) ReleaseCharge(ctx context.Context, chargeID int64) error {
+	result, err := repo.DB.ExecContext(ctx, "DELETE FROM limit_charges WHERE id = $1", chargeID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrLimitChargeNotFound
+	}
+	return nil
+}
+
+func (repo *LimitRepository) SetOverride(ctx context.Context, override *models.LimitOverride) error {
+	query := `
+			INSERT INTO limit_overrides (user_id, kind, period, amount, reason)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (user_id, kind, period)
+			DO UPDATE SET amount = EXCLUDED.amount, reason = EXCLUDED.reason, updated_at = NOW()
+			RETURNING updated_at`
+	err := repo.DB.QueryRowContext(ctx, query, override.UserID, override.Kind, override.Period, override.Amount, override.Reason).Scan(&override.UpdatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+func (repo *LimitRepository) DeleteOverride(ctx context.Context, userID int, kind, period string) error {
+	query := "DELETE FROM limit_overrides WHERE user_id = $1 AND kind = $2 AND period = $3"
+	result, err := repo.DB.ExecContext(ctx, query, userID, kind, period)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrLimitOverrideNotFound
+	}
+	return nil
+}
+
+func getLevels(ctx context.Context, q querier, userID int, lock bool) (accessLevel, ratingLevel int, err error) {
+	query := "SELECT access_level, rating_level FROM users WHERE id = $1"
+	if lock {
+		query += " FOR UPDATE"
+	}
+
+	err = q.QueryRowContext(ctx, query, userID).Scan(&accessLevel, &ratingLevel)
+	if err == sql.ErrNoRows {
+		return 0, 0, ErrUserNotFound
+	}
+	return accessLevel, ratingLevel, err
+}
+
+// getLimits takes the best tier the levels qualify for and applies the
+// overrides of the user on top.
+func getLimits(ctx context.Context, q querier, userID, accessLevel, ratingLevel int) ([]*models.Limit, error) {
+	query := `
+			SELECT kind, period, amount, FALSE FROM (
+				SELECT DISTINCT ON (kind, period) kind, period, amount
+				FROM limit_tiers
+				WHERE access_level = $1 AND min_rating <= $2
+				ORDER BY kind, period, min_rating DESC
+			) tiers
+			WHERE NOT EXISTS (
+				SELECT 1 FROM limit_overrides o
+				WHERE o.user_id = $3 AND o.kind = tiers.kind AND o.period = tiers.period
+			)
+			UNION ALL
+			SELECT kind, period, amount, TRUE FROM limit_overrides WHERE user_id = $3
+			ORDER BY 1, 2`
+	rows, err := q.QueryContext(ctx, query, accessLevel, ratingLevel, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	limits := []*models.Limit{}
+	for rows.Next() {
+		var limit models.Limit
+		if err := rows.Scan(&limit.Kind, &limit.Period, &limit.Amount, &limit.Overridden); err != nil {
+			return nil, err
+		}
+		limits = append(limits, &limit)
+	}
+
+	return limits, rows.Err()
+}
@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"wallet/models"
+
+	"github.com/lib/pq"
+)
+
+type MovementRepository struct {
+	DB *sql.DB
+}
+
+func NewMovementRepository(db *sql.DB) *MovementRepository {
+	return &MovementRepository{DB: db}
+}
+
+// ListMovements returns the history of an account, newest first.
+func (repo *MovementRepository) ListMovements(ctx context.Context, accountNumber string, filter models.MovementFilter) ([]*models.Movement, error) {
+	conditions := []string{"account_number = $1"}
+	args := []interface{}{accountNumber}
+
+	addCondition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if !filter.From.IsZero() {
+		addCondition("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at < $%d", filter.To)
+	}
+	if len(filter.Types) > 0 {
+		addCondition("type = ANY($%d)", pq.Array(filter.Types))
+	}
+	if filter.MinAmount > 0 {
+		addCondition("ABS(amount) >= $%d", filter.MinAmount)
+	}
+	if filter.MaxAmount > 0 {
+		addCondition("ABS(amount) <= $%d", filter.MaxAmount)
+	}
+	if filter.BeforeID > 0 {
+		addCondition("id < $%d", filter.BeforeID)
+	}
+
+	query := "SELECT id, account_number, type, amount, balance_after, COALESCE(counterparty, ''), created_at FROM account_movements WHERE " +
+		strings.Join(conditions, " AND ") + " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := repo.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []*models.Movement
+	for rows.Next() {
+		var movement models.Movement
+		err := rows.Scan(&movement.ID, &movement.AccountNumber, &movement.Type, &movement.Amount, &movement.BalanceAfter, &movement.Counterparty, &movement.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, &movement)
+	}
+
+	return movements, rows.Err()
+}
+
+// ListPeriodMovements returns the movements of [from, to) oldest first, in
+// the order their balances follow each other.
+func (repo *MovementRepository) ListPeriodMovements(ctx context.Context, accountNumber string, from, to time.Time, limit int) ([]*models.Movement, error) {
+	query := `
+			SELECT id, account_number, type, amount, balance_after, COALESCE(counterparty, ''), created_at
+			FROM account_movements
+			WHERE account_number = $1 AND created_at >= $2 AND created_at < $3
+			ORDER BY created_at, id
+			LIMIT $4`
+	rows, err := repo.DB.QueryContext(ctx, query, accountNumber, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []*models.Movement
+	for rows.Next() {
+		var movement models.Movement
+		err := rows.Scan(&movement.ID, &movement.AccountNumber, &movement.Type, &movement.Amount, &movement.BalanceAfter, &movement.Counterparty, &movement.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, &movement)
+	}
+
+	return movements, rows.Err()
+}
+
+// BalanceAt returns the balance the account had right before the given moment.
+func (repo *MovementRepository) BalanceAt(ctx context.Context, accountNumber string, at time.Time) (float64, error) {
+	query := "SELECT balance_after FROM account_movements WHERE account_number = $1 AND created_at < $2 ORDER BY created_at DESC, id DESC LIMIT 1"
+
+	var balance float64
+	err := repo.DB.QueryRowContext(ctx, query, accountNumber, at).Scan(&balance)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return balance, nil
+}
+
+// recordMovement appends an entry to the account history as part of the
+// transaction that changed the balance.
+func recordMovement(ctx context.Context, tx *sql.Tx, movement *models.Movement) error {
+	query := "INSERT INTO account_movements (account_number, type, amount, balance_after, counterparty) VALUES ($1, $2, $3, $4, NULLIF($5, ''))"
+	_, err := tx.ExecContext(ctx, query, movement.AccountNumber, movement.Type, movement.Amount, movement.BalanceAfter, movement.Counterparty)
+	return err
+}
@@ -0,0 +1,421 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"wallet/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrOrganizationNotFound                  = errors.New("organization not found")
+	ErrNotOrganizationMember                 = errors.New("user is not a member of the organization")
+	ErrLastApprover                          = errors.New("organization needs at least one approver")
+	ErrOrganizationTransferNotFound          = errors.New("organization transfer not found")
+	ErrInvalidOrganizationTransferTransition = errors.New("organization transfer is no longer pending")
+)
+
+const organizationTransferColumns = "id, organization_id, sender_account_number, receiver_account_number, currency, amount, memo, " +
+	"requires_approval, initiated_by, approved_by, rejected_by, reason, status, created_at, updated_at"
+
+type OrganizationRepository struct {
+	DB *sql.DB
+}
+
+func NewOrganizationRepository(db *sql.DB) *OrganizationRepository {
+	return &OrganizationRepository{DB: db}
+}
+
+// CreateOrganization creates the organization with its creator as the first
+// approver.
+func (repo *OrganizationRepository) CreateOrganization(ctx context.Context, name string, creatorID int) (*models.Organization, error) {
+	var id int64
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		if err := tx.QueryRowContext(ctx, "INSERT INTO organizations (name) VALUES ($1) RETURNING id", name).Scan(&id); err != nil {
+			return err
+		}
+
+		query := "INSERT INTO organization_members (organization_id, user_id, role, added_by) VALUES ($1, $2, $3, $2)"
+		if _, err := tx.ExecContext(ctx, query, id, creatorID, models.OrganizationApprover); err != nil {
+			return err
+		}
+		return recordOrganizationActivity(ctx, tx, id, creatorID, "organization_created", map[string]interface{}{"name": name})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repo.GetOrganization(ctx, id)
+}
+
+func (repo *OrganizationRepository) GetOrganization(ctx context.Context, id int64) (*models.Organization, error) {
+	org := &models.Organization{Members: []*models.OrganizationMember{}, Accounts: []string{}, Thresholds: map[string]float64{}}
+	err := repo.DB.QueryRowContext(ctx, "SELECT id, name, created_at FROM organizations WHERE id = $1", id).Scan(&org.ID, &org.Name, &org.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := repo.DB.QueryContext(ctx, "SELECT user_id, role, added_by, created_at FROM organization_members WHERE organization_id = $1 ORDER BY user_id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var member models.OrganizationMember
+		if err := rows.Scan(&member.UserID, &member.Role, &member.AddedBy, &member.CreatedAt); err != nil {
+			return nil, err
+		}
+		org.Members = append(org.Members, &member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if org.Accounts, err = repo.ListAccounts(ctx, id); err != nil {
+		return nil, err
+	}
+
+	rows, err = repo.DB.QueryContext(ctx, "SELECT currency, amount FROM organization_thresholds WHERE organization_id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var currency string
+		var amount float64
+		if err := rows.Scan(&currency, &amount); err != nil {
+			return nil, err
+		}
+		org.Thresholds[currency] = amount
+	}
+	return org, rows.Err()
+}
+
+// GetMember returns the member of an existing organization.
+func (repo *OrganizationRepository) GetMember(ctx context.Context, orgID int64, userID int) (*models.OrganizationMember, error) {
+	var exists bool
+	if err := repo.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM organizations WHERE id = $1)", orgID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrOrganizationNotFound
+	}
+
+	member := models.OrganizationMember{UserID: userID}
+	query := "SELECT role, added_by, created_at FROM organization_members WHERE organization_id = $1 AND user_id = $2"
+	err := repo.DB.QueryRowContext(ctx, query, orgID, userID).Scan(&member.Role, &member.AddedBy, &member.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotOrganizationMember
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// SetMember adds the user to the organization or changes their role.
+func (repo *OrganizationRepository) SetMember(ctx context.Context, orgID int64, actorID, userID int, role string) (*models.OrganizationMember, error) {
+	member := &models.OrganizationMember{UserID: userID, Role: role, AddedBy: actorID}
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		if err := lockOrganization(ctx, tx, orgID); err != nil {
+			return err
+		}
+		if role != models.OrganizationApprover {
+			if err := checkOtherApprover(ctx, tx, orgID, userID); err != nil {
+				return err
+			}
+		}
+
+		query := `
+				INSERT INTO organization_members (organization_id, user_id, role, added_by) VALUES ($1, $2, $3, $4)
+				ON CONFLICT (organization_id, user_id) DO UPDATE SET role = EXCLUDED.role
+				RETURNING added_by, created_at`
+		if err := tx.QueryRowContext(ctx, query, orgID, userID, role, actorID).Scan(&member.AddedBy, &member.CreatedAt); err != nil {
+			return err
+		}
+		return recordOrganizationActivity(ctx, tx, orgID, actorID, "member_set", map[string]interface{}{"user_id": userID, "role": role})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+func (repo *OrganizationRepository) RemoveMember(ctx context.Context, orgID int64, actorID, userID int) error {
+	return inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		if err := lockOrganization(ctx, tx, orgID); err != nil {
+			return err
+		}
+		if err := checkOtherApprover(ctx, tx, orgID, userID); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, "DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2", orgID, userID)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrNotOrganizationMember
+		}
+		return recordOrganizationActivity(ctx, tx, orgID, actorID, "member_removed", map[string]interface{}{"user_id": userID})
+	})
+}
+
+// AddAccount opens an account in the currency for the organization.
+func (repo *OrganizationRepository) AddAccount(ctx context.Context, orgID int64, actorID int, currency string) (*models.Account, error) {
+	var account *models.Account
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		var err error
+		if account, err = createAccount(ctx, tx, currency); err != nil {
+			return err
+		}
+
+		query := "INSERT INTO organization_accounts (organization_id, account_number) VALUES ($1, $2)"
+		if _, err := tx.ExecContext(ctx, query, orgID, account.AccountNumber); err != nil {
+			return err
+		}
+		return recordOrganizationActivity(ctx, tx, orgID, actorID, "account_opened", map[string]interface{}{"account_number": account.AccountNumber})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func (repo *OrganizationRepository) ListAccounts(ctx context.Context, orgID int64) ([]string, error) {
+	rows, err := repo.DB.QueryContext(ctx, "SELECT account_number FROM organization_accounts WHERE organization_id = $1 ORDER BY account_number", orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []string{}
+	for rows.Next() {
+		var accountNumber string
+		if err := rows.Scan(&accountNumber); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, accountNumber)
+	}
+	return accounts, rows.Err()
+}
+
+// GetOrganizationIDByAccount returns the organization owning the account,
+// or 0 if it is not an organization account.
+func (repo *OrganizationRepository) GetOrganizationIDByAccount(ctx context.Context, accountNumber string) (int64, error) {
+	var orgID int64
+	err := repo.DB.QueryRowContext(ctx, "SELECT organization_id FROM organization_accounts WHERE account_number = $1", accountNumber).Scan(&orgID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return orgID, err
+}
+
+func (repo *OrganizationRepository) SetThreshold(ctx context.Context, orgID int64, actorID int, currency string, amount float64) error {
+	return inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		query := `
+				INSERT INTO organization_thresholds (organization_id, currency, amount) VALUES ($1, $2, $3)
+				ON CONFLICT (organization_id, currency) DO UPDATE SET amount = EXCLUDED.amount`
+		if _, err := tx.ExecContext(ctx, query, orgID, currency, amount); err != nil {
+			return err
+		}
+		return recordOrganizationActivity(ctx, tx, orgID, actorID, "threshold_set", map[string]interface{}{"currency": currency, "amount": amount})
+	})
+}
+
+func (repo *OrganizationRepository) CreateTransfer(ctx context.Context, t *models.OrganizationTransfer) (*models.OrganizationTransfer, error) {
+	var created *models.OrganizationTransfer
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		query := `
+				INSERT INTO organization_transfers (organization_id, sender_account_number, receiver_account_number, currency, amount, memo, requires_approval, initiated_by, status)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+				RETURNING ` + organizationTransferColumns
+		var err error
+		created, err = scanOrganizationTransfer(tx.QueryRowContext(ctx, query, t.OrganizationID, t.SenderAccountNumber, t.ReceiverAccountNumber,
+			t.Currency, t.Amount, t.Memo, t.RequiresApproval, t.InitiatedBy, models.OrganizationTransferPending))
+		if err != nil {
+			return err
+		}
+		return recordOrganizationActivity(ctx, tx, t.OrganizationID, t.InitiatedBy, "transfer_initiated", map[string]interface{}{"transfer_id": created.ID})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// ExecuteTransfer moves the money of the pending transfer and marks it
+// executed, approved by approverID if set, in one transaction. A
+// concurrent decision on the same transfer waits and then finds it done.
+func (repo *OrganizationRepository) ExecuteTransfer(ctx context.Context, orgID, id int64, actorID int, approverID *int, amount float64, fee *models.FeeItem, houseAccountNumber string) (*models.OrganizationTransfer, error) {
+	var executed *models.OrganizationTransfer
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		t, err := lockPendingOrganizationTransfer(ctx, tx, orgID, id)
+		if err != nil {
+			return err
+		}
+		if err := transferWithFee(ctx, tx, t.SenderAccountNumber, t.ReceiverAccountNumber, amount, fee, houseAccountNumber); err != nil {
+			return err
+		}
+
+		query := "UPDATE organization_transfers SET status = $2, approved_by = $3, updated_at = NOW() WHERE id = $1 RETURNING " + organizationTransferColumns
+		if executed, err = scanOrganizationTransfer(tx.QueryRowContext(ctx, query, id, models.OrganizationTransferExecuted, approverID)); err != nil {
+			return err
+		}
+		return recordOrganizationActivity(ctx, tx, orgID, actorID, "transfer_executed", map[string]interface{}{"transfer_id": id})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return executed, nil
+}
+
+// CloseTransfer ends a pending transfer without moving money: rejected or
+// cancelled by actorID, or failed.
+func (repo *OrganizationRepository) CloseTransfer(ctx context.Context, orgID, id int64, actorID int, status, reason string) (*models.OrganizationTransfer, error) {
+	var closed *models.OrganizationTransfer
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		if _, err := lockPendingOrganizationTransfer(ctx, tx, orgID, id); err != nil {
+			return err
+		}
+
+		var rejectedBy *int
+		if status != models.OrganizationTransferFailed {
+			rejectedBy = &actorID
+		}
+		query := "UPDATE organization_transfers SET status = $2, rejected_by = $3, reason = $4, updated_at = NOW() WHERE id = $1 RETURNING " + organizationTransferColumns
+		var err error
+		if closed, err = scanOrganizationTransfer(tx.QueryRowContext(ctx, query, id, status, rejectedBy, reason)); err != nil {
+			return err
+		}
+		return recordOrganizationActivity(ctx, tx, orgID, actorID, "transfer_"+status, map[string]interface{}{"transfer_id": id, "reason": reason})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return closed, nil
+}
+
+func (repo *OrganizationRepository) GetTransfer(ctx context.Context, orgID, id int64) (*models.OrganizationTransfer, error) {
+	query := "SELECT " + organizationTransferColumns + " FROM organization_transfers WHERE organization_id = $1 AND id = $2"
+	t, err := scanOrganizationTransfer(repo.DB.QueryRowContext(ctx, query, orgID, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrOrganizationTransferNotFound
+	}
+	return t, err
+}
+
+// ListTransfers returns the organization's transfers, newest first, with
+// one of the statuses if any are given.
+func (repo *OrganizationRepository) ListTransfers(ctx context.Context, orgID int64, statuses []string) ([]*models.OrganizationTransfer, error) {
+	query := "SELECT " + organizationTransferColumns + " FROM organization_transfers WHERE organization_id = $1 AND (cardinality($2::text[]) = 0 OR status = ANY($2)) ORDER BY id DESC"
+	rows, err := repo.DB.QueryContext(ctx, query, orgID, pq.Array(statuses))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := []*models.OrganizationTransfer{}
+	for rows.Next() {
+		t, err := scanOrganizationTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+	return transfers, rows.Err()
+}
+
+func (repo *OrganizationRepository) ListActivity(ctx context.Context, orgID int64, limit int) ([]*models.OrganizationActivity, error) {
+	query := "SELECT id, user_id, action, details, created_at FROM organization_activity WHERE organization_id = $1 ORDER BY id DESC LIMIT $2"
+	rows, err := repo.DB.QueryContext(ctx, query, orgID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activity := []*models.OrganizationActivity{}
+	for rows.Next() {
+		var entry models.OrganizationActivity
+		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.Action, &entry.Details, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		activity = append(activity, &entry)
+	}
+	return activity, rows.Err()
+}
+
+// lockOrganization serializes membership changes, so two approvers cannot
+// demote each other at the same time.
+func lockOrganization(ctx context.Context, tx *sql.Tx, orgID int64) error {
+	var id int64
+	err := tx.QueryRowContext(ctx, "SELECT id FROM organizations WHERE id = $1 FOR UPDATE", orgID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrOrganizationNotFound
+	}
+	return err
+}
+
+// checkOtherApprover fails if userID is the only approver left.
+func checkOtherApprover(ctx context.Context, tx *sql.Tx, orgID int64, userID int) error {
+	var others int
+	query := "SELECT COUNT(*) FROM organization_members WHERE organization_id = $1 AND role = $2 AND user_id <> $3"
+	if err := tx.QueryRowContext(ctx, query, orgID, models.OrganizationApprover, userID).Scan(&others); err != nil {
+		return err
+	}
+	if others == 0 {
+		return ErrLastApprover
+	}
+	return nil
+}
+
+func lockPendingOrganizationTransfer(ctx context.Context, tx *sql.Tx, orgID, id int64) (*models.OrganizationTransfer, error) {
+	query := "SELECT " + organizationTransferColumns + " FROM organization_transfers WHERE organization_id = $1 AND id = $2 FOR UPDATE"
+	t, err := scanOrganizationTransfer(tx.QueryRowContext(ctx, query, orgID, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrOrganizationTransferNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.Status != models.OrganizationTransferPending {
+		return nil, ErrInvalidOrganizationTransferTransition
+	}
+	return t, nil
+}
+
+func recordOrganizationActivity(ctx context.Context, tx *sql.Tx, orgID int64, userID int, action string, details interface{}) error {
+	body, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	query := "INSERT INTO organization_activity (organization_id, user_id, action, details) VALUES ($1, $2, $3, $4)"
+	_, err = tx.ExecContext(ctx, query, orgID, userID, action, body)
+	return err
+}
+
+func scanOrganizationTransfer(row rowScanner) (*models.OrganizationTransfer, error) {
+	var t models.OrganizationTransfer
+	var approvedBy, rejectedBy sql.NullInt64
+	err := row.Scan(&t.ID, &t.OrganizationID, &t.SenderAccountNumber, &t.ReceiverAccountNumber, &t.Currency, &t.Amount, &t.Memo,
+		&t.RequiresApproval, &t.InitiatedBy, &approvedBy, &rejectedBy, &t.Reason, &t.Status, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if approvedBy.Valid {
+		id := int(approvedBy.Int64)
+		t.ApprovedBy = &id
+	}
+	if rejectedBy.Valid {
+		id := int(rejectedBy.Int64)
+		t.RejectedBy = &id
+	}
+	return &t, nil
+}
@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"wallet/models"
+)
+
+var ErrPaymentNotFound = errors.New("payment not found")
+
+const paymentColumns = "id, sender_account_number, recipient_account_number, recipient_username, currency, amount, fee, memo, created_at"
+
+type PaymentRepository struct {
+	DB *sql.DB
+}
+
+func NewPaymentRepository(db *sql.DB) *PaymentRepository {
+	return &PaymentRepository{DB: db}
+}
+
+// CreatePayment transfers the amount to the recipient and stores the
+// payment in the same transaction.
+func (repo *PaymentRepository) CreatePayment(ctx context.Context, p *models.Payment, fee *models.FeeItem, houseAccountNumber string) (*models.Payment, error) {
+	var created *models.Payment
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		err := transferWithFee(ctx, tx, p.SenderAccountNumber, p.RecipientAccountNumber, p.Amount, fee, houseAccountNumber)
+		if err != nil {
+			return err
+		}
+
+		query := `
+				INSERT INTO payments (sender_account_number, recipient_account_number, recipient_username, currency, amount, fee, memo)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+				RETURNING ` + paymentColumns
+		created, err = scanPayment(tx.QueryRowContext(ctx, query, p.SenderAccountNumber, p.RecipientAccountNumber,
+			p.RecipientUsername, p.Currency, p.Amount, p.Fee, p.Memo))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (repo *PaymentRepository) GetPayment(ctx context.Context, id int64) (*models.Payment, error) {
+	query := "SELECT " + paymentColumns + " FROM payments WHERE id = $1"
+	p, err := scanPayment(repo.DB.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrPaymentNotFound
+	}
+	return p, err
+}
+
+// ListAccountPayments returns the payments sent from and received by the
+// account, newest first.
+func (repo *PaymentRepository) ListAccountPayments(ctx context.Context, accountNumber string, limit int) ([]*models.Payment, error) {
+	query := "SELECT " + paymentColumns + " FROM payments WHERE sender_account_number = $1 OR recipient_account_number = $1 ORDER BY id DESC LIMIT $2"
+	rows, err := repo.DB.QueryContext(ctx, query, accountNumber, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []*models.Payment{}
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
+
+func scanPayment(row rowScanner) (*models.Payment, error) {
+	var p models.Payment
+	err := row.Scan(&p.ID, &p.SenderAccountNumber, &p.RecipientAccountNumber, &p.RecipientUsername, &p.Currency,
+		&p.Amount, &p.Fee, &p.Memo, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
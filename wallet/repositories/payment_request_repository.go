@@ -0,0 +1,164 @@
+package repositories
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"wallet/models"
+)
+
+var (
+	ErrPaymentRequestNotFound = errors.New("payment request not found")
+	ErrPaymentRequestNotOpen  = errors.New("payment request is no longer open")
+)
+
+const maxReferenceAttempts = 5
+
+// An open request past its expiry reads as expired, nothing has to sweep
+// the table for it.
+const paymentRequestColumns = "id, reference, payee_account_number, currency, amount, memo, " +
+	"CASE WHEN status = 'open' AND expires_at <= NOW() THEN 'expired' ELSE status END, " +
+	"expires_at, payer_account_number, paid_at, created_at, updated_at"
+
+type PaymentRequestRepository struct {
+	DB *sql.DB
+}
+
+func NewPaymentRequestRepository(db *sql.DB) *PaymentRequestRepository {
+	return &PaymentRequestRepository{DB: db}
+}
+
+// CreatePaymentRequest stores the request under a new random reference.
+func (repo *PaymentRequestRepository) CreatePaymentRequest(ctx context.Context, pr *models.PaymentRequest) (*models.PaymentRequest, error) {
+	query := `
+			INSERT INTO payment_requests (reference, payee_account_number, currency, amount, memo, status, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (reference) DO NOTHING
+			RETURNING ` + paymentRequestColumns
+
+	for attempt := 1; attempt <= maxReferenceAttempts; attempt++ {
+		reference, err := newPaymentReference()
+		if err != nil {
+			return nil, err
+		}
+
+		created, err := scanPaymentRequest(repo.DB.QueryRowContext(ctx, query, reference, pr.PayeeAccountNumber, pr.Currency,
+			pr.Amount, pr.Memo, models.PaymentRequestOpen, pr.ExpiresAt))
+		if err == sql.ErrNoRows {
+			continue
+		}
+		return created, err
+	}
+	return nil, errors.New("could not generate a free payment request reference")
+}
+
+func (repo *PaymentRequestRepository) GetPaymentRequest(ctx context.Context, reference string) (*models.PaymentRequest, error) {
+	query := "SELECT " + paymentRequestColumns + " FROM payment_requests WHERE reference = $1"
+	pr, err := scanPaymentRequest(repo.DB.QueryRowContext(ctx, query, reference))
+	if err == sql.ErrNoRows {
+		return nil, ErrPaymentRequestNotFound
+	}
+	return pr, err
+}
+
+func (repo *PaymentRequestRepository) ListAccountPaymentRequests(ctx context.Context, accountNumber string) ([]*models.PaymentRequest, error) {
+	query := "SELECT " + paymentRequestColumns + " FROM payment_requests WHERE payee_account_number = $1 OR payer_account_number = $1 ORDER BY id DESC"
+	rows, err := repo.DB.QueryContext(ctx, query, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []*models.PaymentRequest{}
+	for rows.Next() {
+		pr, err := scanPaymentRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, pr)
+	}
+	return requests, rows.Err()
+}
+
+func (repo *PaymentRequestRepository) Cancel(ctx context.Context, reference string) (*models.PaymentRequest, error) {
+	var cancelled *models.PaymentRequest
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		if _, err := lockOpenPaymentRequest(ctx, tx, reference); err != nil {
+			return err
+		}
+
+		query := "UPDATE payment_requests SET status = $2, updated_at = NOW() WHERE reference = $1 RETURNING " + paymentRequestColumns
+		var err error
+		cancelled, err = scanPaymentRequest(tx.QueryRowContext(ctx, query, reference, models.PaymentRequestCancelled))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cancelled, nil
+}
+
+// Settle transfers amount to the payee of the open request and marks it paid
+// by the payer's account in one transaction. A concurrent payment of the
+// same request waits for the lock and then finds it paid.
+func (repo *PaymentRequestRepository) Settle(ctx context.Context, reference, payerAccountNumber string, amount float64, fee *models.FeeItem, houseAccountNumber string) (*models.PaymentRequest, error) {
+	var paid *models.PaymentRequest
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		pr, err := lockOpenPaymentRequest(ctx, tx, reference)
+		if err != nil {
+			return err
+		}
+		if err := transferWithFee(ctx, tx, payerAccountNumber, pr.PayeeAccountNumber, amount, fee, houseAccountNumber); err != nil {
+			return err
+		}
+
+		query := `
+				UPDATE payment_requests
+				SET status = $2, payer_account_number = $3, paid_at = NOW(), updated_at = NOW()
+				WHERE reference = $1
+				RETURNING ` + paymentRequestColumns
+		paid, err = scanPaymentRequest(tx.QueryRowContext(ctx, query, reference, models.PaymentRequestPaid, payerAccountNumber))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paid, nil
+}
+
+func lockOpenPaymentRequest(ctx context.Context, tx *sql.Tx, reference string) (*models.PaymentRequest, error) {
+	query := "SELECT " + paymentRequestColumns + " FROM payment_requests WHERE reference = $1 FOR UPDATE"
+	pr, err := scanPaymentRequest(tx.QueryRowContext(ctx, query, reference))
+	if err == sql.ErrNoRows {
+		return nil, ErrPaymentRequestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pr.Status != models.PaymentRequestOpen {
+		return nil, ErrPaymentRequestNotOpen
+	}
+	return pr, nil
+}
+
+// newPaymentReference returns a reference like PR-7KQ2M4XJWTZA that is easy
+// to read out and type.
+func newPaymentReference() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "PR-" + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)[:12], nil
+}
+
+func scanPaymentRequest(row rowScanner) (*models.PaymentRequest, error) {
+	var pr models.PaymentRequest
+	err := row.Scan(&pr.ID, &pr.Reference, &pr.PayeeAccountNumber, &pr.Currency, &pr.Amount, &pr.Memo, &pr.Status,
+		&pr.ExpiresAt, &pr.PayerAccountNumber, &pr.PaidAt, &pr.CreatedAt, &pr.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
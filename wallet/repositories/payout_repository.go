@@ -0,0 +1,182 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"wallet/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrPayoutNotFound     = errors.New("payout not found")
+	ErrDuplicateReference = errors.New("payout reference was paid already")
+)
+
+const payoutColumns = "id, account_number, currency, mode, status, line_count, paid_count, failed_count, total_amount, total_fees, created_at"
+
+type PayoutRepository struct {
+	DB *sql.DB
+}
+
+func NewPayoutRepository(db *sql.DB) *PayoutRepository {
+	return &PayoutRepository{DB: db}
+}
+
+// PaidLines returns the paid lines of the account's payouts by reference.
+func (repo *PayoutRepository) PaidLines(ctx context.Context, accountNumber string, references []string) (map[string]*models.PayoutLine, error) {
+	query := `
+			SELECT line, recipient, recipient_account_number, amount, currency, reference, fee, status, error
+			FROM payout_lines WHERE account_number = $1 AND status = $2 AND reference = ANY($3)`
+	rows, err := repo.DB.QueryContext(ctx, query, accountNumber, models.PayoutLinePaid, pq.Array(references))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := make(map[string]*models.PayoutLine)
+	for rows.Next() {
+		line, err := scanPayoutLine(rows)
+		if err != nil {
+			return nil, err
+		}
+		lines[line.Reference] = line
+	}
+	return lines, rows.Err()
+}
+
+// CreatePayout stores the payout before its lines are paid one by one.
+func (repo *PayoutRepository) CreatePayout(ctx context.Context, p *models.Payout) error {
+	return insertPayout(ctx, repo.DB, p)
+}
+
+// ExecutePayout stores the payout and pays all its valid lines in one
+// transaction. Nothing is paid if any of the transfers fails.
+func (repo *PayoutRepository) ExecutePayout(ctx context.Context, p *models.Payout, houseAccountNumber string) error {
+	return inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		if err := insertPayout(ctx, tx, p); err != nil {
+			return err
+		}
+
+		for _, line := range p.Lines {
+			if line.Status == models.PayoutLineValid {
+				err := transferWithFee(ctx, tx, p.AccountNumber, line.RecipientAccountNumber, line.Amount, line.FeeItem, houseAccountNumber)
+				if err != nil {
+					return err
+				}
+				line.Status = models.PayoutLinePaid
+			}
+			if err := insertPayoutLine(ctx, tx, p.ID, line); err != nil {
+				return err
+			}
+		}
+		return updatePayout(ctx, tx, p)
+	})
+}
+
+// PayLine pays a single line of the payout and records it.
+func (repo *PayoutRepository) PayLine(ctx context.Context, p *models.Payout, line *models.PayoutLine, houseAccountNumber string) error {
+	return inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		err := transferWithFee(ctx, tx, p.AccountNumber, line.RecipientAccountNumber, line.Amount, line.FeeItem, houseAccountNumber)
+		if err != nil {
+			return err
+		}
+
+		paid := *line
+		paid.Status = models.PayoutLinePaid
+		if err := insertPayoutLine(ctx, tx, p.ID, &paid); err != nil {
+			return err
+		}
+		line.Status = paid.Status
+		return nil
+	})
+}
+
+// RecordLine records a line that was not paid.
+func (repo *PayoutRepository) RecordLine(ctx context.Context, p *models.Payout, line *models.PayoutLine) error {
+	return inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		return insertPayoutLine(ctx, tx, p.ID, line)
+	})
+}
+
+func (repo *PayoutRepository) FinishPayout(ctx context.Context, p *models.Payout) error {
+	return inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		return updatePayout(ctx, tx, p)
+	})
+}
+
+func (repo *PayoutRepository) GetPayout(ctx context.Context, id int64) (*models.Payout, error) {
+	query := "SELECT " + payoutColumns + " FROM payouts WHERE id = $1"
+	var p models.Payout
+	err := repo.DB.QueryRowContext(ctx, query, id).Scan(&p.ID, &p.AccountNumber, &p.Currency, &p.Mode, &p.Status,
+		&p.LineCount, &p.PaidCount, &p.FailedCount, &p.TotalAmount, &p.TotalFees, &p.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrPayoutNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+			SELECT line, recipient, recipient_account_number, amount, currency, reference, fee, status, error
+			FROM payout_lines WHERE payout_id = $1 ORDER BY line`
+	rows, err := repo.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	p.Lines = []*models.PayoutLine{}
+	for rows.Next() {
+		line, err := scanPayoutLine(rows)
+		if err != nil {
+			return nil, err
+		}
+		p.Lines = append(p.Lines, line)
+	}
+	return &p, rows.Err()
+}
+
+func insertPayout(ctx context.Context, q querier, p *models.Payout) error {
+	query := `
+			INSERT INTO payouts (account_number, currency, mode, status, line_count)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, created_at`
+	return q.QueryRowContext(ctx, query, p.AccountNumber, p.Currency, p.Mode, p.Status, p.LineCount).Scan(&p.ID, &p.CreatedAt)
+}
+
+// insertPayoutLine fails with ErrDuplicateReference if a paid line with the
+// same reference exists, e.g. paid by a concurrent payout.
+func insertPayoutLine(ctx context.Context, tx *sql.Tx, payoutID int64, line *models.PayoutLine) error {
+	query := `
+			INSERT INTO payout_lines (payout_id, account_number, line, recipient, recipient_account_number, amount, currency, reference, fee, status, error)
+			SELECT $1, account_number, $2, $3, $4, $5, $6, $7, $8, $9, $10 FROM payouts WHERE id = $1`
+	_, err := tx.ExecContext(ctx, query, payoutID, line.Line, line.Recipient, line.RecipientAccountNumber, line.Amount,
+		line.Currency, line.Reference, line.Fee, line.Status, line.Error)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicateReference
+	}
+	return err
+}
+
+func updatePayout(ctx context.Context, tx *sql.Tx, p *models.Payout) error {
+	query := `
+			UPDATE payouts
+			SET status = $2, paid_count = $3, failed_count = $4, total_amount = $5, total_fees = $6
+			WHERE id = $1`
+	_, err := tx.ExecContext(ctx, query, p.ID, p.Status, p.PaidCount, p.FailedCount, p.TotalAmount, p.TotalFees)
+	return err
+}
+
+func scanPayoutLine(row rowScanner) (*models.PayoutLine, error) {
+	var line models.PayoutLine
+	err := row.Scan(&line.Line, &line.Recipient, &line.RecipientAccountNumber, &line.Amount, &line.Currency,
+		&line.Reference, &line.Fee, &line.Status, &line.Error)
+	if err != nil {
+		return nil, err
+	}
+	return &line, nil
+}
@@ -0,0 +1,172 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"wallet/models"
+
+	"github.com/lib/pq"
+)
+
+var ErrReconciliationNotFound = errors.New("reconciliation report not found")
+
+type ReconciliationRepository struct {
+	DB *sql.DB
+}
+
+func NewReconciliationRepository(db *sql.DB) *ReconciliationRepository {
+	return &ReconciliationRepository{DB: db}
+}
+
+// Snapshot reads the accounts whose balances disagree with their movements
+// and holds, the liabilities per currency and the chain deposits and
+// withdrawals of the chain currencies, all as of the same moment.
+func (repo *ReconciliationRepository) Snapshot(ctx context.Context, chainCurrencies []string) (*models.LedgerSnapshot, error) {
+	tx, err := repo.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	snapshot := &models.LedgerSnapshot{Accounts: []models.AccountDrift{}, Liabilities: make(map[string]float64)}
+
+	rows, err := tx.QueryContext(ctx, "SELECT currency, COUNT(*), COALESCE(SUM(balance), 0) FROM accounts GROUP BY currency")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var currency string
+		var count int
+		var total float64
+		if err := rows.Scan(&currency, &count, &total); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		snapshot.AccountsChecked += count
+		snapshot.Liabilities[currency] = total
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query := `
+			SELECT a.account_number, a.currency, a.balance, COALESCE(m.total, 0), a.balance - COALESCE(m.total, 0),
+				a.held_balance, COALESCE(h.total, 0), a.held_balance - COALESCE(h.total, 0)
+			FROM accounts a
+			LEFT JOIN (SELECT account_number, SUM(amount) AS total FROM account_movements GROUP BY account_number) m
+				ON m.account_number = a.account_number
+			LEFT JOIN (SELECT account_number, SUM(amount) AS total FROM account_holds WHERE status = 'active' GROUP BY account_number) h
+				ON h.account_number = a.account_number
+			WHERE a.balance <> COALESCE(m.total, 0) OR a.held_balance <> COALESCE(h.total, 0)
+			ORDER BY a.account_number`
+	rows, err = tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var drift models.AccountDrift
+		err := rows.Scan(&drift.AccountNumber, &drift.Currency, &drift.Balance, &drift.MovementTotal, &drift.Drift,
+			&drift.HeldBalance, &drift.ActiveHolds, &drift.HeldDrift)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		snapshot.Accounts = append(snapshot.Accounts, drift)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Reorged deposits are still booked until their reversal.
+	query = "SELECT " + chainDepositColumns + " FROM chain_deposits WHERE currency = ANY($1) AND status IN ('credited', 'reorged') ORDER BY block_height, id"
+	rows, err = tx.QueryContext(ctx, query, pq.Array(chainCurrencies))
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		deposit, err := scanChainDeposit(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		snapshot.Deposits = append(snapshot.Deposits, deposit)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = "SELECT " + withdrawalColumns + " FROM withdrawals WHERE currency = ANY($1) AND status = 'confirmed' ORDER BY id"
+	rows, err = tx.QueryContext(ctx, query, pq.Array(chainCurrencies))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		withdrawal, err := scanWithdrawal(rows)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Withdrawals = append(snapshot.Withdrawals, withdrawal)
+	}
+	return snapshot, rows.Err()
+}
+
+func (repo *ReconciliationRepository) SaveReport(ctx context.Context, report *models.ReconciliationReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	query := `
+			INSERT INTO reconciliation_reports (started_at, finished_at, discrepancies, report)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id`
+	return repo.DB.QueryRowContext(ctx, query, report.StartedAt, report.FinishedAt, report.Discrepancies, body).Scan(&report.ID)
+}
+
+func (repo *ReconciliationRepository) GetReport(ctx context.Context, id int64) (*models.ReconciliationReport, error) {
+	report, err := scanReconciliationReport(repo.DB.QueryRowContext(ctx, "SELECT id, report FROM reconciliation_reports WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrReconciliationNotFound
+	}
+	return report, err
+}
+
+// ListReports returns the latest reports first.
+func (repo *ReconciliationRepository) ListReports(ctx context.Context, limit int) ([]*models.ReconciliationReport, error) {
+	rows, err := repo.DB.QueryContext(ctx, "SELECT id, report FROM reconciliation_reports ORDER BY id DESC LIMIT $1", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []*models.ReconciliationReport{}
+	for rows.Next() {
+		report, err := scanReconciliationReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+func scanReconciliationReport(row rowScanner) (*models.ReconciliationReport, error) {
+	var id int64
+	var body []byte
+	if err := row.Scan(&id, &body); err != nil {
+		return nil, err
+	}
+
+	var report models.ReconciliationReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, err
+	}
+	report.ID = id
+	return &report, nil
+}
@@ -0,0 +1,245 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+	"wallet/models"
+)
+
+var (
+	ErrScheduledTransferNotFound = errors.New("scheduled transfer not found")
+	ErrInvalidScheduleTransition = errors.New("invalid scheduled transfer status transition")
+	// ErrScheduledTransferNotDue is returned when the occurrence was already
+	// handled, e.g. by another worker, or the transfer was paused meanwhile.
+	ErrScheduledTransferNotDue = errors.New("scheduled transfer is not due")
+)
+
+const scheduledTransferColumns = `id, sender_account_number, receiver_account_number, amount, kind, interval_seconds, cron,
+	end_at, next_run_at, retry_at, status, attempts, runs, last_run_at, last_error, created_at, updated_at`
+
+type ScheduledTransferRepository struct {
+	DB *sql.DB
+}
+
+func NewScheduledTransferRepository(db *sql.DB) *ScheduledTransferRepository {
+	return &ScheduledTransferRepository{DB: db}
+}
+
+func (repo *ScheduledTransferRepository) CreateScheduledTransfer(ctx context.Context, st *models.ScheduledTransfer) (*models.ScheduledTransfer, error) {
+	query := `
+			INSERT INTO scheduled_transfers (sender_account_number, receiver_account_number, amount, kind, interval_seconds, cron, end_at, next_run_at, status)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING ` + scheduledTransferColumns
+	return scanScheduledTransfer(repo.DB.QueryRowContext(ctx, query,
+		st.SenderAccountNumber, st.ReceiverAccountNumber, st.Amount, st.Kind, st.IntervalSeconds, st.Cron, st.EndAt, st.NextRunAt, models.ScheduleActive))
+}
+
+func (repo *ScheduledTransferRepository) GetScheduledTransfer(ctx context.Context, id int64) (*models.ScheduledTransfer, error) {
+	st, err := scanScheduledTransfer(repo.DB.QueryRowContext(ctx, "SELECT "+scheduledTransferColumns+" FROM scheduled_transfers WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrScheduledTransferNotFound
+	}
+	return st, err
+}
+
+// ListScheduledTransfers returns the scheduled transfers sent from the account.
+func (repo *ScheduledTransferRepository) ListScheduledTransfers(ctx context.Context, accountNumber string) ([]*models.ScheduledTransfer, error) {
+	query := "SELECT " + scheduledTransferColumns + " FROM scheduled_transfers WHERE sender_account_number = $1 ORDER BY id"
+	return repo.list(ctx, query, accountNumber)
+}
+
+// ListDue returns active transfers whose next run or retry is due.
+func (repo *ScheduledTransferRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*models.ScheduledTransfer, error) {
+	query := `
+			SELECT ` + scheduledTransferColumns + `
+			FROM scheduled_transfers
+			WHERE status = $1 AND COALESCE(retry_at, next_run_at) <= $2
+			ORDER BY COALESCE(retry_at, next_run_at)
+			LIMIT $3`
+	return repo.list(ctx, query, models.ScheduleActive, now, limit)
+}
+
+func (repo *ScheduledTransferRepository) ListRuns(ctx context.Context, id int64) ([]*models.ScheduledTransferRun, error) {
+	query := "SELECT id, scheduled_transfer_id, scheduled_for, status, error, created_at FROM scheduled_transfer_runs WHERE scheduled_transfer_id = $1 ORDER BY id DESC"
+	rows, err := repo.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []*models.ScheduledTransferRun{}
+	for rows.Next() {
+		var run models.ScheduledTransferRun
+		if err := rows.Scan(&run.ID, &run.ScheduledTransferID, &run.ScheduledFor, &run.Status, &run.Error, &run.CreatedAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, &run)
+	}
+
+	return runs, rows.Err()
+}
+
+// Execute transfers the occurrence scheduled for the given time and moves
+// the transfer on to next, or completes it when next is nil. The transfer,
+// the run and the new schedule are committed together, so an occurrence is
+// never executed twice.
+func (repo *ScheduledTransferRepository) Execute(ctx context.Context, id int64, scheduledFor time.Time, next *time.Time, fee *models.FeeItem, houseAccountNumber string) (*models.ScheduledTransfer, error) {
+	var st *models.ScheduledTransfer
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		current, err := lockDueScheduledTransfer(ctx, tx, id, scheduledFor)
+		if err != nil {
+			return err
+		}
+
+		if err := transferWithFee(ctx, tx, current.SenderAccountNumber, current.ReceiverAccountNumber, current.Amount, fee, houseAccountNumber); err != nil {
+			return err
+		}
+
+		if err := insertRun(ctx, tx, id, scheduledFor, models.RunSucceeded, ""); err != nil {
+			return err
+		}
+
+		status := models.ScheduleActive
+		nextRunAt := scheduledFor
+		if next != nil {
+			nextRunAt = *next
+		} else {
+			status = models.ScheduleCompleted
+		}
+
+		query := `
+				UPDATE scheduled_transfers
+				SET next_run_at = $1, status = $2, retry_at = NULL, attempts = 0, runs = runs + 1,
+					last_run_at = NOW(), last_error = '', updated_at = NOW()
+				WHERE id = $3
+				RETURNING ` + scheduledTransferColumns
+		st, err = scanScheduledTransfer(tx.QueryRowContext(ctx, query, nextRunAt, status, id))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// RecordFailure records a failed attempt. The occurrence is retried at
+// retryAt, without it the scheduled transfer fails for good.
+func (repo *ScheduledTransferRepository) RecordFailure(ctx context.Context, id int64, scheduledFor time.Time, runErr error, retryAt *time.Time) (*models.ScheduledTransfer, error) {
+	var st *models.ScheduledTransfer
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		if _, err := lockDueScheduledTransfer(ctx, tx, id, scheduledFor); err != nil {
+			return err
+		}
+
+		if err := insertRun(ctx, tx, id, scheduledFor, models.RunFailed, runErr.Error()); err != nil {
+			return err
+		}
+
+		status := models.ScheduleActive
+		if retryAt == nil {
+			status = models.ScheduleFailed
+		}
+
+		query := `
+				UPDATE scheduled_transfers
+				SET retry_at = $1, status = $2, attempts = attempts + 1, last_error = $3, updated_at = NOW()
+				WHERE id = $4
+				RETURNING ` + scheduledTransferColumns
+		var err error
+		st, err = scanScheduledTransfer(tx.QueryRowContext(ctx, query, retryAt, status, runErr.Error(), id))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// UpdateStatus moves the transfer to status if it currently is in one of the
+// from states. Resuming starts over with the retries.
+func (repo *ScheduledTransferRepository) UpdateStatus(ctx context.Context, id int64, status string, from ...string) (*models.ScheduledTransfer, error) {
+	var st *models.ScheduledTransfer
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		current, err := scanScheduledTransfer(tx.QueryRowContext(ctx, "SELECT "+scheduledTransferColumns+" FROM scheduled_transfers WHERE id = $1 FOR UPDATE", id))
+		if err == sql.ErrNoRows {
+			return ErrScheduledTransferNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		allowed := false
+		for _, s := range from {
+			allowed = allowed || current.Status == s
+		}
+		if !allowed {
+			return ErrInvalidScheduleTransition
+		}
+
+		query := `
+				UPDATE scheduled_transfers
+				SET status = $1, updated_at = NOW(),
+					attempts = CASE WHEN $1 = 'active' THEN 0 ELSE attempts END,
+					retry_at = CASE WHEN $1 = 'active' THEN NULL ELSE retry_at END
+				WHERE id = $2
+				RETURNING ` + scheduledTransferColumns
+		st, err = scanScheduledTransfer(tx.QueryRowContext(ctx, query, status, id))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func (repo *ScheduledTransferRepository) list(ctx context.Context, query string, args ...interface{}) ([]*models.ScheduledTransfer, error) {
+	rows, err := repo.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := []*models.ScheduledTransfer{}
+	for rows.Next() {
+		st, err := scanScheduledTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, st)
+	}
+
+	return transfers, rows.Err()
+}
+
+func lockDueScheduledTransfer(ctx context.Context, tx *sql.Tx, id int64, scheduledFor time.Time) (*models.ScheduledTransfer, error) {
+	query := "SELECT " + scheduledTransferColumns + " FROM scheduled_transfers WHERE id = $1 FOR UPDATE"
+	st, err := scanScheduledTransfer(tx.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrScheduledTransferNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if st.Status != models.ScheduleActive || !st.NextRunAt.Equal(scheduledFor) {
+		return nil, ErrScheduledTransferNotDue
+	}
+	return st, nil
+}
+
+func insertRun(ctx context.Context, tx *sql.Tx, id int64, scheduledFor time.Time, status, runErr string) error {
+	query := "INSERT INTO scheduled_transfer_runs (scheduled_transfer_id, scheduled_for, status, error) VALUES ($1, $2, $3, $4)"
+	_, err := tx.ExecContext(ctx, query, id, scheduledFor, status, runErr)
+	return err
+}
+
+func scanScheduledTransfer(row rowScanner) (*models.ScheduledTransfer, error) {
+	var st models.ScheduledTransfer
+	err := row.Scan(&st.ID, &st.SenderAccountNumber, &st.ReceiverAccountNumber, &st.Amount, &st.Kind, &st.IntervalSeconds, &st.Cron,
+		&st.EndAt, &st.NextRunAt, &st.RetryAt, &st.Status, &st.Attempts, &st.Runs, &st.LastRunAt, &st.LastError, &st.CreatedAt, &st.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
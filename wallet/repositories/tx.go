@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+	"wallet/models"
+
+	"github.com/lib/pq"
+)
+
+// Transactions aborted by a deadlock or a serialization failure are
+// retried.
+const (
+	maxTxAttempts  = 3
+	txRetryBackoff = 50 * time.Millisecond
+)
+
+// inTx runs fn in a transaction and commits it if fn succeeds. The whole
+// transaction is retried when the database aborts it because of a deadlock
+// or a serialization failure with a concurrent one.
+func inTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, db, fn)
+		if err == nil || attempt == maxTxAttempts || !isRetryable(err) {
+			return translateError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * txRetryBackoff):
+		}
+	}
+}
+
+func runTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func isRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	switch pqErr.Code {
+	case "40001", "40P01": // serialization_failure, deadlock_detected
+		return true
+	}
+	return false
+}
+
+// translateError turns a violation of the balance CHECK constraints into
+// ErrInsufficientFunds. The constraints are only a backstop, the balance is
+// normally checked before it is changed. Other CHECK violations are bugs
+// and pass through as they are.
+func translateError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23514" && balanceConstraints[pqErr.Constraint] {
+		return ErrInsufficientFunds
+	}
+	return err
+}
+
+var balanceConstraints = map[string]bool{
+	"accounts_balance_non_negative": true,
+	"accounts_held_balance_covered": true,
+}
+
+// lockAccounts locks the accounts in ascending order of their numbers, so
+// two transfers in opposite directions cannot deadlock.
+func lockAccounts(ctx context.Context, tx *sql.Tx, accountNumbers ...string) error {
+	return lockInOrder(ctx, tx, lockActiveAccount, accountNumbers)
+}
+
+// lockAccountsAnyStatus is lockAccounts for settling funds that were
+// committed earlier. The accounts may have been frozen since.
+func lockAccountsAnyStatus(ctx context.Context, tx *sql.Tx, accountNumbers ...string) error {
+	return lockInOrder(ctx, tx, lockAccount, accountNumbers)
+}
+
+func lockInOrder(ctx context.Context, tx *sql.Tx, lock func(context.Context, *sql.Tx, string) (*models.Account, error), accountNumbers []string) error {
+	sorted := append([]string(nil), accountNumbers...)
+	sort.Strings(sorted)
+
+	for _, accountNumber := range sorted {
+		if _, err := lock(ctx, tx, accountNumber); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestInTxRetriesConcurrencyFailures(t *testing.T) {
+	for _, code := range []pq.ErrorCode{"40001", "40P01"} {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE accounts").WillReturnError(&pq.Error{Code: code})
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE accounts").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err = inTx(context.Background(), db, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(context.Background(), "UPDATE accounts SET balance = balance")
+			return err
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", code, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("%s: %v", code, err)
+		}
+		db.Close()
+	}
+}
@@ -0,0 +1,199 @@
+package repositories
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"wallet/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrVoucherNotFound        = errors.New("voucher not found")
+	ErrVoucherNotRedeemable   = errors.New("voucher is used up, expired or disabled")
+	ErrVoucherAlreadyRedeemed = errors.New("voucher was already redeemed by this user")
+)
+
+// A voucher reads as exhausted or expired without anything having to sweep
+// the table, like payment requests.
+const voucherColumns = "id, code_suffix, currency, amount, max_redemptions, redemptions, " +
+	"CASE WHEN status <> 'active' THEN status WHEN redemptions >= max_redemptions THEN 'exhausted' " +
+	"WHEN expires_at <= NOW() THEN 'expired' ELSE status END, expires_at, created_at"
+
+const voucherRedemptionColumns = "id, voucher_id, user_id, account_number, currency, amount, created_at"
+
+type VoucherRepository struct {
+	DB *sql.DB
+}
+
+func NewVoucherRepository(db *sql.DB) *VoucherRepository {
+	return &VoucherRepository{DB: db}
+}
+
+// CreateVouchers mints count vouchers like v, each under its own new code.
+// Only the returned vouchers carry the codes.
+func (repo *VoucherRepository) CreateVouchers(ctx context.Context, v *models.Voucher, count int) ([]*models.Voucher, error) {
+	query := `
+			INSERT INTO vouchers (code_hash, code_suffix, currency, amount, max_redemptions, status, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (code_hash) DO NOTHING
+			RETURNING ` + voucherColumns
+
+	var vouchers []*models.Voucher
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		vouchers = make([]*models.Voucher, 0, count)
+		for len(vouchers) < count {
+			code, err := newVoucherCode()
+			if err != nil {
+				return err
+			}
+
+			created, err := scanVoucher(tx.QueryRowContext(ctx, query, hashVoucherCode(code), code[len(code)-4:], v.Currency,
+				v.Amount, v.MaxRedemptions, models.VoucherActive, v.ExpiresAt))
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			created.Code = code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16]
+			vouchers = append(vouchers, created)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vouchers, nil
+}
+
+func (repo *VoucherRepository) GetVoucher(ctx context.Context, id int64) (*models.Voucher, error) {
+	v, err := scanVoucher(repo.DB.QueryRowContext(ctx, "SELECT "+voucherColumns+" FROM vouchers WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrVoucherNotFound
+	}
+	return v, err
+}
+
+// GetVoucherByCode looks the voucher up by its code without the dashes.
+func (repo *VoucherRepository) GetVoucherByCode(ctx context.Context, code string) (*models.Voucher, error) {
+	v, err := scanVoucher(repo.DB.QueryRowContext(ctx, "SELECT "+voucherColumns+" FROM vouchers WHERE code_hash = $1", hashVoucherCode(code)))
+	if err == sql.ErrNoRows {
+		return nil, ErrVoucherNotFound
+	}
+	return v, err
+}
+
+// DisableVoucher stops further redemptions, the ones made stay.
+func (repo *VoucherRepository) DisableVoucher(ctx context.Context, id int64) (*models.Voucher, error) {
+	query := "UPDATE vouchers SET status = $2 WHERE id = $1 RETURNING " + voucherColumns
+	v, err := scanVoucher(repo.DB.QueryRowContext(ctx, query, id, models.VoucherDisabled))
+	if err == sql.ErrNoRows {
+		return nil, ErrVoucherNotFound
+	}
+	return v, err
+}
+
+func (repo *VoucherRepository) ListRedemptions(ctx context.Context, voucherID int64) ([]*models.VoucherRedemption, error) {
+	query := "SELECT " + voucherRedemptionColumns + " FROM voucher_redemptions WHERE voucher_id = $1 ORDER BY id"
+	rows, err := repo.DB.QueryContext(ctx, query, voucherID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	redemptions := []*models.VoucherRedemption{}
+	for rows.Next() {
+		redemption, err := scanVoucherRedemption(rows)
+		if err != nil {
+			return nil, err
+		}
+		redemptions = append(redemptions, redemption)
+	}
+	return redemptions, rows.Err()
+}
+
+// Redeem pays the voucher out of the house account into the user's account.
+// The voucher row stays locked until the payment is committed, so
+// concurrent redemptions of the same code queue up and see each other's
+// count; a user redeeming twice hits the unique index on the redemptions.
+func (repo *VoucherRepository) Redeem(ctx context.Context, code string, userID int, accountNumber, houseAccountNumber string) (*models.VoucherRedemption, error) {
+	var redemption *models.VoucherRedemption
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		query := "SELECT " + voucherColumns + " FROM vouchers WHERE code_hash = $1 FOR UPDATE"
+		v, err := scanVoucher(tx.QueryRowContext(ctx, query, hashVoucherCode(code)))
+		if err == sql.ErrNoRows {
+			return ErrVoucherNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if v.Status != models.VoucherActive {
+			return ErrVoucherNotRedeemable
+		}
+
+		query = `
+				INSERT INTO voucher_redemptions (voucher_id, user_id, account_number, currency, amount)
+				VALUES ($1, $2, $3, $4, $5)
+				RETURNING ` + voucherRedemptionColumns
+		redemption, err = scanVoucherRedemption(tx.QueryRowContext(ctx, query, v.ID, userID, accountNumber, v.Currency, v.Amount))
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrVoucherAlreadyRedeemed
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := transfer(ctx, tx, houseAccountNumber, accountNumber, v.Amount); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE vouchers SET redemptions = redemptions + 1 WHERE id = $1", v.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return redemption, nil
+}
+
+// hashVoucherCode returns what is stored of a code, without the dashes.
+func hashVoucherCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// newVoucherCode returns 16 random base32 characters, 80 bits. They are
+// handed out in groups of four, like 7KQ2-M4XJ-WTZA-3HPD.
+func newVoucherCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.EncodeToString(b), nil
+}
+
+func scanVoucher(row rowScanner) (*models.Voucher, error) {
+	var v models.Voucher
+	err := row.Scan(&v.ID, &v.CodeSuffix, &v.Currency, &v.Amount, &v.MaxRedemptions, &v.Redemptions, &v.Status, &v.ExpiresAt, &v.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func scanVoucherRedemption(row rowScanner) (*models.VoucherRedemption, error) {
+	var redemption models.VoucherRedemption
+	err := row.Scan(&redemption.ID, &redemption.VoucherID, &redemption.UserID, &redemption.AccountNumber,
+		&redemption.Currency, &redemption.Amount, &redemption.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &redemption, nil
+}
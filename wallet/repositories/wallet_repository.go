@@ -4,9 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"wallet/accountnumber"
 	"wallet/models"
 )
 
+var ErrUsernameTaken = errors.New("username belongs to another user")
+
 type WalletRepository struct {
 	DB *sql.DB
 }
@@ -38,6 +42,39 @@ func (repo *WalletRepository) GetWalletByUserID(ctx context.Context, userID int)
 	}, nil
 }
 
+// GetWalletByAuthUserID returns the wallet of the auth service user, nil if
+// the user has none yet.
+func (repo *WalletRepository) GetWalletByAuthUserID(ctx context.Context, authUserID string) (*models.Wallet, error) {
+	query := "SELECT w.user_id, w.accounts FROM wallets w JOIN users u ON u.id = w.user_id WHERE u.auth_user_id = $1"
+
+	var wallet models.Wallet
+	var accountsJSON []byte
+	err := repo.DB.QueryRowContext(ctx, query, authUserID).Scan(&wallet.UserID, &accountsJSON)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(accountsJSON, &wallet.Accounts); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// GetUserIDByAccount returns the owner of the account, or 0 if the account
+// is not part of any wallet.
+func (repo *WalletRepository) GetUserIDByAccount(ctx context.Context, accountNumber string) (int, error) {
+	query := "SELECT user_id FROM wallets WHERE accounts ? $1"
+
+	var userID int
+	err := repo.DB.QueryRowContext(ctx, query, accountNumber).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return userID, err
+}
+
 func (repo *WalletRepository) CreateWallet(ctx context.Context, userID int, usdAccount string) error {
 	accounts := []string{usdAccount}
 	accountsJson, err := json.Marshal(accounts)
@@ -71,35 +108,119 @@ func (repo *WalletRepository) AddAccountToWallet(ctx context.Context, userID int
 	return err
 }
 
-func (repo *WalletRepository) Deposit(ctx context.Context, accountNumber string, amount float64) error {
-	tx, err := repo.DB.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
+// ProvisionWallet gives the user of the auth service a wallet user and makes
+// sure the wallet has an account in each of the currencies. Running it again
+// for the same user changes nothing.
+func (repo *WalletRepository) ProvisionWallet(ctx context.Context, authUserID, username string, currencies []string) (*models.Wallet, error) {
+	var wallet *models.Wallet
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('provision:' || $1))", authUserID)
+		if err != nil {
+			return err
+		}
 
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+		userID, err := provisionUser(ctx, tx, authUserID, username)
+		if err != nil {
+			return err
 		}
-	}()
 
-	query := `
-			UPDATE accounts
-			SET balance = balance + $1
-			WHERE account_number = $2
-	`
+		var accountsJSON []byte
+		err = tx.QueryRowContext(ctx, "SELECT accounts FROM wallets WHERE user_id = $1 FOR UPDATE", userID).Scan(&accountsJSON)
+		exists := err == nil
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		var accounts []string
+		if exists {
+			if err := json.Unmarshal(accountsJSON, &accounts); err != nil {
+				return err
+			}
+		}
+		held := make(map[string]bool, len(accounts))
+		for _, accountNumber := range accounts {
+			held[accountnumber.Currency(accountNumber)] = true
+		}
+		for _, currency := range currencies {
+			if held[currency] {
+				continue
+			}
+			account, err := createAccount(ctx, tx, currency)
+			if err != nil {
+				return err
+			}
+			accounts = append(accounts, account.AccountNumber)
+			held[currency] = true
+		}
+
+		if accountsJSON, err = json.Marshal(accounts); err != nil {
+			return err
+		}
+		query := "INSERT INTO wallets (user_id, accounts) VALUES ($1, $2)"
+		if exists {
+			query = "UPDATE wallets SET accounts = $2 WHERE user_id = $1"
+		}
+		if _, err := tx.ExecContext(ctx, query, userID, accountsJSON); err != nil {
+			return err
+		}
 
-	_, err = tx.ExecContext(ctx, query, amount, accountNumber)
+		wallet = &models.Wallet{UserID: userID, Accounts: accounts}
+		return nil
+	})
 	if err != nil {
-		tx.Rollback()
-		return err
+		return nil, err
 	}
+	return wallet, nil
+}
 
-	if err := tx.Commit(); err != nil {
-		return err
+// provisionUser returns the wallet user linked to the auth service user.
+// Users created before the auth service published events are linked by
+// username.
+func provisionUser(ctx context.Context, tx *sql.Tx, authUserID, username string) (int, error) {
+	var userID int
+	err := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE auth_user_id = $1", authUserID).Scan(&userID)
+	if err != sql.ErrNoRows {
+		return userID, err
 	}
 
-	return nil
+	query := `
+			INSERT INTO users (username, auth_user_id) VALUES ($1, $2)
+			ON CONFLICT (username) DO UPDATE SET auth_user_id = EXCLUDED.auth_user_id
+			WHERE users.auth_user_id IS NULL
+			RETURNING id`
+	err = tx.QueryRowContext(ctx, query, username, authUserID).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrUsernameTaken
+	}
+	return userID, err
+}
+
+func (repo *WalletRepository) Deposit(ctx context.Context, accountNumber string, amount float64) error {
+	return inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		_, err := changeBalance(ctx, tx, accountNumber, amount, models.MovementDeposit, "")
+		return err
+	})
+}
+
+// Withdraw takes amount and the fee, if any, off the account. The fee is
+// credited to the house account.
+func (repo *WalletRepository) Withdraw(ctx context.Context, accountNumber string, amount float64, fee *models.FeeItem, houseAccountNumber string) error {
+	return inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		if err := lockAccounts(ctx, tx, feeAccounts(fee, houseAccountNumber, accountNumber)...); err != nil {
+			return err
+		}
+
+		if _, err := changeBalance(ctx, tx, accountNumber, -amount, models.MovementWithdrawal, ""); err != nil {
+			return err
+		}
+		return chargeFee(ctx, tx, fee, houseAccountNumber)
+	})
+}
+
+func (repo *WalletRepository) Transfer(ctx context.Context, senderAccountNumber, receiverAccountNumber string, amount float64, fee *models.FeeItem, houseAccountNumber string) error {
+	return inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		return transferWithFee(ctx, tx, senderAccountNumber, receiverAccountNumber, amount, fee, houseAccountNumber)
+	})
 }
 
 func (repo *WalletRepository) UpdateWallet(ctx context.Context, wallet *models.Wallet) error {
@@ -137,3 +258,85 @@ func (repo *WalletRepository) UpdateWallet(ctx context.Context, wallet *models.W
 
 	return nil
 }
+
+func transfer(ctx context.Context, tx *sql.Tx, senderAccountNumber, receiverAccountNumber string, amount float64) error {
+	if err := lockAccounts(ctx, tx, senderAccountNumber, receiverAccountNumber); err != nil {
+		return err
+	}
+
+	_, err := changeBalance(ctx, tx, senderAccountNumber, -amount, models.MovementTransferOut, receiverAccountNumber)
+	if err != nil {
+		return err
+	}
+
+	_, err = changeBalance(ctx, tx, receiverAccountNumber, amount, models.MovementTransferIn, senderAccountNumber)
+	return err
+}
+
+// transferWithFee locks all accounts involved up front, the house account
+// takes part in most transfers and must not be locked out of order.
+func transferWithFee(ctx context.Context, tx *sql.Tx, senderAccountNumber, receiverAccountNumber string, amount float64, fee *models.FeeItem, houseAccountNumber string) error {
+	if err := lockAccounts(ctx, tx, feeAccounts(fee, houseAccountNumber, senderAccountNumber, receiverAccountNumber)...); err != nil {
+		return err
+	}
+
+	if err := transfer(ctx, tx, senderAccountNumber, receiverAccountNumber, amount); err != nil {
+		return err
+	}
+	return chargeFee(ctx, tx, fee, houseAccountNumber)
+}
+
+// feeAccounts adds the house account to the accounts to lock if a fee is
+// charged.
+func feeAccounts(fee *models.FeeItem, houseAccountNumber string, accountNumbers ...string) []string {
+	if fee == nil || fee.Amount == 0 {
+		return accountNumbers
+	}
+	return append(accountNumbers, houseAccountNumber)
+}
+
+// changeBalance applies a signed amount to the account and records the
+// movement. Debits can only spend the available balance, funds reserved by
+// holds stay untouched.
+func changeBalance(ctx context.Context, tx *sql.Tx, accountNumber string, amount float64, movementType, counterparty string) (*models.Movement, error) {
+	account, err := lockActiveAccount(ctx, tx, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	return applyBalanceChange(ctx, tx, account, amount, movementType, counterparty)
+}
+
+// settleBalance is changeBalance for funds committed while the account was
+// active, like a withdrawal that already left on chain. It does not look at
+// the account status.
+func settleBalance(ctx context.Context, tx *sql.Tx, accountNumber string, amount float64, movementType, counterparty string) (*models.Movement, error) {
+	account, err := lockAccount(ctx, tx, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	return applyBalanceChange(ctx, tx, account, amount, movementType, counterparty)
+}
+
+func applyBalanceChange(ctx context.Context, tx *sql.Tx, account *models.Account, amount float64, movementType, counterparty string) (*models.Movement, error) {
+	if amount < 0 && account.Available < -amount {
+		return nil, ErrInsufficientFunds
+	}
+
+	movement := &models.Movement{
+		AccountNumber: account.AccountNumber,
+		Type:          movementType,
+		Amount:        amount,
+		Counterparty:  counterparty,
+	}
+
+	query := "UPDATE accounts SET balance = balance + $1 WHERE account_number = $2 RETURNING balance"
+	err := tx.QueryRowContext(ctx, query, amount, account.AccountNumber).Scan(&movement.BalanceAfter)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recordMovement(ctx, tx, movement); err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
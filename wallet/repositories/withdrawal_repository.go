@@ -0,0 +1,230 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"wallet/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrWithdrawalNotFound          = errors.New("withdrawal not found")
+	ErrInvalidWithdrawalTransition = errors.New("withdrawal cannot change to this status")
+)
+
+const withdrawalColumns = "id, account_number, currency, address, amount, fee, hold_id, limit_charge_id, requested_by, requires_approval, " +
+	"approved_by, txid, raw_tx, confirmations, attempts, last_error, status, created_at, updated_at"
+
+type WithdrawalRepository struct {
+	DB *sql.DB
+}
+
+func NewWithdrawalRepository(db *sql.DB) *WithdrawalRepository {
+	return &WithdrawalRepository{DB: db}
+}
+
+// CreateWithdrawal reserves amount plus fee on the account and stores the
+// withdrawal in the same transaction.
+func (repo *WithdrawalRepository) CreateWithdrawal(ctx context.Context, w *models.Withdrawal) (*models.Withdrawal, error) {
+	var created *models.Withdrawal
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		hold, err := placeHold(ctx, tx, w.AccountNumber, w.Amount+w.Fee, models.HoldWithdrawal, "withdrawal")
+		if err != nil {
+			return err
+		}
+
+		query := `
+				INSERT INTO withdrawals (account_number, currency, address, amount, fee, hold_id, limit_charge_id, requested_by, requires_approval, status)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+				RETURNING ` + withdrawalColumns
+		created, err = scanWithdrawal(tx.QueryRowContext(ctx, query, w.AccountNumber, w.Currency, w.Address, w.Amount, w.Fee,
+			hold.ID, w.LimitChargeID, w.RequestedBy, w.RequiresApproval, w.Status))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (repo *WithdrawalRepository) GetWithdrawal(ctx context.Context, id int64) (*models.Withdrawal, error) {
+	query := "SELECT " + withdrawalColumns + " FROM withdrawals WHERE id = $1"
+	w, err := scanWithdrawal(repo.DB.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrWithdrawalNotFound
+	}
+	return w, err
+}
+
+func (repo *WithdrawalRepository) ListAccountWithdrawals(ctx context.Context, accountNumber string) ([]*models.Withdrawal, error) {
+	query := "SELECT " + withdrawalColumns + " FROM withdrawals WHERE account_number = $1 ORDER BY id DESC"
+	return repo.list(ctx, query, accountNumber)
+}
+
+// ListWithdrawals returns the oldest withdrawals with one of the statuses.
+func (repo *WithdrawalRepository) ListWithdrawals(ctx context.Context, statuses []string, limit int) ([]*models.Withdrawal, error) {
+	query := "SELECT " + withdrawalColumns + " FROM withdrawals WHERE status = ANY($1) ORDER BY id LIMIT $2"
+	return repo.list(ctx, query, pq.Array(statuses), limit)
+}
+
+func (repo *WithdrawalRepository) Approve(ctx context.Context, id int64, approver string) (*models.Withdrawal, error) {
+	return repo.update(ctx, id, []string{models.WithdrawalRequested},
+		"status = $2, approved_by = $3", models.WithdrawalApproved, approver)
+}
+
+func (repo *WithdrawalRepository) MarkSigned(ctx context.Context, id int64, txid string, rawTx []byte) (*models.Withdrawal, error) {
+	return repo.update(ctx, id, []string{models.WithdrawalApproved},
+		"status = $2, txid = $3, raw_tx = $4, attempts = 0, last_error = ''", models.WithdrawalSigned, txid, rawTx)
+}
+
+func (repo *WithdrawalRepository) MarkBroadcast(ctx context.Context, id int64) (*models.Withdrawal, error) {
+	return repo.update(ctx, id, []string{models.WithdrawalSigned},
+		"status = $2, attempts = 0, last_error = ''", models.WithdrawalBroadcast)
+}
+
+func (repo *WithdrawalRepository) UpdateConfirmations(ctx context.Context, id int64, confirmations int64) (*models.Withdrawal, error) {
+	return repo.update(ctx, id, []string{models.WithdrawalBroadcast}, "confirmations = $2", confirmations)
+}
+
+// RecordAttempt counts a failed step of the processor without changing the
+// status.
+func (repo *WithdrawalRepository) RecordAttempt(ctx context.Context, id int64, stepErr error) (*models.Withdrawal, error) {
+	return repo.update(ctx, id, []string{models.WithdrawalApproved, models.WithdrawalSigned, models.WithdrawalBroadcast},
+		"attempts = attempts + 1, last_error = $2", stepErr.Error())
+}
+
+// Confirm releases the reserve and takes amount and fee off the account for
+// good. The fee goes to the house account. The coins are already on chain,
+// so this settles even if the account has been frozen since.
+func (repo *WithdrawalRepository) Confirm(ctx context.Context, id int64, confirmations int64, fee *models.FeeItem, houseAccountNumber string) (*models.Withdrawal, error) {
+	var confirmed *models.Withdrawal
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		w, err := lockWithdrawal(ctx, tx, id, models.WithdrawalBroadcast)
+		if err != nil {
+			return err
+		}
+
+		if _, err := lockActiveHold(ctx, tx, w.HoldID); err != nil {
+			return err
+		}
+		if err := lockAccountsAnyStatus(ctx, tx, feeAccounts(fee, houseAccountNumber, w.AccountNumber)...); err != nil {
+			return err
+		}
+		if _, err := releaseHold(ctx, tx, w.HoldID); err != nil {
+			return err
+		}
+		if _, err := settleBalance(ctx, tx, w.AccountNumber, -w.Amount, models.MovementWithdrawal, w.Address); err != nil {
+			return err
+		}
+		if fee != nil && fee.Amount != 0 {
+			if _, err := settleBalance(ctx, tx, fee.AccountNumber, -fee.Amount, models.MovementFee, houseAccountNumber); err != nil {
+				return err
+			}
+			if _, err := changeBalance(ctx, tx, houseAccountNumber, fee.Amount, models.MovementFee, fee.AccountNumber); err != nil {
+				return err
+			}
+		}
+
+		confirmed, err = updateWithdrawal(ctx, tx, id, "status = $2, confirmations = $3", models.WithdrawalConfirmed, confirmations)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return confirmed, nil
+}
+
+// Fail gives the reserved funds back and ends the withdrawal.
+func (repo *WithdrawalRepository) Fail(ctx context.Context, id int64, reason string, from ...string) (*models.Withdrawal, error) {
+	var failed *models.Withdrawal
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		w, err := lockWithdrawal(ctx, tx, id, from...)
+		if err != nil {
+			return err
+		}
+
+		if _, err := releaseHold(ctx, tx, w.HoldID); err != nil {
+			return err
+		}
+
+		failed, err = updateWithdrawal(ctx, tx, id, "status = $2, last_error = $3", models.WithdrawalFailed, reason)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return failed, nil
+}
+
+// update sets the columns of a withdrawal in one of the from states. The
+// assignments refer to the values as $2 onwards.
+func (repo *WithdrawalRepository) update(ctx context.Context, id int64, from []string, set string, values ...interface{}) (*models.Withdrawal, error) {
+	var updated *models.Withdrawal
+	err := inTx(ctx, repo.DB, func(tx *sql.Tx) error {
+		if _, err := lockWithdrawal(ctx, tx, id, from...); err != nil {
+			return err
+		}
+
+		var err error
+		updated, err = updateWithdrawal(ctx, tx, id, set, values...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (repo *WithdrawalRepository) list(ctx context.Context, query string, args ...interface{}) ([]*models.Withdrawal, error) {
+	rows, err := repo.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	withdrawals := []*models.Withdrawal{}
+	for rows.Next() {
+		w, err := scanWithdrawal(rows)
+		if err != nil {
+			return nil, err
+		}
+		withdrawals = append(withdrawals, w)
+	}
+	return withdrawals, rows.Err()
+}
+
+func lockWithdrawal(ctx context.Context, tx *sql.Tx, id int64, from ...string) (*models.Withdrawal, error) {
+	query := "SELECT " + withdrawalColumns + " FROM withdrawals WHERE id = $1 FOR UPDATE"
+	w, err := scanWithdrawal(tx.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrWithdrawalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, status := range from {
+		if w.Status == status {
+			return w, nil
+		}
+	}
+	return nil, ErrInvalidWithdrawalTransition
+}
+
+func updateWithdrawal(ctx context.Context, tx *sql.Tx, id int64, set string, values ...interface{}) (*models.Withdrawal, error) {
+	query := "UPDATE withdrawals SET " + set + ", updated_at = NOW() WHERE id = $1 RETURNING " + withdrawalColumns
+	return scanWithdrawal(tx.QueryRowContext(ctx, query, append([]interface{}{id}, values...)...))
+}
+
+func scanWithdrawal(row rowScanner) (*models.Withdrawal, error) {
+	var w models.Withdrawal
+	err := row.Scan(&w.ID, &w.AccountNumber, &w.Currency, &w.Address, &w.Amount, &w.Fee, &w.HoldID, &w.LimitChargeID,
+		&w.RequestedBy, &w.RequiresApproval, &w.ApprovedBy, &w.TxID, &w.RawTx, &w.Confirmations, &w.Attempts,
+		&w.LastError, &w.Status, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
@@ -0,0 +1,172 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+	"wallet/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const (
+	testAccount      = "BTC-0001"
+	testHouseAccount = "BTC-HOUSE"
+)
+
+func newMock(t *testing.T) (*WithdrawalRepository, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := newMockDB(t)
+	return NewWithdrawalRepository(db), mock
+}
+
+// newMockDB fails the test if not every expected statement ran.
+func newMockDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	return db, mock
+}
+
+func query(sql string) string {
+	return regexp.QuoteMeta(sql)
+}
+
+func withdrawalRow(status string) *sqlmock.Rows {
+	columns := []string{"id", "account_number", "currency", "address", "amount", "fee", "hold_id", "limit_charge_id", "requested_by",
+		"requires_approval", "approved_by", "txid", "raw_tx", "confirmations", "attempts", "last_error", "status", "created_at", "updated_at"}
+	return sqlmock.NewRows(columns).AddRow(1, testAccount, "BTC", "bc1qexternal", 0.5, 0.0001, 7, nil, "user:1",
+		false, "", "txid", []byte("raw"), 0, 0, "", status, time.Now(), time.Now())
+}
+
+func accountRow(accountNumber, status string, balance, held float64) *sqlmock.Rows {
+	columns := []string{"id", "account_number", "currency", "balance", "held_balance", "active", "status", "status_reason", "status_changed_at"}
+	return sqlmock.NewRows(columns).AddRow(1, accountNumber, "BTC", balance, held, status == models.AccountActive, status, "", time.Now())
+}
+
+func holdRow(kind, status string) *sqlmock.Rows {
+	columns := []string{"id", "account_number", "amount", "kind", "reference", "status", "created_at", "updated_at"}
+	return sqlmock.NewRows(columns).AddRow(7, testAccount, 0.5001, kind, "withdrawal", status, time.Now(), time.Now())
+}
+
+func expectLockAccount(mock sqlmock.Sqlmock, accountNumber, status string, balance, held float64) {
+	mock.ExpectQuery(query("FROM accounts WHERE account_number = $1 FOR UPDATE")).WithArgs(accountNumber).
+		WillReturnRows(accountRow(accountNumber, status, balance, held))
+}
+
+func expectBalanceChange(mock sqlmock.Sqlmock, accountNumber string, amount, balanceAfter float64, movementType string) {
+	mock.ExpectQuery(query("UPDATE accounts SET balance = balance + $1")).WithArgs(amount, accountNumber).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(balanceAfter))
+	mock.ExpectExec(query("INSERT INTO account_movements")).WithArgs(accountNumber, movementType, amount, balanceAfter, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func TestWithdrawalConfirmSettlesFrozenAccount(t *testing.T) {
+	repo, mock := newMock(t)
+	const frozen = models.AccountFrozen
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(query("FROM withdrawals WHERE id = $1 FOR UPDATE")).WithArgs(1).WillReturnRows(withdrawalRow(models.WithdrawalBroadcast))
+	mock.ExpectQuery(query("FROM account_holds WHERE id = $1 FOR UPDATE")).WithArgs(7).WillReturnRows(holdRow(models.HoldWithdrawal, models.HoldActive))
+	expectLockAccount(mock, testAccount, frozen, 1, 0.5001)
+	expectLockAccount(mock, testHouseAccount, models.AccountActive, 0, 0)
+
+	mock.ExpectQuery(query("FROM account_holds WHERE id = $1 FOR UPDATE")).WithArgs(7).WillReturnRows(holdRow(models.HoldWithdrawal, models.HoldActive))
+	mock.ExpectExec(query("UPDATE accounts SET held_balance = held_balance - $1")).WithArgs(0.5001, testAccount).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(query("UPDATE account_holds SET status = $1")).WithArgs(models.HoldReleased, 7).WillReturnRows(holdRow(models.HoldWithdrawal, models.HoldReleased))
+
+	expectLockAccount(mock, testAccount, frozen, 1, 0)
+	expectBalanceChange(mock, testAccount, -0.5, 0.5, models.MovementWithdrawal)
+	expectLockAccount(mock, testAccount, frozen, 0.5, 0)
+	expectBalanceChange(mock, testAccount, -0.0001, 0.4999, models.MovementFee)
+	expectLockAccount(mock, testHouseAccount, models.AccountActive, 0, 0)
+	expectBalanceChange(mock, testHouseAccount, 0.0001, 0.0001, models.MovementFee)
+
+	mock.ExpectQuery(query("UPDATE withdrawals SET status = $2, confirmations = $3")).WithArgs(1, models.WithdrawalConfirmed, 6).
+		WillReturnRows(withdrawalRow(models.WithdrawalConfirmed))
+	mock.ExpectCommit()
+
+	fee := &models.FeeItem{AccountNumber: testAccount, Amount: 0.0001}
+	w, err := repo.Confirm(context.Background(), 1, 6, fee, testHouseAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Status != models.WithdrawalConfirmed {
+		t.Fatalf("status = %s, want %s", w.Status, models.WithdrawalConfirmed)
+	}
+}
+
+func TestWithdrawalMarkSignedFromApproved(t *testing.T) {
+	repo, mock := newMock(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(query("FROM withdrawals WHERE id = $1 FOR UPDATE")).WithArgs(1).WillReturnRows(withdrawalRow(models.WithdrawalApproved))
+	mock.ExpectQuery(query("UPDATE withdrawals SET status = $2, txid = $3, raw_tx = $4, attempts = 0")).
+		WithArgs(1, models.WithdrawalSigned, "txid", []byte("raw")).WillReturnRows(withdrawalRow(models.WithdrawalSigned))
+	mock.ExpectCommit()
+
+	w, err := repo.MarkSigned(context.Background(), 1, "txid", []byte("raw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Status != models.WithdrawalSigned {
+		t.Fatalf("status = %s, want %s", w.Status, models.WithdrawalSigned)
+	}
+}
+
+func TestWithdrawalRefusesSkippedStep(t *testing.T) {
+	repo, mock := newMock(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(query("FROM withdrawals WHERE id = $1 FOR UPDATE")).WithArgs(1).WillReturnRows(withdrawalRow(models.WithdrawalApproved))
+	mock.ExpectRollback()
+
+	if _, err := repo.MarkBroadcast(context.Background(), 1); !errors.Is(err, ErrInvalidWithdrawalTransition) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidWithdrawalTransition)
+	}
+}
+
+func TestWithdrawalFailReleasesHold(t *testing.T) {
+	repo, mock := newMock(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(query("FROM withdrawals WHERE id = $1 FOR UPDATE")).WithArgs(1).WillReturnRows(withdrawalRow(models.WithdrawalApproved))
+	mock.ExpectQuery(query("FROM account_holds WHERE id = $1 FOR UPDATE")).WithArgs(7).WillReturnRows(holdRow(models.HoldWithdrawal, models.HoldActive))
+	mock.ExpectExec(query("UPDATE accounts SET held_balance = held_balance - $1")).WithArgs(0.5001, testAccount).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(query("UPDATE account_holds SET status = $1")).WithArgs(models.HoldReleased, 7).WillReturnRows(holdRow(models.HoldWithdrawal, models.HoldReleased))
+	mock.ExpectQuery(query("UPDATE withdrawals SET status = $2, last_error = $3")).WithArgs(1, models.WithdrawalFailed, "unsignable").
+		WillReturnRows(withdrawalRow(models.WithdrawalFailed))
+	mock.ExpectCommit()
+
+	w, err := repo.Fail(context.Background(), 1, "unsignable", models.WithdrawalApproved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Status != models.WithdrawalFailed {
+		t.Fatalf("status = %s, want %s", w.Status, models.WithdrawalFailed)
+	}
+}
+
+func TestWithdrawalFailKeepsHoldOfSentWithdrawal(t *testing.T) {
+	repo, mock := newMock(t)
+
+	// Signed meanwhile, the coins may leave, so the hold stays.
+	mock.ExpectBegin()
+	mock.ExpectQuery(query("FROM withdrawals WHERE id = $1 FOR UPDATE")).WithArgs(1).WillReturnRows(withdrawalRow(models.WithdrawalSigned))
+	mock.ExpectRollback()
+
+	if _, err := repo.Fail(context.Background(), 1, "unsignable", models.WithdrawalApproved); !errors.Is(err, ErrInvalidWithdrawalTransition) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidWithdrawalTransition)
+	}
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	netmail "net/mail"
+	"strings"
+	"time"
+	"wallet/mail"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+// Bounds of the cooling-off period of new addresses.
+const (
+	MinAddressCoolingOff = 24 * time.Hour
+	MaxAddressCoolingOff = 48 * time.Hour
+)
+
+var (
+	ErrInvalidCoolingOff     = errors.New("address cooling-off period must be between 24 and 48 hours")
+	ErrAddressCoolingOff     = errors.New("address is still in its cooling-off period")
+	ErrAddressNotWhitelisted = errors.New("withdrawals are limited to the addresses in the address book")
+	ErrInvalidEmail          = errors.New("invalid email address")
+)
+
+type AddressBookService struct {
+	addressBookRepo       *repositories.AddressBookRepository
+	walletRepo            *repositories.WalletRepository
+	currencyService       *CurrencyService
+	depositAddressService *DepositAddressService
+	mailer                mail.Sender
+	coolingOff            time.Duration
+}
+
+// NewAddressBookService takes the time a new address has to wait before it
+// can be withdrawn to.
+func NewAddressBookService(addressBookRepo *repositories.AddressBookRepository, walletRepo *repositories.WalletRepository, currencyService *CurrencyService, depositAddressService *DepositAddressService, mailer mail.Sender, coolingOff time.Duration) (*AddressBookService, error) {
+	if coolingOff < MinAddressCoolingOff || coolingOff > MaxAddressCoolingOff {
+		return nil, ErrInvalidCoolingOff
+	}
+	return &AddressBookService{
+		addressBookRepo:       addressBookRepo,
+		walletRepo:            walletRepo,
+		currencyService:       currencyService,
+		depositAddressService: depositAddressService,
+		mailer:                mailer,
+		coolingOff:            coolingOff,
+	}, nil
+}
+
+func (service *AddressBookService) ListAddresses(ctx context.Context, userID int) ([]*models.AddressBookEntry, error) {
+	return service.addressBookRepo.ListEntries(ctx, userID)
+}
+
+// AddAddress checks the address against the format of its chain and stores
+// it. It becomes usable after the cooling-off period.
+func (service *AddressBookService) AddAddress(ctx context.Context, userID int, currencyCode, address, label string) (*models.AddressBookEntry, error) {
+	wallet, err := service.walletRepo.GetWalletByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if wallet == nil {
+		return nil, ErrWalletNotFound
+	}
+
+	currency, err := service.currencyService.RequireCurrency(ctx, currencyCode)
+	if err != nil {
+		return nil, err
+	}
+	address, supported, err := service.depositAddressService.normalizeAddress(currency.Code, address)
+	if !supported || currency.Kind != models.CurrencyCrypto {
+		return nil, ErrWithdrawalUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := service.addressBookRepo.CreateEntry(ctx, &models.AddressBookEntry{
+		UserID:   userID,
+		Currency: currency.Code,
+		Address:  address,
+		Label:    strings.TrimSpace(label),
+		UsableAt: time.Now().UTC().Add(service.coolingOff),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	service.notify(ctx, userID, "New withdrawal address added",
+		fmt.Sprintf("The %s address %s (%s) was added to your address book. It can be used from %s.\n\n"+
+			"If this was not you, remove the address and contact support.",
+			entry.Currency, entry.Address, entry.Label, entry.UsableAt.Format(time.RFC1123)))
+	return entry, nil
+}
+
+func (service *AddressBookService) UpdateLabel(ctx context.Context, userID int, id int64, label string) (*models.AddressBookEntry, error) {
+	entry, err := service.addressBookRepo.UpdateLabel(ctx, userID, id, strings.TrimSpace(label))
+	if err != nil {
+		return nil, err
+	}
+
+	service.notify(ctx, userID, "Withdrawal address renamed",
+		fmt.Sprintf("The %s address %s in your address book is now labelled %q.", entry.Currency, entry.Address, entry.Label))
+	return entry, nil
+}
+
+func (service *AddressBookService) DeleteAddress(ctx context.Context, userID int, id int64) (*models.AddressBookEntry, error) {
+	entry, err := service.addressBookRepo.DeleteEntry(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	service.notify(ctx, userID, "Withdrawal address removed",
+		fmt.Sprintf("The %s address %s (%s) was removed from your address book.", entry.Currency, entry.Address, entry.Label))
+	return entry, nil
+}
+
+func (service *AddressBookService) GetSettings(ctx context.Context, userID int) (*models.AddressBookSettings, error) {
+	return service.addressBookRepo.GetSettings(ctx, userID)
+}
+
+// UpdateSettings changes the fields given. Whitelist only mode starts right
+// away but stays in force for the cooling-off period after it is turned off.
+// A new email is told about the change as well as the old one.
+func (service *AddressBookService) UpdateSettings(ctx context.Context, userID int, whitelistOnly *bool, email *string) (*models.AddressBookSettings, error) {
+	settings, err := service.addressBookRepo.GetSettings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	previousEmail := settings.Email
+
+	var changes []string
+	if whitelistOnly != nil && *whitelistOnly != settings.WhitelistOnly {
+		settings.WhitelistOnly = *whitelistOnly
+		settings.WhitelistUntil = nil
+		if *whitelistOnly {
+			changes = append(changes, "Withdrawals are now limited to the addresses in your address book.")
+		} else {
+			until := time.Now().UTC().Add(service.coolingOff)
+			settings.WhitelistUntil = &until
+			changes = append(changes, fmt.Sprintf("Withdrawals to addresses outside your address book are allowed from %s.", until.Format(time.RFC1123)))
+		}
+	}
+	if email != nil && *email != settings.Email {
+		if *email != "" {
+			parsed, err := netmail.ParseAddress(*email)
+			if err != nil || parsed.Address != *email {
+				return nil, ErrInvalidEmail
+			}
+		}
+		settings.Email = *email
+		changes = append(changes, fmt.Sprintf("Address book notifications now go to %q.", settings.Email))
+	}
+	if len(changes) == 0 {
+		return settings, nil
+	}
+
+	if err := service.addressBookRepo.SaveSettings(ctx, settings); err != nil {
+		return nil, err
+	}
+
+	body := strings.Join(changes, "\n") + "\n\nIf this was not you, contact support."
+	service.send(ctx, previousEmail, "Address book settings changed", body)
+	if settings.Email != previousEmail {
+		service.send(ctx, settings.Email, "Address book settings changed", body)
+	}
+	return settings, nil
+}
+
+// checkWithdrawal tells whether the owner of the account may withdraw to the
+// address. Accounts outside of any wallet have no address book.
+func (service *AddressBookService) checkWithdrawal(ctx context.Context, accountNumber, currency, address string) error {
+	userID, err := service.walletRepo.GetUserIDByAccount(ctx, accountNumber)
+	if err != nil || userID == 0 {
+		return err
+	}
+
+	entry, err := service.addressBookRepo.FindEntry(ctx, userID, currency, address)
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		if !entry.Usable {
+			return ErrAddressCoolingOff
+		}
+		return nil
+	}
+
+	settings, err := service.addressBookRepo.GetSettings(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if settings.Whitelisted(time.Now()) {
+		return ErrAddressNotWhitelisted
+	}
+	return nil
+}
+
+func (service *AddressBookService) notify(ctx context.Context, userID int, subject, body string) {
+	settings, err := service.addressBookRepo.GetSettings(ctx, userID)
+	if err != nil {
+		log.Printf("address book: notifying user %d: %v", userID, err)
+		return
+	}
+	service.send(ctx, settings.Email, subject, body)
+}
+
+// send is best effort, the change it reports is already made. Users who did
+// not give an email are not notified.
+func (service *AddressBookService) send(ctx context.Context, to, subject, body string) {
+	if to == "" {
+		return
+	}
+	if err := service.mailer.Send(ctx, mail.Message{To: to, Subject: subject, Body: body}); err != nil {
+		log.Printf("address book: mail to %s: %v", to, err)
+	}
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+const (
+	// Days are snapshotted a little after midnight, so transfers that
+	// started before midnight have committed.
+	snapshotDelay = 10 * time.Minute
+	// Missed days are caught up after downtime, but not further back.
+	maxSnapshotCatchUp = 31
+	maxSnapshotRange   = 366
+)
+
+var (
+	ErrSnapshotRangeTooLong = errors.New("snapshot range is limited to 366 days")
+	ErrDayNotOver           = errors.New("the day has not ended yet")
+)
+
+// BalanceSnapshotService keeps the end of day balance of every account.
+type BalanceSnapshotService struct {
+	balanceSnapshotRepo *repositories.BalanceSnapshotRepository
+	accountRepo         *repositories.AccountRepository
+	currencyService     *CurrencyService
+}
+
+func NewBalanceSnapshotService(balanceSnapshotRepo *repositories.BalanceSnapshotRepository, accountRepo *repositories.AccountRepository, currencyService *CurrencyService) *BalanceSnapshotService {
+	return &BalanceSnapshotService{
+		balanceSnapshotRepo: balanceSnapshotRepo,
+		accountRepo:         accountRepo,
+		currencyService:     currencyService,
+	}
+}
+
+// Run snapshots the days that ended since the last run, checking every
+// interval until ctx is done.
+func (service *BalanceSnapshotService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := service.SnapshotDue(ctx, time.Now()); err != nil {
+			log.Printf("balance snapshots: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SnapshotDue snapshots every day after the last snapshotted one that has
+// ended by now.
+func (service *BalanceSnapshotService) SnapshotDue(ctx context.Context, now time.Time) error {
+	last := startOfDay(now.Add(-snapshotDelay)).AddDate(0, 0, -1)
+	first := last.AddDate(0, 0, 1-maxSnapshotCatchUp)
+
+	latest, err := service.balanceSnapshotRepo.LatestDay(ctx)
+	if err != nil {
+		return err
+	}
+	if latest.IsZero() {
+		first = last
+	} else if next := latest.AddDate(0, 0, 1); next.After(first) {
+		first = next
+	}
+
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		if _, err := service.SnapshotDay(ctx, day); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SnapshotDay snapshots the balances at the end of the day and returns how
+// many accounts got a new snapshot.
+func (service *BalanceSnapshotService) SnapshotDay(ctx context.Context, day time.Time) (int64, error) {
+	day = startOfDay(day)
+	if day.AddDate(0, 0, 1).After(time.Now()) {
+		return 0, ErrDayNotOver
+	}
+	count, err := service.balanceSnapshotRepo.SnapshotDay(ctx, day)
+	if err != nil {
+		return 0, err
+	}
+	log.Printf("balance snapshots: %s: %d accounts", day.Format("2006-01-02"), count)
+	return count, nil
+}
+
+// ListSnapshots returns the end of day balances of the account for the
+// days from through to.
+func (service *BalanceSnapshotService) ListSnapshots(ctx context.Context, accountNumber string, from, to time.Time) (*models.BalanceSnapshotSeries, error) {
+	from, to = startOfDay(from), startOfDay(to)
+	if to.Before(from) {
+		return nil, ErrInvalidRange
+	}
+	if to.Sub(from) >= maxSnapshotRange*24*time.Hour {
+		return nil, ErrSnapshotRangeTooLong
+	}
+
+	account, err := service.accountRepo.GetAccountByNumber(ctx, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := service.balanceSnapshotRepo.ListSnapshots(ctx, accountNumber, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &models.BalanceSnapshotSeries{AccountNumber: accountNumber, Currency: account.Currency, Snapshots: snapshots}, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+var (
+	ErrCurrencyDisabled       = errors.New("currency is disabled")
+	ErrInvalidCurrency        = errors.New("currency code must be 2 to 10 uppercase letters or digits")
+	ErrInvalidCurrencyKind    = errors.New("currency kind must be fiat or crypto")
+	ErrInvalidDecimals        = errors.New("currency decimals must be between 0 and 18")
+	ErrInvalidMinAmount       = errors.New("currency min_amount must not be negative")
+	ErrInvalidConfirmations   = errors.New("currency confirmations must be between 0 and 1000")
+	ErrAmountBelowMinimum     = errors.New("amount is below the minimum for the currency")
+	ErrCurrencyMismatch       = errors.New("accounts are in different currencies")
+	ErrCryptocurrencyExpected = errors.New("currency is not a cryptocurrency")
+)
+
+var currencyCodeRegex = regexp.MustCompile(`^[A-Z0-9]{2,10}$`)
+
+type CurrencyService struct {
+	currencyRepo *repositories.CurrencyRepository
+}
+
+func NewCurrencyService(currencyRepo *repositories.CurrencyRepository) *CurrencyService {
+	return &CurrencyService{currencyRepo: currencyRepo}
+}
+
+func (service *CurrencyService) ListCurrencies(ctx context.Context, includeDisabled bool) ([]*models.Currency, error) {
+	return service.currencyRepo.ListCurrencies(ctx, includeDisabled)
+}
+
+func (service *CurrencyService) GetCurrency(ctx context.Context, code string) (*models.Currency, error) {
+	return service.currencyRepo.GetCurrency(ctx, strings.ToUpper(code))
+}
+
+// RequireCurrency returns the currency if it is registered and enabled.
+func (service *CurrencyService) RequireCurrency(ctx context.Context, code string) (*models.Currency, error) {
+	currency, err := service.GetCurrency(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if !currency.Enabled {
+		return nil, ErrCurrencyDisabled
+	}
+	return currency, nil
+}
+
+// NormalizeAmount rounds amount to the precision of the currency and checks
+// it against the currency minimum.
+func (service *CurrencyService) NormalizeAmount(currency *models.Currency, amount float64) (float64, error) {
+	rounded := currency.Round(amount)
+	if rounded <= 0 {
+		return 0, ErrInvalidAmount
+	}
+	if rounded < currency.MinAmount {
+		return 0, ErrAmountBelowMinimum
+	}
+	return rounded, nil
+}
+
+func (service *CurrencyService) CreateCurrency(ctx context.Context, currency *models.Currency) error {
+	currency.Code = strings.ToUpper(currency.Code)
+	if err := validateCurrency(currency); err != nil {
+		return err
+	}
+	return service.currencyRepo.CreateCurrency(ctx, currency)
+}
+
+func (service *CurrencyService) UpdateCurrency(ctx context.Context, currency *models.Currency) error {
+	currency.Code = strings.ToUpper(currency.Code)
+	if err := validateCurrency(currency); err != nil {
+		return err
+	}
+	return service.currencyRepo.UpdateCurrency(ctx, currency)
+}
+
+func validateCurrency(currency *models.Currency) error {
+	switch {
+	case !currencyCodeRegex.MatchString(currency.Code):
+		return ErrInvalidCurrency
+	case currency.Kind != models.CurrencyFiat && currency.Kind != models.CurrencyCrypto:
+		return ErrInvalidCurrencyKind
+	case currency.Decimals < 0 || currency.Decimals > 18:
+		return ErrInvalidDecimals
+	case currency.MinAmount < 0:
+		return ErrInvalidMinAmount
+	case currency.Confirmations < 0 || currency.Confirmations > 1000:
+		return ErrInvalidConfirmations
+	}
+	return nil
+}
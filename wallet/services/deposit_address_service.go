@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"wallet/chainaddress"
+	"wallet/hdkey"
+	"wallet/models"
+	"wallet/repositories"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// bip44Purpose and bip44AccountDepth describe the extended keys the service
+// accepts: m/44'/coin'/account'.
+const (
+	bip44Purpose      = 44
+	bip44AccountDepth = 3
+	// Receive addresses are on the external chain, m/.../0/index.
+	externalChain = 0
+)
+
+var (
+	ErrDepositAddressUnsupported = errors.New("deposit addresses are not available for this currency")
+	ErrInvalidDepositKey         = errors.New("deposit key must be the extended public key of a BIP44 account")
+)
+
+// depositChain derives the addresses of one currency.
+type depositChain struct {
+	key       *hdkey.ExtendedKey
+	coin      uint32
+	address   func(key *secp256k1.PublicKey) string
+	normalize func(address string) (string, error)
+}
+
+type DepositAddressService struct {
+	depositAddressRepo *repositories.DepositAddressRepository
+	accountRepo        *repositories.AccountRepository
+	currencyService    *CurrencyService
+	chains             map[string]*depositChain
+}
+
+// NewDepositAddressService takes the extended public keys per currency code.
+// BTC and ETH are supported, currencies without a key get no addresses.
+func NewDepositAddressService(depositAddressRepo *repositories.DepositAddressRepository, accountRepo *repositories.AccountRepository, currencyService *CurrencyService, keys map[string]string) (*DepositAddressService, error) {
+	chains := make(map[string]*depositChain)
+	for currency, serialized := range keys {
+		key, err := hdkey.Parse(serialized)
+		if err != nil {
+			return nil, fmt.Errorf("deposit key for %s: %w", currency, err)
+		}
+		if key.Depth() != bip44AccountDepth || key.ChildNumber() < hdkey.HardenedOffset {
+			return nil, fmt.Errorf("deposit key for %s: %w", currency, ErrInvalidDepositKey)
+		}
+
+		chain := &depositChain{key: key}
+		switch currency {
+		case "BTC":
+			hrp := chainaddress.BitcoinMainnet
+			if key.Network() == hdkey.Testnet {
+				hrp, chain.coin = chainaddress.BitcoinTestnet, 1
+			}
+			chain.address = func(key *secp256k1.PublicKey) string { return chainaddress.Bitcoin(key, hrp) }
+			chain.normalize = func(address string) (string, error) { return chainaddress.NormalizeBitcoin(address, hrp) }
+		case "ETH":
+			chain.coin = 60
+			chain.address = chainaddress.Ethereum
+			chain.normalize = chainaddress.NormalizeEthereum
+		default:
+			return nil, fmt.Errorf("deposit key for %s: %w", currency, ErrDepositAddressUnsupported)
+		}
+		chains[currency] = chain
+	}
+
+	return &DepositAddressService{
+		depositAddressRepo: depositAddressRepo,
+		accountRepo:        accountRepo,
+		currencyService:    currencyService,
+		chains:             chains,
+	}, nil
+}
+
+// GetDepositAddress returns the current address of the account, the first
+// call hands out one.
+func (service *DepositAddressService) GetDepositAddress(ctx context.Context, accountNumber string) (*models.DepositAddress, error) {
+	if _, err := service.chain(ctx, accountNumber); err != nil {
+		return nil, err
+	}
+
+	address, err := service.depositAddressRepo.GetLatest(ctx, accountNumber)
+	if errors.Is(err, repositories.ErrDepositAddressNotFound) {
+		return service.NewDepositAddress(ctx, accountNumber)
+	}
+	return address, err
+}
+
+// NewDepositAddress hands out a fresh address, e.g. for privacy. Earlier
+// addresses of the account stay valid.
+func (service *DepositAddressService) NewDepositAddress(ctx context.Context, accountNumber string) (*models.DepositAddress, error) {
+	chain, err := service.chain(ctx, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.depositAddressRepo.Create(ctx, accountNumber, func(next uint32) (*models.DepositAddress, error) {
+		for index := next; index < hdkey.HardenedOffset; index++ {
+			key, err := chain.key.Derive(fmt.Sprintf("%d/%d", externalChain, index))
+			if errors.Is(err, hdkey.ErrInvalidChild) {
+				// Happens with a probability below 1 in 2^127, BIP32 says to
+				// go on with the next index.
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			return &models.DepositAddress{
+				Address:         chain.address(key.PublicKey()),
+				DerivationIndex: index,
+				DerivationPath:  chain.key.Path(bip44Purpose, chain.coin, externalChain, index),
+			}, nil
+		}
+		return nil, hdkey.ErrInvalidPath
+	})
+}
+
+func (service *DepositAddressService) ListDepositAddresses(ctx context.Context, accountNumber string) ([]*models.DepositAddress, error) {
+	if _, err := service.accountRepo.GetAccountByNumber(ctx, accountNumber); err != nil {
+		return nil, err
+	}
+	return service.depositAddressRepo.List(ctx, accountNumber)
+}
+
+// normalizeAddress checks an external address on the chain of the currency,
+// on the same network as the deposit addresses, and returns its canonical
+// form. It reports false if the wallet is not connected to that chain.
+func (service *DepositAddressService) normalizeAddress(currency, address string) (string, bool, error) {
+	chain, ok := service.chains[currency]
+	if !ok {
+		return "", false, nil
+	}
+	address, err := chain.normalize(strings.TrimSpace(address))
+	return address, true, err
+}
+
+// chain returns the chain deposits to the account arrive on.
+func (service *DepositAddressService) chain(ctx context.Context, accountNumber string) (*depositChain, error) {
+	account, err := service.accountRepo.GetAccountByNumber(ctx, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	currency, err := service.currencyService.RequireCurrency(ctx, account.Currency)
+	if err != nil {
+		return nil, err
+	}
+	chain, ok := service.chains[currency.Code]
+	if !ok || currency.Kind != models.CurrencyCrypto {
+		return nil, ErrDepositAddressUnsupported
+	}
+	return chain, nil
+}
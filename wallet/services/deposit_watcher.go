@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"wallet/chain"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+// DepositWatcher scans the chains for payments to the deposit addresses and
+// credits them once they have the confirmations their currency requires.
+type DepositWatcher struct {
+	chainDepositRepo   chainDeposits
+	depositAddressRepo depositAddresses
+	currencyService    currencies
+	clients            map[string]chain.Client
+}
+
+// chainDeposits is the part of ChainDepositRepository the watcher uses.
+type chainDeposits interface {
+	LastBlock(ctx context.Context, currency string) (*models.ChainBlock, error)
+	RecordBlock(ctx context.Context, block *models.ChainBlock, parentHash string, deposits []*models.ChainDeposit) error
+	Rollback(ctx context.Context, currency string, height int64) error
+	UpdateConfirmations(ctx context.Context, currency string, tipHeight int64) error
+	ListDeposits(ctx context.Context, currency, status string, maxHeight int64) ([]*models.ChainDeposit, error)
+	ListAccountDeposits(ctx context.Context, accountNumber string) ([]*models.ChainDeposit, error)
+	Credit(ctx context.Context, id int64) (*models.ChainDeposit, error)
+	Reverse(ctx context.Context, id int64) (*models.ChainDeposit, error)
+}
+
+type depositAddresses interface {
+	FindByAddresses(ctx context.Context, currency string, addresses []string) ([]*models.DepositAddress, error)
+}
+
+// currencies is the part of CurrencyService the chain workers use.
+type currencies interface {
+	GetCurrency(ctx context.Context, code string) (*models.Currency, error)
+}
+
+// NewDepositWatcher takes a chain client per currency code.
+func NewDepositWatcher(chainDepositRepo *repositories.ChainDepositRepository, depositAddressRepo *repositories.DepositAddressRepository, currencyService *CurrencyService, clients map[string]chain.Client) *DepositWatcher {
+	return &DepositWatcher{
+		chainDepositRepo:   chainDepositRepo,
+		depositAddressRepo: depositAddressRepo,
+		currencyService:    currencyService,
+		clients:            clients,
+	}
+}
+
+// Run polls every chain each pollInterval until ctx is done.
+func (watcher *DepositWatcher) Run(ctx context.Context, pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for currency := range watcher.clients {
+			if err := watcher.Poll(ctx, currency); err != nil {
+				log.Printf("deposit watcher %s: %v", currency, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll scans the new blocks of the currency's chain, then credits deposits
+// that are confirmed and reverses credited ones a reorg has dropped. The
+// first poll starts at the current tip.
+func (watcher *DepositWatcher) Poll(ctx context.Context, currencyCode string) error {
+	client, ok := watcher.clients[currencyCode]
+	if !ok {
+		return ErrDepositAddressUnsupported
+	}
+	currency, err := watcher.currencyService.GetCurrency(ctx, currencyCode)
+	if err != nil {
+		return err
+	}
+
+	tip, err := client.TipHeight(ctx)
+	if err != nil {
+		return err
+	}
+	if err := watcher.scan(ctx, currency, client, tip); err != nil {
+		return err
+	}
+	if err := watcher.chainDepositRepo.UpdateConfirmations(ctx, currency.Code, tip); err != nil {
+		return err
+	}
+
+	confirmations := int64(currency.Confirmations)
+	if confirmations < 1 {
+		confirmations = 1
+	}
+	confirmed, err := watcher.chainDepositRepo.ListDeposits(ctx, currency.Code, models.DepositPending, tip-confirmations+1)
+	if err != nil {
+		return err
+	}
+	for _, deposit := range confirmed {
+		// A frozen account keeps the deposit pending until it is unfrozen.
+		if _, err := watcher.chainDepositRepo.Credit(ctx, deposit.ID); err != nil {
+			log.Printf("deposit watcher %s: crediting %s:%d: %v", currency.Code, deposit.TxID, deposit.Vout, err)
+		}
+	}
+
+	reorged, err := watcher.chainDepositRepo.ListDeposits(ctx, currency.Code, models.DepositReorged, tip)
+	if err != nil {
+		return err
+	}
+	for _, deposit := range reorged {
+		// Fails while the funds are spent, the reversal is retried with
+		// every poll.
+		if _, err := watcher.chainDepositRepo.Reverse(ctx, deposit.ID); err != nil {
+			log.Printf("deposit watcher %s: reversing %s:%d: %v", currency.Code, deposit.TxID, deposit.Vout, err)
+		}
+	}
+	return nil
+}
+
+func (watcher *DepositWatcher) scan(ctx context.Context, currency *models.Currency, client chain.Client, tip int64) error {
+	last, err := watcher.chainDepositRepo.LastBlock(ctx, currency.Code)
+	if err != nil {
+		return err
+	}
+
+	// Step back until the last recorded block is on the best chain again. If
+	// the whole history is dropped, scanning resumes at the oldest block.
+	height := tip
+	for last != nil {
+		block, err := client.BlockByHeight(ctx, last.Height)
+		if err != nil && !errors.Is(err, chain.ErrBlockNotFound) {
+			return err
+		}
+		if err == nil && block.Hash == last.Hash {
+			break
+		}
+		height = last.Height
+		if last, err = watcher.rollback(ctx, last); err != nil {
+			return err
+		}
+	}
+
+	if last != nil {
+		height = last.Height + 1
+	}
+	for ; height <= tip; height++ {
+		block, err := client.BlockByHeight(ctx, height)
+		if errors.Is(err, chain.ErrBlockNotFound) {
+			// The tip moved back since it was read.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if last != nil && block.ParentHash != last.Hash {
+			// A reorg happened while scanning, the next poll sorts it out.
+			return nil
+		}
+
+		deposits, err := watcher.deposits(ctx, currency, block)
+		if err != nil {
+			return err
+		}
+
+		recorded := &models.ChainBlock{Currency: currency.Code, Height: block.Height, Hash: block.Hash}
+		err = watcher.chainDepositRepo.RecordBlock(ctx, recorded, block.ParentHash, deposits)
+		if errors.Is(err, repositories.ErrChainCursorMoved) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		last = recorded
+	}
+	return nil
+}
+
+// rollback drops the last recorded block and returns the one before it.
+func (watcher *DepositWatcher) rollback(ctx context.Context, last *models.ChainBlock) (*models.ChainBlock, error) {
+	log.Printf("deposit watcher %s: block %d %s left the best chain", last.Currency, last.Height, last.Hash)
+	if err := watcher.chainDepositRepo.Rollback(ctx, last.Currency, last.Height); err != nil {
+		return nil, err
+	}
+	return watcher.chainDepositRepo.LastBlock(ctx, last.Currency)
+}
+
+// deposits picks the outputs of the block paying one of our addresses.
+func (watcher *DepositWatcher) deposits(ctx context.Context, currency *models.Currency, block *chain.Block) ([]*models.ChainDeposit, error) {
+	if len(block.Outputs) == 0 {
+		return nil, nil
+	}
+
+	addresses := make([]string, len(block.Outputs))
+	for i, output := range block.Outputs {
+		addresses[i] = output.Address
+	}
+	found, err := watcher.depositAddressRepo.FindByAddresses(ctx, currency.Code, addresses)
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[string]*models.DepositAddress, len(found))
+	for _, address := range found {
+		owners[strings.ToLower(address.Address)] = address
+	}
+
+	var deposits []*models.ChainDeposit
+	for _, output := range block.Outputs {
+		owner, ok := owners[strings.ToLower(output.Address)]
+		amount := currency.Round(output.Amount)
+		if !ok || amount <= 0 {
+			continue
+		}
+		deposits = append(deposits, &models.ChainDeposit{
+			Currency:      currency.Code,
+			TxID:          output.TxID,
+			Vout:          output.Vout,
+			Address:       owner.Address,
+			AccountNumber: owner.AccountNumber,
+			Amount:        amount,
+		})
+	}
+	return deposits, nil
+}
+
+// ListDeposits returns the chain deposits to the account, newest first.
+func (watcher *DepositWatcher) ListDeposits(ctx context.Context, accountNumber string) ([]*models.ChainDeposit, error) {
+	return watcher.chainDepositRepo.ListAccountDeposits(ctx, accountNumber)
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"wallet/chain"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+const (
+	testDepositAddress = "bc1qdeposit"
+	testAccount        = "BTC-0001"
+)
+
+// fakeChainDeposits keeps the recorded blocks as the cursor and records every
+// other call. The deposits it lists and the errors of Credit and Reverse are
+// set by the test, the status rules are ChainDepositRepository's.
+type fakeChainDeposits struct {
+	blocks     []*models.ChainBlock
+	recorded   []*models.ChainDeposit
+	rollbacks  []int64
+	tips       []int64
+	listed     map[string][]*models.ChainDeposit
+	maxHeights map[string]int64
+	credited   []int64
+	reversed   []int64
+	errs       map[int64]error
+}
+
+func newFakeChainDeposits() *fakeChainDeposits {
+	return &fakeChainDeposits{
+		listed:     make(map[string][]*models.ChainDeposit),
+		maxHeights: make(map[string]int64),
+		errs:       make(map[int64]error),
+	}
+}
+
+func (f *fakeChainDeposits) LastBlock(ctx context.Context, currency string) (*models.ChainBlock, error) {
+	if len(f.blocks) == 0 {
+		return nil, nil
+	}
+	return f.blocks[len(f.blocks)-1], nil
+}
+
+func (f *fakeChainDeposits) RecordBlock(ctx context.Context, block *models.ChainBlock, parentHash string, deposits []*models.ChainDeposit) error {
+	f.blocks = append(f.blocks, block)
+	f.recorded = append(f.recorded, deposits...)
+	return nil
+}
+
+func (f *fakeChainDeposits) Rollback(ctx context.Context, currency string, height int64) error {
+	f.rollbacks = append(f.rollbacks, height)
+	for len(f.blocks) > 0 && f.blocks[len(f.blocks)-1].Height >= height {
+		f.blocks = f.blocks[:len(f.blocks)-1]
+	}
+	return nil
+}
+
+func (f *fakeChainDeposits) UpdateConfirmations(ctx context.Context, currency string, tipHeight int64) error {
+	f.tips = append(f.tips, tipHeight)
+	return nil
+}
+
+func (f *fakeChainDeposits) ListDeposits(ctx context.Context, currency, status string, maxHeight int64) ([]*models.ChainDeposit, error) {
+	f.maxHeights[status] = maxHeight
+	return f.listed[status], nil
+}
+
+func (f *fakeChainDeposits) ListAccountDeposits(ctx context.Context, accountNumber string) ([]*models.ChainDeposit, error) {
+	return nil, nil
+}
+
+func (f *fakeChainDeposits) Credit(ctx context.Context, id int64) (*models.ChainDeposit, error) {
+	f.credited = append(f.credited, id)
+	return &models.ChainDeposit{ID: id}, f.errs[id]
+}
+
+func (f *fakeChainDeposits) Reverse(ctx context.Context, id int64) (*models.ChainDeposit, error) {
+	f.reversed = append(f.reversed, id)
+	return &models.ChainDeposit{ID: id}, f.errs[id]
+}
+
+type fakeDepositAddresses map[string]*models.DepositAddress
+
+func (f fakeDepositAddresses) FindByAddresses(ctx context.Context, currency string, addresses []string) ([]*models.DepositAddress, error) {
+	var found []*models.DepositAddress
+	for _, address := range addresses {
+		if owner, ok := f[address]; ok {
+			found = append(found, owner)
+		}
+	}
+	return found, nil
+}
+
+type fakeCurrencies map[string]*models.Currency
+
+func (f fakeCurrencies) GetCurrency(ctx context.Context, code string) (*models.Currency, error) {
+	currency, ok := f[code]
+	if !ok {
+		return nil, repositories.ErrCurrencyNotFound
+	}
+	return currency, nil
+}
+
+func newTestWatcher(confirmations int) (*DepositWatcher, *fakeChainDeposits, *chain.Simulated) {
+	simulated := chain.NewSimulated()
+	deposits := newFakeChainDeposits()
+	watcher := &DepositWatcher{
+		chainDepositRepo: deposits,
+		depositAddressRepo: fakeDepositAddresses{
+			testDepositAddress: {AccountNumber: testAccount, Currency: "BTC", Address: testDepositAddress},
+		},
+		currencyService: fakeCurrencies{"BTC": {Code: "BTC", Decimals: 8, Confirmations: confirmations}},
+		clients:         map[string]chain.Client{"BTC": simulated},
+	}
+	return watcher, deposits, simulated
+}
+
+func poll(t *testing.T, watcher *DepositWatcher) {
+	t.Helper()
+	if err := watcher.Poll(context.Background(), "BTC"); err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+}
+
+func equalIDs(got, want []int64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDepositWatcherRecordsOwnOutputs(t *testing.T) {
+	watcher, deposits, simulated := newTestWatcher(1)
+	poll(t, watcher)
+
+	block := simulated.Mine(
+		chain.Output{Address: testDepositAddress, Amount: 1.5},
+		chain.Output{Address: "bc1qsomeoneelse", Amount: 4},
+	)
+	poll(t, watcher)
+
+	if len(deposits.blocks) != 2 || deposits.blocks[1].Hash != block.Hash {
+		t.Fatalf("recorded blocks %+v, want the genesis block and %s", deposits.blocks, block.Hash)
+	}
+	if len(deposits.recorded) != 1 {
+		t.Fatalf("recorded %d deposits, want 1", len(deposits.recorded))
+	}
+	deposit := deposits.recorded[0]
+	if deposit.AccountNumber != testAccount || deposit.Amount != 1.5 || deposit.TxID != block.Outputs[0].TxID {
+		t.Fatalf("recorded %+v, want 1.5 to %s in %s", deposit, testAccount, block.Outputs[0].TxID)
+	}
+}
+
+func TestDepositWatcherCreditsAtConfirmationThreshold(t *testing.T) {
+	watcher, deposits, simulated := newTestWatcher(3)
+	for i := 0; i < 5; i++ {
+		simulated.Mine()
+	}
+	deposits.listed[models.DepositPending] = []*models.ChainDeposit{{ID: 1}, {ID: 2}}
+
+	poll(t, watcher)
+	if len(deposits.tips) != 1 || deposits.tips[0] != 5 {
+		t.Fatalf("confirmations counted for tips %v, want [5]", deposits.tips)
+	}
+	if maxHeight := deposits.maxHeights[models.DepositPending]; maxHeight != 3 {
+		t.Fatalf("pending deposits listed up to height %d, want 3", maxHeight)
+	}
+	if !equalIDs(deposits.credited, []int64{1, 2}) {
+		t.Fatalf("credited %v, want [1 2]", deposits.credited)
+	}
+}
+
+func TestDepositWatcherKeepsGoingAfterFailedCredit(t *testing.T) {
+	watcher, deposits, _ := newTestWatcher(1)
+	deposits.listed[models.DepositPending] = []*models.ChainDeposit{{ID: 1}, {ID: 2}}
+	deposits.listed[models.DepositReorged] = []*models.ChainDeposit{{ID: 3}}
+	deposits.errs[1] = repositories.ErrAccountFrozen
+
+	poll(t, watcher)
+	if !equalIDs(deposits.credited, []int64{1, 2}) {
+		t.Fatalf("credited %v, want [1 2]", deposits.credited)
+	}
+	if !equalIDs(deposits.reversed, []int64{3}) {
+		t.Fatalf("reversed %v, want [3]", deposits.reversed)
+	}
+}
+
+func TestDepositWatcherRollsBackReorg(t *testing.T) {
+	watcher, deposits, simulated := newTestWatcher(1)
+	poll(t, watcher)
+	simulated.Mine(chain.Output{Address: testDepositAddress, Amount: 2})
+	poll(t, watcher)
+
+	if err := simulated.Reorg(1); err != nil {
+		t.Fatal(err)
+	}
+	replaced, err := simulated.BlockByHeight(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deposits.listed[models.DepositReorged] = []*models.ChainDeposit{{ID: 1}}
+	poll(t, watcher)
+
+	if !equalIDs(deposits.rollbacks, []int64{1}) {
+		t.Fatalf("rolled back %v, want [1]", deposits.rollbacks)
+	}
+	if last := deposits.blocks[len(deposits.blocks)-1]; last.Height != 1 || last.Hash != replaced.Hash {
+		t.Fatalf("last block %d %s, want 1 %s", last.Height, last.Hash, replaced.Hash)
+	}
+	if maxHeight := deposits.maxHeights[models.DepositReorged]; maxHeight != 1 {
+		t.Fatalf("reorged deposits listed up to height %d, want 1", maxHeight)
+	}
+	if !equalIDs(deposits.reversed, []int64{1}) {
+		t.Fatalf("reversed %v, want [1]", deposits.reversed)
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"time"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+var (
+	ErrInvalidFeeOperation = errors.New("fee operation must be transfer, withdrawal or trade")
+	ErrInvalidFeeRule      = errors.New("fee amounts must not be negative, percent must be at most 100 and max_fee not below min_fee")
+	ErrAnyCurrencyFeeRule  = errors.New("fee rules for any currency can only charge a percentage")
+	ErrInvalidFeeDiscount  = errors.New("discount needs a rating of 0 to 9 and a percent of 0 to 100")
+)
+
+type FeeService struct {
+	feeRepo         *repositories.FeeRepository
+	limitRepo       *repositories.LimitRepository
+	walletRepo      *repositories.WalletRepository
+	currencyService *CurrencyService
+}
+
+func NewFeeService(feeRepo *repositories.FeeRepository, limitRepo *repositories.LimitRepository, walletRepo *repositories.WalletRepository, currencyService *CurrencyService) *FeeService {
+	return &FeeService{feeRepo: feeRepo, limitRepo: limitRepo, walletRepo: walletRepo, currencyService: currencyService}
+}
+
+// Quote returns the fee the account pays for an operation over amount, or
+// nil if the operation is free. The rating discount of the account owner is
+// already applied.
+func (service *FeeService) Quote(ctx context.Context, operation string, account *models.Account, amount float64) (*models.FeeItem, error) {
+	if err := validateFeeOperation(operation); err != nil {
+		return nil, err
+	}
+
+	rule, err := service.feeRepo.FindRule(ctx, operation, account.Currency, amount)
+	if err != nil || rule == nil {
+		return nil, err
+	}
+
+	fee := rule.Flat + amount*rule.Percent/100
+	fee = math.Max(fee, rule.MinFee)
+	if rule.MaxFee > 0 {
+		fee = math.Min(fee, rule.MaxFee)
+	}
+
+	discount, err := service.discount(ctx, account.AccountNumber)
+	if err != nil {
+		return nil, err
+	}
+	fee -= fee * discount / 100
+
+	currency, err := service.currencyService.GetCurrency(ctx, account.Currency)
+	if err != nil {
+		return nil, err
+	}
+	fee = currency.Round(fee)
+	if fee <= 0 {
+		return nil, nil
+	}
+
+	return &models.FeeItem{
+		Type:          operation,
+		AccountNumber: account.AccountNumber,
+		Currency:      account.Currency,
+		Amount:        fee,
+		RuleID:        rule.ID,
+		Discount:      discount,
+	}, nil
+}
+
+// HouseAccount returns the account fees in the currency are credited to.
+func (service *FeeService) HouseAccount(ctx context.Context, currency string) (string, error) {
+	return service.feeRepo.GetHouseAccount(ctx, currency)
+}
+
+func (service *FeeService) GetSchedule(ctx context.Context) (*models.FeeSchedule, error) {
+	return service.feeRepo.GetSchedule(ctx)
+}
+
+func (service *FeeService) CreateRule(ctx context.Context, rule *models.FeeRule) error {
+	if err := validateFeeOperation(rule.Operation); err != nil {
+		return err
+	}
+	if rule.MinAmount < 0 || rule.Flat < 0 || rule.Percent < 0 || rule.Percent > 100 || rule.MinFee < 0 || rule.MaxFee < 0 ||
+		(rule.MaxFee > 0 && rule.MaxFee < rule.MinFee) {
+		return ErrInvalidFeeRule
+	}
+
+	rule.Currency = strings.ToUpper(rule.Currency)
+	if rule.Currency == "" {
+		rule.Currency = models.FeeAnyCurrency
+	}
+	if rule.Currency == models.FeeAnyCurrency {
+		if rule.MinAmount != 0 || rule.Flat != 0 || rule.MinFee != 0 || rule.MaxFee != 0 {
+			return ErrAnyCurrencyFeeRule
+		}
+	} else if _, err := service.currencyService.GetCurrency(ctx, rule.Currency); err != nil {
+		return err
+	}
+
+	return service.feeRepo.CreateRule(ctx, rule)
+}
+
+func (service *FeeService) DeleteRule(ctx context.Context, id int64) error {
+	return service.feeRepo.DeleteRule(ctx, id)
+}
+
+func (service *FeeService) SetDiscount(ctx context.Context, discount *models.FeeDiscount) error {
+	if discount.MinRating < 0 || discount.MinRating > 9 || discount.Percent < 0 || discount.Percent > 100 {
+		return ErrInvalidFeeDiscount
+	}
+	return service.feeRepo.SetDiscount(ctx, discount)
+}
+
+// discount returns the rating discount of the account owner. Accounts
+// outside of any wallet, like the house accounts, get none.
+func (service *FeeService) discount(ctx context.Context, accountNumber string) (float64, error) {
+	userID, err := service.walletRepo.GetUserIDByAccount(ctx, accountNumber)
+	if err != nil || userID == 0 {
+		return 0, err
+	}
+
+	_, ratingLevel, err := service.limitRepo.GetLevels(ctx, userID)
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return service.feeRepo.FindDiscount(ctx, ratingLevel)
+}
+
+// houseAccount returns the house account for the fee, or "" if there is no
+// fee to post.
+func (service *FeeService) houseAccount(ctx context.Context, fee *models.FeeItem) (string, error) {
+	if fee == nil {
+		return "", nil
+	}
+	return service.HouseAccount(ctx, fee.Currency)
+}
+
+func validateFeeOperation(operation string) error {
+	switch operation {
+	case models.FeeTransfer, models.FeeWithdrawal, models.FeeTrade:
+		return nil
+	}
+	return ErrInvalidFeeOperation
+}
+
+// newReceipt itemises a movement of amount from accountNumber to the
+// counterparty with the fees charged for it.
+func newReceipt(operation string, account *models.Account, counterparty string, amount float64, currency *models.Currency, fees ...*models.FeeItem) *models.Receipt {
+	receipt := &models.Receipt{
+		Operation:                 operation,
+		AccountNumber:             account.AccountNumber,
+		CounterpartyAccountNumber: counterparty,
+		Currency:                  account.Currency,
+		Amount:                    amount,
+		Fees:                      []models.FeeItem{},
+		Total:                     amount,
+		Net:                       amount,
+		CreatedAt:                 time.Now().UTC(),
+	}
+	for _, fee := range fees {
+		if fee == nil {
+			continue
+		}
+		receipt.Fees = append(receipt.Fees, *fee)
+		if fee.AccountNumber == account.AccountNumber {
+			receipt.Total = currency.Round(receipt.Total + fee.Amount)
+		} else {
+			receipt.Net = currency.Round(receipt.Net - fee.Amount)
+		}
+	}
+	return receipt
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+const (
+	defaultGrantExpiry = 30 * 24 * time.Hour
+	maxGrantExpiry     = 365 * 24 * time.Hour
+)
+
+var (
+	ErrAccessDenied        = errors.New("principal is not allowed to do this for the wallet owner")
+	ErrInvalidGrantScope   = errors.New("scopes must be view or trade, withdrawals cannot be delegated")
+	ErrInvalidGrantee      = errors.New("grant needs either another user or an API key as grantee")
+	ErrInvalidGrantExpiry  = errors.New("expiry must be in the future and at most a year away")
+	ErrOrganizationAccount = errors.New("organization accounts only move funds through organization transfers")
+)
+
+var grantableScopes = map[string]bool{
+	models.GrantScopeView:  true,
+	models.GrantScopeTrade: true,
+}
+
+// GrantService lets owners delegate access to their wallet. Trading implies
+// viewing, nothing implies withdrawing.
+type GrantService struct {
+	grantRepo        *repositories.GrantRepository
+	walletRepo       *repositories.WalletRepository
+	organizationRepo *repositories.OrganizationRepository
+}
+
+func NewGrantService(grantRepo *repositories.GrantRepository, walletRepo *repositories.WalletRepository, organizationRepo *repositories.OrganizationRepository) *GrantService {
+	return &GrantService{grantRepo: grantRepo, walletRepo: walletRepo, organizationRepo: organizationRepo}
+}
+
+// CreateGrant gives granteeUserID the scopes over the owner's wallet, or a
+// new API key if granteeUserID is 0 and apiKey is set. Grants expire after
+// 30 days unless expiresAt says otherwise.
+func (service *GrantService) CreateGrant(ctx context.Context, ownerID, granteeUserID int, apiKey bool, scopes []string, expiresAt *time.Time) (*models.Grant, error) {
+	if (granteeUserID == 0) == !apiKey || granteeUserID == ownerID {
+		return nil, ErrInvalidGrantee
+	}
+	if len(scopes) == 0 {
+		return nil, ErrInvalidGrantScope
+	}
+	for _, scope := range scopes {
+		if !grantableScopes[scope] {
+			return nil, ErrInvalidGrantScope
+		}
+	}
+
+	now := time.Now().UTC()
+	expiry := now.Add(defaultGrantExpiry)
+	if expiresAt != nil {
+		expiry = expiresAt.UTC()
+	}
+	if !expiry.After(now) || expiry.Sub(now) > maxGrantExpiry {
+		return nil, ErrInvalidGrantExpiry
+	}
+
+	grant := &models.Grant{OwnerID: ownerID, Scopes: scopes, ExpiresAt: expiry}
+	var key string
+	if apiKey {
+		var err error
+		if key, err = newAPIKey(); err != nil {
+			return nil, err
+		}
+	} else {
+		grant.GranteeUserID = &granteeUserID
+	}
+
+	created, err := service.grantRepo.CreateGrant(ctx, grant, hashAPIKey(key))
+	if err != nil {
+		return nil, err
+	}
+	created.APIKey = key
+	return created, nil
+}
+
+func (service *GrantService) ListGrants(ctx context.Context, ownerID int) ([]*models.Grant, error) {
+	return service.grantRepo.ListGrants(ctx, ownerID)
+}
+
+// RevokeGrant ends the grant right away.
+func (service *GrantService) RevokeGrant(ctx context.Context, ownerID int, id int64) (*models.Grant, error) {
+	return service.grantRepo.RevokeGrant(ctx, ownerID, id)
+}
+
+// Authorize checks that the principal may act on the owner's wallet with
+// the scope. Owners may do anything, everyone else needs a live grant. A
+// nil principal is the caller acting for the owner as before grants, the
+// services authenticating users pass who acts.
+func (service *GrantService) Authorize(ctx context.Context, principal *models.Principal, ownerID int, scope string) error {
+	if principal == nil || (principal.APIKey == "" && principal.UserID == ownerID) {
+		return nil
+	}
+	if ownerID == 0 || scope == models.GrantScopeOwner {
+		return ErrAccessDenied
+	}
+
+	scopes := []string{scope}
+	if scope == models.GrantScopeView {
+		scopes = append(scopes, models.GrantScopeTrade)
+	}
+	for _, scope := range scopes {
+		granted, err := service.grantRepo.HasGrant(ctx, ownerID, principal.UserID, hashAPIKey(principal.APIKey), scope, time.Now())
+		if err != nil {
+			return err
+		}
+		if granted {
+			return nil
+		}
+	}
+	return ErrAccessDenied
+}
+
+// AuthorizeAccount is Authorize for the owner of the account. Accounts
+// outside any wallet are only open to a nil principal. Nobody may trade or
+// act as owner on an organization account this way, its funds only move
+// through the approvals of organization transfers.
+func (service *GrantService) AuthorizeAccount(ctx context.Context, principal *models.Principal, accountNumber, scope string) error {
+	if scope != models.GrantScopeView {
+		orgID, err := service.organizationRepo.GetOrganizationIDByAccount(ctx, accountNumber)
+		if err != nil {
+			return err
+		}
+		if orgID != 0 {
+			return ErrOrganizationAccount
+		}
+	}
+	if principal == nil {
+		return nil
+	}
+	ownerID, err := service.walletRepo.GetUserIDByAccount(ctx, accountNumber)
+	if err != nil {
+		return err
+	}
+	return service.Authorize(ctx, principal, ownerID, scope)
+}
+
+func newAPIKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "wk_" + hex.EncodeToString(b), nil
+}
+
+func hashAPIKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"wallet/models"
+	"wallet/repositories"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAuthorizeAccountKeepsOrganizationAccountsOffGenericRoutes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	service := NewGrantService(repositories.NewGrantRepository(db), repositories.NewWalletRepository(db), repositories.NewOrganizationRepository(db))
+	organizationOf := regexp.QuoteMeta("SELECT organization_id FROM organization_accounts WHERE account_number = $1")
+
+	// Not even a request without a principal may move the funds.
+	for _, scope := range []string{models.GrantScopeTrade, models.GrantScopeOwner} {
+		mock.ExpectQuery(organizationOf).WithArgs(testAccount).WillReturnRows(sqlmock.NewRows([]string{"organization_id"}).AddRow(3))
+		if err := service.AuthorizeAccount(context.Background(), nil, testAccount, scope); !errors.Is(err, ErrOrganizationAccount) {
+			t.Fatalf("%s: err = %v, want %v", scope, err, ErrOrganizationAccount)
+		}
+	}
+
+	mock.ExpectQuery(organizationOf).WithArgs(testAccount).WillReturnRows(sqlmock.NewRows([]string{"organization_id"}))
+	if err := service.AuthorizeAccount(context.Background(), nil, testAccount, models.GrantScopeOwner); err != nil {
+		t.Fatalf("personal account: %v", err)
+	}
+	if err := service.AuthorizeAccount(context.Background(), nil, testAccount, models.GrantScopeView); err != nil {
+		t.Fatalf("view: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
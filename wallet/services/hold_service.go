@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+var (
+	ErrInvalidAmount       = errors.New("amount must be positive")
+	ErrInvalidHoldKind     = errors.New("hold kind must be order or dispute")
+	ErrHoldKindNotAllowed  = errors.New("only order and dispute holds can be released or captured")
+	ErrDestinationRequired = errors.New("destination account is required")
+	ErrNotTradeHold        = errors.New("hold is not an order hold")
+	ErrNotTradeParty       = errors.New("accounts do not belong to the parties of the trade")
+)
+
+type HoldService struct {
+	holdRepo        *repositories.HoldRepository
+	accountRepo     *repositories.AccountRepository
+	walletRepo      *repositories.WalletRepository
+	currencyService *CurrencyService
+	feeService      *FeeService
+	walletService   *WalletService
+}
+
+func NewHoldService(holdRepo *repositories.HoldRepository, accountRepo *repositories.AccountRepository, walletRepo *repositories.WalletRepository, currencyService *CurrencyService, feeService *FeeService, walletService *WalletService) *HoldService {
+	return &HoldService{holdRepo: holdRepo, accountRepo: accountRepo, walletRepo: walletRepo, currencyService: currencyService, feeService: feeService, walletService: walletService}
+}
+
+func (service *HoldService) PlaceHold(ctx context.Context, accountNumber string, amount float64, kind, reference string) (*models.Hold, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if !generalHoldKind(kind) {
+		return nil, ErrInvalidHoldKind
+	}
+
+	account, err := service.accountRepo.GetAccountByNumber(ctx, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	currency, err := service.currencyService.RequireCurrency(ctx, account.Currency)
+	if err != nil {
+		return nil, err
+	}
+	amount, err = service.currencyService.NormalizeAmount(currency, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.holdRepo.PlaceHold(ctx, accountNumber, amount, kind, reference)
+}
+
+func (service *HoldService) GetHold(ctx context.Context, holdID int64) (*models.Hold, error) {
+	return service.holdRepo.GetHold(ctx, holdID)
+}
+
+func (service *HoldService) ListHolds(ctx context.Context, accountNumber string) ([]*models.Hold, error) {
+	if _, err := service.accountRepo.GetAccountByNumber(ctx, accountNumber); err != nil {
+		return nil, err
+	}
+	return service.holdRepo.ListActiveHolds(ctx, accountNumber)
+}
+
+func (service *HoldService) ReleaseHold(ctx context.Context, holdID int64) (*models.Hold, error) {
+	if _, err := service.generalHold(ctx, holdID); err != nil {
+		return nil, err
+	}
+	return service.holdRepo.ReleaseHold(ctx, holdID)
+}
+
+// CaptureHold moves the held funds to the destination account, which must be
+// in the same currency. Funds only leave the system through withdrawals, so
+// a destination is required. A capture is a transfer: the sender pays the
+// transfer fee and it counts towards their transfer limits.
+func (service *HoldService) CaptureHold(ctx context.Context, holdID int64, destinationAccountNumber string) (*models.Hold, error) {
+	if destinationAccountNumber == "" {
+		return nil, ErrDestinationRequired
+	}
+	hold, err := service.generalHold(ctx, holdID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := service.walletService.planTransfer(ctx, hold.AccountNumber, destinationAccountNumber, hold.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	captured, err := service.holdRepo.CaptureHold(ctx, holdID, destinationAccountNumber, plan.fee, plan.houseAccount)
+	if err != nil {
+		service.walletService.abandonTransfer(ctx, plan)
+		return nil, err
+	}
+	return captured, nil
+}
+
+// SettleTrade pays paymentAmount from the payer to the payee and captures the
+// hold to the destination account in one go. The payee is charged the trade
+// fee out of the payment, so the receipt shows what they got net. The hold
+// must be an order hold, the payee must belong to its seller and the
+// destination to the payer.
+func (service *HoldService) SettleTrade(ctx context.Context, holdID int64, destinationAccountNumber, payerAccountNumber, payeeAccountNumber string, paymentAmount float64) (*models.Receipt, error) {
+	if payerAccountNumber == payeeAccountNumber {
+		return nil, ErrSameAccount
+	}
+	if paymentAmount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	hold, err := service.holdRepo.GetHold(ctx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Kind != models.HoldOrder {
+		return nil, ErrNotTradeHold
+	}
+	if err := service.checkTradeParties(ctx, hold.AccountNumber, payeeAccountNumber, payerAccountNumber, destinationAccountNumber); err != nil {
+		return nil, err
+	}
+
+	accounts := make(map[string]*models.Account)
+	for _, number := range []string{hold.AccountNumber, destinationAccountNumber, payerAccountNumber, payeeAccountNumber} {
+		account, err := service.accountRepo.GetAccountByNumber(ctx, number)
+		if err != nil {
+			return nil, err
+		}
+		accounts[number] = account
+	}
+	payer, payee := accounts[payerAccountNumber], accounts[payeeAccountNumber]
+	if payer.Currency != payee.Currency || accounts[hold.AccountNumber].Currency != accounts[destinationAccountNumber].Currency {
+		return nil, ErrCurrencyMismatch
+	}
+
+	currency, err := service.currencyService.RequireCurrency(ctx, payer.Currency)
+	if err != nil {
+		return nil, err
+	}
+	paymentAmount, err = service.currencyService.NormalizeAmount(currency, paymentAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	fee, err := service.feeService.Quote(ctx, models.FeeTrade, payee, paymentAmount)
+	if err != nil {
+		return nil, err
+	}
+	houseAccount, err := service.feeService.houseAccount(ctx, fee)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = service.holdRepo.SettleTrade(ctx, holdID, destinationAccountNumber, payerAccountNumber, payeeAccountNumber, paymentAmount, fee, houseAccount)
+	if err != nil {
+		return nil, err
+	}
+	return newReceipt(models.FeeTrade, payer, payeeAccountNumber, paymentAmount, currency, fee), nil
+}
+
+// generalHold returns the hold if it may be released or captured on its
+// own. Withdrawal holds are only finished by their withdrawal, which may
+// already be on its way to the chain.
+func (service *HoldService) generalHold(ctx context.Context, holdID int64) (*models.Hold, error) {
+	hold, err := service.holdRepo.GetHold(ctx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	if !generalHoldKind(hold.Kind) {
+		return nil, ErrHoldKindNotAllowed
+	}
+	return hold, nil
+}
+
+func generalHoldKind(kind string) bool {
+	return kind == models.HoldOrder || kind == models.HoldDispute
+}
+
+// checkTradeParties makes sure the seller gets paid into their own wallet
+// and the buyer receives the held funds into theirs.
+func (service *HoldService) checkTradeParties(ctx context.Context, holdAccountNumber, payeeAccountNumber, payerAccountNumber, destinationAccountNumber string) error {
+	owners := make(map[string]int)
+	for _, number := range []string{holdAccountNumber, payeeAccountNumber, payerAccountNumber, destinationAccountNumber} {
+		ownerID, err := service.walletRepo.GetUserIDByAccount(ctx, number)
+		if err != nil {
+			return err
+		}
+		if ownerID == 0 {
+			return ErrNotTradeParty
+		}
+		owners[number] = ownerID
+	}
+	if owners[payeeAccountNumber] != owners[holdAccountNumber] || owners[destinationAccountNumber] != owners[payerAccountNumber] {
+		return ErrNotTradeParty
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+	"wallet/models"
+	"wallet/repositories"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var holdRowColumns = []string{"id", "account_number", "amount", "kind", "reference", "status", "created_at", "updated_at"}
+
+func newTestHoldService(t *testing.T) (*HoldService, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	service := NewHoldService(repositories.NewHoldRepository(db), repositories.NewAccountRepository(db), repositories.NewWalletRepository(db), nil, nil, nil)
+	return service, mock
+}
+
+func holdRow(kind, status string) *sqlmock.Rows {
+	return sqlmock.NewRows(holdRowColumns).AddRow(7, testAccount, 0.5, kind, "", status, time.Now(), time.Now())
+}
+
+func TestHoldServiceRefusesWithdrawalHolds(t *testing.T) {
+	service, mock := newTestHoldService(t)
+	getHold := regexp.QuoteMeta("FROM account_holds WHERE id = $1")
+
+	mock.ExpectQuery(getHold).WithArgs(7).WillReturnRows(holdRow(models.HoldWithdrawal, models.HoldActive))
+	if _, err := service.ReleaseHold(context.Background(), 7); !errors.Is(err, ErrHoldKindNotAllowed) {
+		t.Fatalf("release: err = %v, want %v", err, ErrHoldKindNotAllowed)
+	}
+
+	mock.ExpectQuery(getHold).WithArgs(7).WillReturnRows(holdRow(models.HoldWithdrawal, models.HoldActive))
+	if _, err := service.CaptureHold(context.Background(), 7, "BTC-0002"); !errors.Is(err, ErrHoldKindNotAllowed) {
+		t.Fatalf("capture: err = %v, want %v", err, ErrHoldKindNotAllowed)
+	}
+
+	// Neither touched the hold or the balance.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHoldServiceReleasesOrderHold(t *testing.T) {
+	service, mock := newTestHoldService(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM account_holds WHERE id = $1")).WithArgs(7).WillReturnRows(holdRow(models.HoldOrder, models.HoldActive))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("FROM account_holds WHERE id = $1 FOR UPDATE")).WithArgs(7).WillReturnRows(holdRow(models.HoldOrder, models.HoldActive))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE accounts SET held_balance = held_balance - $1")).WithArgs(0.5, testAccount).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE account_holds SET status = $1")).WithArgs(models.HoldReleased, 7).WillReturnRows(holdRow(models.HoldOrder, models.HoldReleased))
+	mock.ExpectCommit()
+
+	hold, err := service.ReleaseHold(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hold.Status != models.HoldReleased {
+		t.Fatalf("status = %s, want %s", hold.Status, models.HoldReleased)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHoldServicePlacesOnlyGeneralHolds(t *testing.T) {
+	service, mock := newTestHoldService(t)
+
+	if _, err := service.PlaceHold(context.Background(), testAccount, 1, models.HoldWithdrawal, ""); !errors.Is(err, ErrInvalidHoldKind) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidHoldKind)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+	"wallet/fx"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+// LimitCurrency is the currency limits are configured and counted in.
+const LimitCurrency = "USD"
+
+var (
+	ErrInvalidLimitKind   = errors.New("limit kind must be withdrawal, transfer or order")
+	ErrInvalidLimitPeriod = errors.New("limit period must be day or month")
+	ErrInvalidLimitAmount = errors.New("limit amount must not be negative")
+	ErrInvalidLevel       = errors.New("access level must be 1 to 3 and rating level 0 to 9")
+)
+
+type LimitService struct {
+	limitRepo       *repositories.LimitRepository
+	walletRepo      *repositories.WalletRepository
+	currencyService *CurrencyService
+	rates           fx.RateProvider
+}
+
+func NewLimitService(limitRepo *repositories.LimitRepository, walletRepo *repositories.WalletRepository, currencyService *CurrencyService, rates fx.RateProvider) *LimitService {
+	return &LimitService{limitRepo: limitRepo, walletRepo: walletRepo, currencyService: currencyService, rates: rates}
+}
+
+// Limits returns every limit of the user with the allowance left in the
+// current day or month.
+func (service *LimitService) Limits(ctx context.Context, userID int) (*models.UserLimits, error) {
+	accessLevel, ratingLevel, err := service.limitRepo.GetLevels(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	limits, err := service.limitRepo.GetLimits(ctx, userID, accessLevel, ratingLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	userLimits := &models.UserLimits{
+		UserID:      userID,
+		AccessLevel: accessLevel,
+		RatingLevel: ratingLevel,
+		Currency:    LimitCurrency,
+		Limits:      []*models.LimitUsage{},
+	}
+
+	now := time.Now().UTC()
+	for _, limit := range limits {
+		start := periodStart(now, limit.Period)
+		used, err := service.limitRepo.GetUsage(ctx, userID, limit.Kind, start)
+		if err != nil {
+			return nil, err
+		}
+
+		usage := &models.LimitUsage{
+			Limit:    *limit,
+			Used:     used,
+			ResetsAt: nextPeriodStart(start, limit.Period),
+		}
+		if used < limit.Amount {
+			usage.Remaining = limit.Amount - used
+		}
+		userLimits.Limits = append(userLimits.Limits, usage)
+	}
+
+	return userLimits, nil
+}
+
+// ChargeLimit counts amount, given in currency, against the limits of the
+// user. The charge must be released if the operation does not go through.
+func (service *LimitService) ChargeLimit(ctx context.Context, userID int, kind, currency string, amount float64, reference string) (*models.LimitCharge, error) {
+	if err := validateLimitKind(kind); err != nil {
+		return nil, err
+	}
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	value, err := service.limitValue(ctx, currency, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	periodStarts := map[string]time.Time{
+		models.LimitDaily:   periodStart(now, models.LimitDaily),
+		models.LimitMonthly: periodStart(now, models.LimitMonthly),
+	}
+
+	charge := &models.LimitCharge{UserID: userID, Kind: kind, Amount: value, Reference: reference}
+	if err := service.limitRepo.Charge(ctx, charge, periodStarts); err != nil {
+		return nil, err
+	}
+	return charge, nil
+}
+
+func (service *LimitService) ReleaseLimit(ctx context.Context, chargeID int64) error {
+	return service.limitRepo.ReleaseCharge(ctx, chargeID)
+}
+
+// chargeAccount charges the owner of the account. Accounts outside of any
+// wallet are not limited, and neither is moving money to another account of
+// the same user, so the returned charge may be nil.
+func (service *LimitService) chargeAccount(ctx context.Context, account *models.Account, kind string, amount float64, counterparty string) (*models.LimitCharge, error) {
+	userID, err := service.walletRepo.GetUserIDByAccount(ctx, account.AccountNumber)
+	if err != nil || userID == 0 {
+		return nil, err
+	}
+
+	own, err := service.ownedBy(ctx, counterparty, userID)
+	if err != nil || own {
+		return nil, err
+	}
+
+	return service.ChargeLimit(ctx, userID, kind, account.Currency, amount, account.AccountNumber)
+}
+
+// ownedBy tells whether the account belongs to the user. Funds moving
+// between the accounts of one user do not count towards the limits.
+func (service *LimitService) ownedBy(ctx context.Context, accountNumber string, userID int) (bool, error) {
+	if accountNumber == "" {
+		return false, nil
+	}
+	ownerID, err := service.walletRepo.GetUserIDByAccount(ctx, accountNumber)
+	if err != nil {
+		return false, err
+	}
+	return ownerID == userID, nil
+}
+
+// release is best effort, a charge that could not be released only makes the
+// limit stricter until the period ends.
+func (service *LimitService) release(ctx context.Context, charge *models.LimitCharge) {
+	if charge != nil {
+		_ = service.limitRepo.ReleaseCharge(ctx, charge.ID)
+	}
+}
+
+func (service *LimitService) SetOverride(ctx context.Context, override *models.LimitOverride) error {
+	if err := validateLimitKind(override.Kind); err != nil {
+		return err
+	}
+	if err := validateLimitPeriod(override.Period); err != nil {
+		return err
+	}
+	if override.Amount < 0 {
+		return ErrInvalidLimitAmount
+	}
+	return service.limitRepo.SetOverride(ctx, override)
+}
+
+func (service *LimitService) DeleteOverride(ctx context.Context, userID int, kind, period string) error {
+	return service.limitRepo.DeleteOverride(ctx, userID, kind, period)
+}
+
+// SetLevels stores the access and rating level of a user as assigned by the
+// auth service.
+func (service *LimitService) SetLevels(ctx context.Context, userID, accessLevel, ratingLevel int) error {
+	if accessLevel < 1 || accessLevel > 3 || ratingLevel < 0 || ratingLevel > 9 {
+		return ErrInvalidLevel
+	}
+	return service.limitRepo.SetLevels(ctx, userID, accessLevel, ratingLevel)
+}
+
+func (service *LimitService) limitValue(ctx context.Context, currency string, amount float64) (float64, error) {
+	limitCurrency, err := service.currencyService.GetCurrency(ctx, LimitCurrency)
+	if err != nil {
+		return 0, err
+	}
+
+	rate, err := service.rates.Rate(ctx, currency, LimitCurrency)
+	if err != nil {
+		return 0, err
+	}
+	return limitCurrency.Round(amount * rate.Value), nil
+}
+
+func validateLimitKind(kind string) error {
+	switch kind {
+	case models.LimitWithdrawal, models.LimitTransfer, models.LimitOrder:
+		return nil
+	}
+	return ErrInvalidLimitKind
+}
+
+func validateLimitPeriod(period string) error {
+	if period != models.LimitDaily && period != models.LimitMonthly {
+		return ErrInvalidLimitPeriod
+	}
+	return nil
+}
+
+func periodStart(t time.Time, period string) time.Time {
+	year, month, day := t.Date()
+	if period == models.LimitMonthly {
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+const (
+	maxOrganizationNameLength = 100
+	organizationActivityLimit = 100
+)
+
+var (
+	ErrInvalidOrganizationName = errors.New("organization name must be 1 to 100 characters")
+	ErrInvalidOrganizationRole = errors.New("role must be viewer, initiator or approver")
+	ErrInvalidThreshold        = errors.New("threshold must not be negative")
+	ErrInsufficientRole        = errors.New("member role does not allow this action")
+	ErrNotOrganizationAccount  = errors.New("account does not belong to the organization")
+	// ErrSelfTransferApproval enforces maker-checker on organization
+	// transfers the same way ErrSelfApproval does on withdrawals.
+	ErrSelfTransferApproval = errors.New("a transfer cannot be approved by its initiator")
+)
+
+var organizationRoleRanks = map[string]int{
+	models.OrganizationViewer:    1,
+	models.OrganizationInitiator: 2,
+	models.OrganizationApprover:  3,
+}
+
+// OrganizationService manages accounts shared by the members of an
+// organization. Every call names the member acting, who must hold at least
+// the role the action needs, and the activity log records them.
+type OrganizationService struct {
+	organizationRepo *repositories.OrganizationRepository
+	currencyService  *CurrencyService
+	walletService    *WalletService
+}
+
+func NewOrganizationService(organizationRepo *repositories.OrganizationRepository, currencyService *CurrencyService, walletService *WalletService) *OrganizationService {
+	return &OrganizationService{
+		organizationRepo: organizationRepo,
+		currencyService:  currencyService,
+		walletService:    walletService,
+	}
+}
+
+func (service *OrganizationService) CreateOrganization(ctx context.Context, userID int, name string) (*models.Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxOrganizationNameLength {
+		return nil, ErrInvalidOrganizationName
+	}
+	return service.organizationRepo.CreateOrganization(ctx, name, userID)
+}
+
+func (service *OrganizationService) GetOrganization(ctx context.Context, orgID int64, userID int) (*models.Organization, error) {
+	if err := service.authorize(ctx, orgID, userID, models.OrganizationViewer); err != nil {
+		return nil, err
+	}
+	return service.organizationRepo.GetOrganization(ctx, orgID)
+}
+
+// SetMember adds a member or changes their role. The organization always
+// keeps at least one approver.
+func (service *OrganizationService) SetMember(ctx context.Context, orgID int64, userID, memberID int, role string) (*models.OrganizationMember, error) {
+	if _, ok := organizationRoleRanks[role]; !ok {
+		return nil, ErrInvalidOrganizationRole
+	}
+	if err := service.authorize(ctx, orgID, userID, models.OrganizationApprover); err != nil {
+		return nil, err
+	}
+	return service.organizationRepo.SetMember(ctx, orgID, userID, memberID, role)
+}
+
+func (service *OrganizationService) RemoveMember(ctx context.Context, orgID int64, userID, memberID int) error {
+	if err := service.authorize(ctx, orgID, userID, models.OrganizationApprover); err != nil {
+		return err
+	}
+	return service.organizationRepo.RemoveMember(ctx, orgID, userID, memberID)
+}
+
+func (service *OrganizationService) OpenAccount(ctx context.Context, orgID int64, userID int, currencyCode string) (*models.Account, error) {
+	if err := service.authorize(ctx, orgID, userID, models.OrganizationApprover); err != nil {
+		return nil, err
+	}
+	currency, err := service.currencyService.RequireCurrency(ctx, currencyCode)
+	if err != nil {
+		return nil, err
+	}
+	return service.organizationRepo.AddAccount(ctx, orgID, userID, currency.Code)
+}
+
+func (service *OrganizationService) ListAccounts(ctx context.Context, orgID int64, userID int) ([]*models.Account, error) {
+	if err := service.authorize(ctx, orgID, userID, models.OrganizationViewer); err != nil {
+		return nil, err
+	}
+
+	numbers, err := service.organizationRepo.ListAccounts(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]*models.Account, 0, len(numbers))
+	for _, number := range numbers {
+		account, err := service.walletService.GetAccount(ctx, number)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+// SetThreshold sets the amount up to which transfers in the currency go out
+// without approval. Zero makes every transfer need approval.
+func (service *OrganizationService) SetThreshold(ctx context.Context, orgID int64, userID int, currencyCode string, amount float64) error {
+	if amount < 0 {
+		return ErrInvalidThreshold
+	}
+	if err := service.authorize(ctx, orgID, userID, models.OrganizationApprover); err != nil {
+		return err
+	}
+	currency, err := service.currencyService.GetCurrency(ctx, currencyCode)
+	if err != nil {
+		return err
+	}
+	return service.organizationRepo.SetThreshold(ctx, orgID, userID, currency.Code, amount)
+}
+
+// InitiateTransfer starts a transfer from an organization account. Transfers
+// within the threshold of their currency are executed right away, the rest
+// wait for another member to approve them.
+func (service *OrganizationService) InitiateTransfer(ctx context.Context, orgID int64, userID int, senderAccountNumber, receiverAccountNumber string, amount float64, memo string) (*models.OrganizationTransfer, error) {
+	memo = strings.TrimSpace(memo)
+	if utf8.RuneCountInString(memo) > maxMemoLength {
+		return nil, ErrMemoTooLong
+	}
+	if senderAccountNumber == receiverAccountNumber {
+		return nil, ErrSameAccount
+	}
+	if err := service.authorize(ctx, orgID, userID, models.OrganizationInitiator); err != nil {
+		return nil, err
+	}
+
+	owner, err := service.organizationRepo.GetOrganizationIDByAccount(ctx, senderAccountNumber)
+	if err != nil {
+		return nil, err
+	}
+	if owner != orgID {
+		return nil, ErrNotOrganizationAccount
+	}
+
+	sender, currency, amount, err := service.walletService.accountAmount(ctx, senderAccountNumber, amount)
+	if err != nil {
+		return nil, err
+	}
+	receiver, err := service.walletService.GetAccount(ctx, receiverAccountNumber)
+	if err != nil {
+		return nil, err
+	}
+	if receiver.Currency != sender.Currency {
+		return nil, ErrCurrencyMismatch
+	}
+
+	org, err := service.organizationRepo.GetOrganization(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	threshold, ok := org.Thresholds[currency.Code]
+
+	t, err := service.organizationRepo.CreateTransfer(ctx, &models.OrganizationTransfer{
+		OrganizationID:        orgID,
+		SenderAccountNumber:   sender.AccountNumber,
+		ReceiverAccountNumber: receiverAccountNumber,
+		Currency:              currency.Code,
+		Amount:                amount,
+		Memo:                  memo,
+		RequiresApproval:      !ok || amount > threshold,
+		InitiatedBy:           userID,
+	})
+	if err != nil || t.RequiresApproval {
+		return t, err
+	}
+
+	executed, err := service.execute(ctx, t, userID, nil)
+	if err != nil {
+		if _, closeErr := service.organizationRepo.CloseTransfer(ctx, orgID, t.ID, userID, models.OrganizationTransferFailed, err.Error()); closeErr != nil {
+			return nil, closeErr
+		}
+		return nil, err
+	}
+	return executed, nil
+}
+
+// ApproveTransfer executes a pending transfer. The approver must be another
+// member than the initiator. If the transfer fails it stays pending, so it
+// can be approved again once the account is funded or rejected.
+func (service *OrganizationService) ApproveTransfer(ctx context.Context, orgID, transferID int64, userID int) (*models.OrganizationTransfer, error) {
+	if err := service.authorize(ctx, orgID, userID, models.OrganizationApprover); err != nil {
+		return nil, err
+	}
+
+	t, err := service.organizationRepo.GetTransfer(ctx, orgID, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if t.InitiatedBy == userID {
+		return nil, ErrSelfTransferApproval
+	}
+	return service.execute(ctx, t, userID, &userID)
+}
+
+func (service *OrganizationService) RejectTransfer(ctx context.Context, orgID, transferID int64, userID int, reason string) (*models.OrganizationTransfer, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	if err := service.authorize(ctx, orgID, userID, models.OrganizationApprover); err != nil {
+		return nil, err
+	}
+	return service.organizationRepo.CloseTransfer(ctx, orgID, transferID, userID, models.OrganizationTransferRejected, reason)
+}
+
+// CancelTransfer withdraws a pending transfer. Only its initiator can.
+func (service *OrganizationService) CancelTransfer(ctx context.Context, orgID, transferID int64, userID int) (*models.OrganizationTransfer, error) {
+	if err := service.authorize(ctx, orgID, userID, models.OrganizationInitiator); err != nil {
+		return nil, err
+	}
+
+	t, err := service.organizationRepo.GetTransfer(ctx, orgID, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if t.InitiatedBy != userID {
+		return nil, ErrInsufficientRole
+	}
+	return service.organizationRepo.CloseTransfer(ctx, orgID, transferID, userID, models.OrganizationTransferCancelled, "")
+}
+
+func (service *OrganizationService) GetTransfer(ctx context.Context, orgID, transferID int64, userID int) (*models.OrganizationTransfer, error) {
+	if err := service.authorize(ctx, orgID, userID, models.OrganizationViewer); err != nil {
+		return nil, err
+	}
+	return service.organizationRepo.GetTransfer(ctx, orgID, transferID)
+}
+
+func (service *OrganizationService) ListTransfers(ctx context.Context, orgID int64, userID int, statuses []string) ([]*models.OrganizationTransfer, error) {
+	if err := service.authorize(ctx, orgID, userID, models.OrganizationViewer); err != nil {
+		return nil, err
+	}
+	return service.organizationRepo.ListTransfers(ctx, orgID, statuses)
+}
+
+// ListActivity returns the latest actions taken in the organization.
+func (service *OrganizationService) ListActivity(ctx context.Context, orgID int64, userID int) ([]*models.OrganizationActivity, error) {
+	if err := service.authorize(ctx, orgID, userID, models.OrganizationViewer); err != nil {
+		return nil, err
+	}
+	return service.organizationRepo.ListActivity(ctx, orgID, organizationActivityLimit)
+}
+
+// execute moves the money in the transaction that locks the transfer row,
+// so two approvers cannot both pay it.
+func (service *OrganizationService) execute(ctx context.Context, t *models.OrganizationTransfer, userID int, approverID *int) (*models.OrganizationTransfer, error) {
+	plan, err := service.walletService.planTransfer(ctx, t.SenderAccountNumber, t.ReceiverAccountNumber, t.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	executed, err := service.organizationRepo.ExecuteTransfer(ctx, t.OrganizationID, t.ID, userID, approverID, plan.amount, plan.fee, plan.houseAccount)
+	if err != nil {
+		service.walletService.abandonTransfer(ctx, plan)
+		return nil, err
+	}
+	executed.Receipt = plan.receipt()
+	return executed, nil
+}
+
+// authorize checks that the user is a member with at least the role.
+func (service *OrganizationService) authorize(ctx context.Context, orgID int64, userID int, role string) error {
+	member, err := service.organizationRepo.GetMember(ctx, orgID, userID)
+	if err != nil {
+		return err
+	}
+	if organizationRoleRanks[member.Role] < organizationRoleRanks[role] {
+		return ErrInsufficientRole
+	}
+	return nil
+}
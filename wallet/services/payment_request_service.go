@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+const (
+	defaultPaymentRequestExpiry = 7 * 24 * time.Hour
+	maxPaymentRequestExpiry     = 90 * 24 * time.Hour
+)
+
+var ErrInvalidExpiry = errors.New("expiry must be in the future and at most 90 days away")
+
+// PaymentRequestService lets users ask others for money. Paying a request
+// is an ordinary transfer to the account that asked for it.
+type PaymentRequestService struct {
+	paymentRequestRepo *repositories.PaymentRequestRepository
+	accountRepo        *repositories.AccountRepository
+	walletService      *WalletService
+}
+
+func NewPaymentRequestService(paymentRequestRepo *repositories.PaymentRequestRepository, accountRepo *repositories.AccountRepository, walletService *WalletService) *PaymentRequestService {
+	return &PaymentRequestService{
+		paymentRequestRepo: paymentRequestRepo,
+		accountRepo:        accountRepo,
+		walletService:      walletService,
+	}
+}
+
+// CreatePaymentRequest asks for amount to be paid into the account. The
+// currency, if given, must be the account's. Requests expire after a week
+// unless expiresAt says otherwise.
+func (service *PaymentRequestService) CreatePaymentRequest(ctx context.Context, accountNumber, currency string, amount float64, memo string, expiresAt *time.Time) (*models.PaymentRequest, error) {
+	memo = strings.TrimSpace(memo)
+	if utf8.RuneCountInString(memo) > maxMemoLength {
+		return nil, ErrMemoTooLong
+	}
+
+	now := time.Now().UTC()
+	expiry := now.Add(defaultPaymentRequestExpiry)
+	if expiresAt != nil {
+		expiry = expiresAt.UTC()
+	}
+	if !expiry.After(now) || expiry.Sub(now) > maxPaymentRequestExpiry {
+		return nil, ErrInvalidExpiry
+	}
+
+	account, accountCurrency, amount, err := service.walletService.accountAmount(ctx, accountNumber, amount)
+	if err != nil {
+		return nil, err
+	}
+	if currency != "" && strings.ToUpper(currency) != account.Currency {
+		return nil, ErrCurrencyMismatch
+	}
+
+	return service.paymentRequestRepo.CreatePaymentRequest(ctx, &models.PaymentRequest{
+		PayeeAccountNumber: account.AccountNumber,
+		Currency:           accountCurrency.Code,
+		Amount:             amount,
+		Memo:               memo,
+		ExpiresAt:          expiry,
+	})
+}
+
+func (service *PaymentRequestService) GetPaymentRequest(ctx context.Context, reference string) (*models.PaymentRequest, error) {
+	return service.paymentRequestRepo.GetPaymentRequest(ctx, reference)
+}
+
+// ListPaymentRequests returns the requests the account made or paid.
+func (service *PaymentRequestService) ListPaymentRequests(ctx context.Context, accountNumber string) ([]*models.PaymentRequest, error) {
+	if _, err := service.accountRepo.GetAccountByNumber(ctx, accountNumber); err != nil {
+		return nil, err
+	}
+	return service.paymentRequestRepo.ListAccountPaymentRequests(ctx, accountNumber)
+}
+
+func (service *PaymentRequestService) Cancel(ctx context.Context, reference string) (*models.PaymentRequest, error) {
+	return service.paymentRequestRepo.Cancel(ctx, reference)
+}
+
+// Pay settles the open request from the payer's account. The payer pays the
+// transfer fee on top of the requested amount. The transfer and the request
+// being marked paid are one transaction, so a request is paid at most once.
+func (service *PaymentRequestService) Pay(ctx context.Context, reference, payerAccountNumber string) (*models.PaidPaymentRequest, error) {
+	pr, err := service.paymentRequestRepo.GetPaymentRequest(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+	if pr.Status != models.PaymentRequestOpen {
+		return nil, repositories.ErrPaymentRequestNotOpen
+	}
+
+	plan, err := service.walletService.planTransfer(ctx, payerAccountNumber, pr.PayeeAccountNumber, pr.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	paid, err := service.paymentRequestRepo.Settle(ctx, reference, payerAccountNumber, plan.amount, plan.fee, plan.houseAccount)
+	if err != nil {
+		service.walletService.abandonTransfer(ctx, plan)
+		return nil, err
+	}
+	return &models.PaidPaymentRequest{PaymentRequest: paid, Receipt: plan.receipt()}, nil
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+	"wallet/accountnumber"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+const maxMemoLength = 140
+
+var (
+	ErrRecipientRequired = errors.New("recipient username or email is required")
+	ErrRecipientNotFound = errors.New("recipient not found")
+	ErrSelfPayment       = errors.New("cannot pay yourself")
+	ErrMemoTooLong       = errors.New("memo is too long")
+)
+
+// UserDirectory finds users of the auth service by username or email.
+type UserDirectory interface {
+	LookupUser(ctx context.Context, identifier string) (*models.DirectoryUser, error)
+}
+
+// PaymentService pays other users by username or email.
+type PaymentService struct {
+	paymentRepo   *repositories.PaymentRepository
+	walletRepo    *repositories.WalletRepository
+	accountRepo   *repositories.AccountRepository
+	walletService *WalletService
+	limitService  *LimitService
+	feeService    *FeeService
+	directory     UserDirectory
+}
+
+func NewPaymentService(paymentRepo *repositories.PaymentRepository, walletRepo *repositories.WalletRepository, accountRepo *repositories.AccountRepository, walletService *WalletService, limitService *LimitService, feeService *FeeService, directory UserDirectory) *PaymentService {
+	return &PaymentService{
+		paymentRepo:   paymentRepo,
+		walletRepo:    walletRepo,
+		accountRepo:   accountRepo,
+		walletService: walletService,
+		limitService:  limitService,
+		feeService:    feeService,
+		directory:     directory,
+	}
+}
+
+// Pay sends amount from the account to the user with the username or email.
+// The money goes to the recipient's account in the same currency, which is
+// opened if the recipient has none. The sender pays the transfer fee.
+func (service *PaymentService) Pay(ctx context.Context, senderAccountNumber, recipient string, amount float64, memo string) (*models.PaymentReceipt, error) {
+	recipient = strings.TrimSpace(recipient)
+	if recipient == "" {
+		return nil, ErrRecipientRequired
+	}
+	memo = strings.TrimSpace(memo)
+	if utf8.RuneCountInString(memo) > maxMemoLength {
+		return nil, ErrMemoTooLong
+	}
+
+	sender, currency, amount, err := service.walletService.accountAmount(ctx, senderAccountNumber, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := service.directory.LookupUser(ctx, recipient)
+	if err != nil {
+		return nil, err
+	}
+	receiver, err := service.recipientAccount(ctx, sender, user, true)
+	if err != nil {
+		return nil, err
+	}
+
+	fee, err := service.feeService.Quote(ctx, models.FeeTransfer, sender, amount)
+	if err != nil {
+		return nil, err
+	}
+	houseAccount, err := service.feeService.houseAccount(ctx, fee)
+	if err != nil {
+		return nil, err
+	}
+
+	charge, err := service.limitService.chargeAccount(ctx, sender, models.LimitTransfer, amount, receiver.AccountNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	payment := &models.Payment{
+		SenderAccountNumber:    sender.AccountNumber,
+		RecipientAccountNumber: receiver.AccountNumber,
+		RecipientUsername:      user.Username,
+		Currency:               currency.Code,
+		Amount:                 amount,
+		Memo:                   memo,
+	}
+	if fee != nil {
+		payment.Fee = fee.Amount
+	}
+	payment, err = service.paymentRepo.CreatePayment(ctx, payment, fee, houseAccount)
+	if err != nil {
+		service.limitService.release(ctx, charge)
+		return nil, err
+	}
+
+	senderReceipt := newReceipt(models.PaymentSent, sender, receiver.AccountNumber, amount, currency, fee)
+	recipientReceipt := newReceipt(models.PaymentReceived, receiver, sender.AccountNumber, amount, currency)
+	recipientReceipt.Total = 0
+	senderReceipt.CreatedAt = payment.CreatedAt
+	recipientReceipt.CreatedAt = payment.CreatedAt
+	return &models.PaymentReceipt{Payment: payment, Sender: senderReceipt, Recipient: recipientReceipt}, nil
+}
+
+func (service *PaymentService) GetPayment(ctx context.Context, id int64) (*models.Payment, error) {
+	return service.paymentRepo.GetPayment(ctx, id)
+}
+
+func (service *PaymentService) ListPayments(ctx context.Context, accountNumber string, limit int) ([]*models.Payment, error) {
+	if _, err := service.accountRepo.GetAccountByNumber(ctx, accountNumber); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return service.paymentRepo.ListAccountPayments(ctx, accountNumber, limit)
+}
+
+// recipientAccount picks the recipient's active account in the currency of
+// the sender's account. With open, recipients without a wallet or without
+// an account in the currency get one; otherwise the account is nil then.
+func (service *PaymentService) recipientAccount(ctx context.Context, sender *models.Account, user *models.DirectoryUser, open bool) (*models.Account, error) {
+	wallet, err := service.walletRepo.GetWalletByAuthUserID(ctx, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if open && !holdsCurrency(wallet, sender.Currency) {
+		wallet, err = service.walletRepo.ProvisionWallet(ctx, user.UserID, user.Username, []string{sender.Currency})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if wallet == nil {
+		return nil, nil
+	}
+
+	senderUserID, err := service.walletRepo.GetUserIDByAccount(ctx, sender.AccountNumber)
+	if err != nil {
+		return nil, err
+	}
+	if senderUserID == wallet.UserID {
+		return nil, ErrSelfPayment
+	}
+
+	var fallback *models.Account
+	for _, accountNumber := range wallet.Accounts {
+		if accountnumber.Currency(accountNumber) != sender.Currency {
+			continue
+		}
+		account, err := service.accountRepo.GetAccountByNumber(ctx, accountNumber)
+		if err != nil {
+			return nil, err
+		}
+		if account.Status == models.AccountActive {
+			return account, nil
+		}
+		if fallback == nil {
+			fallback = account
+		}
+	}
+	// Only frozen or closed accounts are left, the transfer reports why.
+	return fallback, nil
+}
+
+func holdsCurrency(wallet *models.Wallet, currency string) bool {
+	if wallet == nil {
+		return false
+	}
+	for _, accountNumber := range wallet.Accounts {
+		if accountnumber.Currency(accountNumber) == currency {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"wallet/models"
+)
+
+// Payout file formats. CSV files start with a header naming the recipient,
+// amount, currency and reference columns; JSON lines files hold one object
+// with these fields per line.
+const (
+	PayoutFormatCSV   = "csv"
+	PayoutFormatJSONL = "jsonl"
+)
+
+const maxPayoutLines = 1000
+
+var (
+	ErrInvalidPayoutFile  = errors.New("payout file must be CSV with a recipient, amount and reference header, or JSON lines")
+	ErrTooManyPayoutLines = errors.New("payout file has more than 1000 lines")
+)
+
+// ParsePayoutFile reads the lines of a payout file. Lines that cannot be
+// read are returned as invalid, so they show up in the results.
+func ParsePayoutFile(r io.Reader, format string) ([]*models.PayoutLine, error) {
+	var lines []*models.PayoutLine
+	var err error
+	switch format {
+	case PayoutFormatCSV:
+		lines, err = parsePayoutCSV(r)
+	case PayoutFormatJSONL:
+		lines, err = parsePayoutJSONL(r)
+	default:
+		return nil, ErrInvalidPayoutFile
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) > maxPayoutLines {
+		return nil, ErrTooManyPayoutLines
+	}
+	return lines, nil
+}
+
+func parsePayoutCSV(r io.Reader) ([]*models.PayoutLine, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, ErrInvalidPayoutFile
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"recipient", "amount", "reference"} {
+		if _, ok := columns[name]; !ok {
+			return nil, ErrInvalidPayoutFile
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var lines []*models.PayoutLine
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line := &models.PayoutLine{Status: models.PayoutLineValid}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			line.Line, line.Status, line.Error = parseErr.Line, models.PayoutLineInvalid, parseErr.Err.Error()
+			lines = append(lines, line)
+			if len(lines) > maxPayoutLines {
+				return lines, nil
+			}
+			continue
+		}
+
+		line.Line, _ = reader.FieldPos(0)
+		line.Recipient = field(record, "recipient")
+		line.Currency = field(record, "currency")
+		line.Reference = field(record, "reference")
+		if line.Amount, err = strconv.ParseFloat(field(record, "amount"), 64); err != nil {
+			line.Status, line.Error = models.PayoutLineInvalid, "invalid amount"
+		}
+		lines = append(lines, line)
+		if len(lines) > maxPayoutLines {
+			return lines, nil
+		}
+	}
+	return lines, nil
+}
+
+func parsePayoutJSONL(r io.Reader) ([]*models.PayoutLine, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []*models.PayoutLine
+	for number := 1; scanner.Scan(); number++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var entry struct {
+			Recipient string  `json:"recipient"`
+			Amount    float64 `json:"amount"`
+			Currency  string  `json:"currency"`
+			Reference string  `json:"reference"`
+		}
+		line := &models.PayoutLine{Line: number, Status: models.PayoutLineValid}
+		if err := json.Unmarshal([]byte(text), &entry); err != nil {
+			line.Status, line.Error = models.PayoutLineInvalid, fmt.Sprintf("invalid JSON: %v", err)
+		} else {
+			line.Recipient = strings.TrimSpace(entry.Recipient)
+			line.Amount = entry.Amount
+			line.Currency = strings.TrimSpace(entry.Currency)
+			line.Reference = strings.TrimSpace(entry.Reference)
+		}
+		lines = append(lines, line)
+		if len(lines) > maxPayoutLines {
+			return lines, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, ErrInvalidPayoutFile
+	}
+	return lines, nil
+}
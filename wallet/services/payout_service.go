@@ -0,0 +1,382 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"wallet/accountnumber"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+const maxPayoutReferenceLength = 100
+
+var (
+	ErrInvalidPayoutMode = errors.New("mode must be all_or_nothing or best_effort")
+	ErrEmptyPayout       = errors.New("payout has no lines")
+)
+
+// PayoutService pays many recipients from one account. Every line carries a
+// reference that is paid at most once per account, so a payout file can be
+// submitted again after a failure without paying anyone twice.
+type PayoutService struct {
+	payoutRepo      *repositories.PayoutRepository
+	accountRepo     *repositories.AccountRepository
+	currencyService *CurrencyService
+	paymentService  *PaymentService
+	limitService    *LimitService
+	feeService      *FeeService
+}
+
+func NewPayoutService(payoutRepo *repositories.PayoutRepository, accountRepo *repositories.AccountRepository, currencyService *CurrencyService, paymentService *PaymentService, limitService *LimitService, feeService *FeeService) *PayoutService {
+	return &PayoutService{
+		payoutRepo:      payoutRepo,
+		accountRepo:     accountRepo,
+		currencyService: currencyService,
+		paymentService:  paymentService,
+		limitService:    limitService,
+		feeService:      feeService,
+	}
+}
+
+// Submit validates the lines and, unless it is a dry run, pays them from
+// the account. A dry run returns a preview of what would be paid. An all or
+// nothing payout with invalid lines is returned rejected and pays nothing.
+func (service *PayoutService) Submit(ctx context.Context, accountNumber string, lines []*models.PayoutLine, mode string, dryRun bool) (*models.Payout, error) {
+	if mode != models.PayoutAllOrNothing && mode != models.PayoutBestEffort {
+		return nil, ErrInvalidPayoutMode
+	}
+	if len(lines) == 0 {
+		return nil, ErrEmptyPayout
+	}
+
+	sender, err := service.accountRepo.GetAccountByNumber(ctx, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	currency, err := service.currencyService.RequireCurrency(ctx, sender.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	payout := &models.Payout{
+		AccountNumber: sender.AccountNumber,
+		Currency:      currency.Code,
+		Mode:          mode,
+		LineCount:     len(lines),
+		Available:     sender.Available,
+		Lines:         lines,
+	}
+	if err := service.validate(ctx, payout, sender, currency, !dryRun); err != nil {
+		return nil, err
+	}
+
+	invalid := false
+	for _, line := range lines {
+		invalid = invalid || line.Status == models.PayoutLineInvalid
+	}
+	switch {
+	case dryRun:
+		payout.Status = models.PayoutPreview
+		service.summarize(payout, currency, models.PayoutLineValid)
+		return payout, nil
+	case invalid && mode == models.PayoutAllOrNothing:
+		payout.Status = models.PayoutRejected
+		service.summarize(payout, currency, models.PayoutLineValid)
+		return payout, nil
+	case mode == models.PayoutAllOrNothing:
+		err = service.payAll(ctx, payout, sender, currency)
+	default:
+		err = service.payEach(ctx, payout, sender, currency)
+	}
+	if err != nil {
+		return nil, err
+	}
+	payout.Available = 0
+	return payout, nil
+}
+
+func (service *PayoutService) GetPayout(ctx context.Context, id int64) (*models.Payout, error) {
+	return service.payoutRepo.GetPayout(ctx, id)
+}
+
+// validate resolves the recipients and quotes the fees of the lines. Lines
+// that cannot be paid are marked invalid, lines paid before duplicate.
+// Recipients without an account in the currency get one if open is set.
+func (service *PayoutService) validate(ctx context.Context, payout *models.Payout, sender *models.Account, currency *models.Currency, open bool) error {
+	seen := make(map[string]bool)
+	var references []string
+	for _, line := range payout.Lines {
+		if line.Status == models.PayoutLineInvalid {
+			continue
+		}
+		reason, err := service.validateLine(ctx, line, sender, currency, open)
+		if err != nil {
+			return err
+		}
+		if reason == "" && seen[line.Reference] {
+			reason = "reference appears more than once"
+		}
+		if reason != "" {
+			line.Status, line.Error = models.PayoutLineInvalid, reason
+			continue
+		}
+		seen[line.Reference] = true
+		references = append(references, line.Reference)
+	}
+
+	paid, err := service.payoutRepo.PaidLines(ctx, sender.AccountNumber, references)
+	if err != nil {
+		return err
+	}
+	for _, line := range payout.Lines {
+		if earlier, ok := paid[line.Reference]; ok && line.Status == models.PayoutLineValid {
+			line.Status, line.Error = models.PayoutLineDuplicate, "reference was paid before"
+			line.RecipientAccountNumber, line.Fee, line.FeeItem = earlier.RecipientAccountNumber, earlier.Fee, nil
+		}
+	}
+	return nil
+}
+
+// validateLine returns why the line cannot be paid, or "" if it can. Only
+// unexpected failures are returned as errors.
+func (service *PayoutService) validateLine(ctx context.Context, line *models.PayoutLine, sender *models.Account, currency *models.Currency, open bool) (string, error) {
+	switch {
+	case line.Reference == "":
+		return "reference is required", nil
+	case len(line.Reference) > maxPayoutReferenceLength:
+		return "reference is too long", nil
+	case line.Recipient == "":
+		return ErrRecipientRequired.Error(), nil
+	}
+	if line.Currency == "" {
+		line.Currency = currency.Code
+	}
+	if strings.ToUpper(line.Currency) != currency.Code {
+		return ErrCurrencyMismatch.Error(), nil
+	}
+	line.Currency = currency.Code
+
+	amount, err := service.currencyService.NormalizeAmount(currency, line.Amount)
+	if err != nil {
+		return err.Error(), nil
+	}
+	line.Amount = amount
+
+	receiver, err := service.recipient(ctx, line.Recipient, sender, open)
+	if isLineError(err) {
+		return err.Error(), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if receiver != nil {
+		line.RecipientAccountNumber = receiver.AccountNumber
+	}
+
+	line.FeeItem, err = service.feeService.Quote(ctx, models.FeeTransfer, sender, amount)
+	if err != nil {
+		return "", err
+	}
+	if line.FeeItem != nil {
+		line.Fee = line.FeeItem.Amount
+	}
+	return "", nil
+}
+
+// recipient finds the account a line pays into. Recipients are account
+// numbers or the username or email of a user.
+func (service *PayoutService) recipient(ctx context.Context, recipient string, sender *models.Account, open bool) (*models.Account, error) {
+	if accountNumber, err := accountnumber.Parse(recipient); err == nil {
+		if accountNumber == sender.AccountNumber {
+			return nil, ErrSameAccount
+		}
+		receiver, err := service.accountRepo.GetAccountByNumber(ctx, accountNumber)
+		if err != nil {
+			return nil, err
+		}
+		if receiver.Currency != sender.Currency {
+			return nil, ErrCurrencyMismatch
+		}
+		return receiver, nil
+	}
+
+	user, err := service.paymentService.directory.LookupUser(ctx, recipient)
+	if err != nil {
+		return nil, err
+	}
+	return service.paymentService.recipientAccount(ctx, sender, user, open)
+}
+
+// payAll pays every valid line in a single transaction.
+func (service *PayoutService) payAll(ctx context.Context, payout *models.Payout, sender *models.Account, currency *models.Currency) error {
+	houseAccount, err := service.payoutHouseAccount(ctx, payout)
+	if err != nil {
+		return err
+	}
+
+	// The limit is charged for the whole payout, a payout of duplicates only
+	// pays nothing.
+	service.summarize(payout, currency, models.PayoutLineValid)
+	limited, err := service.limitedAmount(ctx, payout, sender, currency)
+	if err != nil {
+		return err
+	}
+	var charge *models.LimitCharge
+	if limited > 0 {
+		charge, err = service.limitService.chargeAccount(ctx, sender, models.LimitTransfer, limited, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	payout.Status = models.PayoutCompleted
+	for _, line := range payout.Lines {
+		if line.Status == models.PayoutLineValid {
+			payout.PaidCount++
+		}
+	}
+	if err := service.payoutRepo.ExecutePayout(ctx, payout, houseAccount); err != nil {
+		service.limitService.release(ctx, charge)
+		return err
+	}
+	return nil
+}
+
+// limitedAmount totals the valid lines that count towards the transfer
+// limit of the sender. Like payLine, it leaves out lines paid into the
+// sender's own accounts.
+func (service *PayoutService) limitedAmount(ctx context.Context, payout *models.Payout, sender *models.Account, currency *models.Currency) (float64, error) {
+	userID, err := service.limitService.walletRepo.GetUserIDByAccount(ctx, sender.AccountNumber)
+	if err != nil || userID == 0 {
+		return 0, err
+	}
+
+	total := 0.0
+	for _, line := range payout.Lines {
+		if line.Status != models.PayoutLineValid {
+			continue
+		}
+		own, err := service.limitService.ownedBy(ctx, line.RecipientAccountNumber, userID)
+		if err != nil {
+			return 0, err
+		}
+		if !own {
+			total += line.Amount
+		}
+	}
+	return currency.Round(total), nil
+}
+
+// payEach pays the valid lines one by one and records why the others were
+// not paid.
+func (service *PayoutService) payEach(ctx context.Context, payout *models.Payout, sender *models.Account, currency *models.Currency) error {
+	houseAccount, err := service.payoutHouseAccount(ctx, payout)
+	if err != nil {
+		return err
+	}
+
+	payout.Status = models.PayoutProcessing
+	if err := service.payoutRepo.CreatePayout(ctx, payout); err != nil {
+		return err
+	}
+
+	for _, line := range payout.Lines {
+		if line.Status == models.PayoutLineValid {
+			err := service.payLine(ctx, payout, line, sender, houseAccount)
+			if err == nil {
+				continue
+			}
+			if errors.Is(err, repositories.ErrDuplicateReference) {
+				line.Status, line.Error = models.PayoutLineDuplicate, "reference was paid before"
+			} else {
+				line.Status, line.Error = models.PayoutLineFailed, err.Error()
+			}
+		}
+		if err := service.payoutRepo.RecordLine(ctx, payout, line); err != nil {
+			service.abortEach(ctx, payout, currency, err)
+			return err
+		}
+	}
+	return service.finishEach(ctx, payout, currency)
+}
+
+// abortEach ends a payout that stopped half way. Nothing resumes it, so the
+// lines not reached count as failed rather than leaving it processing. The
+// file can be submitted again, paid lines are skipped as duplicates.
+func (service *PayoutService) abortEach(ctx context.Context, payout *models.Payout, currency *models.Currency, cause error) {
+	for _, line := range payout.Lines {
+		if line.Status == models.PayoutLineValid {
+			line.Status, line.Error = models.PayoutLineFailed, cause.Error()
+		}
+	}
+	_ = service.finishEach(ctx, payout, currency)
+}
+
+func (service *PayoutService) finishEach(ctx context.Context, payout *models.Payout, currency *models.Currency) error {
+	service.summarize(payout, currency, models.PayoutLinePaid)
+	switch {
+	case payout.FailedCount == 0:
+		payout.Status = models.PayoutCompleted
+	case payout.PaidCount == 0:
+		payout.Status = models.PayoutFailed
+	default:
+		payout.Status = models.PayoutPartiallyCompleted
+	}
+	return service.payoutRepo.FinishPayout(ctx, payout)
+}
+
+func (service *PayoutService) payLine(ctx context.Context, payout *models.Payout, line *models.PayoutLine, sender *models.Account, houseAccount string) error {
+	charge, err := service.limitService.chargeAccount(ctx, sender, models.LimitTransfer, line.Amount, line.RecipientAccountNumber)
+	if err != nil {
+		return err
+	}
+	if err := service.payoutRepo.PayLine(ctx, payout, line, houseAccount); err != nil {
+		service.limitService.release(ctx, charge)
+		return err
+	}
+	return nil
+}
+
+// payoutHouseAccount returns the account the fees of the payout go to. All
+// lines are in the same currency, so they share it.
+func (service *PayoutService) payoutHouseAccount(ctx context.Context, payout *models.Payout) (string, error) {
+	for _, line := range payout.Lines {
+		if line.Status == models.PayoutLineValid && line.FeeItem != nil {
+			return service.feeService.houseAccount(ctx, line.FeeItem)
+		}
+	}
+	return "", nil
+}
+
+// summarize totals the lines with the status and counts the lines that
+// were neither paid nor paid before as failed.
+func (service *PayoutService) summarize(payout *models.Payout, currency *models.Currency, status string) {
+	payout.PaidCount, payout.FailedCount = 0, 0
+	payout.TotalAmount, payout.TotalFees = 0, 0
+	for _, line := range payout.Lines {
+		switch line.Status {
+		case status:
+			payout.TotalAmount += line.Amount
+			payout.TotalFees += line.Fee
+			if status == models.PayoutLinePaid {
+				payout.PaidCount++
+			}
+		case models.PayoutLineInvalid, models.PayoutLineFailed:
+			payout.FailedCount++
+		}
+	}
+	payout.TotalAmount = currency.Round(payout.TotalAmount)
+	payout.TotalFees = currency.Round(payout.TotalFees)
+}
+
+// isLineError tells whether resolving a recipient failed because of the
+// line rather than the service.
+func isLineError(err error) bool {
+	return errors.Is(err, ErrRecipientNotFound) ||
+		errors.Is(err, ErrSelfPayment) ||
+		errors.Is(err, ErrSameAccount) ||
+		errors.Is(err, ErrCurrencyMismatch) ||
+		errors.Is(err, repositories.ErrAccountNotFound) ||
+		errors.Is(err, repositories.ErrUsernameTaken)
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+var ErrInvalidUserEvent = errors.New("user event needs a user_id and a username")
+
+// ProvisioningService opens the wallets of newly registered users.
+type ProvisioningService struct {
+	walletRepo      *repositories.WalletRepository
+	currencyService *CurrencyService
+	currencies      []string
+}
+
+// NewProvisioningService takes the currencies every new wallet gets an
+// account in, USD if none are given.
+func NewProvisioningService(walletRepo *repositories.WalletRepository, currencyService *CurrencyService, currencies []string) *ProvisioningService {
+	normalized := make([]string, 0, len(currencies))
+	for _, currency := range currencies {
+		if currency = strings.ToUpper(strings.TrimSpace(currency)); currency != "" {
+			normalized = append(normalized, currency)
+		}
+	}
+	if len(normalized) == 0 {
+		normalized = []string{"USD"}
+	}
+	return &ProvisioningService{walletRepo: walletRepo, currencyService: currencyService, currencies: normalized}
+}
+
+// ProvisionWallet handles a user registered event. Events are delivered at
+// least once, a repeated one returns the wallet provisioned before.
+func (service *ProvisioningService) ProvisionWallet(ctx context.Context, event *models.UserRegistered) (*models.Wallet, error) {
+	if event.UserID == "" || event.Username == "" {
+		return nil, ErrInvalidUserEvent
+	}
+
+	for _, code := range service.currencies {
+		if _, err := service.currencyService.RequireCurrency(ctx, code); err != nil {
+			return nil, err
+		}
+	}
+	return service.walletRepo.ProvisionWallet(ctx, event.UserID, event.Username, service.currencies)
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+	"wallet/alert"
+	"wallet/chain"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+// ReconciliationService recomputes the balances from their history and the
+// crypto holdings from the chains, and reports where they disagree.
+type ReconciliationService struct {
+	reconciliationRepo *repositories.ReconciliationRepository
+	currencyService    *CurrencyService
+	clients            map[string]chain.Client
+	broadcasters       map[string]chain.Broadcaster
+	alerts             alert.Hook
+}
+
+// NewReconciliationService takes the chain clients and broadcasters per
+// currency code. Currencies without a client are only checked against
+// their movements.
+func NewReconciliationService(reconciliationRepo *repositories.ReconciliationRepository, currencyService *CurrencyService, clients map[string]chain.Client, broadcasters map[string]chain.Broadcaster, alerts alert.Hook) *ReconciliationService {
+	return &ReconciliationService{
+		reconciliationRepo: reconciliationRepo,
+		currencyService:    currencyService,
+		clients:            clients,
+		broadcasters:       broadcasters,
+		alerts:             alerts,
+	}
+}
+
+// Run reconciles every interval until ctx is done.
+func (service *ReconciliationService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := service.Reconcile(ctx); err != nil {
+			log.Printf("reconciliation: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile checks the ledger, stores the report and raises an alert if it
+// found discrepancies.
+func (service *ReconciliationService) Reconcile(ctx context.Context) (*models.ReconciliationReport, error) {
+	report := &models.ReconciliationReport{StartedAt: time.Now().UTC(), Chains: []models.ChainDrift{}}
+
+	currencies := make([]string, 0, len(service.clients))
+	for currency := range service.clients {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	snapshot, err := service.reconciliationRepo.Snapshot(ctx, currencies)
+	if err != nil {
+		return nil, err
+	}
+	report.AccountsChecked = snapshot.AccountsChecked
+	report.Accounts = snapshot.Accounts
+	report.Discrepancies = len(snapshot.Accounts)
+
+	for _, currency := range currencies {
+		drift, err := service.reconcileChain(ctx, currency, snapshot)
+		if err != nil {
+			return nil, fmt.Errorf("reconciling %s: %w", currency, err)
+		}
+		report.Chains = append(report.Chains, *drift)
+		if drift.Drift != 0 || len(drift.Missing) > 0 {
+			report.Discrepancies++
+		}
+	}
+
+	report.FinishedAt = time.Now().UTC()
+	if err := service.reconciliationRepo.SaveReport(ctx, report); err != nil {
+		return nil, err
+	}
+
+	if report.Discrepancies > 0 {
+		summary := fmt.Sprintf("reconciliation report %d found %d discrepancies", report.ID, report.Discrepancies)
+		if err := service.alerts.Alert(ctx, alert.Alert{Source: "reconciliation", Summary: summary, Details: report}); err != nil {
+			log.Printf("reconciliation: alert: %v", err)
+		}
+	}
+	return report, nil
+}
+
+func (service *ReconciliationService) GetReport(ctx context.Context, id int64) (*models.ReconciliationReport, error) {
+	return service.reconciliationRepo.GetReport(ctx, id)
+}
+
+func (service *ReconciliationService) ListReports(ctx context.Context, limit int) ([]*models.ReconciliationReport, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return service.reconciliationRepo.ListReports(ctx, limit)
+}
+
+// reconcileChain compares the liabilities in the currency with the booked
+// deposits and withdrawals the chain still confirms.
+func (service *ReconciliationService) reconcileChain(ctx context.Context, currencyCode string, snapshot *models.LedgerSnapshot) (*models.ChainDrift, error) {
+	currency, err := service.currencyService.GetCurrency(ctx, currencyCode)
+	if err != nil {
+		return nil, err
+	}
+	client := service.clients[currencyCode]
+	drift := &models.ChainDrift{Currency: currencyCode, Liabilities: snapshot.Liabilities[currencyCode]}
+
+	// Deposits are sorted by height, so each block is fetched once.
+	var block *chain.Block
+	for _, deposit := range snapshot.Deposits {
+		if deposit.Currency != currencyCode {
+			continue
+		}
+		drift.Deposits += deposit.Amount
+
+		if block == nil || block.Height != deposit.BlockHeight {
+			block, err = client.BlockByHeight(ctx, deposit.BlockHeight)
+			if errors.Is(err, chain.ErrBlockNotFound) {
+				block, err = &chain.Block{Height: deposit.BlockHeight}, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if block.Hash == deposit.BlockHash && paysOutput(block, deposit) {
+			drift.OnChain += deposit.Amount
+		} else {
+			drift.Missing = append(drift.Missing, fmt.Sprintf("%s:%d", deposit.TxID, deposit.Vout))
+		}
+	}
+
+	broadcaster := service.broadcasters[currencyCode]
+	for _, withdrawal := range snapshot.Withdrawals {
+		if withdrawal.Currency != currencyCode {
+			continue
+		}
+		drift.Withdrawals += withdrawal.Amount
+
+		// Without a broadcaster confirmed withdrawals are taken as they are.
+		confirmations := int64(1)
+		if broadcaster != nil {
+			confirmations, err = broadcaster.Confirmations(ctx, withdrawal.TxID)
+			if errors.Is(err, chain.ErrTransactionNotFound) {
+				confirmations, err = 0, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if confirmations > 0 {
+			drift.OnChain -= withdrawal.Amount
+		} else {
+			drift.Missing = append(drift.Missing, withdrawal.TxID)
+		}
+	}
+
+	drift.Liabilities = currency.Round(drift.Liabilities)
+	drift.Deposits = currency.Round(drift.Deposits)
+	drift.Withdrawals = currency.Round(drift.Withdrawals)
+	drift.OnChain = currency.Round(drift.OnChain)
+	drift.Drift = currency.Round(drift.Liabilities - drift.OnChain)
+	return drift, nil
+}
+
+func paysOutput(block *chain.Block, deposit *models.ChainDeposit) bool {
+	for _, output := range block.Outputs {
+		if output.TxID == deposit.TxID && output.Vout == deposit.Vout {
+			return true
+		}
+	}
+	return false
+}
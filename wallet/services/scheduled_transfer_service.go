@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+	"wallet/models"
+	"wallet/repositories"
+	"wallet/schedule"
+)
+
+const (
+	minTransferInterval = time.Minute
+	// A failed occurrence is retried with a doubling delay, after the last
+	// attempt the scheduled transfer is marked as failed.
+	maxScheduleAttempts = 5
+	scheduleRetryDelay  = time.Minute
+	scheduleBatchSize   = 100
+)
+
+var (
+	ErrInvalidSchedule  = errors.New("give either run_at, interval_seconds or cron")
+	ErrIntervalTooShort = errors.New("interval must be at least one minute")
+	ErrEndBeforeStart   = errors.New("end_at must be after the first run")
+	ErrScheduleInPast   = errors.New("run_at must not be in the past")
+)
+
+type ScheduledTransferService struct {
+	scheduledTransferRepo *repositories.ScheduledTransferRepository
+	accountRepo           *repositories.AccountRepository
+	currencyService       *CurrencyService
+	limitService          *LimitService
+	feeService            *FeeService
+}
+
+func NewScheduledTransferService(scheduledTransferRepo *repositories.ScheduledTransferRepository, accountRepo *repositories.AccountRepository, currencyService *CurrencyService, limitService *LimitService, feeService *FeeService) *ScheduledTransferService {
+	return &ScheduledTransferService{
+		scheduledTransferRepo: scheduledTransferRepo,
+		accountRepo:           accountRepo,
+		currencyService:       currencyService,
+		limitService:          limitService,
+		feeService:            feeService,
+	}
+}
+
+// CreateScheduledTransfer schedules a transfer at runAt, every interval from
+// runAt on, or whenever the cron expression matches after runAt. Without
+// runAt the schedule starts now.
+func (service *ScheduledTransferService) CreateScheduledTransfer(ctx context.Context, st *models.ScheduledTransfer, runAt time.Time) (*models.ScheduledTransfer, error) {
+	if st.SenderAccountNumber == st.ReceiverAccountNumber {
+		return nil, ErrSameAccount
+	}
+
+	now := time.Now().UTC()
+	if runAt.IsZero() {
+		runAt = now
+	}
+	if runAt.Before(now.Add(-time.Minute)) {
+		return nil, ErrScheduleInPast
+	}
+
+	switch {
+	case st.Cron != "" && st.IntervalSeconds == 0:
+		cron, err := schedule.Parse(st.Cron)
+		if err != nil {
+			return nil, err
+		}
+		st.Kind = models.ScheduleCron
+		st.NextRunAt = cron.Next(runAt.Add(-time.Nanosecond))
+		if st.NextRunAt.IsZero() {
+			return nil, schedule.ErrInvalidCron
+		}
+	case st.IntervalSeconds > 0 && st.Cron == "":
+		if time.Duration(st.IntervalSeconds)*time.Second < minTransferInterval {
+			return nil, ErrIntervalTooShort
+		}
+		st.Kind = models.ScheduleInterval
+		st.NextRunAt = runAt
+	case st.IntervalSeconds == 0 && st.Cron == "":
+		st.Kind = models.ScheduleOnce
+		st.NextRunAt = runAt
+	default:
+		return nil, ErrInvalidSchedule
+	}
+	if st.EndAt != nil && !st.EndAt.After(st.NextRunAt) {
+		return nil, ErrEndBeforeStart
+	}
+
+	sender, err := service.accountRepo.GetAccountByNumber(ctx, st.SenderAccountNumber)
+	if err != nil {
+		return nil, err
+	}
+	receiver, err := service.accountRepo.GetAccountByNumber(ctx, st.ReceiverAccountNumber)
+	if err != nil {
+		return nil, err
+	}
+	if sender.Currency != receiver.Currency {
+		return nil, ErrCurrencyMismatch
+	}
+
+	currency, err := service.currencyService.RequireCurrency(ctx, sender.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if st.Amount, err = service.currencyService.NormalizeAmount(currency, st.Amount); err != nil {
+		return nil, err
+	}
+
+	return service.scheduledTransferRepo.CreateScheduledTransfer(ctx, st)
+}
+
+func (service *ScheduledTransferService) GetScheduledTransfer(ctx context.Context, id int64) (*models.ScheduledTransfer, error) {
+	return service.scheduledTransferRepo.GetScheduledTransfer(ctx, id)
+}
+
+func (service *ScheduledTransferService) ListScheduledTransfers(ctx context.Context, accountNumber string) ([]*models.ScheduledTransfer, error) {
+	if _, err := service.accountRepo.GetAccountByNumber(ctx, accountNumber); err != nil {
+		return nil, err
+	}
+	return service.scheduledTransferRepo.ListScheduledTransfers(ctx, accountNumber)
+}
+
+func (service *ScheduledTransferService) ListRuns(ctx context.Context, id int64) ([]*models.ScheduledTransferRun, error) {
+	if _, err := service.scheduledTransferRepo.GetScheduledTransfer(ctx, id); err != nil {
+		return nil, err
+	}
+	return service.scheduledTransferRepo.ListRuns(ctx, id)
+}
+
+func (service *ScheduledTransferService) Pause(ctx context.Context, id int64) (*models.ScheduledTransfer, error) {
+	return service.scheduledTransferRepo.UpdateStatus(ctx, id, models.SchedulePaused, models.ScheduleActive)
+}
+
+// Resume activates a paused or failed transfer again. Occurrences missed in
+// the meantime are not made up for, only the next one runs right away.
+func (service *ScheduledTransferService) Resume(ctx context.Context, id int64) (*models.ScheduledTransfer, error) {
+	return service.scheduledTransferRepo.UpdateStatus(ctx, id, models.ScheduleActive, models.SchedulePaused, models.ScheduleFailed)
+}
+
+func (service *ScheduledTransferService) Cancel(ctx context.Context, id int64) (*models.ScheduledTransfer, error) {
+	return service.scheduledTransferRepo.UpdateStatus(ctx, id, models.ScheduleCancelled, models.ScheduleActive, models.SchedulePaused, models.ScheduleFailed)
+}
+
+// Run executes due transfers every pollInterval until ctx is done.
+func (service *ScheduledTransferService) Run(ctx context.Context, pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := service.RunDue(ctx, time.Now().UTC()); err != nil {
+			log.Printf("scheduled transfers: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue executes every transfer due at now. Several workers may run it at
+// the same time, each occurrence is still executed only once.
+func (service *ScheduledTransferService) RunDue(ctx context.Context, now time.Time) error {
+	due, err := service.scheduledTransferRepo.ListDue(ctx, now, scheduleBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, st := range due {
+		if err := service.execute(ctx, st, now); err != nil {
+			log.Printf("scheduled transfer %d: %v", st.ID, err)
+		}
+	}
+	return nil
+}
+
+func (service *ScheduledTransferService) execute(ctx context.Context, st *models.ScheduledTransfer, now time.Time) error {
+	next := service.nextRun(st, now)
+
+	err := service.transfer(ctx, st, next)
+	if err == nil || errors.Is(err, repositories.ErrScheduledTransferNotDue) {
+		return nil
+	}
+
+	var retryAt *time.Time
+	if st.Attempts+1 < maxScheduleAttempts {
+		at := now.Add(scheduleRetryDelay << st.Attempts)
+		retryAt = &at
+	}
+
+	_, recordErr := service.scheduledTransferRepo.RecordFailure(ctx, st.ID, st.NextRunAt, err, retryAt)
+	if recordErr != nil && !errors.Is(recordErr, repositories.ErrScheduledTransferNotDue) {
+		return recordErr
+	}
+	return err
+}
+
+func (service *ScheduledTransferService) transfer(ctx context.Context, st *models.ScheduledTransfer, next *time.Time) error {
+	sender, err := service.accountRepo.GetAccountByNumber(ctx, st.SenderAccountNumber)
+	if err != nil {
+		return err
+	}
+
+	fee, err := service.feeService.Quote(ctx, models.FeeTransfer, sender, st.Amount)
+	if err != nil {
+		return err
+	}
+	houseAccount, err := service.feeService.houseAccount(ctx, fee)
+	if err != nil {
+		return err
+	}
+
+	charge, err := service.limitService.chargeAccount(ctx, sender, models.LimitTransfer, st.Amount, st.ReceiverAccountNumber)
+	if err != nil {
+		return err
+	}
+
+	if _, err := service.scheduledTransferRepo.Execute(ctx, st.ID, st.NextRunAt, next, fee, houseAccount); err != nil {
+		service.limitService.release(ctx, charge)
+		return err
+	}
+	return nil
+}
+
+// nextRun returns the occurrence after the one being executed, or nil if it
+// is the last one. Occurrences missed while the worker was down are skipped.
+func (service *ScheduledTransferService) nextRun(st *models.ScheduledTransfer, now time.Time) *time.Time {
+	var next time.Time
+	switch st.Kind {
+	case models.ScheduleInterval:
+		interval := time.Duration(st.IntervalSeconds) * time.Second
+		next = st.NextRunAt.Add(interval)
+		if !next.After(now) {
+			next = next.Add(now.Sub(next).Truncate(interval) + interval)
+		}
+	case models.ScheduleCron:
+		cron, err := schedule.Parse(st.Cron)
+		if err != nil {
+			return nil
+		}
+		next = cron.Next(now)
+	default:
+		return nil
+	}
+
+	if next.IsZero() || (st.EndAt != nil && next.After(*st.EndAt)) {
+		return nil
+	}
+	return &next
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+
+	// A statement covers at most a year and is built in memory, so the
+	// movements it holds are capped as well.
+	maxStatementRange     = 366 * 24 * time.Hour
+	maxStatementMovements = 10000
+)
+
+var (
+	ErrInvalidCursor         = errors.New("invalid cursor")
+	ErrInvalidPeriod         = errors.New("period must be either day or month")
+	ErrInvalidRange          = errors.New("from must be before to")
+	ErrStatementRangeTooLong = errors.New("statement range is limited to 366 days")
+	ErrStatementTooLarge     = errors.New("statement has more than 10000 movements, request a shorter range")
+)
+
+type StatementService struct {
+	accountRepo     *repositories.AccountRepository
+	movementRepo    *repositories.MovementRepository
+	currencyService *CurrencyService
+}
+
+func NewStatementService(accountRepo *repositories.AccountRepository, movementRepo *repositories.MovementRepository, currencyService *CurrencyService) *StatementService {
+	return &StatementService{accountRepo: accountRepo, movementRepo: movementRepo, currencyService: currencyService}
+}
+
+// ListTransactions returns one page of the account history, newest first.
+// The returned cursor is passed back as filter.BeforeID via DecodeCursor.
+func (service *StatementService) ListTransactions(ctx context.Context, accountNumber string, filter models.MovementFilter) (*models.MovementPage, error) {
+	if _, err := service.accountRepo.GetAccountByNumber(ctx, accountNumber); err != nil {
+		return nil, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	// One extra row tells whether there is a next page.
+	filter.Limit = limit + 1
+	movements, err := service.movementRepo.ListMovements(ctx, accountNumber, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.MovementPage{Transactions: movements}
+	if len(movements) > limit {
+		page.Transactions = movements[:limit]
+		page.NextCursor = EncodeCursor(movements[limit-1].ID)
+	}
+	if page.Transactions == nil {
+		page.Transactions = []*models.Movement{}
+	}
+	return page, nil
+}
+
+// BalanceAt returns the balance of the account right before at, as it
+// follows from the account's movements.
+func (service *StatementService) BalanceAt(ctx context.Context, accountNumber string, at time.Time) (*models.AccountBalance, error) {
+	account, err := service.accountRepo.GetAccountByNumber(ctx, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	currency, err := service.currencyService.GetCurrency(ctx, account.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := service.movementRepo.BalanceAt(ctx, accountNumber, at)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AccountBalance{
+		AccountNumber: accountNumber,
+		Currency:      currency.Code,
+		At:            at.UTC(),
+		Balance:       currency.Round(balance),
+	}, nil
+}
+
+// Statement builds the account statement for [from, to) split into periods,
+// each with its opening and closing balance.
+func (service *StatementService) Statement(ctx context.Context, accountNumber string, from, to time.Time, period string) (*models.Statement, error) {
+	if period != models.PeriodDay && period != models.PeriodMonth {
+		return nil, ErrInvalidPeriod
+	}
+	if !from.Before(to) {
+		return nil, ErrInvalidRange
+	}
+	if to.Sub(from) > maxStatementRange {
+		return nil, ErrStatementRangeTooLong
+	}
+	account, err := service.accountRepo.GetAccountByNumber(ctx, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	// Disabled currencies still get statements, only the precision is needed.
+	currency, err := service.currencyService.GetCurrency(ctx, account.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	opening, err := service.movementRepo.BalanceAt(ctx, accountNumber, from)
+	if err != nil {
+		return nil, err
+	}
+
+	movements, err := service.movementRepo.ListPeriodMovements(ctx, accountNumber, from, to, maxStatementMovements+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(movements) > maxStatementMovements {
+		return nil, ErrStatementTooLarge
+	}
+
+	statement := &models.Statement{
+		AccountNumber:  accountNumber,
+		Currency:       currency.Code,
+		Decimals:       currency.Decimals,
+		From:           from,
+		To:             to,
+		OpeningBalance: opening,
+		ClosingBalance: opening,
+	}
+
+	balance := opening
+	next := 0
+	for start := from; start.Before(to); {
+		end := nextPeriodStart(start, period)
+		if end.After(to) {
+			end = to
+		}
+
+		current := &models.StatementPeriod{
+			Start:          start,
+			End:            end,
+			OpeningBalance: balance,
+			Movements:      []*models.Movement{},
+		}
+		for next < len(movements) && movements[next].CreatedAt.Before(end) {
+			movement := movements[next]
+			if movement.Amount >= 0 {
+				current.Credits += movement.Amount
+			} else {
+				current.Debits -= movement.Amount
+			}
+			balance = movement.BalanceAfter
+			current.Movements = append(current.Movements, movement)
+			next++
+		}
+		current.ClosingBalance = balance
+
+		statement.Periods = append(statement.Periods, current)
+		start = end
+	}
+	statement.ClosingBalance = balance
+
+	return statement, nil
+}
+
+func EncodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func DecodeCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
+
+func nextPeriodStart(t time.Time, period string) time.Time {
+	year, month, day := t.Date()
+	if period == models.PeriodMonth {
+		return time.Date(year, month+1, 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(year, month, day+1, 0, 0, 0, 0, t.Location())
+}
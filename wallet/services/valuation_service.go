@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"wallet/fx"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+var ErrWalletNotFound = errors.New("wallet not found")
+
+// DefaultQuoteCurrency is used when the caller does not ask for one.
+const DefaultQuoteCurrency = "USD"
+
+type ValuationService struct {
+	walletRepo      *repositories.WalletRepository
+	accountRepo     *repositories.AccountRepository
+	currencyService *CurrencyService
+	rates           fx.RateProvider
+}
+
+func NewValuationService(walletRepo *repositories.WalletRepository, accountRepo *repositories.AccountRepository, currencyService *CurrencyService, rates fx.RateProvider) *ValuationService {
+	return &ValuationService{walletRepo: walletRepo, accountRepo: accountRepo, currencyService: currencyService, rates: rates}
+}
+
+// ValueWallet returns every account of the user with its balance and the
+// total of all accounts converted to quoteCurrency. Accounts in a currency
+// without a rate are returned unvalued instead of failing the response.
+func (service *ValuationService) ValueWallet(ctx context.Context, userID int, quoteCurrency string) (*models.WalletValuation, error) {
+	if quoteCurrency == "" {
+		quoteCurrency = DefaultQuoteCurrency
+	}
+	quote, err := service.currencyService.RequireCurrency(ctx, quoteCurrency)
+	if err != nil {
+		return nil, err
+	}
+	quoteCurrency = quote.Code
+
+	wallet, err := service.walletRepo.GetWalletByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if wallet == nil {
+		return nil, ErrWalletNotFound
+	}
+
+	accounts, err := service.accountRepo.GetAccountsByNumbers(ctx, wallet.Accounts)
+	if err != nil {
+		return nil, err
+	}
+
+	valuation := &models.WalletValuation{
+		UserID:        userID,
+		QuoteCurrency: quoteCurrency,
+		Accounts:      []*models.AccountValuation{},
+		Rates:         []models.Rate{},
+		Unpriced:      []string{},
+	}
+
+	used := make(map[string]*models.Rate)
+	for _, account := range accounts {
+		rate, ok := used[account.Currency]
+		if !ok {
+			found, err := service.rates.Rate(ctx, account.Currency, quoteCurrency)
+			switch {
+			case errors.Is(err, fx.ErrRateNotFound):
+				valuation.Unpriced = append(valuation.Unpriced, account.Currency)
+			case err != nil:
+				return nil, err
+			default:
+				rate = &found
+				valuation.Rates = append(valuation.Rates, found)
+			}
+			used[account.Currency] = rate
+		}
+
+		accountValuation := &models.AccountValuation{
+			AccountNumber: account.AccountNumber,
+			Currency:      account.Currency,
+			Balance:       account.Balance,
+			Available:     account.Available,
+		}
+		if rate != nil {
+			value := quote.Round(account.Balance * rate.Value)
+			accountValuation.Value = &value
+			valuation.Total += value
+		}
+		valuation.Accounts = append(valuation.Accounts, accountValuation)
+	}
+
+	valuation.Total = quote.Round(valuation.Total)
+
+	return valuation, nil
+}
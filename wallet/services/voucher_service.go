@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"wallet/accountnumber"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+const (
+	defaultVoucherExpiry = 90 * 24 * time.Hour
+	maxVoucherExpiry     = 2 * 365 * 24 * time.Hour
+	maxVouchersPerMint   = 1000
+)
+
+var (
+	ErrInvalidVoucherCount   = errors.New("count must be between 1 and 1000")
+	ErrInvalidMaxRedemptions = errors.New("max_redemptions must be at least 1")
+	ErrInvalidVoucherExpiry  = errors.New("expiry must be in the future and at most two years away")
+	ErrVoucherCodeRequired   = errors.New("voucher code is required")
+	ErrNoVoucherAccount      = errors.New("user has no active account in the voucher currency")
+)
+
+// VoucherService mints gift codes and pays them out of the house account of
+// their currency, the same account fees are credited to.
+type VoucherService struct {
+	voucherRepo     *repositories.VoucherRepository
+	walletRepo      *repositories.WalletRepository
+	accountRepo     *repositories.AccountRepository
+	currencyService *CurrencyService
+	feeService      *FeeService
+}
+
+func NewVoucherService(voucherRepo *repositories.VoucherRepository, walletRepo *repositories.WalletRepository, accountRepo *repositories.AccountRepository, currencyService *CurrencyService, feeService *FeeService) *VoucherService {
+	return &VoucherService{
+		voucherRepo:     voucherRepo,
+		walletRepo:      walletRepo,
+		accountRepo:     accountRepo,
+		currencyService: currencyService,
+		feeService:      feeService,
+	}
+}
+
+// Mint creates count vouchers worth amount each, redeemable by up to
+// maxRedemptions users. They expire after 90 days unless expiresAt says
+// otherwise. The house account is not debited until a voucher is redeemed.
+func (service *VoucherService) Mint(ctx context.Context, currencyCode string, amount float64, maxRedemptions, count int, expiresAt *time.Time) ([]*models.Voucher, error) {
+	if count < 1 || count > maxVouchersPerMint {
+		return nil, ErrInvalidVoucherCount
+	}
+	if maxRedemptions < 1 {
+		return nil, ErrInvalidMaxRedemptions
+	}
+
+	now := time.Now().UTC()
+	expiry := now.Add(defaultVoucherExpiry)
+	if expiresAt != nil {
+		expiry = expiresAt.UTC()
+	}
+	if !expiry.After(now) || expiry.Sub(now) > maxVoucherExpiry {
+		return nil, ErrInvalidVoucherExpiry
+	}
+
+	currency, err := service.currencyService.RequireCurrency(ctx, currencyCode)
+	if err != nil {
+		return nil, err
+	}
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if amount, err = service.currencyService.NormalizeAmount(currency, amount); err != nil {
+		return nil, err
+	}
+
+	return service.voucherRepo.CreateVouchers(ctx, &models.Voucher{
+		Currency:       currency.Code,
+		Amount:         amount,
+		MaxRedemptions: maxRedemptions,
+		ExpiresAt:      expiry,
+	}, count)
+}
+
+func (service *VoucherService) GetVoucher(ctx context.Context, id int64) (*models.Voucher, error) {
+	return service.voucherRepo.GetVoucher(ctx, id)
+}
+
+func (service *VoucherService) DisableVoucher(ctx context.Context, id int64) (*models.Voucher, error) {
+	return service.voucherRepo.DisableVoucher(ctx, id)
+}
+
+func (service *VoucherService) ListRedemptions(ctx context.Context, id int64) ([]*models.VoucherRedemption, error) {
+	if _, err := service.voucherRepo.GetVoucher(ctx, id); err != nil {
+		return nil, err
+	}
+	return service.voucherRepo.ListRedemptions(ctx, id)
+}
+
+// Redeem pays the voucher into the user's active account in its currency.
+// Codes are read case-insensitively, with or without the dashes.
+func (service *VoucherService) Redeem(ctx context.Context, userID int, code string) (*models.VoucherRedemption, error) {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if code == "" {
+		return nil, ErrVoucherCodeRequired
+	}
+
+	v, err := service.voucherRepo.GetVoucherByCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if v.Status != models.VoucherActive {
+		return nil, repositories.ErrVoucherNotRedeemable
+	}
+
+	account, err := service.voucherAccount(ctx, userID, v.Currency)
+	if err != nil {
+		return nil, err
+	}
+	houseAccount, err := service.feeService.HouseAccount(ctx, v.Currency)
+	if err != nil {
+		return nil, err
+	}
+	return service.voucherRepo.Redeem(ctx, code, userID, account, houseAccount)
+}
+
+// voucherAccount picks the user's active account in the currency.
+func (service *VoucherService) voucherAccount(ctx context.Context, userID int, currency string) (string, error) {
+	wallet, err := service.walletRepo.GetWalletByUserID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if wallet == nil {
+		return "", ErrWalletNotFound
+	}
+
+	for _, accountNumber := range wallet.Accounts {
+		if accountnumber.Currency(accountNumber) != currency {
+			continue
+		}
+		account, err := service.accountRepo.GetAccountByNumber(ctx, accountNumber)
+		if err != nil {
+			return "", err
+		}
+		if account.Status == models.AccountActive {
+			return account.AccountNumber, nil
+		}
+	}
+	return "", ErrNoVoucherAccount
+}
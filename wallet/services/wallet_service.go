@@ -2,17 +2,32 @@ package services
 
 import (
 	"context"
+	"errors"
 	"wallet/models"
 	"wallet/repositories"
 )
 
+var (
+	ErrReasonRequired = errors.New("reason is required")
+	ErrSameAccount    = errors.New("cannot transfer to the same account")
+)
+
 type WalletService struct {
-	walletRepo  *repositories.WalletRepository
-	accountRepo *repositories.AccountRepository
+	walletRepo      *repositories.WalletRepository
+	accountRepo     *repositories.AccountRepository
+	currencyService *CurrencyService
+	limitService    *LimitService
+	feeService      *FeeService
 }
 
-func NewWalletService(walletRepo *repositories.WalletRepository, accountRepo *repositories.AccountRepository) *WalletService {
-	return &WalletService{walletRepo: walletRepo, accountRepo: accountRepo}
+func NewWalletService(walletRepo *repositories.WalletRepository, accountRepo *repositories.AccountRepository, currencyService *CurrencyService, limitService *LimitService, feeService *FeeService) *WalletService {
+	return &WalletService{
+		walletRepo:      walletRepo,
+		accountRepo:     accountRepo,
+		currencyService: currencyService,
+		limitService:    limitService,
+		feeService:      feeService,
+	}
 }
 
 func (service *WalletService) GetWalletByUserId(ctx context.Context, userID int) (*models.Wallet, error) {
@@ -20,15 +35,25 @@ func (service *WalletService) GetWalletByUserId(ctx context.Context, userID int)
 }
 
 func (service *WalletService) CreateWallet(ctx context.Context, userID int) error {
-	account, err := service.accountRepo.CreateAccount(ctx, "USD")
+	currency, err := service.currencyService.RequireCurrency(ctx, "USD")
+	if err != nil {
+		return err
+	}
+
+	account, err := service.accountRepo.CreateAccount(ctx, currency.Code)
 	if err != nil {
 		return err
 	}
 	return service.walletRepo.CreateWallet(ctx, userID, account.AccountNumber)
 }
 
-func (service *WalletService) CreateAccount(ctx context.Context, userID int, currency string) (*models.Account, error) {
-	account, err := service.accountRepo.CreateAccount(ctx, currency)
+func (service *WalletService) CreateAccount(ctx context.Context, userID int, currencyCode string) (*models.Account, error) {
+	currency, err := service.currencyService.RequireCurrency(ctx, currencyCode)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := service.accountRepo.CreateAccount(ctx, currency.Code)
 	if err != nil {
 		return nil, err
 	}
@@ -45,5 +70,171 @@ func (service *WalletService) UpdateWallet(ctx context.Context, wallet *models.W
 }
 
 func (service *WalletService) Deposit(ctx context.Context, accountNumber string, amount float64) error {
+	_, _, amount, err := service.accountAmount(ctx, accountNumber, amount)
+	if err != nil {
+		return err
+	}
 	return service.walletRepo.Deposit(ctx, accountNumber, amount)
 }
+
+// Withdraw takes amount plus the withdrawal fee off the account. Crypto
+// leaves through withdrawal requests to an address instead.
+func (service *WalletService) Withdraw(ctx context.Context, accountNumber string, amount float64) (*models.Receipt, error) {
+	account, currency, amount, err := service.accountAmount(ctx, accountNumber, amount)
+	if err != nil {
+		return nil, err
+	}
+	if currency.Kind == models.CurrencyCrypto {
+		return nil, ErrWithdrawalAddressRequired
+	}
+
+	fee, err := service.feeService.Quote(ctx, models.FeeWithdrawal, account, amount)
+	if err != nil {
+		return nil, err
+	}
+	houseAccount, err := service.feeService.houseAccount(ctx, fee)
+	if err != nil {
+		return nil, err
+	}
+
+	charge, err := service.limitService.chargeAccount(ctx, account, models.LimitWithdrawal, amount, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := service.walletRepo.Withdraw(ctx, accountNumber, amount, fee, houseAccount); err != nil {
+		service.limitService.release(ctx, charge)
+		return nil, err
+	}
+	return newReceipt(models.FeeWithdrawal, account, "", amount, currency, fee), nil
+}
+
+// Transfer moves amount between two accounts of the same currency in a
+// single transaction. The sender pays the transfer fee on top.
+func (service *WalletService) Transfer(ctx context.Context, senderAccountNumber, receiverAccountNumber string, amount float64) (*models.Receipt, error) {
+	plan, err := service.planTransfer(ctx, senderAccountNumber, receiverAccountNumber, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := service.walletRepo.Transfer(ctx, senderAccountNumber, receiverAccountNumber, plan.amount, plan.fee, plan.houseAccount); err != nil {
+		service.abandonTransfer(ctx, plan)
+		return nil, err
+	}
+	return plan.receipt(), nil
+}
+
+// transferPlan is a transfer that has been checked, priced and charged to
+// the sender's limits. Whoever books it releases the charge if that fails.
+type transferPlan struct {
+	sender       *models.Account
+	currency     *models.Currency
+	receiver     string
+	amount       float64
+	fee          *models.FeeItem
+	houseAccount string
+	charge       *models.LimitCharge
+}
+
+func (plan *transferPlan) receipt() *models.Receipt {
+	return newReceipt(models.FeeTransfer, plan.sender, plan.receiver, plan.amount, plan.currency, plan.fee)
+}
+
+// planTransfer prepares a transfer for a repository that books it together
+// with its own changes, in one transaction.
+func (service *WalletService) planTransfer(ctx context.Context, senderAccountNumber, receiverAccountNumber string, amount float64) (*transferPlan, error) {
+	if senderAccountNumber == receiverAccountNumber {
+		return nil, ErrSameAccount
+	}
+
+	sender, currency, amount, err := service.accountAmount(ctx, senderAccountNumber, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	receiver, err := service.accountRepo.GetAccountByNumber(ctx, receiverAccountNumber)
+	if err != nil {
+		return nil, err
+	}
+	if receiver.Currency != sender.Currency {
+		return nil, ErrCurrencyMismatch
+	}
+
+	fee, err := service.feeService.Quote(ctx, models.FeeTransfer, sender, amount)
+	if err != nil {
+		return nil, err
+	}
+	houseAccount, err := service.feeService.houseAccount(ctx, fee)
+	if err != nil {
+		return nil, err
+	}
+
+	charge, err := service.limitService.chargeAccount(ctx, sender, models.LimitTransfer, amount, receiverAccountNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transferPlan{
+		sender:       sender,
+		currency:     currency,
+		receiver:     receiverAccountNumber,
+		amount:       amount,
+		fee:          fee,
+		houseAccount: houseAccount,
+		charge:       charge,
+	}, nil
+}
+
+// abandonTransfer gives back the limits of a planned transfer that was not
+// booked.
+func (service *WalletService) abandonTransfer(ctx context.Context, plan *transferPlan) {
+	service.limitService.release(ctx, plan.charge)
+}
+
+// accountAmount loads the account and rounds amount to the precision of the
+// account currency.
+func (service *WalletService) accountAmount(ctx context.Context, accountNumber string, amount float64) (*models.Account, *models.Currency, float64, error) {
+	if amount <= 0 {
+		return nil, nil, 0, ErrInvalidAmount
+	}
+
+	account, err := service.accountRepo.GetAccountByNumber(ctx, accountNumber)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	currency, err := service.currencyService.RequireCurrency(ctx, account.Currency)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	amount, err = service.currencyService.NormalizeAmount(currency, amount)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return account, currency, amount, nil
+}
+
+func (service *WalletService) FreezeAccount(ctx context.Context, accountNumber, reason string) (*models.Account, error) {
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	return service.accountRepo.UpdateStatus(ctx, accountNumber, models.AccountFrozen, reason)
+}
+
+func (service *WalletService) UnfreezeAccount(ctx context.Context, accountNumber, reason string) (*models.Account, error) {
+	return service.accountRepo.UpdateStatus(ctx, accountNumber, models.AccountActive, reason)
+}
+
+// CloseAccount permanently closes an account. Only accounts with a zero
+// balance can be closed.
+func (service *WalletService) CloseAccount(ctx context.Context, accountNumber, reason string) (*models.Account, error) {
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	return service.accountRepo.UpdateStatus(ctx, accountNumber, models.AccountClosed, reason)
+}
+
+func (service *WalletService) GetAccount(ctx context.Context, accountNumber string) (*models.Account, error) {
+	return service.accountRepo.GetAccountByNumber(ctx, accountNumber)
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+	"wallet/chain"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+const (
+	withdrawalBatchSize = 100
+	// A withdrawal that cannot be signed this many times in a row fails.
+	// Once signed it is retried for good, the transaction may still be mined.
+	maxSigningAttempts = 5
+)
+
+// WithdrawalProcessor signs approved withdrawals, broadcasts them and
+// settles them once they have the confirmations their currency requires.
+type WithdrawalProcessor struct {
+	withdrawalRepo    withdrawalSteps
+	withdrawalService withdrawalFailer
+	currencyService   currencies
+	feeService        houseAccounts
+	signer            chain.Signer
+	broadcasters      map[string]chain.Broadcaster
+}
+
+// withdrawalSteps is the part of WithdrawalRepository the processor uses.
+type withdrawalSteps interface {
+	ListWithdrawals(ctx context.Context, statuses []string, limit int) ([]*models.Withdrawal, error)
+	MarkSigned(ctx context.Context, id int64, txid string, rawTx []byte) (*models.Withdrawal, error)
+	MarkBroadcast(ctx context.Context, id int64) (*models.Withdrawal, error)
+	UpdateConfirmations(ctx context.Context, id int64, confirmations int64) (*models.Withdrawal, error)
+	RecordAttempt(ctx context.Context, id int64, stepErr error) (*models.Withdrawal, error)
+	Confirm(ctx context.Context, id int64, confirmations int64, fee *models.FeeItem, houseAccountNumber string) (*models.Withdrawal, error)
+}
+
+type withdrawalFailer interface {
+	fail(ctx context.Context, id int64, reason string, from ...string) (*models.Withdrawal, error)
+}
+
+type houseAccounts interface {
+	HouseAccount(ctx context.Context, currency string) (string, error)
+}
+
+// NewWithdrawalProcessor takes a broadcaster per currency code. Withdrawals
+// in other currencies stay approved until one is configured.
+func NewWithdrawalProcessor(withdrawalRepo *repositories.WithdrawalRepository, withdrawalService *WithdrawalService, currencyService *CurrencyService, feeService *FeeService, signer chain.Signer, broadcasters map[string]chain.Broadcaster) *WithdrawalProcessor {
+	return &WithdrawalProcessor{
+		withdrawalRepo:    withdrawalRepo,
+		withdrawalService: withdrawalService,
+		currencyService:   currencyService,
+		feeService:        feeService,
+		signer:            signer,
+		broadcasters:      broadcasters,
+	}
+}
+
+// Run processes the pending withdrawals each pollInterval until ctx is done.
+func (processor *WithdrawalProcessor) Run(ctx context.Context, pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := processor.ProcessPending(ctx); err != nil {
+			log.Printf("withdrawal processor: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessPending moves every approved, signed and broadcast withdrawal one
+// step further where possible.
+func (processor *WithdrawalProcessor) ProcessPending(ctx context.Context) error {
+	statuses := []string{models.WithdrawalApproved, models.WithdrawalSigned, models.WithdrawalBroadcast}
+	withdrawals, err := processor.withdrawalRepo.ListWithdrawals(ctx, statuses, withdrawalBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, withdrawal := range withdrawals {
+		broadcaster, ok := processor.broadcasters[withdrawal.Currency]
+		if !ok {
+			continue
+		}
+		if err := processor.process(ctx, withdrawal, broadcaster); err != nil {
+			log.Printf("withdrawal processor: withdrawal %d: %v", withdrawal.ID, err)
+			processor.recordAttempt(ctx, withdrawal, err)
+		}
+	}
+	return nil
+}
+
+func (processor *WithdrawalProcessor) process(ctx context.Context, withdrawal *models.Withdrawal, broadcaster chain.Broadcaster) error {
+	var err error
+	if withdrawal.Status == models.WithdrawalApproved {
+		payment := chain.Payment{
+			Currency:  withdrawal.Currency,
+			Reference: "withdrawal:" + strconv.FormatInt(withdrawal.ID, 10),
+			Address:   withdrawal.Address,
+			Amount:    withdrawal.Amount,
+		}
+		signed, err := processor.signer.Sign(ctx, payment)
+		if err != nil {
+			return err
+		}
+		if withdrawal, err = processor.withdrawalRepo.MarkSigned(ctx, withdrawal.ID, signed.TxID, signed.Raw); err != nil {
+			return err
+		}
+	}
+
+	signed := &chain.SignedTransaction{TxID: withdrawal.TxID, Raw: withdrawal.RawTx}
+	if withdrawal.Status == models.WithdrawalSigned {
+		if err := broadcaster.Broadcast(ctx, signed); err != nil {
+			return err
+		}
+		if withdrawal, err = processor.withdrawalRepo.MarkBroadcast(ctx, withdrawal.ID); err != nil {
+			return err
+		}
+	}
+
+	confirmations, err := broadcaster.Confirmations(ctx, withdrawal.TxID)
+	if errors.Is(err, chain.ErrTransactionNotFound) {
+		// Dropped from the mempool or by a reorg, the same transaction is
+		// sent again.
+		return broadcaster.Broadcast(ctx, signed)
+	}
+	if err != nil {
+		return err
+	}
+
+	currency, err := processor.currencyService.GetCurrency(ctx, withdrawal.Currency)
+	if err != nil {
+		return err
+	}
+	required := int64(currency.Confirmations)
+	if required < 1 {
+		required = 1
+	}
+	if confirmations < required {
+		if confirmations != withdrawal.Confirmations {
+			_, err = processor.withdrawalRepo.UpdateConfirmations(ctx, withdrawal.ID, confirmations)
+		}
+		return err
+	}
+
+	var fee *models.FeeItem
+	var houseAccount string
+	if withdrawal.Fee > 0 {
+		fee = &models.FeeItem{
+			Type:          models.FeeWithdrawal,
+			AccountNumber: withdrawal.AccountNumber,
+			Currency:      withdrawal.Currency,
+			Amount:        withdrawal.Fee,
+		}
+		if houseAccount, err = processor.feeService.HouseAccount(ctx, withdrawal.Currency); err != nil {
+			return err
+		}
+	}
+	_, err = processor.withdrawalRepo.Confirm(ctx, withdrawal.ID, confirmations, fee, houseAccount)
+	return err
+}
+
+// recordAttempt counts the failed step and gives up on withdrawals that
+// could never be signed.
+func (processor *WithdrawalProcessor) recordAttempt(ctx context.Context, withdrawal *models.Withdrawal, stepErr error) {
+	withdrawal, err := processor.withdrawalRepo.RecordAttempt(ctx, withdrawal.ID, stepErr)
+	if err != nil || withdrawal.Status != models.WithdrawalApproved || withdrawal.Attempts < maxSigningAttempts {
+		return
+	}
+	if _, err := processor.withdrawalService.fail(ctx, withdrawal.ID, stepErr.Error(), models.WithdrawalApproved); err != nil {
+		log.Printf("withdrawal processor: failing withdrawal %d: %v", withdrawal.ID, err)
+	}
+}
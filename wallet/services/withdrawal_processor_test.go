@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"wallet/chain"
+	"wallet/models"
+)
+
+// fakeWithdrawals lists the withdrawals it is given and records the steps
+// the processor takes. Each step returns the withdrawal as the repository
+// would after it, the status rules are WithdrawalRepository's. It also
+// stands in for the service failing them.
+type fakeWithdrawals struct {
+	withdrawals []*models.Withdrawal
+	steps       []string
+	attempts    int
+	fee         *models.FeeItem
+	house       string
+}
+
+func (f *fakeWithdrawals) ListWithdrawals(ctx context.Context, statuses []string, limit int) ([]*models.Withdrawal, error) {
+	return f.withdrawals, nil
+}
+
+func (f *fakeWithdrawals) MarkSigned(ctx context.Context, id int64, txid string, rawTx []byte) (*models.Withdrawal, error) {
+	f.record("MarkSigned %d %s", id, txid)
+	return f.step(id, func(w *models.Withdrawal) { w.Status, w.TxID, w.RawTx = models.WithdrawalSigned, txid, rawTx }), nil
+}
+
+func (f *fakeWithdrawals) MarkBroadcast(ctx context.Context, id int64) (*models.Withdrawal, error) {
+	f.record("MarkBroadcast %d", id)
+	return f.step(id, func(w *models.Withdrawal) { w.Status = models.WithdrawalBroadcast }), nil
+}
+
+func (f *fakeWithdrawals) UpdateConfirmations(ctx context.Context, id int64, confirmations int64) (*models.Withdrawal, error) {
+	f.record("UpdateConfirmations %d %d", id, confirmations)
+	return f.step(id, func(w *models.Withdrawal) { w.Confirmations = confirmations }), nil
+}
+
+func (f *fakeWithdrawals) RecordAttempt(ctx context.Context, id int64, stepErr error) (*models.Withdrawal, error) {
+	f.record("RecordAttempt %d", id)
+	return f.step(id, func(w *models.Withdrawal) { w.Attempts = f.attempts }), nil
+}
+
+func (f *fakeWithdrawals) Confirm(ctx context.Context, id int64, confirmations int64, fee *models.FeeItem, houseAccountNumber string) (*models.Withdrawal, error) {
+	f.record("Confirm %d %d", id, confirmations)
+	f.fee, f.house = fee, houseAccountNumber
+	return f.step(id, func(w *models.Withdrawal) { w.Status = models.WithdrawalConfirmed }), nil
+}
+
+func (f *fakeWithdrawals) fail(ctx context.Context, id int64, reason string, from ...string) (*models.Withdrawal, error) {
+	f.record("fail %d %v", id, from)
+	return f.step(id, func(w *models.Withdrawal) { w.Status = models.WithdrawalFailed }), nil
+}
+
+func (f *fakeWithdrawals) record(format string, args ...interface{}) {
+	f.steps = append(f.steps, fmt.Sprintf(format, args...))
+}
+
+// step returns a changed copy of the listed withdrawal.
+func (f *fakeWithdrawals) step(id int64, change func(w *models.Withdrawal)) *models.Withdrawal {
+	for _, w := range f.withdrawals {
+		if w.ID == id {
+			changed := *w
+			change(&changed)
+			return &changed
+		}
+	}
+	return nil
+}
+
+func (f *fakeWithdrawals) expectSteps(t *testing.T, want ...string) {
+	t.Helper()
+	if fmt.Sprint(f.steps) != fmt.Sprint(want) {
+		t.Fatalf("steps %q, want %q", f.steps, want)
+	}
+	f.steps = nil
+}
+
+type fakeHouseAccounts string
+
+func (f fakeHouseAccounts) HouseAccount(ctx context.Context, currency string) (string, error) {
+	return string(f), nil
+}
+
+type failingSigner struct{}
+
+func (failingSigner) Sign(ctx context.Context, payment chain.Payment) (*chain.SignedTransaction, error) {
+	return nil, errors.New("signing service unavailable")
+}
+
+func newTestProcessor(signer chain.Signer, confirmations int, withdrawals ...*models.Withdrawal) (*WithdrawalProcessor, *fakeWithdrawals, *chain.Simulated) {
+	simulated := chain.NewSimulated()
+	repo := &fakeWithdrawals{withdrawals: withdrawals}
+	processor := &WithdrawalProcessor{
+		withdrawalRepo:    repo,
+		withdrawalService: repo,
+		currencyService:   fakeCurrencies{"BTC": {Code: "BTC", Decimals: 8, Confirmations: confirmations}},
+		feeService:        fakeHouseAccounts("BTC-HOUSE"),
+		signer:            signer,
+		broadcasters:      map[string]chain.Broadcaster{"BTC": simulated},
+	}
+	return processor, repo, simulated
+}
+
+func approvedWithdrawal(id int64, currency string) *models.Withdrawal {
+	return &models.Withdrawal{
+		ID:            id,
+		AccountNumber: testAccount,
+		Currency:      currency,
+		Address:       "bc1qexternal",
+		Amount:        0.5,
+		Fee:           0.0001,
+		Status:        models.WithdrawalApproved,
+	}
+}
+
+// broadcastWithdrawal is the approved withdrawal after the processor sent it.
+func broadcastWithdrawal(t *testing.T, simulated *chain.Simulated, confirmations int64) *models.Withdrawal {
+	t.Helper()
+	w := approvedWithdrawal(1, "BTC")
+	signed := signWithdrawal(w)
+	if err := simulated.Broadcast(context.Background(), signed); err != nil {
+		t.Fatal(err)
+	}
+	w.Status, w.TxID, w.RawTx, w.Confirmations = models.WithdrawalBroadcast, signed.TxID, signed.Raw, confirmations
+	return w
+}
+
+func signWithdrawal(w *models.Withdrawal) *chain.SignedTransaction {
+	payment := chain.Payment{Currency: w.Currency, Reference: fmt.Sprintf("withdrawal:%d", w.ID), Address: w.Address, Amount: w.Amount}
+	signed, _ := chain.FakeSigner{}.Sign(context.Background(), payment)
+	return signed
+}
+
+func process(t *testing.T, processor *WithdrawalProcessor) {
+	t.Helper()
+	if err := processor.ProcessPending(context.Background()); err != nil {
+		t.Fatalf("ProcessPending: %v", err)
+	}
+}
+
+func TestWithdrawalProcessorSignsAndBroadcasts(t *testing.T) {
+	w := approvedWithdrawal(1, "BTC")
+	processor, repo, simulated := newTestProcessor(chain.FakeSigner{}, 2, w)
+	signed := signWithdrawal(w)
+
+	process(t, processor)
+	repo.expectSteps(t, "MarkSigned 1 "+signed.TxID, "MarkBroadcast 1")
+	if confirmations, err := simulated.Confirmations(context.Background(), signed.TxID); err != nil || confirmations != 0 {
+		t.Fatalf("transaction: %d confirmations, %v; want it in the mempool", confirmations, err)
+	}
+}
+
+func TestWithdrawalProcessorConfirmsAtThreshold(t *testing.T) {
+	processor, repo, simulated := newTestProcessor(chain.FakeSigner{}, 2)
+	repo.withdrawals = []*models.Withdrawal{broadcastWithdrawal(t, simulated, 0)}
+
+	simulated.Mine()
+	process(t, processor)
+	repo.expectSteps(t, "UpdateConfirmations 1 1")
+
+	repo.withdrawals[0].Confirmations = 1
+	process(t, processor)
+	repo.expectSteps(t)
+
+	simulated.Mine()
+	process(t, processor)
+	repo.expectSteps(t, "Confirm 1 2")
+	if repo.fee == nil || repo.fee.Amount != 0.0001 || repo.fee.AccountNumber != testAccount || repo.house != "BTC-HOUSE" {
+		t.Fatalf("confirmed with fee %+v to %q, want 0.0001 from %s to BTC-HOUSE", repo.fee, repo.house, testAccount)
+	}
+}
+
+func TestWithdrawalProcessorWaitsOutReorg(t *testing.T) {
+	processor, repo, simulated := newTestProcessor(chain.FakeSigner{}, 2)
+	repo.withdrawals = []*models.Withdrawal{broadcastWithdrawal(t, simulated, 1)}
+	simulated.Mine()
+	if err := simulated.Reorg(1); err != nil {
+		t.Fatal(err)
+	}
+
+	process(t, processor)
+	repo.expectSteps(t, "UpdateConfirmations 1 0")
+}
+
+func TestWithdrawalProcessorRebroadcastsLostTransaction(t *testing.T) {
+	processor, repo, simulated := newTestProcessor(chain.FakeSigner{}, 1)
+	w := broadcastWithdrawal(t, simulated, 0)
+	repo.withdrawals = []*models.Withdrawal{w}
+
+	// A node that never saw the transaction, e.g. after a restart.
+	lost := chain.NewSimulated()
+	processor.broadcasters["BTC"] = lost
+	process(t, processor)
+	repo.expectSteps(t)
+	if confirmations, err := lost.Confirmations(context.Background(), w.TxID); err != nil || confirmations != 0 {
+		t.Fatalf("transaction on the new node: %d confirmations, %v; want it in the mempool", confirmations, err)
+	}
+}
+
+func TestWithdrawalProcessorFailsUnsignableWithdrawal(t *testing.T) {
+	processor, repo, _ := newTestProcessor(failingSigner{}, 1, approvedWithdrawal(1, "BTC"))
+
+	repo.attempts = maxSigningAttempts - 1
+	process(t, processor)
+	repo.expectSteps(t, "RecordAttempt 1")
+
+	repo.attempts = maxSigningAttempts
+	process(t, processor)
+	repo.expectSteps(t, "RecordAttempt 1", "fail 1 [approved]")
+}
+
+func TestWithdrawalProcessorSkipsCurrencyWithoutBroadcaster(t *testing.T) {
+	processor, repo, _ := newTestProcessor(chain.FakeSigner{}, 1, approvedWithdrawal(1, "ETH"))
+
+	process(t, processor)
+	repo.expectSteps(t)
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"wallet/models"
+	"wallet/repositories"
+)
+
+var (
+	ErrWithdrawalUnsupported     = errors.New("withdrawals to external addresses are not available for this currency")
+	ErrWithdrawalAddressRequired = errors.New("crypto withdrawals need a destination address")
+	ErrApproverRequired          = errors.New("approver is required")
+	ErrRequesterUnknown          = errors.New("withdrawal has no known requester to check the approver against")
+	// ErrSelfApproval enforces maker-checker: whoever requested a withdrawal
+	// cannot approve it.
+	ErrSelfApproval = errors.New("a withdrawal cannot be approved by its requester")
+)
+
+type WithdrawalService struct {
+	withdrawalRepo        *repositories.WithdrawalRepository
+	accountRepo           *repositories.AccountRepository
+	walletRepo            *repositories.WalletRepository
+	currencyService       *CurrencyService
+	feeService            *FeeService
+	limitService          *LimitService
+	depositAddressService *DepositAddressService
+	addressBookService    *AddressBookService
+	approvalThreshold     float64
+}
+
+// NewWithdrawalService takes the approval threshold in the limit currency.
+// Withdrawals worth more wait for an admin to approve them.
+func NewWithdrawalService(withdrawalRepo *repositories.WithdrawalRepository, accountRepo *repositories.AccountRepository, walletRepo *repositories.WalletRepository, currencyService *CurrencyService, feeService *FeeService, limitService *LimitService, depositAddressService *DepositAddressService, addressBookService *AddressBookService, approvalThreshold float64) *WithdrawalService {
+	return &WithdrawalService{
+		withdrawalRepo:        withdrawalRepo,
+		accountRepo:           accountRepo,
+		walletRepo:            walletRepo,
+		currencyService:       currencyService,
+		feeService:            feeService,
+		limitService:          limitService,
+		depositAddressService: depositAddressService,
+		addressBookService:    addressBookService,
+		approvalThreshold:     approvalThreshold,
+	}
+}
+
+// RequestWithdrawal reserves amount plus the withdrawal fee on the account
+// for a payment to an external address the owner's address book allows.
+// A nil principal requests for the owner of the account.
+func (service *WithdrawalService) RequestWithdrawal(ctx context.Context, accountNumber, address string, amount float64, principal *models.Principal) (*models.Withdrawal, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	requestedBy, err := service.requester(ctx, accountNumber, principal)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := service.accountRepo.GetAccountByNumber(ctx, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	currency, err := service.currencyService.RequireCurrency(ctx, account.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if currency.Kind != models.CurrencyCrypto {
+		return nil, ErrWithdrawalUnsupported
+	}
+	amount, err = service.currencyService.NormalizeAmount(currency, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	address, supported, err := service.depositAddressService.normalizeAddress(currency.Code, address)
+	if !supported {
+		return nil, ErrWithdrawalUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := service.addressBookService.checkWithdrawal(ctx, account.AccountNumber, currency.Code, address); err != nil {
+		return nil, err
+	}
+
+	fee, err := service.feeService.Quote(ctx, models.FeeWithdrawal, account, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	charge, err := service.limitService.chargeAccount(ctx, account, models.LimitWithdrawal, amount, "")
+	if err != nil {
+		return nil, err
+	}
+
+	withdrawal := &models.Withdrawal{
+		AccountNumber:    account.AccountNumber,
+		Currency:         currency.Code,
+		Address:          address,
+		Amount:           amount,
+		RequestedBy:      requestedBy,
+		RequiresApproval: service.requiresApproval(ctx, currency.Code, amount),
+		Status:           models.WithdrawalApproved,
+	}
+	if fee != nil {
+		withdrawal.Fee = fee.Amount
+	}
+	if charge != nil {
+		withdrawal.LimitChargeID = &charge.ID
+	}
+	if withdrawal.RequiresApproval {
+		withdrawal.Status = models.WithdrawalRequested
+	}
+
+	created, err := service.withdrawalRepo.CreateWithdrawal(ctx, withdrawal)
+	if err != nil {
+		service.limitService.release(ctx, charge)
+		return nil, err
+	}
+	return created, nil
+}
+
+// Approve lets a withdrawal waiting for approval go to the processor. The
+// approver has to be someone other than the requester, so a withdrawal
+// without a known requester cannot be approved.
+func (service *WithdrawalService) Approve(ctx context.Context, id int64, approver string) (*models.Withdrawal, error) {
+	if approver == "" {
+		return nil, ErrApproverRequired
+	}
+
+	withdrawal, err := service.withdrawalRepo.GetWithdrawal(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if withdrawal.RequestedBy == "" {
+		return nil, ErrRequesterUnknown
+	}
+	if strings.EqualFold(withdrawal.RequestedBy, approver) {
+		return nil, ErrSelfApproval
+	}
+	return service.withdrawalRepo.Approve(ctx, id, approver)
+}
+
+// Reject fails a withdrawal waiting for approval and frees its funds.
+func (service *WithdrawalService) Reject(ctx context.Context, id int64, approver, reason string) (*models.Withdrawal, error) {
+	if approver == "" {
+		return nil, ErrApproverRequired
+	}
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	return service.fail(ctx, id, "rejected by "+approver+": "+reason, models.WithdrawalRequested)
+}
+
+// Cancel withdraws a request that has not been signed yet.
+func (service *WithdrawalService) Cancel(ctx context.Context, id int64) (*models.Withdrawal, error) {
+	return service.fail(ctx, id, "cancelled", models.WithdrawalRequested, models.WithdrawalApproved)
+}
+
+func (service *WithdrawalService) GetWithdrawal(ctx context.Context, id int64) (*models.Withdrawal, error) {
+	return service.withdrawalRepo.GetWithdrawal(ctx, id)
+}
+
+func (service *WithdrawalService) ListAccountWithdrawals(ctx context.Context, accountNumber string) ([]*models.Withdrawal, error) {
+	return service.withdrawalRepo.ListAccountWithdrawals(ctx, accountNumber)
+}
+
+// ListWithdrawals returns the withdrawals with the status, oldest first. An
+// empty status lists the ones waiting for approval.
+func (service *WithdrawalService) ListWithdrawals(ctx context.Context, status string) ([]*models.Withdrawal, error) {
+	if status == "" {
+		status = models.WithdrawalRequested
+	}
+	return service.withdrawalRepo.ListWithdrawals(ctx, []string{status}, 100)
+}
+
+// requester names who asks for a withdrawal as user:<id>. It is empty for
+// API keys and for accounts outside any wallet.
+func (service *WithdrawalService) requester(ctx context.Context, accountNumber string, principal *models.Principal) (string, error) {
+	var userID int
+	if principal != nil {
+		userID = principal.UserID
+	} else {
+		ownerID, err := service.walletRepo.GetUserIDByAccount(ctx, accountNumber)
+		if err != nil {
+			return "", err
+		}
+		userID = ownerID
+	}
+	if userID == 0 {
+		return "", nil
+	}
+	return "user:" + strconv.Itoa(userID), nil
+}
+
+// fail ends the withdrawal and gives back its share of the withdrawal limit.
+func (service *WithdrawalService) fail(ctx context.Context, id int64, reason string, from ...string) (*models.Withdrawal, error) {
+	withdrawal, err := service.withdrawalRepo.Fail(ctx, id, reason, from...)
+	if err != nil {
+		return nil, err
+	}
+	if withdrawal.LimitChargeID != nil {
+		_ = service.limitService.ReleaseLimit(ctx, *withdrawal.LimitChargeID)
+	}
+	return withdrawal, nil
+}
+
+// requiresApproval compares the value of the withdrawal with the threshold.
+// A withdrawal whose value is unknown is held for approval too.
+func (service *WithdrawalService) requiresApproval(ctx context.Context, currency string, amount float64) bool {
+	value, err := service.limitService.limitValue(ctx, currency, amount)
+	return err != nil || value > service.approvalThreshold
+}
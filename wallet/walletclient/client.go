@@ -0,0 +1,350 @@
+// Package walletclient calls the wallet service over HTTP. Errors returned
+// by the service are mapped back to the sentinel errors of the wallet
+// repositories and services, so callers can use errors.Is the same way as
+// with the in-process implementation.
+package walletclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"wallet/accountnumber"
+	"wallet/models"
+	"wallet/repositories"
+	"wallet/services"
+)
+
+const (
+	defaultTimeout    = 5 * time.Second
+	defaultMaxRetries = 3
+	defaultBackoff    = 100 * time.Millisecond
+
+	idempotencyKeyHeader = "Idempotency-Key"
+	requestInProgress    = "request with this idempotency key is in progress"
+)
+
+// knownErrors are matched against the body of error responses.
+var knownErrors = []error{
+	accountnumber.ErrInvalid,
+	repositories.ErrAccountNotFound,
+	repositories.ErrAccountFrozen,
+	repositories.ErrAccountClosed,
+	repositories.ErrInsufficientFunds,
+	repositories.ErrHoldNotFound,
+	repositories.ErrHoldNotActive,
+	repositories.ErrCurrencyNotFound,
+	repositories.ErrUserNotFound,
+	repositories.ErrLimitExceeded,
+	repositories.ErrLimitChargeNotFound,
+	services.ErrInvalidAmount,
+	services.ErrInvalidHoldKind,
+	services.ErrDestinationRequired,
+	services.ErrNotTradeHold,
+	services.ErrHoldKindNotAllowed,
+	services.ErrNotTradeParty,
+	services.ErrSameAccount,
+	services.ErrWalletNotFound,
+	services.ErrOrganizationAccount,
+	services.ErrCurrencyDisabled,
+	services.ErrCurrencyMismatch,
+	services.ErrAmountBelowMinimum,
+}
+
+// Error is returned for every non successful response of the wallet service.
+type Error struct {
+	StatusCode int
+	Message    string
+	err        error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("wallet service: %d %s", e.StatusCode, e.Message)
+}
+
+// Unwrap returns the matching wallet sentinel error, if any.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      string
+	maxRetries int
+	backoff    time.Duration
+}
+
+type Option func(*Client)
+
+// WithHTTPClient replaces the default client, e.g. to share a transport.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithTimeout limits every single attempt of a request. It works on a copy
+// of the HTTP client, a client shared through WithHTTPClient keeps its own.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		cp := *c.httpClient
+		cp.Timeout = timeout
+		c.httpClient = &cp
+	}
+}
+
+// WithToken sends the service token, needed for the limit charges.
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithRetries sets how many times a failed request is retried and the base
+// delay of the exponential backoff between attempts.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		maxRetries: defaultMaxRetries,
+		backoff:    defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey makes the next mutating call use the given key instead
+// of a random one. Use it when the caller itself may repeat the operation.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+func (c *Client) GetWalletByUserId(ctx context.Context, userID int) (*models.Wallet, error) {
+	var wallet models.Wallet
+	err := c.do(ctx, http.MethodGet, "/wallets/"+strconv.Itoa(userID), nil, &wallet)
+	if errors.Is(err, services.ErrWalletNotFound) {
+		// Same as the in-process implementation: no wallet is not an error.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (c *Client) GetAccount(ctx context.Context, accountNumber string) (*models.Account, error) {
+	var account models.Account
+	err := c.do(ctx, http.MethodGet, "/accounts/"+url.PathEscape(accountNumber), nil, &account)
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (c *Client) GetCurrency(ctx context.Context, code string) (*models.Currency, error) {
+	var currency models.Currency
+	err := c.do(ctx, http.MethodGet, "/currencies/"+url.PathEscape(code), nil, &currency)
+	if err != nil {
+		return nil, err
+	}
+	return &currency, nil
+}
+
+func (c *Client) ChargeLimit(ctx context.Context, userID int, kind, currency string, amount float64, reference string) (*models.LimitCharge, error) {
+	request := map[string]interface{}{
+		"kind":      kind,
+		"currency":  currency,
+		"amount":    amount,
+		"reference": reference,
+	}
+
+	var charge models.LimitCharge
+	err := c.do(ctx, http.MethodPost, "/users/"+strconv.Itoa(userID)+"/limit_charges", request, &charge)
+	if err != nil {
+		return nil, err
+	}
+	return &charge, nil
+}
+
+func (c *Client) ReleaseLimit(ctx context.Context, chargeID int64) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/limit_charges/%d", chargeID), nil, nil)
+}
+
+func (c *Client) Transfer(ctx context.Context, senderAccountNumber, receiverAccountNumber string, amount float64) (*models.Receipt, error) {
+	request := map[string]interface{}{
+		"sender_account_number":   senderAccountNumber,
+		"receiver_account_number": receiverAccountNumber,
+		"amount":                  amount,
+	}
+
+	var receipt models.Receipt
+	err := c.do(ctx, http.MethodPost, "/transfers", request, &receipt)
+	if err != nil {
+		return nil, err
+	}
+	return &receipt, nil
+}
+
+func (c *Client) PlaceHold(ctx context.Context, accountNumber string, amount float64, kind, reference string) (*models.Hold, error) {
+	request := map[string]interface{}{
+		"amount":    amount,
+		"kind":      kind,
+		"reference": reference,
+	}
+
+	var hold models.Hold
+	err := c.do(ctx, http.MethodPost, "/accounts/"+url.PathEscape(accountNumber)+"/holds", request, &hold)
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func (c *Client) ReleaseHold(ctx context.Context, holdID int64) (*models.Hold, error) {
+	var hold models.Hold
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/holds/%d/release", holdID), nil, &hold)
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func (c *Client) CaptureHold(ctx context.Context, holdID int64, destinationAccountNumber string) (*models.Hold, error) {
+	request := map[string]string{"destination_account_number": destinationAccountNumber}
+
+	var hold models.Hold
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/holds/%d/capture", holdID), request, &hold)
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func (c *Client) SettleTrade(ctx context.Context, holdID int64, destinationAccountNumber, payerAccountNumber, payeeAccountNumber string, paymentAmount float64) (*models.Receipt, error) {
+	request := map[string]interface{}{
+		"destination_account_number": destinationAccountNumber,
+		"payer_account_number":       payerAccountNumber,
+		"payee_account_number":       payeeAccountNumber,
+		"payment_amount":             paymentAmount,
+	}
+
+	var receipt models.Receipt
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/holds/%d/settle", holdID), request, &receipt)
+	if err != nil {
+		return nil, err
+	}
+	return &receipt, nil
+}
+
+// do sends the request and retries it on network errors and server side
+// failures. Mutating requests carry an idempotency key that stays the same
+// across the attempts, so a retry never applies an operation twice.
+func (c *Client) do(ctx context.Context, method, path string, request, response interface{}) error {
+	var body []byte
+	if request != nil {
+		var err error
+		if body, err = json.Marshal(request); err != nil {
+			return err
+		}
+	}
+
+	key := ""
+	if method != http.MethodGet {
+		key, _ = ctx.Value(idempotencyKey{}).(string)
+		if key == "" {
+			key = newIdempotencyKey()
+		}
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.backoffDelay(attempt)):
+			}
+		}
+
+		retry, err := c.attempt(ctx, method, path, key, body, response)
+		if err == nil || !retry {
+			return err
+		}
+		lastErr = err
+	}
+	return lastErr
+}
+
+func (c *Client) attempt(ctx context.Context, method, path, key string, body []byte, response interface{}) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if response == nil {
+			return false, nil
+		}
+		return false, json.NewDecoder(resp.Body).Decode(response)
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	apiErr := &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	for _, known := range knownErrors {
+		if apiErr.Message == known.Error() {
+			apiErr.err = known
+			break
+		}
+	}
+
+	// A conflict on the idempotency key means the first attempt is still
+	// running, so it is worth waiting for it.
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusConflict && apiErr.Message == requestInProgress)
+	return retry, apiErr
+}
+
+// backoffDelay doubles the delay with every attempt and adds up to 50% jitter.
+func (c *Client) backoffDelay(attempt int) time.Duration {
+	delay := c.backoff << (attempt - 1)
+	return delay + time.Duration(mathrand.Int63n(int64(delay)/2+1))
+}
+
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,16 @@
+package walletclient
+
+import "wallet/services"
+
+// Local offers the same calls as Client, served by the wallet services of
+// the current process. It is used when both run against the same database.
+type Local struct {
+	*services.WalletService
+	*services.HoldService
+	*services.CurrencyService
+	*services.LimitService
+}
+
+func NewLocal(walletService *services.WalletService, holdService *services.HoldService, currencyService *services.CurrencyService, limitService *services.LimitService) *Local {
+	return &Local{WalletService: walletService, HoldService: holdService, CurrencyService: currencyService, LimitService: limitService}
+}